@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/conn"
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Export and purge the audit log",
+}
+
+var auditExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export audit log entries as JSON lines, oldest first",
+	Args:  cobra.NoArgs,
+	Run:   ExportAuditLog,
+}
+
+var auditPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete audit log entries older than the retention window",
+	Long:  "Delete audit log entries older than AUDIT_RETENTION_DAYS (or --days). Export them first if they have to be archived.",
+	Args:  cobra.NoArgs,
+	Run:   PurgeAuditLog,
+}
+
+var auditExportFilter dto.AuditLogFilterRequest
+var auditExportOrganization string
+var auditExportFrom string
+var auditExportTo string
+var auditExportOutput string
+var auditPurgeDays int
+
+func init() {
+	auditExportCmd.Flags().StringVar(&auditExportOrganization, "organization", "", "only export the entries of the organization (slug)")
+	auditExportCmd.Flags().StringVar(&auditExportFilter.Action, "action", "", "filter by action, e.g. course.delete")
+	auditExportCmd.Flags().StringVar(&auditExportFilter.TargetType, "target-type", "", "filter by target type, e.g. course")
+	auditExportCmd.Flags().UintVar(&auditExportFilter.TargetID, "target-id", 0, "filter by target id")
+	auditExportCmd.Flags().UintVar(&auditExportFilter.ActorID, "actor-id", 0, "filter by actor id")
+	auditExportCmd.Flags().StringVar(&auditExportFrom, "from", "", "only entries at or after this RFC 3339 time")
+	auditExportCmd.Flags().StringVar(&auditExportTo, "to", "", "only entries before this RFC 3339 time")
+	auditExportCmd.Flags().StringVarP(&auditExportOutput, "output", "o", "", "file to write to, stdout when empty")
+
+	auditPurgeCmd.Flags().IntVar(&auditPurgeDays, "days", 0, "retention in days, defaults to AUDIT_RETENTION_DAYS")
+
+	auditCmd.AddCommand(auditExportCmd, auditPurgeCmd)
+}
+
+func ExportAuditLog(cmd *cobra.Command, args []string) {
+	auditExportFilter.From = parseAuditTime("from", auditExportFrom)
+	auditExportFilter.To = parseAuditTime("to", auditExportTo)
+
+	conn.InitDB()
+	auditLogRepo := repository.NewAuditLogRepository(conn.Db())
+	if auditExportOrganization != "" {
+		auditLogRepo = auditLogRepo.ForOrganization(organizationID(auditExportOrganization))
+	}
+
+	out := os.Stdout
+	if auditExportOutput != "" {
+		file, err := os.Create(auditExportOutput)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", auditExportOutput, err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	encoder := json.NewEncoder(out)
+	exported := 0
+	err := auditLogRepo.FindInBatches(auditExportFilter, 500, func(entries []domain.AuditLog) error {
+		for i := range entries {
+			if err := encoder.Encode(services.MapAuditLogToResponse(&entries[i])); err != nil {
+				return err
+			}
+			exported++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to export the audit log: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d audit log entries exported\n", exported)
+}
+
+func PurgeAuditLog(cmd *cobra.Command, args []string) {
+	days := auditPurgeDays
+	if days <= 0 {
+		days = config.Audit().RetentionDays
+	}
+	if days <= 0 {
+		log.Fatalf("Invalid retention of %d days", days)
+	}
+
+	conn.InitDB()
+	auditLogRepo := repository.NewAuditLogRepository(conn.Db())
+
+	before := time.Now().AddDate(0, 0, -days)
+	deleted, err := auditLogRepo.DeleteBefore(before)
+	if err != nil {
+		log.Fatalf("Failed to purge the audit log: %v", err)
+	}
+
+	fmt.Printf("%d audit log entries older than %s deleted\n", deleted, before.Format(time.RFC3339))
+}
+
+func parseAuditTime(flag, value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Fatalf("Invalid --%s %q, expected an RFC 3339 time such as 2024-01-31T00:00:00Z", flag, value)
+	}
+	return &t
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/conn"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/spf13/cobra"
+)
+
+var organizationsCmd = &cobra.Command{
+	Use:   "organizations",
+	Short: "Manage organizations (tenants)",
+}
+
+var organizationsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List organizations",
+	Args:  cobra.NoArgs,
+	Run:   ListOrganizations,
+}
+
+var organizationsCreateCmd = &cobra.Command{
+	Use:   "create <slug>",
+	Short: "Create an organization",
+	Long:  "Create an organization. The slug is its subdomain and X-Organization header value; add its first admin with `users create-admin --organization <slug>`.",
+	Args:  cobra.ExactArgs(1),
+	Run:   CreateOrganization,
+}
+
+var organizationsSCIMTokenCmd = &cobra.Command{
+	Use:   "scim-token <slug>",
+	Short: "Issue or revoke the SCIM bearer token of an organization",
+	Long:  "Issue a new SCIM bearer token for the organization and print it once, the previous token stops working. With --revoke provisioning is disabled instead.",
+	Args:  cobra.ExactArgs(1),
+	Run:   RotateSCIMToken,
+}
+
+var (
+	createOrganizationName string
+	revokeSCIMToken        bool
+)
+
+func init() {
+	organizationsCreateCmd.Flags().StringVar(&createOrganizationName, "name", "", "display name of the organization (required)")
+	_ = organizationsCreateCmd.MarkFlagRequired("name")
+
+	organizationsSCIMTokenCmd.Flags().BoolVar(&revokeSCIMToken, "revoke", false, "remove the token and disable SCIM provisioning")
+
+	organizationsCmd.AddCommand(organizationsListCmd, organizationsCreateCmd, organizationsSCIMTokenCmd)
+}
+
+func ListOrganizations(cmd *cobra.Command, args []string) {
+	conn.InitDB()
+	organizationRepo := repository.NewOrganizationRepository(conn.Db())
+
+	organizations, err := organizationRepo.GetAll()
+	if err != nil {
+		log.Fatalf("Failed to list organizations: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSLUG\tNAME\tCREATED")
+	for _, organization := range organizations {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", organization.ID, organization.Slug, organization.Name, organization.CreatedAt.Format(time.RFC3339))
+	}
+	_ = w.Flush()
+}
+
+func CreateOrganization(cmd *cobra.Command, args []string) {
+	conn.InitDB()
+	conn.InitRedis()
+	organizationService := services.NewOrganizationService(repository.NewOrganizationRepository(conn.Db()), services.NewRedisService(conn.Redis()))
+
+	organization, err := organizationService.CreateOrganization(createOrganizationName, args[0])
+	if err != nil {
+		log.Fatalf("Failed to create organization: %v", err)
+	}
+
+	fmt.Printf("Organization %s created with id %d\n", organization.Slug, organization.ID)
+}
+
+func RotateSCIMToken(cmd *cobra.Command, args []string) {
+	conn.InitDB()
+	conn.InitRedis()
+	organizationService := services.NewOrganizationService(repository.NewOrganizationRepository(conn.Db()), services.NewRedisService(conn.Redis()))
+
+	if revokeSCIMToken {
+		if err := organizationService.RevokeSCIMToken(args[0]); err != nil {
+			log.Fatalf("Failed to revoke the SCIM token: %v", err)
+		}
+		fmt.Printf("SCIM provisioning disabled for %s\n", args[0])
+		return
+	}
+
+	token, err := organizationService.RotateSCIMToken(args[0])
+	if err != nil {
+		log.Fatalf("Failed to issue the SCIM token: %v", err)
+	}
+
+	fmt.Printf("SCIM token for %s (shown only once): %s\n", args[0], token)
+}
@@ -21,6 +21,11 @@ func Execute() {
 	//conn.InitDB()
 
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(usersCmd)
+	rootCmd.AddCommand(organizationsCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(scheduleCmd)
+	rootCmd.AddCommand(trashCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		panic(err)
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/conn"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/spf13/cobra"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Apply the publish schedule of courses and lessons",
+}
+
+var scheduleRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Apply the publish schedule every SCHEDULER_INTERVAL seconds until stopped",
+	Long:  "Run the publish scheduler as a standalone worker. Set SCHEDULER_ENABLED=false on the serve replicas when it is deployed, although running both is safe.",
+	Args:  cobra.NoArgs,
+	Run:   RunPublishScheduler,
+}
+
+var scheduleApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply the publish and unpublish dates that are due once, e.g. from cron",
+	Args:  cobra.NoArgs,
+	Run:   ApplyPublishSchedule,
+}
+
+var scheduleInterval int64
+
+func init() {
+	scheduleRunCmd.Flags().Int64Var(&scheduleInterval, "interval", 0, "seconds between two passes, defaults to SCHEDULER_INTERVAL")
+
+	scheduleCmd.AddCommand(scheduleRunCmd, scheduleApplyCmd)
+}
+
+func RunPublishScheduler(cmd *cobra.Command, args []string) {
+	interval := scheduleInterval
+	if interval <= 0 {
+		interval = config.Scheduler().Interval
+	}
+	if interval <= 0 {
+		log.Fatalf("Invalid interval of %d seconds", interval)
+	}
+
+	conn.InitDB()
+	scheduler := newPublishScheduler()
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	fmt.Printf("Publish scheduler running every %d seconds\n", interval)
+	scheduler.Run(time.Duration(interval)*time.Second, stop)
+}
+
+func ApplyPublishSchedule(cmd *cobra.Command, args []string) {
+	conn.InitDB()
+
+	applied, err := newPublishScheduler().RunOnce(time.Now())
+	if err != nil {
+		log.Fatalf("Failed to apply the publish schedule: %v", err)
+	}
+
+	fmt.Printf("%d scheduled transitions applied\n", applied)
+}
+
+// newPublishScheduler builds the scheduler on the unscoped repositories, it
+// works across organizations
+func newPublishScheduler() services.PublishScheduler {
+	db := conn.Db()
+	return services.NewPublishScheduler(
+		repository.NewPublishScheduleRepository(db),
+		repository.NewCourseRepository(db),
+		repository.NewCourseReviewRepository(db),
+		repository.NewCourseVersionRepository(db),
+		services.NewAuditService(repository.NewAuditLogRepository(db)),
+	)
+}
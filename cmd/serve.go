@@ -2,15 +2,23 @@ package cmd
 
 import (
 	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rijwanansari/vivaLearning/config"
 	"github.com/rijwanansari/vivaLearning/conn"
 	"github.com/rijwanansari/vivaLearning/controllers"
+	"github.com/rijwanansari/vivaLearning/mailer"
 	repository "github.com/rijwanansari/vivaLearning/repositories"
 	"github.com/rijwanansari/vivaLearning/routes"
 	"github.com/rijwanansari/vivaLearning/server"
 	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/jwtutil"
+	"github.com/rijwanansari/vivaLearning/utils/oidcutil"
+	"github.com/rijwanansari/vivaLearning/utils/passwordutil"
 	"github.com/spf13/cobra"
 )
 
@@ -32,6 +40,10 @@ func Serve(cmd *cobra.Command, args []string) {
 	fmt.Printf("DB User: %s\n", dbConfig.User)
 	fmt.Printf("DB Schema: %s\n", dbConfig.Schema)
 	fmt.Printf("DB Debug: %t\n", dbConfig.Debug)
+	fmt.Printf("Redis Host: %s\n", config.Redis().Host)
+	fmt.Printf("Redis Port: %s\n", config.Redis().Port)
+	fmt.Printf("JWT Signing Method: %s\n", config.Jwt().SigningMethod)
+	fmt.Printf("Mail Driver: %s\n", config.Mail().Driver)
 	fmt.Printf("=====================================\n\n")
 
 	// Initialize database connection
@@ -40,31 +52,137 @@ func Serve(cmd *cobra.Command, args []string) {
 	//database client
 	dbClient := conn.Db()
 
+	// Initialize redis connection
+	conn.InitRedis()
+
 	// repositories
 	userRepo := repository.NewUserRepository(dbClient)
 	courseRepo := repository.NewCourseRepository(dbClient)
 	lessonRepo := repository.NewLessonRepository(dbClient)
+	sectionRepo := repository.NewSectionRepository(dbClient)
+	courseVersionRepo := repository.NewCourseVersionRepository(dbClient)
+	courseReviewRepo := repository.NewCourseReviewRepository(dbClient)
 	userCourseRepo := repository.NewUserCourseRepository(dbClient)
+	permissionRepo := repository.NewPermissionRepository(dbClient)
+	courseStaffRepo := repository.NewCourseStaffRepository(dbClient)
+	mfaRepo := repository.NewMFARepository(dbClient)
+	apiKeyRepo := repository.NewAPIKeyRepository(dbClient)
+	identityRepo := repository.NewUserIdentityRepository(dbClient)
+	groupRepo := repository.NewGroupRepository(dbClient)
+	organizationRepo := repository.NewOrganizationRepository(dbClient)
+	auditLogRepo := repository.NewAuditLogRepository(dbClient)
+	privacyRepo := repository.NewPrivacyRepository(dbClient)
+	passwordHistoryRepo := repository.NewPasswordHistoryRepository(dbClient)
+
+	// jwt signing and verification keys
+	keySet, err := jwtutil.NewKeySet(config.Jwt())
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// mail delivery
+	mail, err := mailer.New(config.Mail())
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// single sign-on, the IdP itself is only contacted on the first login
+	var oidcProvider *oidcutil.Provider
+	if config.OIDC().Enabled {
+		if oidcProvider, err = oidcutil.NewProvider(config.OIDC()); err != nil {
+			log.Fatalf("Failed to configure OIDC provider: %v", err)
+		}
+	}
+
+	// breached password check, skipped while no list is configured
+	var breachedList *passwordutil.BreachedList
+	if path := config.Password().BreachedListPath; path != "" {
+		if breachedList, err = passwordutil.OpenBreachedList(path); err != nil {
+			log.Fatalf("Failed to open breached password list: %v", err)
+		}
+	}
 
 	// services
-	userService := services.NewUserService(userRepo)
-	authService := services.NewAuthService(userRepo)
-	courseService := services.NewCourseService(courseRepo, userCourseRepo, lessonRepo)
-	lessonService := services.NewLessonService(lessonRepo, courseRepo, userCourseRepo)
+	redisService := services.NewRedisService(conn.Redis())
+	tokenService := services.NewTokenService(redisService, keySet)
+	permissionService := services.NewPermissionService(permissionRepo, redisService)
+	organizationService := services.NewOrganizationService(organizationRepo, redisService)
+	passwordPolicy := services.NewPasswordPolicyService(passwordHistoryRepo, breachedList)
+	sessionService := services.NewSessionService(redisService, tokenService)
+	mfaService := services.NewMFAService(userRepo, mfaRepo, tokenService, redisService, sessionService)
+	authService := services.NewAuthService(userRepo, tokenService, redisService, mail, keySet, mfaService, sessionService, passwordPolicy)
+	auditService := services.NewAuditService(auditLogRepo)
+	userService := services.NewUserService(userRepo, authService, tokenService, auditService, passwordPolicy)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userRepo)
+	oidcService := services.NewOIDCService(oidcProvider, userRepo, identityRepo, redisService, authService)
+	courseService := services.NewCourseService(courseRepo, userCourseRepo, lessonRepo, courseStaffRepo, userRepo, courseVersionRepo, auditService)
+	lessonService := services.NewLessonService(lessonRepo, courseRepo, userCourseRepo, courseStaffRepo, sectionRepo, courseVersionRepo, auditService)
+	sectionService := services.NewSectionService(sectionRepo, courseRepo, courseStaffRepo, courseVersionRepo, auditService)
+	courseVersionService := services.NewCourseVersionService(courseVersionRepo, courseRepo, userCourseRepo, courseStaffRepo, auditService)
+	courseReviewService := services.NewCourseReviewService(courseReviewRepo, courseRepo, courseVersionRepo, courseStaffRepo, auditService)
+	courseStaffService := services.NewCourseStaffService(courseRepo, courseStaffRepo, userRepo, auditService)
+	groupService := services.NewGroupService(groupRepo, courseRepo, userCourseRepo, auditService)
+	scimService := services.NewSCIMService(userRepo, groupRepo, tokenService, groupService, auditService)
+	publishScheduler := services.NewPublishScheduler(repository.NewPublishScheduleRepository(dbClient), courseRepo, courseReviewRepo, courseVersionRepo, auditService)
+	privacyService := services.NewPrivacyService(privacyRepo, userRepo, userCourseRepo, auditLogRepo, apiKeyService, tokenService, redisService, auditService)
 
 	// controllers
 	authController := controllers.NewAuthController(userService, authService)
-	courseController := controllers.NewCourseController(courseService, lessonService)
+	courseController := controllers.NewCourseController(courseService, lessonService, courseStaffService, courseVersionService, courseReviewService)
 	lessonController := controllers.NewLessonController(lessonService)
+	jwksController := controllers.NewJwksController(tokenService)
+	mfaController := controllers.NewMFAController(mfaService)
+	sessionController := controllers.NewSessionController(sessionService)
+	userController := controllers.NewUserController(userService)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	oidcController := controllers.NewOIDCController(oidcService)
+	groupController := controllers.NewGroupController(groupService)
+	scimController := controllers.NewSCIMController(scimService)
+	auditController := controllers.NewAuditController(auditService)
+	privacyController := controllers.NewPrivacyController(privacyService)
+	sectionController := controllers.NewSectionController(sectionService)
+
+	// publish scheduler, every replica may run it since each transition is applied once
+	if config.Scheduler().Enabled {
+		if config.Scheduler().Interval <= 0 {
+			log.Fatalf("Invalid SCHEDULER_INTERVAL of %d seconds", config.Scheduler().Interval)
+		}
+		go publishScheduler.Run(time.Duration(config.Scheduler().Interval)*time.Second, nil)
+	}
 
 	// Initialize the server
 	echoServer := echo.New()
+	echoServer.IPExtractor = ipExtractor(config.App().TrustedProxies)
 	server := server.New(echoServer)
 
 	//register routes
-	routes := routes.New(echoServer, authController, courseController, lessonController)
+	routes := routes.New(echoServer, tokenService, permissionService, authController, courseController, lessonController, jwksController, mfaController, sessionController, userController, apiKeyController, oidcController, groupController, scimController, auditController, privacyController, sectionController, sessionService, apiKeyService, organizationService)
 	routes.Init()
 
 	// Start the server
 	server.Start(config.App().Port)
 }
+
+// ipExtractor takes the client address from X-Forwarded-For only when the
+// request came through one of the trusted proxies, the login throttle and the
+// audit log would otherwise record whatever address the client claims
+func ipExtractor(trustedProxies string) echo.IPExtractor {
+	var options []echo.TrustOption
+	for _, cidr := range strings.Split(trustedProxies, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, ipRange, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Fatalf("Invalid APP_TRUSTED_PROXIES entry %q: %v", cidr, err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	if len(options) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	// only the configured proxies, not every private address
+	options = append(options, echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+	return echo.ExtractIPFromXFFHeader(options...)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestIPExtractor(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies string
+		remoteAddr     string
+		forwardedFor   string
+		want           string
+	}{
+		{"no proxies ignores the header", "", "203.0.113.7:4321", "198.51.100.1", "203.0.113.7"},
+		{"private sender is not trusted by default", "", "10.0.0.5:4321", "198.51.100.1", "10.0.0.5"},
+		{"untrusted sender is ignored", "10.0.0.0/8", "203.0.113.7:4321", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy forwards the client", "10.0.0.0/8", "10.0.0.5:4321", "198.51.100.1", "198.51.100.1"},
+		{"spoofed entry before the client is ignored", "10.0.0.0/8", "10.0.0.5:4321", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.0/8, 192.168.0.0/16", "10.0.0.5:4321", "198.51.100.1, 192.168.1.1", "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/auth/login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(echo.HeaderXForwardedFor, tt.forwardedFor)
+			req.Header.Set(echo.HeaderXRealIP, "192.0.2.1")
+
+			if got := ipExtractor(tt.trustedProxies)(req); got != tt.want {
+				t.Errorf("ipExtractor(%q) = %q, want %q", tt.trustedProxies, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/conn"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/spf13/cobra"
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage deleted courses and lessons",
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Permanently remove courses and lessons deleted before the retention window",
+	Long: "Permanently remove the courses and lessons that are in the trash for longer than TRASH_RETENTION_DAYS (or --days). " +
+		"Courses go with their lessons, sections, staff, versions, reviews and enrollments, lessons with the progress of learners.",
+	Args: cobra.NoArgs,
+	Run:  PurgeTrash,
+}
+
+var trashPurgeDays int
+
+func init() {
+	trashPurgeCmd.Flags().IntVar(&trashPurgeDays, "days", 0, "retention in days, defaults to TRASH_RETENTION_DAYS")
+
+	trashCmd.AddCommand(trashPurgeCmd)
+}
+
+func PurgeTrash(cmd *cobra.Command, args []string) {
+	days := trashPurgeDays
+	if days <= 0 {
+		days = config.Trash().RetentionDays
+	}
+	if days <= 0 {
+		log.Fatalf("Invalid retention of %d days", days)
+	}
+
+	conn.InitDB()
+	trashRepo := repository.NewTrashRepository(conn.Db())
+
+	before := time.Now().AddDate(0, 0, -days)
+	courses, err := trashRepo.PurgeCourses(before)
+	if err != nil {
+		log.Fatalf("Failed to purge deleted courses: %v", err)
+	}
+	lessons, err := trashRepo.PurgeLessons(before)
+	if err != nil {
+		log.Fatalf("Failed to purge deleted lessons: %v", err)
+	}
+
+	fmt.Printf("%d courses and %d lessons deleted before %s purged\n", courses, lessons, before.Format(time.RFC3339))
+}
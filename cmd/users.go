@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/conn"
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/jwtutil"
+	"github.com/rijwanansari/vivaLearning/utils/passwordutil"
+	"github.com/spf13/cobra"
+)
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage user accounts",
+}
+
+var usersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Args:  cobra.NoArgs,
+	Run:   ListUsers,
+}
+
+var usersCreateAdminCmd = &cobra.Command{
+	Use:   "create-admin <email>",
+	Short: "Create a verified admin account",
+	Long:  "Create a verified admin account. A random password is generated and printed when --password is not given.",
+	Args:  cobra.ExactArgs(1),
+	Run:   CreateAdmin,
+}
+
+var usersSetRoleCmd = &cobra.Command{
+	Use:   "set-role <email> <admin|instructor|learner>",
+	Short: "Change the role of a user and sign out their sessions",
+	Args:  cobra.ExactArgs(2),
+	Run:   SetRole,
+}
+
+var usersListFilter dto.UserFilterRequest
+var usersListOrganization string
+var createAdminPassword string
+var createAdminOrganization string
+
+func init() {
+	usersListCmd.Flags().StringVar(&usersListFilter.Search, "search", "", "filter by email or name")
+	usersListCmd.Flags().StringVar(&usersListFilter.Role, "role", "", "filter by role (admin, instructor, learner)")
+	usersListCmd.Flags().StringVar(&usersListFilter.Status, "status", "", "filter by status (active, suspended)")
+	usersListCmd.Flags().IntVar(&usersListFilter.Page, "page", 1, "page number")
+	usersListCmd.Flags().IntVar(&usersListFilter.Limit, "limit", 50, "users per page")
+	usersListCmd.Flags().StringVar(&usersListOrganization, "organization", "", "only list the users of the organization (slug)")
+
+	usersCreateAdminCmd.Flags().StringVar(&createAdminPassword, "password", "", "password of the new admin")
+	usersCreateAdminCmd.Flags().StringVar(&createAdminOrganization, "organization", domain.DefaultOrganizationSlug, "organization (slug) the admin manages")
+
+	usersCmd.AddCommand(usersListCmd, usersCreateAdminCmd, usersSetRoleCmd)
+}
+
+func ListUsers(cmd *cobra.Command, args []string) {
+	conn.InitDB()
+	userRepo := repository.NewUserRepository(conn.Db())
+	if usersListOrganization != "" {
+		userRepo = userRepo.ForOrganization(organizationID(usersListOrganization))
+	}
+
+	if usersListFilter.Page <= 0 {
+		usersListFilter.Page = 1
+	}
+	if usersListFilter.Limit <= 0 {
+		usersListFilter.Limit = 50
+	}
+
+	users, total, err := userRepo.GetAll(usersListFilter)
+	if err != nil {
+		log.Fatalf("Failed to list users: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tORG\tEMAIL\tNAME\tROLE\tVERIFIED\tMFA\tSTATUS\tCREATED")
+	for _, user := range users {
+		status := "active"
+		if user.IsSuspended() {
+			status = "suspended"
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%t\t%t\t%s\t%s\n", user.ID, user.OrganizationID, user.Email, user.Name,
+			domain.NormalizeRole(user.Role), user.IsVerified(), user.MFAEnabled, status, user.CreatedAt.Format(time.RFC3339))
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\n%d of %d users (page %d)\n", len(users), total, usersListFilter.Page)
+}
+
+func CreateAdmin(cmd *cobra.Command, args []string) {
+	email := args[0]
+
+	conn.InitDB()
+	userRepo := repository.NewUserRepository(conn.Db()).ForOrganization(organizationID(createAdminOrganization))
+
+	if exists, err := userRepo.EmailExists(email); err != nil {
+		log.Fatalf("Failed to look up user: %v", err)
+	} else if exists {
+		log.Fatalf("A user with email %s already exists, use `users set-role %s admin` instead", email, email)
+	}
+
+	var breachedList *passwordutil.BreachedList
+	if path := config.Password().BreachedListPath; path != "" {
+		var err error
+		if breachedList, err = passwordutil.OpenBreachedList(path); err != nil {
+			log.Fatalf("Failed to open breached password list: %v", err)
+		}
+	}
+	passwordPolicy := services.NewPasswordPolicyService(repository.NewPasswordHistoryRepository(conn.Db()), breachedList)
+
+	// a chosen password has to meet the policy, a generated one is random enough
+	password := createAdminPassword
+	if password == "" {
+		var err error
+		if password, err = utils.GenerateRandomToken(12); err != nil {
+			log.Fatalf("Failed to generate password: %v", err)
+		}
+	} else if err := passwordPolicy.Check(nil, password); err != nil {
+		log.Fatalf("Invalid password: %v", err)
+	}
+
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		log.Fatalf("Failed to hash password: %v", err)
+	}
+
+	now := time.Now()
+	user := &domain.User{
+		Email:             email,
+		Password:          hashed,
+		Role:              domain.RoleAdmin,
+		VerifiedAt:        &now,
+		PasswordChangedAt: &now,
+	}
+	if err := userRepo.Create(user); err != nil {
+		log.Fatalf("Failed to create admin: %v", err)
+	}
+	if err := passwordPolicy.Remember(user.ID, hashed); err != nil {
+		log.Printf("Failed to store password history: %v", err)
+	}
+	recordCLIAudit(user.OrganizationID, domain.AuditActionUserCreate, user.ID, nil, map[string]interface{}{"email": user.Email, "role": user.Role})
+
+	fmt.Printf("Admin %s created with id %d in organization %s\n", user.Email, user.ID, createAdminOrganization)
+	if createAdminPassword == "" {
+		fmt.Printf("Generated password: %s\n", password)
+	}
+}
+
+func SetRole(cmd *cobra.Command, args []string) {
+	email, role := args[0], args[1]
+	if role != domain.RoleAdmin && role != domain.RoleInstructor && role != domain.RoleLearner {
+		log.Fatalf("Invalid role %q, expected admin, instructor or learner", role)
+	}
+
+	conn.InitDB()
+	conn.InitRedis()
+	userRepo := repository.NewUserRepository(conn.Db())
+
+	keySet, err := jwtutil.NewKeySet(config.Jwt())
+	if err != nil {
+		log.Fatalf("Failed to load jwt keys: %v", err)
+	}
+	tokenService := services.NewTokenService(services.NewRedisService(conn.Redis()), keySet)
+
+	user, err := userRepo.GetByEmail(email)
+	if err != nil {
+		log.Fatalf("User %s not found", email)
+	}
+
+	previousRole := domain.NormalizeRole(user.Role)
+	user.Role = role
+	if err := userRepo.Update(user); err != nil {
+		log.Fatalf("Failed to update role: %v", err)
+	}
+	recordCLIAudit(user.OrganizationID, domain.AuditActionUserRoleChange, user.ID, map[string]interface{}{"role": previousRole}, map[string]interface{}{"role": role})
+
+	// tokens carry the role, so the user has to log in again
+	if err := tokenService.DeleteAllTokenUUIDs(int(user.ID)); err != nil {
+		log.Fatalf("Role updated but failed to revoke sessions: %v", err)
+	}
+
+	fmt.Printf("User %s is now %s\n", user.Email, role)
+}
+
+// recordCLIAudit records a change made from the command line, without an actor
+func recordCLIAudit(organizationID uint, action string, userID uint, before, after interface{}) {
+	auditService := services.NewAuditService(repository.NewAuditLogRepository(conn.Db())).WithRequest(types.RequestInfo{UserAgent: "cli"})
+	if err := auditService.Record(organizationID, 0, action, domain.AuditTargetUser, userID, before, after); err != nil {
+		log.Printf("Failed to write the audit log: %v", err)
+	}
+}
+
+// organizationID looks up the organization of the --organization flag
+func organizationID(slug string) uint {
+	organization, err := repository.NewOrganizationRepository(conn.Db()).GetBySlug(slug)
+	if err != nil {
+		log.Fatalf("Organization %s not found", slug)
+	}
+	return organization.ID
+}
@@ -11,8 +11,16 @@ import (
 )
 
 type AppConfig struct {
-	Name string `json:"name"`
-	Port int    `json:"port"`
+	Name    string `json:"name"`
+	Port    int    `json:"port"`
+	BaseURL string `json:"baseUrl"` // public URL of the web app, used in emailed links
+
+	// organizations are served from <slug>.<TenantDomain>, subdomains are ignored while empty
+	TenantDomain string `json:"tenantDomain"`
+
+	// comma-separated CIDRs of the reverse proxies whose X-Forwarded-For is
+	// trusted, empty uses the address of the connection
+	TrustedProxies string `json:"trustedProxies"`
 }
 
 type DbConfig struct {
@@ -32,33 +40,144 @@ type LoggerConfig struct {
 	FilePath string `json:"filePath"`
 }
 type RedisConfig struct {
-	Host               string
-	Port               string
-	Pass               string
-	Db                 int
-	MandatoryPrefix    string
-	AccessUuidPrefix   string
-	RefreshUuidPrefix  string
-	UserPrefix         string
-	PermissionPrefix   string
-	UserCacheTTL       time.Duration
-	PermissionCacheTTL time.Duration
+	Host                       string
+	Port                       string
+	Pass                       string
+	Db                         int
+	MandatoryPrefix            string
+	AccessUuidPrefix           string
+	RefreshUuidPrefix          string
+	UserPrefix                 string
+	PermissionPrefix           string
+	PasswordResetPrefix        string
+	VerificationThrottlePrefix string
+	LoginAttemptPrefix         string
+	MFAPrefix                  string
+	SessionPrefix              string
+	OIDCPrefix                 string
+	OrganizationPrefix         string
+	DataExportPrefix           string
+	UserCacheTTL               time.Duration
+	PermissionCacheTTL         time.Duration
+	OrganizationCacheTTL       time.Duration
+}
+
+type MailConfig struct {
+	Driver   string `json:"driver"` // smtp or file
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	FilePath string `json:"filePath"` // file driver only, logs the mails when empty
+}
+
+type AuthConfig struct {
+	PasswordResetExpiry          int64 `json:"passwordResetExpiry"`          // in seconds
+	EmailVerificationExpiry      int64 `json:"emailVerificationExpiry"`      // in seconds
+	VerificationResendInterval   int64 `json:"verificationResendInterval"`   // in seconds
+	RequireVerifiedForEnrollment bool  `json:"requireVerifiedForEnrollment"` // block EnrollInCourse for unverified accounts
+	LoginMaxAttempts             int   `json:"loginMaxAttempts"`             // failed attempts per account before lockout
+	LoginMaxAttemptsPerIP        int   `json:"loginMaxAttemptsPerIp"`        // failed attempts per IP within the window
+	LoginAttemptWindow           int64 `json:"loginAttemptWindow"`           // in seconds
+	LoginBackoffBase             int64 `json:"loginBackoffBase"`             // in seconds, doubled on every failure
+	LoginBackoffMax              int64 `json:"loginBackoffMax"`              // in seconds
+	LoginLockoutDuration         int64 `json:"loginLockoutDuration"`         // in seconds
+	MFAChallengeExpiry           int64 `json:"mfaChallengeExpiry"`           // in seconds
+	MFASetupExpiry               int64 `json:"mfaSetupExpiry"`               // in seconds
+	RequireMFAForAdmins          bool  `json:"requireMfaForAdmins"`
+	ImpersonationExpiry          int64 `json:"impersonationExpiry"`      // in seconds
+	ImpersonationAllowWrites     bool  `json:"impersonationAllowWrites"` // let impersonation tokens call write endpoints
 }
 
 type Config struct {
-	App    AppConfig    `json:"app"`
-	Db     DbConfig     `json:"db"`
-	Logger LoggerConfig `json:"logger"`
-	Jwt    *JwtConfig   `json:"jwt"`
-	Redis  *RedisConfig `json:"redis"`
+	App       AppConfig       `json:"app"`
+	Db        DbConfig        `json:"db"`
+	Logger    LoggerConfig    `json:"logger"`
+	Jwt       *JwtConfig      `json:"jwt"`
+	OIDC      OIDCConfig      `json:"oidc"`
+	SCIM      SCIMConfig      `json:"scim"`
+	Audit     AuditConfig     `json:"audit"`
+	Privacy   PrivacyConfig   `json:"privacy"`
+	Scheduler SchedulerConfig `json:"scheduler"`
+	Trash     TrashConfig     `json:"trash"`
+	Redis     *RedisConfig    `json:"redis"`
+	Mail      MailConfig      `json:"mail"`
+	Auth      AuthConfig      `json:"auth"`
+	Password  PasswordConfig  `json:"password"`
 }
 type JwtConfig struct {
-	AccessTokenSecret  string `json:"accessTokenSecret"`
-	RefreshTokenSecret string `json:"refreshTokenSecret"`
+	SigningMethod      string `json:"signingMethod"` // HS256, RS256 or EdDSA
+	Issuer             string `json:"issuer"`
+	AccessTokenSecret  string `json:"accessTokenSecret"`  // HS256 only
+	RefreshTokenSecret string `json:"refreshTokenSecret"` // HS256 only
+	KeyID              string `json:"keyId"`              // kid of the private key, derived from the key when empty
+	PrivateKeyPath     string `json:"privateKeyPath"`     // PEM private key, RS256/EdDSA only
+	PublicKeyPaths     string `json:"publicKeyPaths"`     // comma-separated [kid=]path of extra PEM public keys accepted for verification
 	AccessTokenExpiry  int64  `json:"accessTokenExpiry"`  // in seconds
 	RefreshTokenExpiry int64  `json:"refreshTokenExpiry"` // in seconds
 }
 
+// OIDCConfig describes the OpenID Connect identity provider used for single
+// sign-on. The provider endpoints are read from the issuer's discovery document.
+type OIDCConfig struct {
+	Enabled      bool   `json:"enabled"`
+	Issuer       string `json:"issuer"` // https, or http on a loopback host for a local fake IdP
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"` // empty for a public client, PKCE is always used
+	RedirectURL  string `json:"redirectUrl"`  // defaults to APP_BASE_URL + /sso/callback
+	Scopes       string `json:"scopes"`       // space-separated, openid is always requested
+	AllowSignup  bool   `json:"allowSignup"`  // provision unknown users on their first login
+	DefaultRole  string `json:"defaultRole"`  // role of provisioned users
+	StateExpiry  int64  `json:"stateExpiry"`  // in seconds, time allowed to complete the login at the IdP
+	ClockSkew    int64  `json:"clockSkew"`    // in seconds, tolerated when checking ID token times
+	CacheTTL     int64  `json:"cacheTtl"`     // in seconds, how long the discovery document and keys are cached
+}
+
+// SCIMConfig configures the /scim/v2 provisioning API used by the HR system.
+// The bearer tokens are issued per organization with `organizations scim-token`.
+type SCIMConfig struct {
+	MaxResults int `json:"maxResults"` // upper bound of the count parameter
+}
+
+// AuditConfig configures the audit log. Entries are kept RetentionDays and
+// removed by the `audit purge` command.
+type AuditConfig struct {
+	RetentionDays int `json:"retentionDays"`
+}
+
+// PasswordConfig is the policy applied whenever a password is chosen: on
+// registration, reset and change
+type PasswordConfig struct {
+	MinLength        int    `json:"minLength"`
+	MaxLength        int    `json:"maxLength"` // bcrypt ignores everything after 72 bytes
+	RequireUppercase bool   `json:"requireUppercase"`
+	RequireLowercase bool   `json:"requireLowercase"`
+	RequireDigit     bool   `json:"requireDigit"`
+	RequireSymbol    bool   `json:"requireSymbol"`
+	MaxAgeDays       int    `json:"maxAgeDays"`       // logins are refused until a reset once the password is older, 0 disables
+	HistorySize      int    `json:"historySize"`      // number of previous passwords that cannot be reused, 0 disables
+	BreachedListPath string `json:"breachedListPath"` // directory of Pwned Passwords range files, empty disables the check
+}
+
+// PrivacyConfig configures the personal data exports of learners
+type PrivacyConfig struct {
+	ExportTTL int64 `json:"exportTtl"` // in seconds, how long a finished export can be downloaded
+}
+
+// SchedulerConfig configures the publish scheduler that applies the
+// publish_at/unpublish_at dates of courses and lessons
+type SchedulerConfig struct {
+	Enabled  bool  `json:"enabled"`  // run the scheduler inside serve, disable when the `schedule run` worker is used
+	Interval int64 `json:"interval"` // in seconds, between two passes
+}
+
+// TrashConfig configures the trash of deleted courses and lessons. They can be
+// restored for RetentionDays and are then removed by the `trash purge` command.
+type TrashConfig struct {
+	RetentionDays int `json:"retentionDays"`
+}
+
 var config Config
 
 func LoadConfig() {
@@ -91,6 +210,9 @@ func bindEnvironmentVariables() {
 	// App configuration
 	_ = viper.BindEnv("app.name", "APP_NAME")
 	_ = viper.BindEnv("app.port", "APP_PORT")
+	_ = viper.BindEnv("app.baseUrl", "APP_BASE_URL")
+	_ = viper.BindEnv("app.tenantDomain", "APP_TENANT_DOMAIN")
+	_ = viper.BindEnv("app.trustedProxies", "APP_TRUSTED_PROXIES")
 
 	// Database configuration
 	_ = viper.BindEnv("db.host", "DB_HOST")
@@ -108,11 +230,89 @@ func bindEnvironmentVariables() {
 	_ = viper.BindEnv("logger.filePath", "LOG_FILE_PATH")
 
 	// JWT configuration
+	_ = viper.BindEnv("jwt.signingMethod", "JWT_SIGNING_METHOD")
+	_ = viper.BindEnv("jwt.issuer", "JWT_ISSUER")
+	_ = viper.BindEnv("jwt.keyId", "JWT_KEY_ID")
+	_ = viper.BindEnv("jwt.privateKeyPath", "JWT_PRIVATE_KEY_PATH")
+	_ = viper.BindEnv("jwt.publicKeyPaths", "JWT_PUBLIC_KEY_PATHS")
 	_ = viper.BindEnv("jwt.accessTokenSecret", "JWT_ACCESS_TOKEN_SECRET")
 	_ = viper.BindEnv("jwt.refreshTokenSecret", "JWT_REFRESH_TOKEN_SECRET")
 	_ = viper.BindEnv("jwt.accessTokenExpiry", "JWT_ACCESS_TOKEN_EXPIRY")
 	_ = viper.BindEnv("jwt.refreshTokenExpiry", "JWT_REFRESH_TOKEN_EXPIRY")
 
+	// OIDC single sign-on configuration
+	_ = viper.BindEnv("oidc.enabled", "OIDC_ENABLED")
+	_ = viper.BindEnv("oidc.issuer", "OIDC_ISSUER")
+	_ = viper.BindEnv("oidc.clientId", "OIDC_CLIENT_ID")
+	_ = viper.BindEnv("oidc.clientSecret", "OIDC_CLIENT_SECRET")
+	_ = viper.BindEnv("oidc.redirectUrl", "OIDC_REDIRECT_URL")
+	_ = viper.BindEnv("oidc.scopes", "OIDC_SCOPES")
+	_ = viper.BindEnv("oidc.allowSignup", "OIDC_ALLOW_SIGNUP")
+	_ = viper.BindEnv("oidc.defaultRole", "OIDC_DEFAULT_ROLE")
+	_ = viper.BindEnv("oidc.stateExpiry", "OIDC_STATE_EXPIRY")
+	_ = viper.BindEnv("oidc.clockSkew", "OIDC_CLOCK_SKEW")
+	_ = viper.BindEnv("oidc.cacheTtl", "OIDC_CACHE_TTL")
+
+	// SCIM provisioning configuration
+	_ = viper.BindEnv("scim.maxResults", "SCIM_MAX_RESULTS")
+
+	// Audit log configuration
+	_ = viper.BindEnv("audit.retentionDays", "AUDIT_RETENTION_DAYS")
+
+	// Password policy configuration
+	_ = viper.BindEnv("password.minLength", "PASSWORD_MIN_LENGTH")
+	_ = viper.BindEnv("password.maxLength", "PASSWORD_MAX_LENGTH")
+	_ = viper.BindEnv("password.requireUppercase", "PASSWORD_REQUIRE_UPPERCASE")
+	_ = viper.BindEnv("password.requireLowercase", "PASSWORD_REQUIRE_LOWERCASE")
+	_ = viper.BindEnv("password.requireDigit", "PASSWORD_REQUIRE_DIGIT")
+	_ = viper.BindEnv("password.requireSymbol", "PASSWORD_REQUIRE_SYMBOL")
+	_ = viper.BindEnv("password.maxAgeDays", "PASSWORD_MAX_AGE_DAYS")
+	_ = viper.BindEnv("password.historySize", "PASSWORD_HISTORY_SIZE")
+	_ = viper.BindEnv("password.breachedListPath", "PASSWORD_BREACHED_LIST_PATH")
+
+	// Personal data export configuration
+	_ = viper.BindEnv("privacy.exportTtl", "PRIVACY_EXPORT_TTL")
+
+	// Publish scheduler configuration
+	_ = viper.BindEnv("scheduler.enabled", "SCHEDULER_ENABLED")
+	_ = viper.BindEnv("scheduler.interval", "SCHEDULER_INTERVAL")
+
+	// Trash configuration
+	_ = viper.BindEnv("trash.retentionDays", "TRASH_RETENTION_DAYS")
+
+	// Redis configuration
+	_ = viper.BindEnv("redis.host", "REDIS_HOST")
+	_ = viper.BindEnv("redis.port", "REDIS_PORT")
+	_ = viper.BindEnv("redis.pass", "REDIS_PASS", "REDIS_PASSWORD")
+	_ = viper.BindEnv("redis.db", "REDIS_DB")
+	_ = viper.BindEnv("redis.mandatoryPrefix", "REDIS_MANDATORY_PREFIX")
+
+	// Mail configuration
+	_ = viper.BindEnv("mail.driver", "MAIL_DRIVER")
+	_ = viper.BindEnv("mail.host", "MAIL_HOST")
+	_ = viper.BindEnv("mail.port", "MAIL_PORT")
+	_ = viper.BindEnv("mail.username", "MAIL_USERNAME")
+	_ = viper.BindEnv("mail.password", "MAIL_PASSWORD")
+	_ = viper.BindEnv("mail.from", "MAIL_FROM")
+	_ = viper.BindEnv("mail.filePath", "MAIL_FILE_PATH")
+
+	// Auth configuration
+	_ = viper.BindEnv("auth.passwordResetExpiry", "AUTH_PASSWORD_RESET_EXPIRY")
+	_ = viper.BindEnv("auth.emailVerificationExpiry", "AUTH_EMAIL_VERIFICATION_EXPIRY")
+	_ = viper.BindEnv("auth.verificationResendInterval", "AUTH_VERIFICATION_RESEND_INTERVAL")
+	_ = viper.BindEnv("auth.requireVerifiedForEnrollment", "AUTH_REQUIRE_VERIFIED_FOR_ENROLLMENT")
+	_ = viper.BindEnv("auth.loginMaxAttempts", "AUTH_LOGIN_MAX_ATTEMPTS")
+	_ = viper.BindEnv("auth.loginMaxAttemptsPerIp", "AUTH_LOGIN_MAX_ATTEMPTS_PER_IP")
+	_ = viper.BindEnv("auth.loginAttemptWindow", "AUTH_LOGIN_ATTEMPT_WINDOW")
+	_ = viper.BindEnv("auth.loginBackoffBase", "AUTH_LOGIN_BACKOFF_BASE")
+	_ = viper.BindEnv("auth.loginBackoffMax", "AUTH_LOGIN_BACKOFF_MAX")
+	_ = viper.BindEnv("auth.loginLockoutDuration", "AUTH_LOGIN_LOCKOUT_DURATION")
+	_ = viper.BindEnv("auth.mfaChallengeExpiry", "AUTH_MFA_CHALLENGE_EXPIRY")
+	_ = viper.BindEnv("auth.mfaSetupExpiry", "AUTH_MFA_SETUP_EXPIRY")
+	_ = viper.BindEnv("auth.requireMfaForAdmins", "AUTH_REQUIRE_MFA_FOR_ADMINS")
+	_ = viper.BindEnv("auth.impersonationExpiry", "AUTH_IMPERSONATION_EXPIRY")
+	_ = viper.BindEnv("auth.impersonationAllowWrites", "AUTH_IMPERSONATION_ALLOW_WRITES")
+
 	// Consul configuration (for fallback)
 	_ = viper.BindEnv("CONSUL_URL")
 	_ = viper.BindEnv("CONSUL_PATH")
@@ -122,6 +322,7 @@ func setDefaults() {
 	// App defaults
 	viper.SetDefault("app.name", "did-api")
 	viper.SetDefault("app.port", 8080)
+	viper.SetDefault("app.baseUrl", "http://localhost:8080")
 
 	// Database defaults
 	viper.SetDefault("db.host", "localhost")
@@ -136,11 +337,48 @@ func setDefaults() {
 	viper.SetDefault("logger.filePath", "logs/app.log")
 
 	// JWT defaults
+	viper.SetDefault("jwt.signingMethod", "HS256")
+	viper.SetDefault("jwt.issuer", "vivaLearning")
 	viper.SetDefault("jwt.accessTokenSecret", "default-access-secret-change-in-production")
 	viper.SetDefault("jwt.refreshTokenSecret", "default-refresh-secret-change-in-production")
 	viper.SetDefault("jwt.accessTokenExpiry", 900)     // 15 minutes in seconds
 	viper.SetDefault("jwt.refreshTokenExpiry", 604800) // 7 days in seconds
 
+	// OIDC defaults
+	viper.SetDefault("oidc.enabled", false)
+	viper.SetDefault("oidc.scopes", "openid email profile")
+	viper.SetDefault("oidc.allowSignup", true)
+	viper.SetDefault("oidc.defaultRole", "learner")
+	viper.SetDefault("oidc.stateExpiry", 600) // 10 minutes in seconds
+	viper.SetDefault("oidc.clockSkew", 60)
+	viper.SetDefault("oidc.cacheTtl", 3600) // 1 hour in seconds
+
+	// SCIM defaults
+	viper.SetDefault("scim.maxResults", 200)
+
+	// Audit defaults
+	viper.SetDefault("audit.retentionDays", 365)
+
+	// Password policy defaults
+	viper.SetDefault("password.minLength", 8)
+	viper.SetDefault("password.maxLength", 72)
+	viper.SetDefault("password.requireUppercase", false)
+	viper.SetDefault("password.requireLowercase", false)
+	viper.SetDefault("password.requireDigit", false)
+	viper.SetDefault("password.requireSymbol", false)
+	viper.SetDefault("password.maxAgeDays", 0)
+	viper.SetDefault("password.historySize", 5)
+
+	// Personal data export defaults
+	viper.SetDefault("privacy.exportTtl", 86400) // 24 hours in seconds
+
+	// Publish scheduler defaults
+	viper.SetDefault("scheduler.enabled", true)
+	viper.SetDefault("scheduler.interval", 60)
+
+	// Trash defaults
+	viper.SetDefault("trash.retentionDays", 30)
+
 	//redis defaults
 	viper.SetDefault("redis.host", "localhost")
 	viper.SetDefault("redis.port", "6379")
@@ -151,8 +389,40 @@ func setDefaults() {
 	viper.SetDefault("redis.refreshUuidPrefix", "refresh:")
 	viper.SetDefault("redis.userPrefix", "user:")
 	viper.SetDefault("redis.permissionPrefix", "permission:")
+	viper.SetDefault("redis.passwordResetPrefix", "password-reset:")
+	viper.SetDefault("redis.verificationThrottlePrefix", "verification-throttle:")
+	viper.SetDefault("redis.loginAttemptPrefix", "login-attempts:")
+	viper.SetDefault("redis.mfaPrefix", "mfa:")
+	viper.SetDefault("redis.sessionPrefix", "session:")
+	viper.SetDefault("redis.oidcPrefix", "oidc:")
+	viper.SetDefault("redis.organizationPrefix", "organization:")
+	viper.SetDefault("redis.dataExportPrefix", "data-export:")
 	viper.SetDefault("redis.userCacheTTL", 5*time.Minute)
 	viper.SetDefault("redis.permissionCacheTTL", 5*time.Minute)
+	viper.SetDefault("redis.organizationCacheTTL", 5*time.Minute)
+
+	// Mail defaults
+	viper.SetDefault("mail.driver", "file")
+	viper.SetDefault("mail.port", "587")
+	viper.SetDefault("mail.from", "no-reply@vivalearning.local")
+	viper.SetDefault("mail.filePath", "logs/mail.log")
+
+	// Auth defaults
+	viper.SetDefault("auth.passwordResetExpiry", 3600)      // 1 hour in seconds
+	viper.SetDefault("auth.emailVerificationExpiry", 86400) // 24 hours in seconds
+	viper.SetDefault("auth.verificationResendInterval", 60)
+	viper.SetDefault("auth.requireVerifiedForEnrollment", false)
+	viper.SetDefault("auth.loginMaxAttempts", 5)
+	viper.SetDefault("auth.loginMaxAttemptsPerIp", 50)
+	viper.SetDefault("auth.loginAttemptWindow", 900) // 15 minutes in seconds
+	viper.SetDefault("auth.loginBackoffBase", 1)
+	viper.SetDefault("auth.loginBackoffMax", 60)
+	viper.SetDefault("auth.loginLockoutDuration", 900) // 15 minutes in seconds
+	viper.SetDefault("auth.mfaChallengeExpiry", 300)   // 5 minutes in seconds
+	viper.SetDefault("auth.mfaSetupExpiry", 600)       // 10 minutes in seconds
+	viper.SetDefault("auth.requireMfaForAdmins", false)
+	viper.SetDefault("auth.impersonationExpiry", 900) // 15 minutes in seconds
+	viper.SetDefault("auth.impersonationAllowWrites", false)
 }
 
 func loadFromConsul() {
@@ -204,6 +474,62 @@ func (j *JwtConfig) GetRefreshTokenExpiry() time.Duration {
 	return time.Duration(j.RefreshTokenExpiry) * time.Second
 }
 
+func OIDC() *OIDCConfig {
+	return &config.OIDC
+}
+
+// GetRedirectURL returns the URL the IdP sends the user back to
+func (o *OIDCConfig) GetRedirectURL() string {
+	if o.RedirectURL != "" {
+		return o.RedirectURL
+	}
+	return config.App.BaseURL + "/sso/callback"
+}
+
+func SCIM() *SCIMConfig {
+	return &config.SCIM
+}
+
+func Audit() *AuditConfig {
+	return &config.Audit
+}
+
+func Password() *PasswordConfig {
+	return &config.Password
+}
+
+func Scheduler() *SchedulerConfig {
+	return &config.Scheduler
+}
+
+func Trash() *TrashConfig {
+	return &config.Trash
+}
+
+func Privacy() *PrivacyConfig {
+	return &config.Privacy
+}
+
 func Redis() *RedisConfig {
 	return config.Redis
 }
+
+func Mail() *MailConfig {
+	return &config.Mail
+}
+
+func Auth() *AuthConfig {
+	return &config.Auth
+}
+
+func (a *AuthConfig) GetPasswordResetExpiry() time.Duration {
+	return time.Duration(a.PasswordResetExpiry) * time.Second
+}
+
+func (a *AuthConfig) GetEmailVerificationExpiry() time.Duration {
+	return time.Duration(a.EmailVerificationExpiry) * time.Second
+}
+
+func (a *AuthConfig) GetImpersonationExpiry() time.Duration {
+	return time.Duration(a.ImpersonationExpiry) * time.Second
+}
@@ -57,16 +57,141 @@ func InitDB() {
 	fmt.Println("Connected to the database successfully")
 
 	// Auto Migrate models
-	err = db.AutoMigrate(
-		&domain.User{},
-		&domain.Course{},
-		&domain.Lesson{},
-		&domain.UserCourse{},
-		&domain.UserLesson{},
-	)
-	if err != nil {
+	if err := Migrate(db); err != nil {
 		log.Fatalf("Auto migration failed: %v", err)
 	}
+
+	seedRolePermissions()
+	seedDefaultOrganization()
+	seedCourseStatus()
+	protectAppendOnlyTables()
+}
+
+// models are the tables created by the auto migration
+var models = []interface{}{
+	&domain.Organization{},
+	&domain.User{},
+	&domain.Course{},
+	&domain.Section{},
+	&domain.Lesson{},
+	&domain.UserCourse{},
+	&domain.UserLesson{},
+	&domain.RolePermission{},
+	&domain.CourseStaff{},
+	&domain.MFARecoveryCode{},
+	&domain.APIKey{},
+	&domain.UserIdentity{},
+	&domain.Group{},
+	&domain.AuditLog{},
+	&domain.PasswordHistory{},
+	&domain.CourseVersion{},
+	&domain.CourseReview{},
+}
+
+// Migrate creates or updates the tables of all models. The seeds and the
+// append-only triggers are Postgres specific and left to InitDB.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(models...)
+}
+
+// seedCourseStatus marks the courses published before the review workflow
+// existed as published, every other course starts as a draft
+func seedCourseStatus() {
+	err := db.Model(&domain.Course{}).
+		Where("is_published = ? AND status = ?", true, domain.CourseStatusDraft).
+		UpdateColumn("status", domain.CourseStatusPublished).Error
+	if err != nil {
+		log.Fatalf("Failed to set the status of published courses: %v", err)
+	}
+}
+
+// appendOnlyTables refuse updates in the database: audit_logs so the log
+// cannot be tampered with, course_versions because published versions are
+// immutable. Deleting stays possible for the retention commands. The value is
+// the statement that may let an update through, audit_logs only accepts the
+// redaction done by the erasure of a user inside its transaction.
+var appendOnlyTables = map[string]string{
+	"audit_logs": `IF current_setting('` + domain.AuditScrubSetting + `', true) = 'on' THEN
+		RETURN NEW;
+	END IF;`,
+	"course_versions": "",
+}
+
+func protectAppendOnlyTables() {
+	for table, exception := range appendOnlyTables {
+		statements := []string{
+			`CREATE OR REPLACE FUNCTION ` + table + `_append_only() RETURNS trigger AS $$
+BEGIN
+	` + exception + `
+	RAISE EXCEPTION '` + table + ` is append-only';
+END;
+$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS ` + table + `_append_only ON ` + table,
+			`CREATE TRIGGER ` + table + `_append_only BEFORE UPDATE ON ` + table + ` FOR EACH ROW EXECUTE PROCEDURE ` + table + `_append_only()`,
+		}
+
+		for _, statement := range statements {
+			if err := db.Exec(statement).Error; err != nil {
+				log.Fatalf("Failed to make %s append-only: %v", table, err)
+			}
+		}
+	}
+}
+
+// tenantTables hold an organization_id column, rows created before
+// organizations existed are moved into the default organization
+var tenantTables = []interface{}{
+	&domain.User{},
+	&domain.Course{},
+	&domain.Lesson{},
+	&domain.UserCourse{},
+	&domain.UserLesson{},
+	&domain.Group{},
+}
+
+// seedDefaultOrganization creates the default organization and assigns it the
+// rows that do not belong to any organization yet
+func seedDefaultOrganization() {
+	organization := domain.Organization{Name: "Default", Slug: domain.DefaultOrganizationSlug}
+	if err := db.Where("slug = ?", organization.Slug).FirstOrCreate(&organization).Error; err != nil {
+		log.Fatalf("Failed to seed the default organization: %v", err)
+	}
+
+	for _, table := range tenantTables {
+		err := db.Model(table).Where("organization_id = ?", 0).UpdateColumn("organization_id", organization.ID).Error
+		if err != nil {
+			log.Fatalf("Failed to assign rows to the default organization: %v", err)
+		}
+	}
+
+	// group names used to be unique across the platform, they are unique per organization now
+	if db.Migrator().HasIndex(&domain.Group{}, "idx_groups_display_name") {
+		if err := db.Migrator().DropIndex(&domain.Group{}, "idx_groups_display_name"); err != nil {
+			log.Fatalf("Failed to drop the group name index: %v", err)
+		}
+	}
+}
+
+// seedRolePermissions fills an empty role_permissions table with the default grants
+func seedRolePermissions() {
+	var count int64
+	if err := db.Model(&domain.RolePermission{}).Count(&count).Error; err != nil {
+		log.Fatalf("Failed to count role permissions: %v", err)
+	}
+	if count > 0 {
+		return
+	}
+
+	var rolePermissions []domain.RolePermission
+	for role, permissions := range domain.DefaultRolePermissions {
+		for _, permission := range permissions {
+			rolePermissions = append(rolePermissions, domain.RolePermission{Role: role, Permission: permission})
+		}
+	}
+
+	if err := db.Create(&rolePermissions).Error; err != nil {
+		log.Fatalf("Failed to seed role permissions: %v", err)
+	}
 }
 
 func Db() *gorm.DB {
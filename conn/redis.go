@@ -0,0 +1,39 @@
+package conn
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/go-redis/redis"
+	"github.com/rijwanansari/vivaLearning/config"
+)
+
+var redisClient *redis.Client
+
+func InitRedis() {
+	// Get Redis config
+	redisConfig := config.Redis()
+
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     redisConfig.Host + ":" + redisConfig.Port,
+		Password: redisConfig.Pass,
+		DB:       redisConfig.Db,
+	})
+
+	// Ping test
+	if _, err := redisClient.Ping().Result(); err != nil {
+		log.Fatalf("Failed to connect to redis: %v", err)
+	}
+
+	fmt.Println("Connected to redis successfully")
+}
+
+func Redis() *redis.Client {
+	return redisClient
+}
+
+func CloseRedis() {
+	if err := redisClient.Close(); err != nil {
+		log.Println("Unable to close redis connection:", err)
+	}
+}
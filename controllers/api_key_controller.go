@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+type APIKeyController struct {
+	APIKeyService services.APIKeyService
+	Validator     *validator.Validate
+}
+
+func NewAPIKeyController(apiKeyService services.APIKeyService) *APIKeyController {
+	return &APIKeyController{
+		APIKeyService: apiKeyService,
+		Validator:     validator.New(),
+	}
+}
+
+// apiKeyService returns the APIKeyService scoped to the organization of the request
+func (ac *APIKeyController) apiKeyService(c echo.Context) services.APIKeyService {
+	return ac.APIKeyService.ForOrganization(getOrganizationIDFromContext(c))
+}
+
+// GetMyAPIKeys lists the personal API keys of the current user
+// GET /api/v1/my/api-keys
+func (ac *APIKeyController) GetMyAPIKeys(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	return ac.listKeys(c, userID)
+}
+
+// CreateMyAPIKey issues a personal API key, the key is only returned once
+// POST /api/v1/my/api-keys
+func (ac *APIKeyController) CreateMyAPIKey(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	return ac.createKey(c, userID, userID)
+}
+
+// RevokeMyAPIKey revokes one of the current user's API keys
+// DELETE /api/v1/my/api-keys/:keyId
+func (ac *APIKeyController) RevokeMyAPIKey(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	return ac.revokeKey(c, userID)
+}
+
+// GetServiceAccounts lists the service accounts
+// GET /api/v1/admin/service-accounts
+func (ac *APIKeyController) GetServiceAccounts(c echo.Context) error {
+	accounts, err := ac.apiKeyService(c).GetServiceAccounts()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   "Failed to retrieve service accounts",
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Service accounts retrieved successfully",
+		Data:    accounts,
+	})
+}
+
+// CreateServiceAccount creates a machine identity with the given role
+// POST /api/v1/admin/service-accounts
+func (ac *APIKeyController) CreateServiceAccount(c echo.Context) error {
+	var req dto.CreateServiceAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+	}
+
+	if err := ac.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	account, err := ac.apiKeyService(c).CreateServiceAccount(req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to create service account"
+		switch {
+		case errors.Is(err, errutil.ErrUserIsAlreadyExists):
+			status, message = http.StatusConflict, "A service account with this name already exists"
+		case errors.Is(err, errutil.ErrInvalidInput):
+			status, message = http.StatusBadRequest, "Name must contain letters or digits"
+		}
+		return c.JSON(status, dto.APIResponse{
+			Success: false,
+			Error:   message,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, dto.APIResponse{
+		Success: true,
+		Message: "Service account created successfully",
+		Data:    account,
+	})
+}
+
+// GetServiceAccountKeys lists the API keys of a service account
+// GET /api/v1/admin/service-accounts/:id/api-keys
+func (ac *APIKeyController) GetServiceAccountKeys(c echo.Context) error {
+	accountID, err := ac.serviceAccountID(c)
+	if err != nil {
+		return err
+	}
+	if accountID == 0 {
+		return nil
+	}
+
+	return ac.listKeys(c, accountID)
+}
+
+// CreateServiceAccountKey issues an API key for a service account
+// POST /api/v1/admin/service-accounts/:id/api-keys
+func (ac *APIKeyController) CreateServiceAccountKey(c echo.Context) error {
+	accountID, err := ac.serviceAccountID(c)
+	if err != nil {
+		return err
+	}
+	if accountID == 0 {
+		return nil
+	}
+
+	return ac.createKey(c, accountID, getUserIDFromContext(c))
+}
+
+// RevokeServiceAccountKey revokes an API key of a service account
+// DELETE /api/v1/admin/service-accounts/:id/api-keys/:keyId
+func (ac *APIKeyController) RevokeServiceAccountKey(c echo.Context) error {
+	accountID, err := ac.serviceAccountID(c)
+	if err != nil {
+		return err
+	}
+	if accountID == 0 {
+		return nil
+	}
+
+	return ac.revokeKey(c, accountID)
+}
+
+// serviceAccountID reads and checks the :id param. It writes the error
+// response itself and returns 0 when the param is not a service account.
+func (ac *APIKeyController) serviceAccountID(c echo.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid service account ID",
+		})
+	}
+
+	if _, err := ac.apiKeyService(c).GetServiceAccount(uint(id)); err != nil {
+		return 0, c.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Error:   "Service account not found",
+		})
+	}
+
+	return uint(id), nil
+}
+
+func (ac *APIKeyController) listKeys(c echo.Context, userID uint) error {
+	keys, err := ac.apiKeyService(c).GetUserKeys(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   "Failed to retrieve API keys",
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "API keys retrieved successfully",
+		Data:    keys,
+	})
+}
+
+func (ac *APIKeyController) createKey(c echo.Context, userID uint, createdBy uint) error {
+	var req dto.CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+	}
+
+	if err := ac.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	key, err := ac.apiKeyService(c).CreateKey(userID, req, createdBy)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   "Failed to create API key",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, dto.APIResponse{
+		Success: true,
+		Message: "API key created, store it now as it will not be shown again",
+		Data:    key,
+	})
+}
+
+func (ac *APIKeyController) revokeKey(c echo.Context, userID uint) error {
+	keyID, err := strconv.ParseUint(c.Param("keyId"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid API key ID",
+		})
+	}
+
+	if err := ac.apiKeyService(c).RevokeKey(userID, uint(keyID)); err != nil {
+		if errors.Is(err, errutil.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, dto.APIResponse{
+				Success: false,
+				Error:   "API key not found",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   "Failed to revoke API key",
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "API key revoked successfully",
+	})
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/services"
+)
+
+type AuditController struct {
+	AuditService services.AuditService
+	Validator    *validator.Validate
+}
+
+func NewAuditController(auditService services.AuditService) *AuditController {
+	return &AuditController{
+		AuditService: auditService,
+		Validator:    validator.New(),
+	}
+}
+
+// GetAuditLogs lists the audit log of the organization, newest first
+// GET /api/v1/admin/audit-logs
+func (ac *AuditController) GetAuditLogs(c echo.Context) error {
+	var filter dto.AuditLogFilterRequest
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid query parameters",
+		})
+	}
+
+	if err := ac.Validator.Struct(filter); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	result, err := ac.AuditService.ForOrganization(getOrganizationIDFromContext(c)).GetAuditLogs(filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   "Failed to retrieve audit logs",
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Audit logs retrieved successfully",
+		Data:    result,
+	})
+}
@@ -1,22 +1,30 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/rijwanansari/vivaLearning/dto"
 	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/msgutil"
 )
 
 type AuthController struct {
 	userService services.UserService
 	authService services.AuthService
+	validator   *validator.Validate
 }
 
 func NewAuthController(userService services.UserService, authService services.AuthService) *AuthController {
 	return &AuthController{
 		userService: userService,
 		authService: authService,
+		validator:   validator.New(),
 	}
 }
 
@@ -26,8 +34,15 @@ func (a *AuthController) RegisterUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
 	}
 
-	user, err := a.authService.Register(req.Email, req.Password)
+	if err := a.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	user, err := a.authService.Register(getOrganizationIDFromContext(c), req.Email, req.Password)
 	if err != nil {
+		if isPasswordPolicyError(err) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
 
@@ -44,10 +59,165 @@ func (a *AuthController) LoginUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
 	}
 
-	token, err := a.authService.Login(req.Email, req.Password)
+	token, challenge, err := a.authService.Login(req.Email, req.Password, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, errutil.ErrTooManyLoginAttempts), errors.Is(err, errutil.ErrAccountLocked):
+			return c.JSON(http.StatusTooManyRequests, echo.Map{"error": err.Error()})
+		case errors.Is(err, errutil.ErrInvalidLoginCredentials):
+			return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
+		case errors.Is(err, errutil.ErrAccountSuspended), errors.Is(err, errutil.ErrPasswordResetRequired), errors.Is(err, errutil.ErrPasswordExpired):
+			return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+	}
+
+	if challenge != nil {
+		// the token is issued by POST /auth/mfa/verify (or /auth/mfa/confirm on first enrollment)
+		return c.JSON(http.StatusOK, echo.Map{"mfa_required": true, "mfa": challenge})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"token": token})
+}
+
+func (a *AuthController) RefreshToken(c echo.Context) error {
+	var req dto.RefreshTokenRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	token, err := a.authService.RefreshToken(req.RefreshToken, clientInfo(c))
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{"token": token})
 }
+
+func (a *AuthController) LogoutUser(c echo.Context) error {
+	token, ok := c.Get("token").(*types.Token)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, msgutil.UserUnauthorized())
+	}
+
+	if err := a.authService.Logout(token); err != nil {
+		return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Logged out successfully"})
+}
+
+func (a *AuthController) LogoutAllDevices(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, msgutil.UserUnauthorized())
+	}
+
+	if err := a.authService.LogoutAll(userID); err != nil {
+		return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Logged out from all devices successfully"})
+}
+
+func (a *AuthController) ForgotPassword(c echo.Context) error {
+	var req dto.ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	if err := a.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	// same answer whether or not the account exists
+	a.authService.ForgotPassword(req.Email)
+	return c.JSON(http.StatusOK, echo.Map{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+func (a *AuthController) VerifyEmail(c echo.Context) error {
+	var req dto.VerifyEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	if err := a.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	if err := a.authService.VerifyEmail(req.Token); err != nil {
+		if errors.Is(err, errutil.ErrInvalidVerificationToken) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		if errors.Is(err, errutil.ErrEmailAlreadyInUse) {
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Email verified successfully"})
+}
+
+func (a *AuthController) ResendVerification(c echo.Context) error {
+	var req dto.ResendVerificationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	if err := a.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	if err := a.authService.ResendVerification(req.Email); err != nil {
+		if errors.Is(err, errutil.ErrVerificationThrottled) {
+			return c.JSON(http.StatusTooManyRequests, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+	}
+
+	// same answer whether or not the account exists
+	return c.JSON(http.StatusOK, echo.Map{"message": "If the email is registered and not yet verified, a verification link has been sent"})
+}
+
+func (a *AuthController) ResetPassword(c echo.Context) error {
+	var req dto.ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	if err := a.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	if err := a.authService.ResetPassword(req.Token, req.Password); err != nil {
+		if errors.Is(err, errutil.ErrInvalidResetToken) || isPasswordPolicyError(err) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Password has been reset successfully"})
+}
+
+// UnlockAccount lifts a login lockout of a user
+// POST /api/v1/admin/users/:id/unlock
+func (a *AuthController) UnlockAccount(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid user ID"})
+	}
+
+	if err := a.authService.UnlockAccount(getOrganizationIDFromContext(c), uint(userID)); err != nil {
+		if errors.Is(err, errutil.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "User not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Account unlocked successfully"})
+}
+
+// isPasswordPolicyError reports whether a new password was refused by the password policy
+func isPasswordPolicyError(err error) bool {
+	return errutil.Exists(err, []error{errutil.ErrWeakPassword, errutil.ErrBreachedPassword, errutil.ErrPasswordReused})
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -8,22 +9,53 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/rijwanansari/vivaLearning/dto"
 	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
 )
 
 type CourseController struct {
-	CourseService services.CourseService
-	LessonService services.LessonService
-	Validator     *validator.Validate
+	CourseService  services.CourseService
+	LessonService  services.LessonService
+	StaffService   services.CourseStaffService
+	VersionService services.CourseVersionService
+	ReviewService  services.CourseReviewService
+	Validator      *validator.Validate
 }
 
-func NewCourseController(courseService services.CourseService, lessonService services.LessonService) *CourseController {
+func NewCourseController(courseService services.CourseService, lessonService services.LessonService, staffService services.CourseStaffService, versionService services.CourseVersionService, reviewService services.CourseReviewService) *CourseController {
 	return &CourseController{
-		CourseService: courseService,
-		LessonService: lessonService,
-		Validator:     validator.New(),
+		CourseService:  courseService,
+		LessonService:  lessonService,
+		StaffService:   staffService,
+		VersionService: versionService,
+		ReviewService:  reviewService,
+		Validator:      validator.New(),
 	}
 }
 
+// courseService returns the CourseService scoped to the organization of the request,
+// recording its changes with the request in the audit log
+func (cc *CourseController) courseService(c echo.Context) services.CourseService {
+	return cc.CourseService.ForOrganization(getOrganizationIDFromContext(c)).WithRequest(requestInfo(c))
+}
+
+// staffService returns the StaffService scoped to the organization of the request,
+// recording its changes with the request in the audit log
+func (cc *CourseController) staffService(c echo.Context) services.CourseStaffService {
+	return cc.StaffService.ForOrganization(getOrganizationIDFromContext(c)).WithRequest(requestInfo(c))
+}
+
+// versionService returns the CourseVersionService scoped to the organization of the request,
+// recording its changes with the request in the audit log
+func (cc *CourseController) versionService(c echo.Context) services.CourseVersionService {
+	return cc.VersionService.ForOrganization(getOrganizationIDFromContext(c)).WithRequest(requestInfo(c))
+}
+
+// reviewService returns the CourseReviewService scoped to the organization of the request,
+// recording its changes with the request in the audit log
+func (cc *CourseController) reviewService(c echo.Context) services.CourseReviewService {
+	return cc.ReviewService.ForOrganization(getOrganizationIDFromContext(c)).WithRequest(requestInfo(c))
+}
+
 // Course CRUD operations
 
 // CreateCourse creates a new course
@@ -53,7 +85,7 @@ func (cc *CourseController) CreateCourse(c echo.Context) error {
 		})
 	}
 
-	course, err := cc.CourseService.CreateCourse(req, userID)
+	course, err := cc.courseService(c).CreateCourse(req, userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -85,7 +117,7 @@ func (cc *CourseController) GetCourse(c echo.Context) error {
 		userID = &uid
 	}
 
-	course, err := cc.CourseService.GetCourseByID(uint(id), userID)
+	course, err := cc.courseService(c).GetCourseByID(uint(id), userID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, dto.APIResponse{
 			Success: false,
@@ -134,7 +166,7 @@ func (cc *CourseController) UpdateCourse(c echo.Context) error {
 		})
 	}
 
-	course, err := cc.CourseService.UpdateCourse(uint(id), req, userID)
+	course, err := cc.courseService(c).UpdateCourse(uint(id), req, userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -149,7 +181,7 @@ func (cc *CourseController) UpdateCourse(c echo.Context) error {
 	})
 }
 
-// DeleteCourse deletes a course
+// DeleteCourse moves a course to the trash
 // DELETE /api/courses/:id
 func (cc *CourseController) DeleteCourse(c echo.Context) error {
 	idParam := c.Param("id")
@@ -169,7 +201,7 @@ func (cc *CourseController) DeleteCourse(c echo.Context) error {
 		})
 	}
 
-	err = cc.CourseService.DeleteCourse(uint(id), userID)
+	err = cc.courseService(c).DeleteCourse(uint(id), userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -186,7 +218,7 @@ func (cc *CourseController) DeleteCourse(c echo.Context) error {
 // GetAllCourses gets all courses (admin)
 // GET /api/admin/courses
 func (cc *CourseController) GetAllCourses(c echo.Context) error {
-	courses, err := cc.CourseService.GetAllCourses()
+	courses, err := cc.courseService(c).GetAllCourses()
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -208,7 +240,7 @@ func (cc *CourseController) GetPublishedCourses(c echo.Context) error {
 		userID = &uid
 	}
 
-	courses, err := cc.CourseService.GetPublishedCourses(userID)
+	courses, err := cc.courseService(c).GetPublishedCourses(userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -245,7 +277,7 @@ func (cc *CourseController) SearchCourses(c echo.Context) error {
 		userID = &uid
 	}
 
-	result, err := cc.CourseService.SearchCourses(filter, userID)
+	result, err := cc.courseService(c).SearchCourses(filter, userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -259,6 +291,55 @@ func (cc *CourseController) SearchCourses(c echo.Context) error {
 	})
 }
 
+// GetCourseTrash lists the deleted courses the user can restore
+// GET /api/v1/courses/trash
+func (cc *CourseController) GetCourseTrash(c echo.Context) error {
+	courses, err := cc.courseService(c).GetTrash(getUserIDFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    courses,
+	})
+}
+
+// RestoreCourse takes a course out of the trash
+// POST /api/v1/courses/:id/restore
+func (cc *CourseController) RestoreCourse(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	course, err := cc.courseService(c).RestoreCourse(uint(id), getUserIDFromContext(c))
+	if errors.Is(err, errutil.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Error:   "Course not found in the trash",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Course restored successfully",
+		Data:    course,
+	})
+}
+
 // GetMyCourses gets courses created by the authenticated user
 // GET /api/my/courses
 func (cc *CourseController) GetMyCourses(c echo.Context) error {
@@ -270,7 +351,7 @@ func (cc *CourseController) GetMyCourses(c echo.Context) error {
 		})
 	}
 
-	courses, err := cc.CourseService.GetCoursesByCreator(userID)
+	courses, err := cc.courseService(c).GetCoursesByCreator(userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -304,8 +385,11 @@ func (cc *CourseController) EnrollInCourse(c echo.Context) error {
 		})
 	}
 
-	result, err := cc.CourseService.EnrollInCourse(uint(courseID), userID)
+	result, err := cc.courseService(c).EnrollInCourse(uint(courseID), userID)
 	if err != nil {
+		if errors.Is(err, errutil.ErrEmailNotVerified) {
+			return c.JSON(http.StatusForbidden, *result)
+		}
 		return c.JSON(http.StatusInternalServerError, *result)
 	}
 
@@ -332,7 +416,7 @@ func (cc *CourseController) UnenrollFromCourse(c echo.Context) error {
 		})
 	}
 
-	result, err := cc.CourseService.UnenrollFromCourse(uint(courseID), userID)
+	result, err := cc.courseService(c).UnenrollFromCourse(uint(courseID), userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, *result)
 	}
@@ -351,7 +435,7 @@ func (cc *CourseController) GetMyEnrolledCourses(c echo.Context) error {
 		})
 	}
 
-	courses, err := cc.CourseService.GetUserEnrolledCourses(userID)
+	courses, err := cc.courseService(c).GetUserEnrolledCourses(userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -385,7 +469,7 @@ func (cc *CourseController) GetCourseProgress(c echo.Context) error {
 		})
 	}
 
-	progress, err := cc.CourseService.GetUserCourseProgress(uint(courseID), userID)
+	progress, err := cc.courseService(c).GetUserCourseProgress(uint(courseID), userID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, dto.APIResponse{
 			Success: false,
@@ -411,7 +495,15 @@ func (cc *CourseController) GetCourseAnalytics(c echo.Context) error {
 		})
 	}
 
-	analytics, err := cc.CourseService.GetCourseAnalytics(uint(courseID))
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	analytics, err := cc.courseService(c).GetCourseAnalytics(uint(courseID), userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -425,21 +517,572 @@ func (cc *CourseController) GetCourseAnalytics(c echo.Context) error {
 	})
 }
 
-// Helper function to get user ID from context
-func getUserIDFromContext(c echo.Context) uint {
-	// This should be set by your authentication middleware
-	if userID := c.Get("user_id"); userID != nil {
-		if id, ok := userID.(uint); ok {
-			return id
-		}
-		if id, ok := userID.(float64); ok {
-			return uint(id)
-		}
-		if idStr, ok := userID.(string); ok {
-			if id, err := strconv.ParseUint(idStr, 10, 32); err == nil {
-				return uint(id)
-			}
-		}
+// Course staff management
+
+// GetCourseStaff lists the staff of a course
+// GET /api/courses/:id/staff
+func (cc *CourseController) GetCourseStaff(c echo.Context) error {
+	idParam := c.Param("id")
+	courseID, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	staff, err := cc.staffService(c).GetCourseStaff(uint(courseID), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    staff,
+	})
+}
+
+// AddCourseStaff invites a user to the staff of a course
+// POST /api/courses/:id/staff
+func (cc *CourseController) AddCourseStaff(c echo.Context) error {
+	idParam := c.Param("id")
+	courseID, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	var req dto.AddCourseStaffRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if err := cc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	staff, err := cc.staffService(c).AddCourseStaff(uint(courseID), req, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, dto.APIResponse{
+		Success: true,
+		Message: "Staff member added successfully",
+		Data:    staff,
+	})
+}
+
+// RemoveCourseStaff removes a user from the staff of a course
+// DELETE /api/courses/:id/staff/:userId
+func (cc *CourseController) RemoveCourseStaff(c echo.Context) error {
+	idParam := c.Param("id")
+	courseID, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	staffUserID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	err = cc.staffService(c).RemoveCourseStaff(uint(courseID), uint(staffUserID), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Staff member removed successfully",
+	})
+}
+
+// TransferCourseOwnership hands the course over to another instructor
+// POST /api/courses/:id/transfer-ownership
+func (cc *CourseController) TransferCourseOwnership(c echo.Context) error {
+	idParam := c.Param("id")
+	courseID, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	var req dto.TransferOwnershipRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if err := cc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	err = cc.staffService(c).TransferOwnership(uint(courseID), req, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Course ownership transferred successfully",
+	})
+}
+
+// Course versions
+
+// PublishCourseDraft publishes the current draft of a course as its next version
+// POST /api/v1/courses/:id/versions
+func (cc *CourseController) PublishCourseDraft(c echo.Context) error {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	var req dto.PublishVersionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if err := cc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	version, err := cc.versionService(c).PublishDraft(uint(courseID), req, getUserIDFromContext(c))
+	if err != nil {
+		return cc.versionError(c, err, "Course not found")
+	}
+
+	return c.JSON(http.StatusCreated, dto.APIResponse{
+		Success: true,
+		Message: "Course version published successfully",
+		Data:    version,
+	})
+}
+
+// GetCourseVersions lists the published versions of a course, newest first
+// GET /api/v1/courses/:id/versions
+func (cc *CourseController) GetCourseVersions(c echo.Context) error {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	versions, err := cc.versionService(c).GetVersions(uint(courseID), getUserIDFromContext(c))
+	if err != nil {
+		return cc.versionError(c, err, "Course not found")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    versions,
+	})
+}
+
+// GetCourseVersion returns a version with its content
+// GET /api/v1/courses/:id/versions/:version
+func (cc *CourseController) GetCourseVersion(c echo.Context) error {
+	courseID, number, ok := cc.parseVersionParams(c)
+	if !ok {
+		return nil
+	}
+
+	version, err := cc.versionService(c).GetVersion(courseID, number, getUserIDFromContext(c))
+	if err != nil {
+		return cc.versionError(c, err, "Version not found")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    version,
+	})
+}
+
+// DiffCourseVersion lists what a version changed compared to the previous one,
+// or to ?against=<version|draft>
+// GET /api/v1/courses/:id/versions/:version/diff
+func (cc *CourseController) DiffCourseVersion(c echo.Context) error {
+	courseID, number, ok := cc.parseVersionParams(c)
+	if !ok {
+		return nil
+	}
+
+	diff, err := cc.versionService(c).DiffVersion(courseID, number, c.QueryParam("against"), getUserIDFromContext(c))
+	if err != nil {
+		return cc.versionError(c, err, "Version not found")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    diff,
+	})
+}
+
+// RollbackCourseVersion publishes a copy of an earlier version
+// POST /api/v1/courses/:id/versions/:version/rollback
+func (cc *CourseController) RollbackCourseVersion(c echo.Context) error {
+	courseID, number, ok := cc.parseVersionParams(c)
+	if !ok {
+		return nil
+	}
+
+	var req dto.PublishVersionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if err := cc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	version, err := cc.versionService(c).RollbackToVersion(courseID, number, req, getUserIDFromContext(c))
+	if err != nil {
+		return cc.versionError(c, err, "Version not found")
+	}
+
+	return c.JSON(http.StatusCreated, dto.APIResponse{
+		Success: true,
+		Message: "Course rolled back successfully",
+		Data:    version,
+	})
+}
+
+// UpgradeCourseVersion moves the current learner to the published version of a course
+// POST /api/v1/courses/:id/enroll/upgrade
+func (cc *CourseController) UpgradeCourseVersion(c echo.Context) error {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	progress, err := cc.versionService(c).UpgradeToLatestVersion(uint(courseID), getUserIDFromContext(c))
+	if err != nil {
+		return cc.versionError(c, err, "Course not found")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Switched to the latest version of the course",
+		Data:    progress,
+	})
+}
+
+// parseVersionParams reads :id and :version, answering 400 itself when one is invalid
+func (cc *CourseController) parseVersionParams(c echo.Context) (uint, int, bool) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		_ = c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+		return 0, 0, false
+	}
+
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number < 1 {
+		_ = c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid version",
+		})
+		return 0, 0, false
 	}
-	return 0
+
+	return uint(courseID), number, true
+}
+
+// versionError maps course version service errors to responses, notFound is
+// the message for a missing course or version
+func (cc *CourseController) versionError(c echo.Context, err error, notFound string) error {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, errutil.ErrRecordNotFound):
+		status = http.StatusNotFound
+		err = errors.New(notFound)
+	case errors.Is(err, errutil.ErrInvalidInput), errors.Is(err, errutil.ErrNotEnrolled):
+		status = http.StatusBadRequest
+	case errors.Is(err, errutil.ErrOwnCourseReview):
+		status = http.StatusForbidden
+	}
+
+	return c.JSON(status, dto.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
+
+// Course review workflow
+
+// SubmitCourseForReview sends a draft course to the reviewers
+// POST /api/v1/courses/:id/submit
+func (cc *CourseController) SubmitCourseForReview(c echo.Context) error {
+	return cc.reviewStep(c, "Course submitted for review", cc.reviewService(c).SubmitForReview)
+}
+
+// ApproveCourse approves a course in review so it can be published
+// POST /api/v1/courses/:id/approve
+func (cc *CourseController) ApproveCourse(c echo.Context) error {
+	return cc.reviewStep(c, "Course approved", cc.reviewService(c).Approve)
+}
+
+// RejectCourse sends a course in review back to draft with the changes to make
+// POST /api/v1/courses/:id/reject
+func (cc *CourseController) RejectCourse(c echo.Context) error {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	var req dto.RejectCourseRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if err := cc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	review, err := cc.reviewService(c).Reject(uint(courseID), req, getUserIDFromContext(c))
+	if err != nil {
+		return cc.reviewError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Course sent back to draft",
+		Data:    review,
+	})
+}
+
+// PublishCourse puts an approved course in the catalog
+// POST /api/v1/courses/:id/publish
+func (cc *CourseController) PublishCourse(c echo.Context) error {
+	return cc.reviewStep(c, "Course published successfully", cc.reviewService(c).Publish)
+}
+
+// ArchiveCourse takes a published course out of the catalog
+// POST /api/v1/courses/:id/archive
+func (cc *CourseController) ArchiveCourse(c echo.Context) error {
+	return cc.reviewStep(c, "Course archived successfully", cc.reviewService(c).Archive)
+}
+
+// ScheduleCourse sets when a course is published once approved and when it is archived
+// PUT /api/v1/courses/:id/schedule
+func (cc *CourseController) ScheduleCourse(c echo.Context) error {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	var req dto.PublishScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	schedule, err := cc.reviewService(c).Schedule(uint(courseID), req, getUserIDFromContext(c))
+	if err != nil {
+		return cc.reviewError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Course schedule updated successfully",
+		Data:    schedule,
+	})
+}
+
+// GetCourseReviews returns the review history of a course with the reviewer comments
+// GET /api/v1/courses/:id/reviews
+func (cc *CourseController) GetCourseReviews(c echo.Context) error {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	reviews, err := cc.reviewService(c).GetReviews(uint(courseID), getUserIDFromContext(c))
+	if err != nil {
+		return cc.reviewError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    reviews,
+	})
+}
+
+// GetReviewQueue lists the courses waiting for review
+// GET /api/v1/admin/courses/review-queue
+func (cc *CourseController) GetReviewQueue(c echo.Context) error {
+	queue, err := cc.reviewService(c).GetReviewQueue()
+	if err != nil {
+		return cc.reviewError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    queue,
+	})
+}
+
+// reviewStep runs a review workflow step that takes an optional comment
+func (cc *CourseController) reviewStep(c echo.Context, message string, step func(uint, dto.CourseReviewRequest, uint) (*dto.CourseReviewResponse, error)) error {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	var req dto.CourseReviewRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if err := cc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	review, err := step(uint(courseID), req, getUserIDFromContext(c))
+	if err != nil {
+		return cc.reviewError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    review,
+	})
+}
+
+// reviewError maps course review service errors to responses
+func (cc *CourseController) reviewError(c echo.Context, err error) error {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, errutil.ErrRecordNotFound):
+		status = http.StatusNotFound
+		err = errors.New("Course not found")
+	case errors.Is(err, errutil.ErrInvalidCourseStatus):
+		status = http.StatusConflict
+	case errors.Is(err, errutil.ErrOwnCourseReview):
+		status = http.StatusForbidden
+	case errors.Is(err, errutil.ErrInvalidInput):
+		status = http.StatusBadRequest
+	}
+
+	return c.JSON(status, dto.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
 }
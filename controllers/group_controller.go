@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+type GroupController struct {
+	GroupService services.GroupService
+	Validator    *validator.Validate
+}
+
+func NewGroupController(groupService services.GroupService) *GroupController {
+	return &GroupController{
+		GroupService: groupService,
+		Validator:    validator.New(),
+	}
+}
+
+// groupService returns the GroupService scoped to the organization of the request,
+// recording its changes with the request in the audit log
+func (gc *GroupController) groupService(c echo.Context) services.GroupService {
+	return gc.GroupService.ForOrganization(getOrganizationIDFromContext(c)).WithRequest(requestInfo(c))
+}
+
+// GetGroups lists the provisioned groups with their courses
+// GET /api/v1/admin/groups
+func (gc *GroupController) GetGroups(c echo.Context) error {
+	groups, err := gc.groupService(c).GetGroups()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   "Failed to retrieve groups",
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Groups retrieved successfully",
+		Data:    groups,
+	})
+}
+
+// AddGroupCourse maps a course to a group and enrolls all of its members.
+// Members joining the group later are enrolled when they are provisioned.
+// POST /api/v1/admin/groups/:id/courses
+func (gc *GroupController) AddGroupCourse(c echo.Context) error {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid group ID",
+		})
+	}
+
+	var req dto.AddGroupCourseRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+	}
+
+	if err := gc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	enrollment, err := gc.groupService(c).AddGroupCourse(uint(groupID), req.CourseID, getUserIDFromContext(c))
+	if err != nil {
+		if errors.Is(err, errutil.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, dto.APIResponse{
+				Success: false,
+				Error:   "Group or course not found",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   "Failed to add course to group",
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Course added to group successfully",
+		Data:    enrollment,
+	})
+}
+
+// RemoveGroupCourse unmaps a course from a group, existing enrollments are kept
+// DELETE /api/v1/admin/groups/:id/courses/:courseId
+func (gc *GroupController) RemoveGroupCourse(c echo.Context) error {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid group ID",
+		})
+	}
+
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	if err := gc.groupService(c).RemoveGroupCourse(uint(groupID), uint(courseID), getUserIDFromContext(c)); err != nil {
+		if errors.Is(err, errutil.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, dto.APIResponse{
+				Success: false,
+				Error:   "Group or course not found",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   "Failed to remove course from group",
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Course removed from group successfully",
+	})
+}
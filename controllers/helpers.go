@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/types"
+)
+
+// Helper function to get user ID from context
+func getUserIDFromContext(c echo.Context) uint {
+	// This should be set by your authentication middleware
+	if userID := c.Get("user_id"); userID != nil {
+		if id, ok := userID.(uint); ok {
+			return id
+		}
+		if id, ok := userID.(float64); ok {
+			return uint(id)
+		}
+		if idStr, ok := userID.(string); ok {
+			if id, err := strconv.ParseUint(idStr, 10, 32); err == nil {
+				return uint(id)
+			}
+		}
+	}
+	return 0
+}
+
+// getOrganizationIDFromContext returns the organization the request is scoped
+// to, set by the Tenant and Auth middlewares
+func getOrganizationIDFromContext(c echo.Context) uint {
+	if id, ok := c.Get("organization_id").(uint); ok {
+		return id
+	}
+	return 0
+}
+
+// getImpersonatorIDFromContext returns the admin behind an impersonated request, 0 otherwise
+func getImpersonatorIDFromContext(c echo.Context) uint {
+	if id, ok := c.Get("impersonator_id").(uint); ok {
+		return id
+	}
+	return 0
+}
+
+// clientInfo describes the client of the request for session records
+func clientInfo(c echo.Context) types.ClientInfo {
+	return types.ClientInfo{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
+}
+
+// requestInfo describes the request for audit log entries
+func requestInfo(c echo.Context) types.RequestInfo {
+	return types.RequestInfo{
+		ImpersonatorID: getImpersonatorIDFromContext(c),
+		IP:             c.RealIP(),
+		UserAgent:      c.Request().UserAgent(),
+		RequestID:      c.Request().Header.Get(echo.HeaderXRequestID),
+		Method:         c.Request().Method,
+		Path:           c.Request().URL.Path,
+	}
+}
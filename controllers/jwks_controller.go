@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/domain"
+)
+
+type JwksController struct {
+	tokenService domain.TokenService
+}
+
+func NewJwksController(tokenService domain.TokenService) *JwksController {
+	return &JwksController{
+		tokenService: tokenService,
+	}
+}
+
+// GetJWKS publishes the public verification keys so that other services can
+// validate our tokens without sharing a secret
+// GET /.well-known/jwks.json
+func (jc *JwksController) GetJWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, jc.tokenService.JWKS())
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/rijwanansari/vivaLearning/dto"
 	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
 )
 
 type LessonController struct {
@@ -22,6 +24,12 @@ func NewLessonController(lessonService services.LessonService) *LessonController
 	}
 }
 
+// lessonService returns the LessonService scoped to the organization of the request,
+// recording its changes with the request in the audit log
+func (lc *LessonController) lessonService(c echo.Context) services.LessonService {
+	return lc.LessonService.ForOrganization(getOrganizationIDFromContext(c)).WithRequest(requestInfo(c))
+}
+
 // CreateLesson creates a new lesson for a course
 // POST /api/courses/:courseId/lessons
 func (lc *LessonController) CreateLesson(c echo.Context) error {
@@ -57,7 +65,13 @@ func (lc *LessonController) CreateLesson(c echo.Context) error {
 		})
 	}
 
-	lesson, err := lc.LessonService.CreateLesson(uint(courseID), req, userID)
+	lesson, err := lc.lessonService(c).CreateLesson(uint(courseID), req, userID)
+	if errors.Is(err, errutil.ErrNotInCourse) {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -89,7 +103,7 @@ func (lc *LessonController) GetLesson(c echo.Context) error {
 		userID = &uid
 	}
 
-	lesson, err := lc.LessonService.GetLessonByID(uint(id), userID)
+	lesson, err := lc.lessonService(c).GetLessonByID(uint(id), userID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, dto.APIResponse{
 			Success: false,
@@ -138,7 +152,13 @@ func (lc *LessonController) UpdateLesson(c echo.Context) error {
 		})
 	}
 
-	lesson, err := lc.LessonService.UpdateLesson(uint(id), req, userID)
+	lesson, err := lc.lessonService(c).UpdateLesson(uint(id), req, userID)
+	if errors.Is(err, errutil.ErrNotInCourse) {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -153,7 +173,47 @@ func (lc *LessonController) UpdateLesson(c echo.Context) error {
 	})
 }
 
-// DeleteLesson deletes a lesson
+// ScheduleLesson sets when a lesson is published and unpublished
+// PUT /api/v1/lessons/:id/schedule
+func (lc *LessonController) ScheduleLesson(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid lesson ID",
+		})
+	}
+
+	var req dto.PublishScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	schedule, err := lc.lessonService(c).ScheduleLesson(uint(id), req, getUserIDFromContext(c))
+	if errors.Is(err, errutil.ErrInvalidInput) {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Lesson schedule updated successfully",
+		Data:    schedule,
+	})
+}
+
+// DeleteLesson moves a lesson to the trash
 // DELETE /api/lessons/:id
 func (lc *LessonController) DeleteLesson(c echo.Context) error {
 	idParam := c.Param("id")
@@ -173,7 +233,7 @@ func (lc *LessonController) DeleteLesson(c echo.Context) error {
 		})
 	}
 
-	err = lc.LessonService.DeleteLesson(uint(id), userID)
+	err = lc.lessonService(c).DeleteLesson(uint(id), userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -187,6 +247,69 @@ func (lc *LessonController) DeleteLesson(c echo.Context) error {
 	})
 }
 
+// GetLessonTrash lists the deleted lessons of a course
+// GET /api/v1/courses/:courseId/lessons/trash
+func (lc *LessonController) GetLessonTrash(c echo.Context) error {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	lessons, err := lc.lessonService(c).GetLessonTrash(uint(courseID), getUserIDFromContext(c))
+	if errors.Is(err, errutil.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Error:   "Course not found",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    lessons,
+	})
+}
+
+// RestoreLesson takes a lesson out of the trash
+// POST /api/v1/lessons/:id/restore
+func (lc *LessonController) RestoreLesson(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid lesson ID",
+		})
+	}
+
+	lesson, err := lc.lessonService(c).RestoreLesson(uint(id), getUserIDFromContext(c))
+	if errors.Is(err, errutil.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Error:   "Lesson not found in the trash",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Lesson restored successfully",
+		Data:    lesson,
+	})
+}
+
 // GetCourseLessons gets all lessons for a course
 // GET /api/courses/:courseId/lessons
 func (lc *LessonController) GetCourseLessons(c echo.Context) error {
@@ -204,7 +327,13 @@ func (lc *LessonController) GetCourseLessons(c echo.Context) error {
 		userID = &uid
 	}
 
-	lessons, err := lc.LessonService.GetLessonsByCourse(uint(courseID), userID)
+	lessons, err := lc.lessonService(c).GetLessonsByCourse(uint(courseID), userID)
+	if errors.Is(err, errutil.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Error:   "Course not found",
+		})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -230,7 +359,13 @@ func (lc *LessonController) GetFreeCourseLessons(c echo.Context) error {
 		})
 	}
 
-	lessons, err := lc.LessonService.GetFreeLessonsByCourse(uint(courseID))
+	lessons, err := lc.lessonService(c).GetFreeLessonsByCourse(uint(courseID))
+	if errors.Is(err, errutil.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Error:   "Course not found",
+		})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -244,7 +379,8 @@ func (lc *LessonController) GetFreeCourseLessons(c echo.Context) error {
 	})
 }
 
-// ReorderLessons reorders lessons in a course
+// ReorderLessons reorders lessons in a course, moving them to another section
+// when an item has a section_id (0 takes the lesson out of its section)
 // PUT /api/courses/:courseId/lessons/reorder
 func (lc *LessonController) ReorderLessons(c echo.Context) error {
 	courseIDParam := c.Param("courseId")
@@ -256,11 +392,7 @@ func (lc *LessonController) ReorderLessons(c echo.Context) error {
 		})
 	}
 
-	var req []struct {
-		LessonID uint `json:"lesson_id" validate:"required"`
-		Sequence int  `json:"sequence" validate:"required,min=1"`
-	}
-
+	var req []dto.ReorderLessonRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
@@ -285,23 +417,13 @@ func (lc *LessonController) ReorderLessons(c echo.Context) error {
 		})
 	}
 
-	// Convert to the expected type
-	var lessonSequences []struct {
-		LessonID uint `json:"lesson_id"`
-		Sequence int  `json:"sequence"`
-	}
-
-	for _, item := range req {
-		lessonSequences = append(lessonSequences, struct {
-			LessonID uint `json:"lesson_id"`
-			Sequence int  `json:"sequence"`
-		}{
-			LessonID: item.LessonID,
-			Sequence: item.Sequence,
+	err = lc.lessonService(c).ReorderLessons(uint(courseID), req, userID)
+	if errors.Is(err, errutil.ErrNotInCourse) {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
 		})
 	}
-
-	err = lc.LessonService.ReorderLessons(uint(courseID), lessonSequences, userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -341,7 +463,7 @@ func (lc *LessonController) UpdateLessonProgress(c echo.Context) error {
 		})
 	}
 
-	result, err := lc.LessonService.UpdateLessonProgress(userID, req)
+	result, err := lc.lessonService(c).UpdateLessonProgress(userID, req)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, *result)
 	}
@@ -387,7 +509,7 @@ func (lc *LessonController) MarkLessonCompleted(c echo.Context) error {
 		})
 	}
 
-	result, err := lc.LessonService.MarkLessonCompleted(userID, uint(lessonID), req.WatchTime)
+	result, err := lc.lessonService(c).MarkLessonCompleted(userID, uint(lessonID), req.WatchTime)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, *result)
 	}
@@ -415,7 +537,7 @@ func (lc *LessonController) GetUserLessonProgress(c echo.Context) error {
 		})
 	}
 
-	lessons, err := lc.LessonService.GetUserLessonProgress(userID, uint(courseID))
+	lessons, err := lc.lessonService(c).GetUserLessonProgress(userID, uint(courseID))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/msgutil"
+)
+
+type MFAController struct {
+	mfaService services.MFAService
+	validator  *validator.Validate
+}
+
+func NewMFAController(mfaService services.MFAService) *MFAController {
+	return &MFAController{
+		mfaService: mfaService,
+		validator:  validator.New(),
+	}
+}
+
+// VerifyMFA exchanges an MFA challenge and a TOTP or recovery code for a token
+// POST /api/v1/auth/mfa/verify
+func (m *MFAController) VerifyMFA(c echo.Context) error {
+	var req dto.MFAVerifyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	if err := m.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	token, err := m.mfaService.VerifyChallenge(req.ChallengeToken, req.Code, clientInfo(c))
+	if err != nil {
+		return m.error(c, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"token": token})
+}
+
+// SetupMFAWithChallenge starts the enrollment of a user who must use MFA before logging in
+// POST /api/v1/auth/mfa/setup
+func (m *MFAController) SetupMFAWithChallenge(c echo.Context) error {
+	var req dto.MFAChallengeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	if err := m.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	setup, err := m.mfaService.BeginChallengeSetup(req.ChallengeToken)
+	if err != nil {
+		return m.error(c, err)
+	}
+
+	return c.JSON(http.StatusOK, setup)
+}
+
+// ConfirmMFAWithChallenge completes a forced enrollment and returns the token
+// together with the recovery codes
+// POST /api/v1/auth/mfa/confirm
+func (m *MFAController) ConfirmMFAWithChallenge(c echo.Context) error {
+	var req dto.MFAVerifyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	if err := m.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	enrollment, err := m.mfaService.ConfirmChallengeSetup(req.ChallengeToken, req.Code, clientInfo(c))
+	if err != nil {
+		return m.error(c, err)
+	}
+
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// SetupMFA generates a TOTP secret and otpauth URI for the current user
+// POST /api/v1/my/mfa/setup
+func (m *MFAController) SetupMFA(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, msgutil.UserUnauthorized())
+	}
+
+	setup, err := m.mfaService.BeginSetup(userID)
+	if err != nil {
+		return m.error(c, err)
+	}
+
+	return c.JSON(http.StatusOK, setup)
+}
+
+// ConfirmMFA enables MFA with a code from the authenticator app and returns the recovery codes
+// POST /api/v1/my/mfa/confirm
+func (m *MFAController) ConfirmMFA(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, msgutil.UserUnauthorized())
+	}
+
+	var req dto.MFACodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	if err := m.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	enrollment, err := m.mfaService.ConfirmSetup(userID, req.Code)
+	if err != nil {
+		return m.error(c, err)
+	}
+
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// DisableMFA turns MFA off after a valid TOTP or recovery code
+// POST /api/v1/my/mfa/disable
+func (m *MFAController) DisableMFA(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, msgutil.UserUnauthorized())
+	}
+
+	var req dto.MFACodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	if err := m.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	if err := m.mfaService.Disable(userID, req.Code); err != nil {
+		return m.error(c, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"message": "Two-factor authentication disabled"})
+}
+
+// error maps MFA service errors to responses
+func (m *MFAController) error(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errutil.ErrTooManyLoginAttempts), errors.Is(err, errutil.ErrAccountLocked):
+		return c.JSON(http.StatusTooManyRequests, echo.Map{"error": err.Error()})
+	case errors.Is(err, errutil.ErrInvalidMFACode), errors.Is(err, errutil.ErrInvalidMFAChallenge):
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
+	case errors.Is(err, errutil.ErrMFARequired):
+		return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+	case errors.Is(err, errutil.ErrMFAAlreadyEnabled), errors.Is(err, errutil.ErrMFANotEnabled), errors.Is(err, errutil.ErrMFASetupNotStarted):
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	case errors.Is(err, errutil.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "User not found"})
+	}
+
+	return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/msgutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+type OIDCController struct {
+	oidcService services.OIDCService
+	validator   *validator.Validate
+}
+
+func NewOIDCController(oidcService services.OIDCService) *OIDCController {
+	return &OIDCController{
+		oidcService: oidcService,
+		validator:   validator.New(),
+	}
+}
+
+// AuthorizeSSO returns the IdP URL the browser has to be sent to
+// GET /api/v1/auth/oidc/authorize
+func (o *OIDCController) AuthorizeSSO(c echo.Context) error {
+	authorization, err := o.oidcService.Authorize(getOrganizationIDFromContext(c))
+	if err != nil {
+		return o.error(c, err)
+	}
+
+	return c.JSON(http.StatusOK, authorization)
+}
+
+// SSOCallback exchanges the code and state the IdP redirected back with for a token
+// POST /api/v1/auth/oidc/callback
+func (o *OIDCController) SSOCallback(c echo.Context) error {
+	var req dto.OIDCCallbackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid input"})
+	}
+
+	if err := o.validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	token, challenge, err := o.oidcService.Callback(req.Code, req.State, clientInfo(c))
+	if err != nil {
+		return o.error(c, err)
+	}
+
+	if challenge != nil {
+		return c.JSON(http.StatusOK, echo.Map{"mfa_required": true, "mfa": challenge})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"token": token})
+}
+
+func (o *OIDCController) error(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errutil.ErrOIDCNotConfigured):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": errutil.ErrOIDCNotConfigured.Error()})
+	case errors.Is(err, errutil.ErrOIDCProvider):
+		logger.Error(err)
+		return c.JSON(http.StatusBadGateway, echo.Map{"error": errutil.ErrOIDCProvider.Error()})
+	case errors.Is(err, errutil.ErrInvalidIDToken):
+		logger.Error(err)
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": errutil.ErrInvalidIDToken.Error()})
+	case errors.Is(err, errutil.ErrInvalidOIDCState), errors.Is(err, errutil.ErrInvalidLoginCredentials):
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
+	case errors.Is(err, errutil.ErrOIDCSignupDisabled), errors.Is(err, errutil.ErrOIDCEmailNotVerified),
+		errors.Is(err, errutil.ErrAccountSuspended):
+		return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+	}
+
+	logger.Error(err)
+	return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+}
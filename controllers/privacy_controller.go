@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+type PrivacyController struct {
+	PrivacyService services.PrivacyService
+}
+
+func NewPrivacyController(privacyService services.PrivacyService) *PrivacyController {
+	return &PrivacyController{
+		PrivacyService: privacyService,
+	}
+}
+
+// privacyService returns the PrivacyService scoped to the organization of the request,
+// recording its changes with the request in the audit log
+func (pc *PrivacyController) privacyService(c echo.Context) services.PrivacyService {
+	return pc.PrivacyService.ForOrganization(getOrganizationIDFromContext(c)).WithRequest(requestInfo(c))
+}
+
+// GetDataExport downloads the archive of everything stored about the current
+// user. The archive is built in the background, until it is ready the state of
+// the export is returned with 202 Accepted.
+// GET /api/v1/my/data-export
+func (pc *PrivacyController) GetDataExport(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	// the data of a user is handed to the user only, not to an admin acting as them
+	if getImpersonatorIDFromContext(c) != 0 {
+		return c.JSON(http.StatusForbidden, dto.APIResponse{
+			Success: false,
+			Error:   "Data exports cannot be requested while impersonating",
+		})
+	}
+
+	export, archive, err := pc.privacyService(c).GetDataExport(userID)
+	if err != nil {
+		return pc.error(c, err, "Failed to export data")
+	}
+
+	if archive == nil {
+		return c.JSON(http.StatusAccepted, dto.APIResponse{
+			Success: true,
+			Message: "Your data export is being prepared, check back later to download it",
+			Data:    export,
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("data-export-%d.zip", userID)))
+	return c.Blob(http.StatusOK, "application/zip", archive)
+}
+
+// EraseMyAccount erases the personal data of the current user and closes the account
+// POST /api/v1/my/erasure
+func (pc *PrivacyController) EraseMyAccount(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	var req dto.EraseAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+	}
+
+	if err := pc.privacyService(c).EraseMyAccount(userID, req); err != nil {
+		return pc.error(c, err, "Failed to erase account")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Your personal data has been erased and your account closed",
+	})
+}
+
+// EraseUser erases the personal data of a user on their request
+// POST /api/v1/admin/users/:id/erase
+func (pc *PrivacyController) EraseUser(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	if err := pc.privacyService(c).EraseUser(uint(userID), getUserIDFromContext(c)); err != nil {
+		return pc.error(c, err, "Failed to erase user")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Personal data of the user erased successfully",
+	})
+}
+
+// error maps privacy service errors to responses
+func (pc *PrivacyController) error(c echo.Context, err error, fallback string) error {
+	status := http.StatusInternalServerError
+	message := fallback
+
+	switch {
+	case errors.Is(err, errutil.ErrRecordNotFound):
+		status, message = http.StatusNotFound, "User not found"
+	case errors.Is(err, errutil.ErrInvalidCurrentPassword):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, errutil.ErrCannotModifySelf), errors.Is(err, errutil.ErrCannotErase):
+		status, message = http.StatusForbidden, err.Error()
+	}
+
+	return c.JSON(status, dto.APIResponse{
+		Success: false,
+		Error:   message,
+	})
+}
@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/middlewares"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+const scimContentType = "application/scim+json"
+
+type SCIMController struct {
+	SCIMService services.SCIMService
+}
+
+func NewSCIMController(scimService services.SCIMService) *SCIMController {
+	return &SCIMController{
+		SCIMService: scimService,
+	}
+}
+
+// scimService returns the SCIMService scoped to the organization of the request,
+// recording its changes with the request in the audit log
+func (sc *SCIMController) scimService(c echo.Context) services.SCIMService {
+	return sc.SCIMService.ForOrganization(getOrganizationIDFromContext(c)).WithRequest(requestInfo(c))
+}
+
+// GetServiceProviderConfig describes the supported SCIM features
+// GET /scim/v2/ServiceProviderConfig
+func (sc *SCIMController) GetServiceProviderConfig(c echo.Context) error {
+	return sc.json(c, http.StatusOK, echo.Map{
+		"schemas":        []string{dto.SCIMSPConfigSchema},
+		"patch":          echo.Map{"supported": true},
+		"bulk":           echo.Map{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         echo.Map{"supported": true, "maxResults": config.SCIM().MaxResults},
+		"changePassword": echo.Map{"supported": false},
+		"sort":           echo.Map{"supported": false},
+		"etag":           echo.Map{"supported": false},
+		"authenticationSchemes": []echo.Map{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Bearer token of the organization, issued with `organizations scim-token`",
+		}},
+	})
+}
+
+// GetResourceTypes lists the provisioned resource types
+// GET /scim/v2/ResourceTypes
+func (sc *SCIMController) GetResourceTypes(c echo.Context) error {
+	resources := []interface{}{
+		echo.Map{"schemas": []string{dto.SCIMResourceSchema}, "id": "User", "name": "User", "endpoint": "/Users", "schema": dto.SCIMUserSchema},
+		echo.Map{"schemas": []string{dto.SCIMResourceSchema}, "id": "Group", "name": "Group", "endpoint": "/Groups", "schema": dto.SCIMGroupSchema},
+	}
+
+	return sc.json(c, http.StatusOK, dto.SCIMListResponse{
+		Schemas:      []string{dto.SCIMListSchema},
+		TotalResults: int64(len(resources)),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// ListUsers lists users matching the optional filter
+// GET /scim/v2/Users
+func (sc *SCIMController) ListUsers(c echo.Context) error {
+	req, err := listRequest(c)
+	if err != nil {
+		return middlewares.SCIMError(c, http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	list, err := sc.scimService(c).ListUsers(req)
+	if err != nil {
+		return sc.error(c, err)
+	}
+
+	return sc.json(c, http.StatusOK, list)
+}
+
+// GetUser returns a single user
+// GET /scim/v2/Users/:id
+func (sc *SCIMController) GetUser(c echo.Context) error {
+	user, err := sc.scimService(c).GetUser(c.Param("id"))
+	if err != nil {
+		return sc.error(c, err)
+	}
+
+	return sc.json(c, http.StatusOK, user)
+}
+
+// CreateUser provisions a user
+// POST /scim/v2/Users
+func (sc *SCIMController) CreateUser(c echo.Context) error {
+	var resource dto.SCIMUser
+	if err := decodeSCIM(c, &resource); err != nil {
+		return middlewares.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Invalid JSON body")
+	}
+
+	user, err := sc.scimService(c).CreateUser(resource)
+	if err != nil {
+		return sc.error(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, user.Meta.Location)
+	return sc.json(c, http.StatusCreated, user)
+}
+
+// ReplaceUser replaces the attributes of a user
+// PUT /scim/v2/Users/:id
+func (sc *SCIMController) ReplaceUser(c echo.Context) error {
+	var resource dto.SCIMUser
+	if err := decodeSCIM(c, &resource); err != nil {
+		return middlewares.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Invalid JSON body")
+	}
+
+	user, err := sc.scimService(c).ReplaceUser(c.Param("id"), resource)
+	if err != nil {
+		return sc.error(c, err)
+	}
+
+	return sc.json(c, http.StatusOK, user)
+}
+
+// PatchUser applies a PatchOp to a user, typically to (de)activate it
+// PATCH /scim/v2/Users/:id
+func (sc *SCIMController) PatchUser(c echo.Context) error {
+	var req dto.SCIMPatchRequest
+	if err := decodeSCIM(c, &req); err != nil {
+		return middlewares.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Invalid JSON body")
+	}
+
+	user, err := sc.scimService(c).PatchUser(c.Param("id"), req)
+	if err != nil {
+		return sc.error(c, err)
+	}
+
+	return sc.json(c, http.StatusOK, user)
+}
+
+// DeleteUser deprovisions a user, the account is deactivated and not deleted
+// DELETE /scim/v2/Users/:id
+func (sc *SCIMController) DeleteUser(c echo.Context) error {
+	if err := sc.scimService(c).DeleteUser(c.Param("id")); err != nil {
+		return sc.error(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListGroups lists groups matching the optional filter
+// GET /scim/v2/Groups
+func (sc *SCIMController) ListGroups(c echo.Context) error {
+	req, err := listRequest(c)
+	if err != nil {
+		return middlewares.SCIMError(c, http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	list, err := sc.scimService(c).ListGroups(req)
+	if err != nil {
+		return sc.error(c, err)
+	}
+
+	return sc.json(c, http.StatusOK, list)
+}
+
+// GetGroup returns a single group with its members
+// GET /scim/v2/Groups/:id
+func (sc *SCIMController) GetGroup(c echo.Context) error {
+	group, err := sc.scimService(c).GetGroup(c.Param("id"))
+	if err != nil {
+		return sc.error(c, err)
+	}
+
+	return sc.json(c, http.StatusOK, group)
+}
+
+// CreateGroup provisions a group
+// POST /scim/v2/Groups
+func (sc *SCIMController) CreateGroup(c echo.Context) error {
+	var resource dto.SCIMGroup
+	if err := decodeSCIM(c, &resource); err != nil {
+		return middlewares.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Invalid JSON body")
+	}
+
+	group, err := sc.scimService(c).CreateGroup(resource)
+	if err != nil {
+		return sc.error(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, group.Meta.Location)
+	return sc.json(c, http.StatusCreated, group)
+}
+
+// ReplaceGroup replaces the name and the members of a group
+// PUT /scim/v2/Groups/:id
+func (sc *SCIMController) ReplaceGroup(c echo.Context) error {
+	var resource dto.SCIMGroup
+	if err := decodeSCIM(c, &resource); err != nil {
+		return middlewares.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Invalid JSON body")
+	}
+
+	group, err := sc.scimService(c).ReplaceGroup(c.Param("id"), resource)
+	if err != nil {
+		return sc.error(c, err)
+	}
+
+	return sc.json(c, http.StatusOK, group)
+}
+
+// PatchGroup applies a PatchOp to a group, typically to add or remove members
+// PATCH /scim/v2/Groups/:id
+func (sc *SCIMController) PatchGroup(c echo.Context) error {
+	var req dto.SCIMPatchRequest
+	if err := decodeSCIM(c, &req); err != nil {
+		return middlewares.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Invalid JSON body")
+	}
+
+	group, err := sc.scimService(c).PatchGroup(c.Param("id"), req)
+	if err != nil {
+		return sc.error(c, err)
+	}
+
+	return sc.json(c, http.StatusOK, group)
+}
+
+// DeleteGroup deletes a group, the enrollments of its members are kept
+// DELETE /scim/v2/Groups/:id
+func (sc *SCIMController) DeleteGroup(c echo.Context) error {
+	if err := sc.scimService(c).DeleteGroup(c.Param("id")); err != nil {
+		return sc.error(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// json writes a SCIM response, echo keeps a content type that is already set
+func (sc *SCIMController) json(c echo.Context, status int, body interface{}) error {
+	c.Response().Header().Set(echo.HeaderContentType, scimContentType)
+	return c.JSON(status, body)
+}
+
+// error maps SCIM service errors to SCIM error responses
+func (sc *SCIMController) error(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errutil.ErrRecordNotFound):
+		return middlewares.SCIMError(c, http.StatusNotFound, "", "Resource not found")
+	case errors.Is(err, errutil.ErrInvalidSCIMFilter):
+		return middlewares.SCIMError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+	case errors.Is(err, errutil.ErrUserIsAlreadyExists), errors.Is(err, errutil.ErrEmailAlreadyInUse), errors.Is(err, errutil.ErrGroupAlreadyExists):
+		return middlewares.SCIMError(c, http.StatusConflict, "uniqueness", err.Error())
+	case errors.Is(err, errutil.ErrInvalidInput):
+		return middlewares.SCIMError(c, http.StatusBadRequest, "invalidValue", err.Error())
+	case errors.Is(err, errutil.ErrLastAdmin), errors.Is(err, errutil.ErrUserErased):
+		return middlewares.SCIMError(c, http.StatusBadRequest, "mutability", err.Error())
+	}
+
+	logger.Error(err)
+	return middlewares.SCIMError(c, http.StatusInternalServerError, "", "Something went wrong")
+}
+
+// decodeSCIM reads the body directly, echo's binder does not accept the
+// application/scim+json content type
+func decodeSCIM(c echo.Context, v interface{}) error {
+	return json.NewDecoder(c.Request().Body).Decode(v)
+}
+
+func listRequest(c echo.Context) (dto.SCIMListRequest, error) {
+	req := dto.SCIMListRequest{Filter: c.QueryParam("filter"), StartIndex: 1}
+
+	if value := c.QueryParam("startIndex"); value != "" {
+		startIndex, err := strconv.Atoi(value)
+		if err != nil {
+			return req, errors.New("startIndex must be a number")
+		}
+		req.StartIndex = startIndex
+	}
+
+	if value := c.QueryParam("count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return req, errors.New("count must be a number")
+		}
+		req.Count = &count
+	}
+
+	return req, nil
+}
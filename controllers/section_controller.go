@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+type SectionController struct {
+	SectionService services.SectionService
+	Validator      *validator.Validate
+}
+
+func NewSectionController(sectionService services.SectionService) *SectionController {
+	return &SectionController{
+		SectionService: sectionService,
+		Validator:      validator.New(),
+	}
+}
+
+// sectionService returns the SectionService scoped to the organization of the request,
+// recording its changes with the request in the audit log
+func (sc *SectionController) sectionService(c echo.Context) services.SectionService {
+	return sc.SectionService.ForOrganization(getOrganizationIDFromContext(c)).WithRequest(requestInfo(c))
+}
+
+// CreateSection creates a new section in a course
+// POST /api/v1/courses/:courseId/sections
+func (sc *SectionController) CreateSection(c echo.Context) error {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	var req dto.CreateSectionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if err := sc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	section, err := sc.sectionService(c).CreateSection(uint(courseID), req, getUserIDFromContext(c))
+	if err != nil {
+		return sc.error(c, err, "Course not found")
+	}
+
+	return c.JSON(http.StatusCreated, dto.APIResponse{
+		Success: true,
+		Message: "Section created successfully",
+		Data:    section,
+	})
+}
+
+// UpdateSection updates a section
+// PUT /api/v1/sections/:id
+func (sc *SectionController) UpdateSection(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid section ID",
+		})
+	}
+
+	var req dto.UpdateSectionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if err := sc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	section, err := sc.sectionService(c).UpdateSection(uint(id), req, getUserIDFromContext(c))
+	if err != nil {
+		return sc.error(c, err, "Section not found")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Section updated successfully",
+		Data:    section,
+	})
+}
+
+// DeleteSection deletes an empty section
+// DELETE /api/v1/sections/:id
+func (sc *SectionController) DeleteSection(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid section ID",
+		})
+	}
+
+	if err := sc.sectionService(c).DeleteSection(uint(id), getUserIDFromContext(c)); err != nil {
+		return sc.error(c, err, "Section not found")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Section deleted successfully",
+	})
+}
+
+// ReorderSections reorders the sections of a course
+// PUT /api/v1/courses/:courseId/sections/reorder
+func (sc *SectionController) ReorderSections(c echo.Context) error {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid course ID",
+		})
+	}
+
+	var req []dto.ReorderSectionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	for _, item := range req {
+		if err := sc.Validator.Struct(item); err != nil {
+			return c.JSON(http.StatusBadRequest, dto.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+	}
+
+	if err := sc.sectionService(c).ReorderSections(uint(courseID), req, getUserIDFromContext(c)); err != nil {
+		return sc.error(c, err, "Course not found")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Sections reordered successfully",
+	})
+}
+
+// error maps section service errors to responses, notFound is the message for
+// a missing course or section
+func (sc *SectionController) error(c echo.Context, err error, notFound string) error {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, errutil.ErrRecordNotFound):
+		status = http.StatusNotFound
+		err = errors.New(notFound)
+	case errors.Is(err, errutil.ErrNotInCourse):
+		status = http.StatusBadRequest
+	case errors.Is(err, errutil.ErrSectionNotEmpty):
+		status = http.StatusConflict
+	}
+
+	return c.JSON(status, dto.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+type SessionController struct {
+	SessionService services.SessionService
+}
+
+func NewSessionController(sessionService services.SessionService) *SessionController {
+	return &SessionController{
+		SessionService: sessionService,
+	}
+}
+
+// GetMySessions lists the active sessions of the current user
+// GET /api/v1/my/sessions
+func (sc *SessionController) GetMySessions(c echo.Context) error {
+	token, ok := c.Get("token").(*types.Token)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	sessions, err := sc.SessionService.GetUserSessions(uint(token.UserID), token.RefreshUuid)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   "Failed to retrieve sessions",
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Sessions retrieved successfully",
+		Data:    sessions,
+	})
+}
+
+// RevokeSession signs out one of the current user's sessions
+// DELETE /api/v1/my/sessions/:id
+func (sc *SessionController) RevokeSession(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	if err := sc.SessionService.RevokeSession(userID, c.Param("id")); err != nil {
+		if errors.Is(err, errutil.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, dto.APIResponse{
+				Success: false,
+				Error:   "Session not found",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Error:   "Failed to revoke session",
+		})
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Session revoked successfully",
+	})
+}
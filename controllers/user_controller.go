@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+type UserController struct {
+	UserService services.UserService
+	Validator   *validator.Validate
+}
+
+func NewUserController(userService services.UserService) *UserController {
+	return &UserController{
+		UserService: userService,
+		Validator:   validator.New(),
+	}
+}
+
+// userService returns the UserService scoped to the organization of the request,
+// recording its changes with the request in the audit log
+func (uc *UserController) userService(c echo.Context) services.UserService {
+	return uc.UserService.ForOrganization(getOrganizationIDFromContext(c)).WithRequest(requestInfo(c))
+}
+
+// GetProfile returns the profile of the current user
+// GET /api/v1/profile
+func (uc *UserController) GetProfile(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	profile, err := uc.userService(c).GetProfile(userID)
+	if err != nil {
+		return uc.error(c, err, "Failed to retrieve profile")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Profile retrieved successfully",
+		Data:    profile,
+	})
+}
+
+// UpdateProfile updates name, avatar, bio, timezone and locale of the current user
+// PUT /api/v1/profile
+func (uc *UserController) UpdateProfile(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	var req dto.UpdateProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+	}
+
+	if err := uc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	profile, err := uc.userService(c).UpdateProfile(userID, req)
+	if err != nil {
+		return uc.error(c, err, "Failed to update profile")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Profile updated successfully",
+		Data:    profile,
+	})
+}
+
+// ChangeEmail sends a verification link to the new address
+// POST /api/v1/profile/email
+func (uc *UserController) ChangeEmail(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	var req dto.ChangeEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+	}
+
+	if err := uc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := uc.userService(c).ChangeEmail(userID, req); err != nil {
+		return uc.error(c, err, "Failed to change email")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "A verification link has been sent to the new email address",
+	})
+}
+
+// ChangePassword sets a new password and signs out every session
+// POST /api/v1/profile/password
+func (uc *UserController) ChangePassword(c echo.Context) error {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		return c.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "Unauthorized",
+		})
+	}
+
+	var req dto.ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+	}
+
+	if err := uc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := uc.userService(c).ChangePassword(userID, req); err != nil {
+		return uc.error(c, err, "Failed to change password")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Password changed successfully, please log in again",
+	})
+}
+
+// ListUsers lists users with search, role/status/verified filters and pagination
+// GET /api/v1/admin/users
+func (uc *UserController) ListUsers(c echo.Context) error {
+	var filter dto.UserFilterRequest
+	if err := c.Bind(&filter); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid query parameters",
+		})
+	}
+
+	if err := uc.Validator.Struct(filter); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	result, err := uc.userService(c).ListUsers(filter)
+	if err != nil {
+		return uc.error(c, err, "Failed to retrieve users")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// ChangeUserRole assigns a new role to a user
+// PUT /api/v1/admin/users/:id/role
+func (uc *UserController) ChangeUserRole(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	var req dto.ChangeRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+	}
+
+	if err := uc.Validator.Struct(req); err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	user, err := uc.userService(c).ChangeRole(uint(userID), req.Role, getUserIDFromContext(c))
+	if err != nil {
+		return uc.error(c, err, "Failed to change role")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Role changed successfully",
+		Data:    user,
+	})
+}
+
+// SuspendUser blocks a user from logging in
+// POST /api/v1/admin/users/:id/suspend
+func (uc *UserController) SuspendUser(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	user, err := uc.userService(c).SuspendUser(uint(userID), getUserIDFromContext(c))
+	if err != nil {
+		return uc.error(c, err, "Failed to suspend user")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "User suspended successfully",
+		Data:    user,
+	})
+}
+
+// ReactivateUser lifts a suspension
+// POST /api/v1/admin/users/:id/reactivate
+func (uc *UserController) ReactivateUser(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	user, err := uc.userService(c).ReactivateUser(uint(userID), getUserIDFromContext(c))
+	if err != nil {
+		return uc.error(c, err, "Failed to reactivate user")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "User reactivated successfully",
+		Data:    user,
+	})
+}
+
+// ForcePasswordReset requires the user to reset their password before the next login
+// POST /api/v1/admin/users/:id/force-password-reset
+func (uc *UserController) ForcePasswordReset(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	if err := uc.userService(c).ForcePasswordReset(uint(userID), getUserIDFromContext(c)); err != nil {
+		return uc.error(c, err, "Failed to force password reset")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Password reset required, a reset link has been sent to the user",
+	})
+}
+
+// ForceLogout signs out every session of a user
+// POST /api/v1/admin/users/:id/logout
+func (uc *UserController) ForceLogout(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	if err := uc.userService(c).ForceLogout(uint(userID), getUserIDFromContext(c)); err != nil {
+		return uc.error(c, err, "Failed to log out user")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "User logged out from all devices",
+	})
+}
+
+// ImpersonateUser issues a short-lived token to view the platform as the user
+// POST /api/v1/admin/users/:id/impersonate
+func (uc *UserController) ImpersonateUser(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	// an impersonation cannot be started from another impersonation
+	if getImpersonatorIDFromContext(c) != 0 {
+		return c.JSON(http.StatusForbidden, dto.APIResponse{
+			Success: false,
+			Error:   errutil.ErrImpersonationReadOnly.Error(),
+		})
+	}
+
+	result, err := uc.userService(c).Impersonate(uint(userID), getUserIDFromContext(c))
+	if err != nil {
+		return uc.error(c, err, "Failed to impersonate user")
+	}
+
+	return c.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Impersonation token issued successfully",
+		Data:    result,
+	})
+}
+
+// error maps user service errors to responses
+func (uc *UserController) error(c echo.Context, err error, fallback string) error {
+	status := http.StatusInternalServerError
+	message := fallback
+
+	switch {
+	case errors.Is(err, errutil.ErrRecordNotFound):
+		status, message = http.StatusNotFound, "User not found"
+	case errors.Is(err, errutil.ErrInvalidCurrentPassword), isPasswordPolicyError(err):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, errutil.ErrEmailAlreadyInUse), errors.Is(err, errutil.ErrLastAdmin), errors.Is(err, errutil.ErrUserErased):
+		status, message = http.StatusConflict, err.Error()
+	case errors.Is(err, errutil.ErrCannotModifySelf), errors.Is(err, errutil.ErrCannotImpersonate), errors.Is(err, errutil.ErrAccountSuspended):
+		status, message = http.StatusForbidden, err.Error()
+	}
+
+	return c.JSON(status, dto.APIResponse{
+		Success: false,
+		Error:   message,
+	})
+}
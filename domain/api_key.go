@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix starts every key so that leaked keys are easy to recognise
+const APIKeyPrefix = "vl_"
+
+// Scopes an API key can be granted. The owner's role permissions still apply
+// on top of them.
+const (
+	ScopeCoursesRead   = "courses:read"
+	ScopeCoursesWrite  = "courses:write"
+	ScopeProgressRead  = "progress:read"
+	ScopeProgressWrite = "progress:write"
+	ScopeUsersRead     = "users:read"
+)
+
+var APIKeyScopes = []string{
+	ScopeCoursesRead, ScopeCoursesWrite, ScopeProgressRead, ScopeProgressWrite, ScopeUsersRead,
+}
+
+// APIKey authenticates a machine client as its owner, either a regular user or
+// a service account. Only the sha256 of the secret part is stored, the
+// public Prefix identifies the key.
+type APIKey struct {
+	ID         uint   `gorm:"primaryKey"`
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"not null;uniqueIndex;size:32"`
+	KeyHash    string `gorm:"not null;size:64"`
+	UserID     uint   `gorm:"not null;index"`
+	Scopes     string // comma-separated scopes
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string
+	CreatedBy  uint
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+
+	User User `gorm:"foreignKey:UserID"`
+}
+
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
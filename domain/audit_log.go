@@ -0,0 +1,80 @@
+package domain
+
+import "time"
+
+// Audited actions, named <target>.<verb>
+const (
+	AuditActionCourseCreate            = "course.create"
+	AuditActionCourseUpdate            = "course.update"
+	AuditActionCourseDelete            = "course.delete"
+	AuditActionCourseRestore           = "course.restore"
+	AuditActionCourseStaffAdd          = "course.staff_add"
+	AuditActionCourseStaffRemove       = "course.staff_remove"
+	AuditActionCourseTransferOwnership = "course.transfer_ownership"
+	AuditActionCoursePublishVersion    = "course.publish_version"
+	AuditActionCourseRollbackVersion   = "course.rollback_version"
+	AuditActionCourseSubmitReview      = "course.submit_review"
+	AuditActionCourseApprove           = "course.approve"
+	AuditActionCourseReject            = "course.reject"
+	AuditActionCoursePublish           = "course.publish"
+	AuditActionCourseArchive           = "course.archive"
+	AuditActionLessonCreate            = "lesson.create"
+	AuditActionLessonUpdate            = "lesson.update"
+	AuditActionLessonDelete            = "lesson.delete"
+	AuditActionLessonRestore           = "lesson.restore"
+	AuditActionLessonReorder           = "lesson.reorder"
+	AuditActionSectionCreate           = "section.create"
+	AuditActionSectionUpdate           = "section.update"
+	AuditActionSectionDelete           = "section.delete"
+	AuditActionSectionReorder          = "section.reorder"
+	AuditActionEnrollmentCreate        = "enrollment.create"
+	AuditActionEnrollmentDelete        = "enrollment.delete"
+	AuditActionEnrollmentUpgrade       = "enrollment.upgrade_version"
+	AuditActionGroupCourseAdd          = "group.course_add"
+	AuditActionGroupCourseRemove       = "group.course_remove"
+	AuditActionUserCreate              = "user.create"
+	AuditActionUserUpdate              = "user.update"
+	AuditActionUserPasswordChange      = "user.password_change"
+	AuditActionUserRoleChange          = "user.role_change"
+	AuditActionUserSuspend             = "user.suspend"
+	AuditActionUserReactivate          = "user.reactivate"
+	AuditActionUserForcePasswordReset  = "user.force_password_reset"
+	AuditActionUserLogout              = "user.logout"
+	AuditActionUserImpersonate         = "user.impersonate"
+	AuditActionUserDataExport          = "user.data_export"
+	AuditActionUserErase               = "user.erase"
+)
+
+// Audited target types
+const (
+	AuditTargetCourse     = "course"
+	AuditTargetLesson     = "lesson"
+	AuditTargetSection    = "section"
+	AuditTargetEnrollment = "enrollment"
+	AuditTargetGroup      = "group"
+	AuditTargetUser       = "user"
+)
+
+// AuditScrubSetting is the transaction setting that lets the erasure of a
+// user redact its entries, any other update of audit_logs is refused
+const AuditScrubSetting = "vivalearning.audit_scrub"
+
+// AuditLog records who did what to which record. Rows are only ever inserted,
+// an update is refused by the database, apart from the redaction done by the
+// erasure of a user, and rows are only removed by the retention command.
+type AuditLog struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;default:0;index" json:"organization_id"`
+	ActorID        uint      `gorm:"index" json:"actor_id"`        // 0 for the system (CLI, SCIM)
+	ImpersonatorID uint      `json:"impersonator_id,omitempty"`    // admin acting as the actor
+	Action         string    `gorm:"not null;index" json:"action"` // e.g. course.update
+	TargetType     string    `gorm:"not null;index:idx_audit_target" json:"target_type"`
+	TargetID       uint      `gorm:"index:idx_audit_target" json:"target_id"`
+	Changes        string    `gorm:"type:jsonb;not null;default:'{}'" json:"changes"` // {"field": {"old": ..., "new": ...}}
+	IP             string    `json:"ip"`
+	UserAgent      string    `json:"user_agent"`
+	RequestID      string    `json:"request_id,omitempty"`
+	Method         string    `json:"method,omitempty"`
+	Path           string    `json:"path,omitempty"`
+	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+}
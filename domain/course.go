@@ -1,25 +1,66 @@
 package domain
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Course statuses. A course is written as a draft, submitted for review,
+// approved or sent back by a reviewer, published once approved and archived
+// when it is retired.
+const (
+	CourseStatusDraft     = "draft"
+	CourseStatusInReview  = "in_review"
+	CourseStatusApproved  = "approved"
+	CourseStatusPublished = "published"
+	CourseStatusArchived  = "archived"
+)
+
+// courseStatusTransitions lists the statuses a course can move to from each status
+var courseStatusTransitions = map[string][]string{
+	CourseStatusDraft:     {CourseStatusInReview},
+	CourseStatusInReview:  {CourseStatusApproved, CourseStatusDraft},
+	CourseStatusApproved:  {CourseStatusPublished, CourseStatusDraft},
+	CourseStatusPublished: {CourseStatusArchived},
+	CourseStatusArchived:  {CourseStatusInReview},
+}
+
+// CourseStatusCanMove reports whether a course can move from one status to the other
+func CourseStatusCanMove(from, to string) bool {
+	for _, status := range courseStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
 
 type Course struct {
-	ID               uint      `gorm:"primaryKey" json:"id"`
-	Title            string    `gorm:"not null" json:"title" validate:"required"`
-	Description      string    `json:"description"`
-	ShortDescription string    `json:"short_description"`
-	Thumbnail        string    `json:"thumbnail"`
-	Level            string    `gorm:"default:'beginner'" json:"level"` // beginner, intermediate, advanced
-	Category         string    `json:"category"`
-	Tags             string    `json:"tags"`     // comma-separated tags
-	Duration         int       `json:"duration"` // total duration in minutes
-	Price            float64   `gorm:"default:0" json:"price"`
-	IsPublished      bool      `gorm:"default:false" json:"is_published"`
-	CreatedBy        uint      `json:"created_by"` // Admin ID
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	OrganizationID   uint           `gorm:"not null;default:0;index" json:"organization_id"`
+	Title            string         `gorm:"not null" json:"title" validate:"required"`
+	Description      string         `json:"description"`
+	ShortDescription string         `json:"short_description"`
+	Thumbnail        string         `json:"thumbnail"`
+	Level            string         `gorm:"default:'beginner'" json:"level"` // beginner, intermediate, advanced
+	Category         string         `json:"category"`
+	Tags             string         `json:"tags"`     // comma-separated tags
+	Duration         int            `json:"duration"` // total duration in minutes
+	Price            float64        `gorm:"default:0" json:"price"`
+	Status           string         `gorm:"not null;default:'draft';index" json:"status"` // see CourseStatus*, moved by the review workflow only
+	IsPublished      bool           `gorm:"default:false" json:"is_published"`            // true while the status is published
+	PublishedVersion int            `gorm:"not null;default:0" json:"published_version"`  // version learners see, 0 while the live rows are served
+	PublishAt        *time.Time     `gorm:"index" json:"publish_at,omitempty"`            // publish once approved and this time has come
+	UnpublishAt      *time.Time     `gorm:"index" json:"unpublish_at,omitempty"`          // archive when this time has come
+	CreatedBy        uint           `json:"created_by"`                                   // Admin ID
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"` // set while the course is in the trash
 
 	// Relationships
 	Lessons     []Lesson     `gorm:"foreignKey:CourseID" json:"lessons,omitempty"`
+	Sections    []Section    `gorm:"foreignKey:CourseID" json:"sections,omitempty"`
 	UserCourses []UserCourse `gorm:"foreignKey:CourseID" json:"user_courses,omitempty"`
 
 	// Computed fields (not stored in DB)
@@ -27,3 +68,11 @@ type Course struct {
 	EnrolledCount  int     `gorm:"-" json:"enrolled_count,omitempty"`
 	CompletionRate float64 `gorm:"-" json:"completion_rate,omitempty"`
 }
+
+// IsLive reports whether the course is in the catalog at the time. Only a
+// published course is, an approved course due by its schedule waits for the
+// scheduler, which publishes the version learners see. A course due to be
+// unpublished leaves the catalog right away.
+func (c *Course) IsLive(now time.Time) bool {
+	return c.Status == CourseStatusPublished && (c.UnpublishAt == nil || c.UnpublishAt.After(now))
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// Review workflow steps recorded for a course
+const (
+	CourseReviewSubmit  = "submit"
+	CourseReviewApprove = "approve"
+	CourseReviewReject  = "reject"
+	CourseReviewPublish = "publish"
+	CourseReviewArchive = "archive"
+)
+
+// CourseReview records one step of the review workflow of a course, with the
+// comment of the user who took it
+type CourseReview struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;default:0;index" json:"organization_id"` // copied from the course
+	CourseID       uint      `gorm:"not null;index" json:"course_id"`
+	UserID         uint      `gorm:"not null" json:"user_id"`
+	Action         string    `gorm:"not null" json:"action"` // see CourseReview*
+	FromStatus     string    `gorm:"not null" json:"from_status"`
+	ToStatus       string    `gorm:"not null" json:"to_status"`
+	Comment        string    `json:"comment"`
+	CreatedAt      time.Time `json:"created_at"`
+}
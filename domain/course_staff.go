@@ -0,0 +1,61 @@
+package domain
+
+import "time"
+
+// Course staff roles
+const (
+	CourseStaffOwner             = "owner"
+	CourseStaffCoInstructor      = "co_instructor"
+	CourseStaffTeachingAssistant = "teaching_assistant"
+	CourseStaffReviewer          = "reviewer"
+)
+
+// Actions a staff member can perform on a course
+const (
+	CourseActionUpdate        = "update"
+	CourseActionDelete        = "delete"
+	CourseActionManageLessons = "manage_lessons"
+	CourseActionViewAnalytics = "view_analytics"
+	CourseActionViewContent   = "view_content"
+	CourseActionManageStaff   = "manage_staff"
+)
+
+var courseStaffActions = map[string][]string{
+	CourseStaffOwner: {
+		CourseActionUpdate, CourseActionDelete, CourseActionManageLessons,
+		CourseActionViewAnalytics, CourseActionViewContent, CourseActionManageStaff,
+	},
+	CourseStaffCoInstructor: {
+		CourseActionUpdate, CourseActionManageLessons, CourseActionViewAnalytics, CourseActionViewContent,
+	},
+	CourseStaffTeachingAssistant: {
+		CourseActionViewAnalytics, CourseActionViewContent,
+	},
+	CourseStaffReviewer: {
+		CourseActionViewContent,
+	},
+}
+
+// CourseStaff links a user to a course they help to run
+type CourseStaff struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CourseID  uint      `gorm:"not null;uniqueIndex:idx_course_staff" json:"course_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_course_staff" json:"user_id"`
+	Role      string    `gorm:"not null" json:"role"`
+	AddedBy   uint      `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// CourseStaffCan reports whether the staff role allows the action
+func CourseStaffCan(role, action string) bool {
+	for _, a := range courseStaffActions[role] {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package domain
+
+import "time"
+
+// CourseVersion is an immutable snapshot of the content of a course: its
+// details, sections and lessons. The live rows are the draft instructors edit,
+// learners read the published version (or the one they are pinned to). Rows are
+// never updated, the database refuses it.
+type CourseVersion struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;default:0;index" json:"organization_id"` // copied from the course
+	CourseID       uint      `gorm:"not null;uniqueIndex:idx_course_version" json:"course_id"`
+	Version        int       `gorm:"not null;uniqueIndex:idx_course_version" json:"version"` // 1, 2, ... per course
+	Snapshot       string    `gorm:"type:jsonb;not null" json:"-"`                           // CourseSnapshot as JSON
+	Note           string    `json:"note"`
+	RestoredFrom   int       `gorm:"not null;default:0" json:"restored_from,omitempty"` // version copied by a rollback
+	PublishedBy    uint      `json:"published_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CourseSnapshot is the content stored in a version. Lessons keep their IDs so
+// progress recorded against a lesson stays valid across versions.
+type CourseSnapshot struct {
+	Course   SnapshotCourse    `json:"course"`
+	Sections []SnapshotSection `json:"sections"`
+	Lessons  []SnapshotLesson  `json:"lessons"` // in course order
+}
+
+type SnapshotCourse struct {
+	Title            string  `json:"title"`
+	Description      string  `json:"description"`
+	ShortDescription string  `json:"short_description"`
+	Thumbnail        string  `json:"thumbnail"`
+	Level            string  `json:"level"`
+	Category         string  `json:"category"`
+	Tags             string  `json:"tags"`
+	Duration         int     `json:"duration"`
+	Price            float64 `json:"price"`
+}
+
+type SnapshotSection struct {
+	ID          uint   `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Sequence    int    `json:"sequence"`
+	IsPublished bool   `json:"is_published"`
+}
+
+type SnapshotLesson struct {
+	ID          uint       `json:"id"`
+	SectionID   *uint      `json:"section_id,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	VideoURL    string     `json:"video_url"`
+	VideoID     string     `json:"video_id"`
+	Script      string     `json:"script"`
+	Duration    int        `json:"duration"`
+	Sequence    int        `json:"sequence"`
+	IsPublished bool       `json:"is_published"`
+	IsFree      bool       `json:"is_free"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+}
+
+// NewCourseSnapshot captures the course with its sections and lessons loaded
+func NewCourseSnapshot(course *Course) CourseSnapshot {
+	snapshot := CourseSnapshot{
+		Course: SnapshotCourse{
+			Title:            course.Title,
+			Description:      course.Description,
+			ShortDescription: course.ShortDescription,
+			Thumbnail:        course.Thumbnail,
+			Level:            course.Level,
+			Category:         course.Category,
+			Tags:             course.Tags,
+			Duration:         course.Duration,
+			Price:            course.Price,
+		},
+		Sections: []SnapshotSection{},
+		Lessons:  []SnapshotLesson{},
+	}
+
+	for _, section := range course.Sections {
+		snapshot.Sections = append(snapshot.Sections, SnapshotSection{
+			ID:          section.ID,
+			Title:       section.Title,
+			Description: section.Description,
+			Sequence:    section.Sequence,
+			IsPublished: section.IsPublished,
+		})
+	}
+	for _, lesson := range course.Lessons {
+		snapshot.Lessons = append(snapshot.Lessons, SnapshotLesson{
+			ID:          lesson.ID,
+			SectionID:   lesson.SectionID,
+			Title:       lesson.Title,
+			Description: lesson.Description,
+			VideoURL:    lesson.VideoURL,
+			VideoID:     lesson.VideoID,
+			Script:      lesson.Script,
+			Duration:    lesson.Duration,
+			Sequence:    lesson.Sequence,
+			IsPublished: lesson.IsPublished,
+			IsFree:      lesson.IsFree,
+			PublishAt:   lesson.PublishAt,
+			UnpublishAt: lesson.UnpublishAt,
+		})
+	}
+
+	return snapshot
+}
+
+// Apply replaces the content of the course with the one of the snapshot,
+// leaving its ID, ownership and publishing state alone
+func (s CourseSnapshot) Apply(course *Course) {
+	course.Title = s.Course.Title
+	course.Description = s.Course.Description
+	course.ShortDescription = s.Course.ShortDescription
+	course.Thumbnail = s.Course.Thumbnail
+	course.Level = s.Course.Level
+	course.Category = s.Course.Category
+	course.Tags = s.Course.Tags
+	course.Duration = s.Course.Duration
+	course.Price = s.Course.Price
+
+	course.Sections = make([]Section, 0, len(s.Sections))
+	for _, section := range s.Sections {
+		course.Sections = append(course.Sections, Section{
+			ID:             section.ID,
+			OrganizationID: course.OrganizationID,
+			CourseID:       course.ID,
+			Title:          section.Title,
+			Description:    section.Description,
+			Sequence:       section.Sequence,
+			IsPublished:    section.IsPublished,
+		})
+	}
+	course.Lessons = make([]Lesson, 0, len(s.Lessons))
+	for _, lesson := range s.Lessons {
+		course.Lessons = append(course.Lessons, s.lesson(course, lesson))
+	}
+}
+
+// Lesson returns the lesson of the snapshot, false when it is not part of it
+func (s CourseSnapshot) Lesson(course *Course, lessonID uint) (Lesson, bool) {
+	for _, lesson := range s.Lessons {
+		if lesson.ID == lessonID {
+			return s.lesson(course, lesson), true
+		}
+	}
+	return Lesson{}, false
+}
+
+// VisibleLessons returns the lessons of published sections that are live now
+// in course order, only the free ones when freeOnly is set
+func (s CourseSnapshot) VisibleLessons(course *Course, freeOnly bool) []Lesson {
+	now := time.Now()
+	publishedSections := map[uint]bool{}
+	for _, section := range s.Sections {
+		publishedSections[section.ID] = section.IsPublished
+	}
+
+	var lessons []Lesson
+	for _, snapshotLesson := range s.Lessons {
+		lesson := s.lesson(course, snapshotLesson)
+		if !lesson.IsLive(now) || freeOnly && !lesson.IsFree {
+			continue
+		}
+		if lesson.SectionID != nil && !publishedSections[*lesson.SectionID] {
+			continue
+		}
+		lessons = append(lessons, lesson)
+	}
+	return lessons
+}
+
+func (s CourseSnapshot) lesson(course *Course, lesson SnapshotLesson) Lesson {
+	return Lesson{
+		ID:             lesson.ID,
+		OrganizationID: course.OrganizationID,
+		Title:          lesson.Title,
+		Description:    lesson.Description,
+		VideoURL:       lesson.VideoURL,
+		VideoID:        lesson.VideoID,
+		Script:         lesson.Script,
+		Duration:       lesson.Duration,
+		CourseID:       course.ID,
+		SectionID:      lesson.SectionID,
+		Sequence:       lesson.Sequence,
+		IsPublished:    lesson.IsPublished,
+		IsFree:         lesson.IsFree,
+		PublishAt:      lesson.PublishAt,
+		UnpublishAt:    lesson.UnpublishAt,
+	}
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// Group is a set of users, maintained by the HR system through SCIM. Members
+// are enrolled in every course attached to the group.
+type Group struct {
+	ID             uint     `gorm:"primaryKey"`
+	OrganizationID uint     `gorm:"not null;default:0;uniqueIndex:idx_group_organization_name"`
+	DisplayName    string   `gorm:"not null;uniqueIndex:idx_group_organization_name"`
+	ExternalID     string   `gorm:"index"`
+	Members        []User   `gorm:"many2many:group_members"`
+	Courses        []Course `gorm:"many2many:group_courses"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
@@ -1,21 +1,30 @@
 package domain
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Lesson struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Title       string    `gorm:"not null" json:"title" validate:"required"`
-	Description string    `json:"description"`
-	VideoURL    string    `json:"video_url"` // YouTube link or video file URL
-	VideoID     string    `json:"video_id"`  // YouTube video ID
-	Script      string    `json:"script"`    // Full script/text content
-	Duration    int       `json:"duration"`  // Duration in seconds
-	CourseID    uint      `gorm:"not null" json:"course_id" validate:"required"`
-	Sequence    int       `gorm:"not null" json:"sequence"` // Order of appearance
-	IsPublished bool      `gorm:"default:false" json:"is_published"`
-	IsFree      bool      `gorm:"default:false" json:"is_free"` // Preview lesson
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	OrganizationID uint           `gorm:"not null;default:0;index" json:"organization_id"` // copied from the course
+	Title          string         `gorm:"not null" json:"title" validate:"required"`
+	Description    string         `json:"description"`
+	VideoURL       string         `json:"video_url"` // YouTube link or video file URL
+	VideoID        string         `json:"video_id"`  // YouTube video ID
+	Script         string         `json:"script"`    // Full script/text content
+	Duration       int            `json:"duration"`  // Duration in seconds
+	CourseID       uint           `gorm:"not null" json:"course_id" validate:"required"`
+	SectionID      *uint          `gorm:"index" json:"section_id,omitempty"` // nil when the lesson is not in a section
+	Sequence       int            `gorm:"not null" json:"sequence"`          // Order within the section
+	IsPublished    bool           `gorm:"default:false" json:"is_published"`
+	IsFree         bool           `gorm:"default:false" json:"is_free"`        // Preview lesson
+	PublishAt      *time.Time     `gorm:"index" json:"publish_at,omitempty"`   // publish when this time has come
+	UnpublishAt    *time.Time     `gorm:"index" json:"unpublish_at,omitempty"` // unpublish when this time has come
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"` // set while the lesson is in the trash
 
 	// Relationships
 	Course Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
@@ -23,3 +32,10 @@ type Lesson struct {
 	// Computed fields (not stored in DB)
 	IsCompleted bool `gorm:"-" json:"is_completed,omitempty"` // For user context
 }
+
+// IsLive reports whether learners see the lesson at the time. A lesson due by
+// its schedule counts as published (or unpublished) before the scheduler got to it.
+func (l *Lesson) IsLive(now time.Time) bool {
+	published := l.IsPublished || l.PublishAt != nil && !l.PublishAt.After(now)
+	return published && (l.UnpublishAt == nil || l.UnpublishAt.After(now))
+}
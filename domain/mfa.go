@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// MFARecoveryCode is a single-use code that replaces a TOTP code when the
+// authenticator device is lost. Only the hash of the code is stored.
+type MFARecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"not null;size:64"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// DefaultOrganizationSlug names the organization that existing data is moved
+// into and that requests without a tenant are served from
+const DefaultOrganizationSlug = "default"
+
+// Organization is a tenant: a client company with its own users, courses and
+// enrollments. Rows of one organization are never visible to another.
+type Organization struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Name          string    `gorm:"not null" json:"name"`
+	Slug          string    `gorm:"not null;uniqueIndex" json:"slug"` // subdomain and X-Organization header value
+	SCIMTokenHash string    `gorm:"index" json:"-"`                   // hash of the SCIM bearer token, empty while provisioning is disabled
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
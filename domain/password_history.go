@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// PasswordHistory keeps the hashes of the passwords a user had, so that the
+// last ones cannot be chosen again
+type PasswordHistory struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"not null;index"`
+	PasswordHash string `gorm:"not null"`
+	CreatedAt    time.Time
+}
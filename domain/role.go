@@ -0,0 +1,49 @@
+package domain
+
+const (
+	RoleAdmin      = "admin"
+	RoleInstructor = "instructor"
+	RoleLearner    = "learner"
+)
+
+// Permissions checked by the RequirePermission middleware
+const (
+	PermCourseCreate    = "course:create"
+	PermCourseUpdate    = "course:update"
+	PermCourseDelete    = "course:delete"
+	PermCoursePublish   = "course:publish"
+	PermCourseAnalytics = "course:analytics"
+	PermCourseReadAll   = "course:read_all"
+	PermLessonManage    = "lesson:manage"
+	PermUserManage      = "user:manage"
+)
+
+// RolePermission grants a single permission to a role
+type RolePermission struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	Role       string `gorm:"not null;uniqueIndex:idx_role_permission" json:"role"`
+	Permission string `gorm:"not null;uniqueIndex:idx_role_permission" json:"permission"`
+}
+
+// DefaultRolePermissions is seeded into an empty role_permissions table
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermCourseCreate, PermCourseUpdate, PermCourseDelete, PermCoursePublish,
+		PermCourseAnalytics, PermCourseReadAll, PermLessonManage, PermUserManage,
+	},
+	RoleInstructor: {
+		PermCourseCreate, PermCourseUpdate, PermCourseDelete, PermCoursePublish,
+		PermCourseAnalytics, PermLessonManage,
+	},
+	RoleLearner: {},
+}
+
+// NormalizeRole maps legacy and empty roles onto the learner role
+func NormalizeRole(role string) string {
+	switch role {
+	case RoleAdmin, RoleInstructor, RoleLearner:
+		return role
+	default:
+		return RoleLearner
+	}
+}
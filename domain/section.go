@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// Section groups the lessons of a course. Sections are ordered within the
+// course and lessons within their section; lessons without a section come
+// before the first section.
+type Section struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;default:0;index" json:"organization_id"` // copied from the course
+	CourseID       uint      `gorm:"not null;index" json:"course_id"`
+	Title          string    `gorm:"not null" json:"title"`
+	Description    string    `json:"description"`
+	Sequence       int       `gorm:"not null" json:"sequence"` // Order within the course
+	IsPublished    bool      `gorm:"default:false" json:"is_published"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// Relationships
+	Lessons []Lesson `gorm:"foreignKey:SectionID" json:"lessons,omitempty"`
+}
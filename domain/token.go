@@ -4,9 +4,15 @@ import "github.com/rijwanansari/vivaLearning/types"
 
 type (
 	TokenService interface {
-		CreateToken(userID int) (*types.Token, error)
+		CreateToken(userID int, orgID int, role string) (*types.Token, error)
+		CreateImpersonationToken(actorID int, userID int, orgID int, role string) (*types.Token, error)
 		StoreTokenUUID(token *types.Token) error
 		ParseAccessToken(accessToken string) (*types.Token, error)
+		ParseRefreshToken(refreshToken string) (*types.Token, error)
 		ReadUserIDFromAccessTokenUUID(accessTokenUuid string) (int, error)
+		RefreshToken(refreshToken string) (*types.Token, error)
+		DeleteTokenUUID(token *types.Token) error
+		DeleteAllTokenUUIDs(userID int) error
+		JWKS() *types.JWKS
 	}
 )
@@ -3,9 +3,39 @@ package domain
 import "time"
 
 type User struct {
-	ID        uint   `gorm:"primaryKey"`
-	Email     string `gorm:"uniqueIndex"`
-	Password  string
-	Role      string // "admin" or "user"
+	ID             uint   `gorm:"primaryKey"`
+	OrganizationID uint   `gorm:"not null;default:0;index"` // tenant the user belongs to, admins only manage their own
+	Email          string `gorm:"uniqueIndex"`
+	Password       string
+	Role           string     // admin, instructor or learner
+	VerifiedAt     *time.Time // nil until the email address is confirmed
+	MFAEnabled     bool
+	MFASecret      string `json:"-"` // base32 TOTP secret, set once enrollment is confirmed
+	Name           string
+	AvatarURL      string
+	Bio            string `gorm:"type:text"`
+	Timezone       string // IANA name, e.g. Asia/Dhaka
+	Locale         string // BCP 47 tag, e.g. en-US
+
+	SuspendedAt           *time.Time // suspended accounts cannot log in
+	PasswordResetRequired bool       // set by an admin, login is refused until the password is reset
+	PasswordChangedAt     *time.Time // drives the password max age, the creation time is used while nil
+	IsServiceAccount      bool       // machine identity, authenticates with API keys only
+	ExternalID            string     `gorm:"index"` // id of the user in the provisioning (SCIM) client
+	ErasedAt              *time.Time // personal data was erased on request, the row only keeps the learning history
+
 	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (u *User) IsVerified() bool {
+	return u.VerifiedAt != nil
+}
+
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt != nil
+}
+
+func (u *User) IsErased() bool {
+	return u.ErasedAt != nil
 }
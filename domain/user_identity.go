@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// identity provider. The pair (issuer, subject) is stable for the lifetime of
+// the IdP account, unlike the email address.
+type UserIdentity struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"not null;index"`
+	Issuer      string `gorm:"not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Subject     string `gorm:"not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Email       string // email reported by the IdP at the last login
+	LastLoginAt *time.Time
+	CreatedAt   time.Time
+}
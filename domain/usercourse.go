@@ -3,15 +3,17 @@ package domain
 import "time"
 
 type UserCourse struct {
-	ID           uint       `gorm:"primaryKey" json:"id"`
-	UserID       uint       `gorm:"not null" json:"user_id"`
-	CourseID     uint       `gorm:"not null" json:"course_id"`
-	LastLessonID uint       `json:"last_lesson_id"`            // Track last viewed lesson
-	Progress     float64    `gorm:"default:0" json:"progress"` // % completed (0-100)
-	IsCompleted  bool       `gorm:"default:false" json:"is_completed"`
-	EnrolledAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"enrolled_at"`
-	CompletedAt  *time.Time `json:"completed_at,omitempty"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	OrganizationID uint       `gorm:"not null;default:0;index" json:"organization_id"` // copied from the course
+	UserID         uint       `gorm:"not null" json:"user_id"`
+	CourseID       uint       `gorm:"not null" json:"course_id"`
+	LastLessonID   uint       `json:"last_lesson_id"`            // Track last viewed lesson
+	Progress       float64    `gorm:"default:0" json:"progress"` // % completed (0-100)
+	IsCompleted    bool       `gorm:"default:false" json:"is_completed"`
+	CourseVersion  int        `gorm:"not null;default:0" json:"course_version"` // version the learner is pinned to, 0 follows the published one
+	EnrolledAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"enrolled_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 
 	// Relationships
 	User   User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
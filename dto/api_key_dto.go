@@ -0,0 +1,39 @@
+package dto
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,min=1,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=courses:read courses:write progress:read progress:write users:read"`
+	ExpiresInDays *int     `json:"expires_in_days,omitempty" validate:"omitempty,min=1,max=365"`
+}
+
+type CreateServiceAccountRequest struct {
+	Name string `json:"name" validate:"required,min=2,max=100"`
+	Role string `json:"role" validate:"required,oneof=admin instructor learner"`
+}
+
+type APIKeyResponse struct {
+	ID         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expires_at,omitempty"`
+	LastUsedAt *string  `json:"last_used_at,omitempty"`
+	LastUsedIP string   `json:"last_used_ip,omitempty"`
+	Revoked    bool     `json:"revoked"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// CreatedAPIKeyResponse carries the full key, which is only ever shown once
+type CreatedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+type ServiceAccountResponse struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Suspended bool   `json:"suspended"`
+	CreatedAt string `json:"created_at"`
+}
@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditLogFilterRequest struct {
+	ActorID    uint       `query:"actor_id"`
+	Action     string     `query:"action"`
+	TargetType string     `query:"target_type"`
+	TargetID   uint       `query:"target_id"`
+	From       *time.Time `query:"from"` // RFC 3339, inclusive
+	To         *time.Time `query:"to"`   // RFC 3339, exclusive
+	Page       int        `query:"page" validate:"omitempty,min=1"`
+	Limit      int        `query:"limit" validate:"omitempty,min=1,max=200"`
+}
+
+type AuditLogResponse struct {
+	ID             uint            `json:"id"`
+	OrganizationID uint            `json:"organization_id"`
+	ActorID        uint            `json:"actor_id"`
+	ImpersonatorID uint            `json:"impersonator_id,omitempty"`
+	Action         string          `json:"action"`
+	TargetType     string          `json:"target_type"`
+	TargetID       uint            `json:"target_id"`
+	Changes        json.RawMessage `json:"changes"`
+	IP             string          `json:"ip"`
+	UserAgent      string          `json:"user_agent"`
+	RequestID      string          `json:"request_id,omitempty"`
+	Method         string          `json:"method,omitempty"`
+	Path           string          `json:"path,omitempty"`
+	CreatedAt      string          `json:"created_at"`
+}
@@ -10,7 +10,6 @@ type CreateCourseRequest struct {
 	Category         string  `json:"category" validate:"required,min=2,max=100"`
 	Tags             string  `json:"tags"`
 	Price            float64 `json:"price" validate:"min=0"`
-	IsPublished      bool    `json:"is_published"`
 }
 
 type UpdateCourseRequest struct {
@@ -22,7 +21,6 @@ type UpdateCourseRequest struct {
 	Category         *string  `json:"category,omitempty" validate:"omitempty,min=2,max=100"`
 	Tags             *string  `json:"tags,omitempty"`
 	Price            *float64 `json:"price,omitempty" validate:"omitempty,min=0"`
-	IsPublished      *bool    `json:"is_published,omitempty"`
 }
 
 type CourseResponse struct {
@@ -36,14 +34,19 @@ type CourseResponse struct {
 	Tags             []string              `json:"tags"`
 	Duration         int                   `json:"duration"`
 	Price            float64               `json:"price"`
+	Status           string                `json:"status"`
 	IsPublished      bool                  `json:"is_published"`
+	PublishedVersion int                   `json:"published_version"`
+	PublishAt        *string               `json:"publish_at,omitempty"`
+	UnpublishAt      *string               `json:"unpublish_at,omitempty"`
 	CreatedBy        uint                  `json:"created_by"`
 	CreatedAt        string                `json:"created_at"`
 	UpdatedAt        string                `json:"updated_at"`
 	LessonCount      int                   `json:"lesson_count"`
 	EnrolledCount    int                   `json:"enrolled_count"`
 	CompletionRate   float64               `json:"completion_rate"`
-	Lessons          []LessonResponse      `json:"lessons,omitempty"`
+	Lessons          []LessonResponse      `json:"lessons,omitempty"`  // Lessons without a section
+	Sections         []SectionResponse     `json:"sections,omitempty"` // Sections with their lessons
 	IsEnrolled       bool                  `json:"is_enrolled,omitempty"`
 	UserProgress     *UserProgressResponse `json:"user_progress,omitempty"`
 }
@@ -70,4 +73,8 @@ type UserProgressResponse struct {
 	IsCompleted  bool    `json:"is_completed"`
 	EnrolledAt   string  `json:"enrolled_at"`
 	CompletedAt  *string `json:"completed_at,omitempty"`
+	// Version of the course the learner sees and the published one, both 0
+	// while the course has no version
+	CourseVersion int `json:"course_version"`
+	LatestVersion int `json:"latest_version"`
 }
@@ -0,0 +1,33 @@
+package dto
+
+// Course review DTOs
+type CourseReviewRequest struct {
+	Comment string `json:"comment" validate:"max=2000"`
+}
+
+// RejectCourseRequest tells the authors what to change, so the comment is required
+type RejectCourseRequest struct {
+	Comment string `json:"comment" validate:"required,min=1,max=2000"`
+}
+
+type CourseReviewResponse struct {
+	ID         uint   `json:"id"`
+	CourseID   uint   `json:"course_id"`
+	UserID     uint   `json:"user_id"`
+	Action     string `json:"action"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	Comment    string `json:"comment"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// ReviewQueueResponse is a course waiting for review with its latest submission
+type ReviewQueueResponse struct {
+	CourseID    uint   `json:"course_id"`
+	Title       string `json:"title"`
+	Category    string `json:"category"`
+	CreatedBy   uint   `json:"created_by"`
+	SubmittedBy uint   `json:"submitted_by"`
+	SubmittedAt string `json:"submitted_at"`
+	Comment     string `json:"comment"`
+}
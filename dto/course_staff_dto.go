@@ -0,0 +1,19 @@
+package dto
+
+// Course staff DTOs
+type AddCourseStaffRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=co_instructor teaching_assistant reviewer"`
+}
+
+type TransferOwnershipRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type CourseStaffResponse struct {
+	UserID  uint   `json:"user_id"`
+	Email   string `json:"email"`
+	Role    string `json:"role"`
+	AddedBy uint   `json:"added_by"`
+	AddedAt string `json:"added_at"`
+}
@@ -0,0 +1,40 @@
+package dto
+
+import "encoding/json"
+
+// Course version DTOs
+type PublishVersionRequest struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+type CourseVersionResponse struct {
+	CourseID     uint   `json:"course_id"`
+	Version      int    `json:"version"`
+	Note         string `json:"note"`
+	RestoredFrom int    `json:"restored_from,omitempty"` // version copied by a rollback
+	IsPublished  bool   `json:"is_published"`            // the version learners see unless pinned
+	PublishedBy  uint   `json:"published_by"`
+	PublishedAt  string `json:"published_at"`
+}
+
+type CourseVersionDetailResponse struct {
+	CourseVersionResponse
+	Content json.RawMessage `json:"content"` // course, sections and lessons
+}
+
+// CourseVersionDiffResponse lists what changed from one version to another.
+// From is 0 when diffing the first version, To is 0 for the current draft.
+type CourseVersionDiffResponse struct {
+	From     int                               `json:"from"`
+	To       int                               `json:"to"`
+	Course   map[string]map[string]interface{} `json:"course"` // {"field": {"old": ..., "new": ...}}
+	Sections []ContentChange                   `json:"sections"`
+	Lessons  []ContentChange                   `json:"lessons"`
+}
+
+type ContentChange struct {
+	ID     uint                              `json:"id"`
+	Title  string                            `json:"title"`
+	Change string                            `json:"change"` // added, removed or changed
+	Fields map[string]map[string]interface{} `json:"fields,omitempty"`
+}
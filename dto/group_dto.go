@@ -0,0 +1,24 @@
+package dto
+
+type AddGroupCourseRequest struct {
+	CourseID uint `json:"course_id" validate:"required"`
+}
+
+type GroupCourseResponse struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+}
+
+type GroupResponse struct {
+	ID          uint                  `json:"id"`
+	DisplayName string                `json:"display_name"`
+	ExternalID  string                `json:"external_id,omitempty"`
+	MemberCount int                   `json:"member_count"`
+	Courses     []GroupCourseResponse `json:"courses"`
+}
+
+type GroupEnrollmentResponse struct {
+	GroupID  uint `json:"group_id"`
+	CourseID uint `json:"course_id"`
+	Enrolled int  `json:"enrolled"` // members enrolled, including those who already were
+}
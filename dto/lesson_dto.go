@@ -8,6 +8,7 @@ type CreateLessonRequest struct {
 	VideoID     string `json:"video_id"`
 	Script      string `json:"script"`
 	Duration    int    `json:"duration" validate:"min=0"`
+	SectionID   *uint  `json:"section_id,omitempty"`
 	Sequence    int    `json:"sequence" validate:"required,min=1"`
 	IsPublished bool   `json:"is_published"`
 	IsFree      bool   `json:"is_free"`
@@ -20,26 +21,38 @@ type UpdateLessonRequest struct {
 	VideoID     *string `json:"video_id,omitempty"`
 	Script      *string `json:"script,omitempty"`
 	Duration    *int    `json:"duration,omitempty" validate:"omitempty,min=0"`
+	SectionID   *uint   `json:"section_id,omitempty"` // 0 takes the lesson out of its section
 	Sequence    *int    `json:"sequence,omitempty" validate:"omitempty,min=1"`
 	IsPublished *bool   `json:"is_published,omitempty"`
 	IsFree      *bool   `json:"is_free,omitempty"`
 }
 
 type LessonResponse struct {
-	ID          uint   `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	VideoURL    string `json:"video_url"`
-	VideoID     string `json:"video_id"`
-	Script      string `json:"script,omitempty"` // May be hidden for non-enrolled users
-	Duration    int    `json:"duration"`
-	CourseID    uint   `json:"course_id"`
-	Sequence    int    `json:"sequence"`
-	IsPublished bool   `json:"is_published"`
-	IsFree      bool   `json:"is_free"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
-	IsCompleted bool   `json:"is_completed,omitempty"` // For enrolled users
+	ID          uint    `json:"id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	VideoURL    string  `json:"video_url"`
+	VideoID     string  `json:"video_id"`
+	Script      string  `json:"script,omitempty"` // May be hidden for non-enrolled users
+	Duration    int     `json:"duration"`
+	CourseID    uint    `json:"course_id"`
+	SectionID   *uint   `json:"section_id,omitempty"`
+	Sequence    int     `json:"sequence"`
+	IsPublished bool    `json:"is_published"`
+	IsFree      bool    `json:"is_free"`
+	PublishAt   *string `json:"publish_at,omitempty"`
+	UnpublishAt *string `json:"unpublish_at,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+	IsCompleted bool    `json:"is_completed,omitempty"` // For enrolled users
+}
+
+// ReorderLessonRequest sets the sequence of a lesson, a SectionID also moves
+// the lesson to that section (0 takes it out of its section)
+type ReorderLessonRequest struct {
+	LessonID  uint  `json:"lesson_id" validate:"required"`
+	SectionID *uint `json:"section_id,omitempty"`
+	Sequence  int   `json:"sequence" validate:"required,min=1"`
 }
 
 type LessonListResponse struct {
@@ -66,17 +79,16 @@ type EnrollCourseRequest struct {
 
 // Search and filter DTOs
 type CourseFilterRequest struct {
-	Category    string   `query:"category"`
-	Level       string   `query:"level" validate:"omitempty,oneof=beginner intermediate advanced"`
-	MinPrice    *float64 `query:"min_price" validate:"omitempty,min=0"`
-	MaxPrice    *float64 `query:"max_price" validate:"omitempty,min=0"`
-	Tags        string   `query:"tags"` // comma-separated
-	Search      string   `query:"search"`
-	IsPublished *bool    `query:"is_published"`
-	Page        int      `query:"page" validate:"min=1"`
-	Limit       int      `query:"limit" validate:"min=1,max=100"`
-	SortBy      string   `query:"sort_by" validate:"omitempty,oneof=title created_at price duration enrolled_count"`
-	SortOrder   string   `query:"sort_order" validate:"omitempty,oneof=asc desc"`
+	Category  string   `query:"category"`
+	Level     string   `query:"level" validate:"omitempty,oneof=beginner intermediate advanced"`
+	MinPrice  *float64 `query:"min_price" validate:"omitempty,min=0"`
+	MaxPrice  *float64 `query:"max_price" validate:"omitempty,min=0"`
+	Tags      string   `query:"tags"` // comma-separated
+	Search    string   `query:"search"`
+	Page      int      `query:"page" validate:"min=1"`
+	Limit     int      `query:"limit" validate:"min=1,max=100"`
+	SortBy    string   `query:"sort_by" validate:"omitempty,oneof=title created_at price duration enrolled_count"`
+	SortOrder string   `query:"sort_order" validate:"omitempty,oneof=asc desc"`
 }
 
 // Response wrapper for paginated results
@@ -0,0 +1,8 @@
+package dto
+
+// OIDCCallbackRequest carries the query parameters the IdP appended to the
+// redirect URL
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
@@ -0,0 +1,75 @@
+package dto
+
+// EraseAccountRequest confirms the erasure of the current account. Accounts
+// without a password (single sign-on, SCIM) send no password.
+type EraseAccountRequest struct {
+	CurrentPassword string `json:"current_password"`
+}
+
+// DataExportResponse is the state of the data export of the current user
+type DataExportResponse struct {
+	Status      string  `json:"status"` // pending or ready
+	RequestedAt string  `json:"requested_at"`
+	CompletedAt *string `json:"completed_at,omitempty"`
+	ExpiresAt   *string `json:"expires_at,omitempty"`
+}
+
+// The files of the data export archive
+
+type ExportProfile struct {
+	ID             uint    `json:"id"`
+	OrganizationID uint    `json:"organization_id"`
+	Email          string  `json:"email"`
+	Role           string  `json:"role"`
+	Name           string  `json:"name"`
+	AvatarURL      string  `json:"avatar_url"`
+	Bio            string  `json:"bio"`
+	Timezone       string  `json:"timezone"`
+	Locale         string  `json:"locale"`
+	VerifiedAt     *string `json:"verified_at,omitempty"`
+	MFAEnabled     bool    `json:"mfa_enabled"`
+	SuspendedAt    *string `json:"suspended_at,omitempty"`
+	ExternalID     string  `json:"external_id,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+	UpdatedAt      string  `json:"updated_at"`
+}
+
+type ExportEnrollment struct {
+	CourseID     uint    `json:"course_id"`
+	CourseTitle  string  `json:"course_title"`
+	Progress     float64 `json:"progress"`
+	LastLessonID uint    `json:"last_lesson_id"`
+	IsCompleted  bool    `json:"is_completed"`
+	EnrolledAt   string  `json:"enrolled_at"`
+	CompletedAt  *string `json:"completed_at,omitempty"`
+}
+
+type ExportLessonProgress struct {
+	CourseID    uint    `json:"course_id"`
+	LessonID    uint    `json:"lesson_id"`
+	LessonTitle string  `json:"lesson_title"`
+	IsCompleted bool    `json:"is_completed"`
+	WatchTime   int     `json:"watch_time"` // in seconds
+	CompletedAt *string `json:"completed_at,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+}
+
+type ExportIdentity struct {
+	Issuer      string  `json:"issuer"`
+	Subject     string  `json:"subject"`
+	Email       string  `json:"email"`
+	LastLoginAt *string `json:"last_login_at,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+type ExportGroup struct {
+	ID          uint   `json:"id"`
+	DisplayName string `json:"display_name"`
+}
+
+type ExportCourseRole struct {
+	CourseID  uint   `json:"course_id"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
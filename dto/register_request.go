@@ -1,6 +1,8 @@
 package dto
 
+// RegisterRequest only checks the shape of the request, the password itself is
+// checked against the configured password policy by the auth service
 type RegisterRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
 }
@@ -0,0 +1,15 @@
+package dto
+
+import "time"
+
+// PublishScheduleRequest replaces the schedule of a course or lesson, a
+// missing or null date clears it
+type PublishScheduleRequest struct {
+	PublishAt   *time.Time `json:"publish_at"`   // RFC 3339
+	UnpublishAt *time.Time `json:"unpublish_at"` // RFC 3339, after publish_at
+}
+
+type PublishScheduleResponse struct {
+	PublishAt   *string `json:"publish_at"`
+	UnpublishAt *string `json:"unpublish_at"`
+}
@@ -0,0 +1,102 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SCIM 2.0 schema and message URNs (RFC 7643, RFC 7644)
+const (
+	SCIMUserSchema     = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMGroupSchema    = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMListSchema     = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMPatchOpSchema  = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMErrorSchema    = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCIMSPConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCIMResourceSchema = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+// SCIMListRequest holds the query parameters of a list request. Count is nil
+// when the client did not send one.
+type SCIMListRequest struct {
+	Filter     string
+	StartIndex int
+	Count      *int
+}
+
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMUser is the SCIM view of domain.User, userName is the email address
+type SCIMUser struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	Name        *SCIMName   `json:"name,omitempty"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Emails      []SCIMEmail `json:"emails,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Locale      string      `json:"locale,omitempty"`
+	Timezone    string      `json:"timezone,omitempty"`
+	Meta        *SCIMMeta   `json:"meta,omitempty"`
+}
+
+type SCIMMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type SCIMGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []SCIMMember `json:"members"`
+	Meta        *SCIMMeta    `json:"meta,omitempty"`
+}
+
+type SCIMListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int64         `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// SCIMPatchOperation is a single add, remove or replace. Value is kept raw
+// since its shape depends on the path.
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
@@ -0,0 +1,33 @@
+package dto
+
+// Section DTOs
+type CreateSectionRequest struct {
+	Title       string `json:"title" validate:"required,min=3,max=200"`
+	Description string `json:"description" validate:"max=1000"`
+	Sequence    int    `json:"sequence" validate:"min=0"` // 0 appends the section
+	IsPublished bool   `json:"is_published"`
+}
+
+type UpdateSectionRequest struct {
+	Title       *string `json:"title,omitempty" validate:"omitempty,min=3,max=200"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=1000"`
+	Sequence    *int    `json:"sequence,omitempty" validate:"omitempty,min=1"`
+	IsPublished *bool   `json:"is_published,omitempty"`
+}
+
+type ReorderSectionRequest struct {
+	SectionID uint `json:"section_id" validate:"required"`
+	Sequence  int  `json:"sequence" validate:"required,min=1"`
+}
+
+type SectionResponse struct {
+	ID          uint             `json:"id"`
+	CourseID    uint             `json:"course_id"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Sequence    int              `json:"sequence"`
+	IsPublished bool             `json:"is_published"`
+	CreatedAt   string           `json:"created_at"`
+	UpdatedAt   string           `json:"updated_at"`
+	Lessons     []LessonResponse `json:"lessons,omitempty"` // Only in the course tree
+}
@@ -0,0 +1,10 @@
+package dto
+
+// TrashItemResponse is a course or lesson in the trash
+type TrashItemResponse struct {
+	ID        uint   `json:"id"`
+	Title     string `json:"title"`
+	CourseID  uint   `json:"course_id,omitempty"` // lessons only
+	DeletedAt string `json:"deleted_at"`
+	PurgeAt   string `json:"purge_at"` // removed for good from this time on
+}
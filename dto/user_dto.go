@@ -0,0 +1,72 @@
+package dto
+
+type UpdateProfileRequest struct {
+	Name      *string `json:"name,omitempty" validate:"omitempty,max=100"`
+	AvatarURL *string `json:"avatar_url,omitempty" validate:"omitempty,url"`
+	Bio       *string `json:"bio,omitempty" validate:"omitempty,max=1000"`
+	Timezone  *string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	Locale    *string `json:"locale,omitempty" validate:"omitempty,bcp47_language_tag"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"new_email" validate:"required,email"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"` // checked against the password policy
+}
+
+type ProfileResponse struct {
+	ID         uint    `json:"id"`
+	Email      string  `json:"email"`
+	Role       string  `json:"role"`
+	Name       string  `json:"name"`
+	AvatarURL  string  `json:"avatar_url"`
+	Bio        string  `json:"bio"`
+	Timezone   string  `json:"timezone"`
+	Locale     string  `json:"locale"`
+	Verified   bool    `json:"verified"`
+	VerifiedAt *string `json:"verified_at,omitempty"`
+	MFAEnabled bool    `json:"mfa_enabled"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+}
+
+type UserFilterRequest struct {
+	Search    string `query:"search"` // email or name
+	Role      string `query:"role" validate:"omitempty,oneof=admin instructor learner"`
+	Status    string `query:"status" validate:"omitempty,oneof=active suspended"`
+	Verified  *bool  `query:"verified"`
+	Service   *bool  `query:"service_account"`
+	Page      int    `query:"page" validate:"omitempty,min=1"`
+	Limit     int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	SortBy    string `query:"sort_by" validate:"omitempty,oneof=email name created_at"`
+	SortOrder string `query:"sort_order" validate:"omitempty,oneof=asc desc"`
+}
+
+type ChangeRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin instructor learner"`
+}
+
+type AdminUserResponse struct {
+	ID                    uint    `json:"id"`
+	Email                 string  `json:"email"`
+	Role                  string  `json:"role"`
+	Name                  string  `json:"name"`
+	Verified              bool    `json:"verified"`
+	MFAEnabled            bool    `json:"mfa_enabled"`
+	Suspended             bool    `json:"suspended"`
+	SuspendedAt           *string `json:"suspended_at,omitempty"`
+	PasswordResetRequired bool    `json:"password_reset_required"`
+	ServiceAccount        bool    `json:"service_account"`
+	CreatedAt             string  `json:"created_at"`
+}
+
+type ImpersonationResponse struct {
+	AccessToken    string `json:"access_token"`
+	ExpiresAt      int64  `json:"expires_at"`
+	UserID         uint   `json:"user_id"`
+	ImpersonatorID uint   `json:"impersonator_id"`
+}
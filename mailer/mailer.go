@@ -0,0 +1,36 @@
+package mailer
+
+import (
+	"fmt"
+
+	"github.com/rijwanansari/vivaLearning/config"
+)
+
+// Supported values of config.MailConfig.Driver
+const (
+	DriverSMTP = "smtp"
+	DriverFile = "file"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+// Mailer delivers transactional emails (password reset, verification, ...)
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New returns the mailer selected by the mail config
+func New(conf *config.MailConfig) (Mailer, error) {
+	switch conf.Driver {
+	case DriverSMTP:
+		return NewSMTPMailer(conf), nil
+	case "", DriverFile:
+		return NewFileMailer(conf.FilePath), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %q", conf.Driver)
+	}
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+)
+
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(conf *config.MailConfig) *SMTPMailer {
+	return &SMTPMailer{
+		host:     conf.Host,
+		port:     conf.Port,
+		username: conf.Username,
+		password: conf.Password,
+		from:     conf.From,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	headers := []string{
+		"From: " + m.from,
+		"To: " + msg.To,
+		"Subject: " + msg.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	body := strings.Join(headers, "\r\n") + "\r\n\r\n" + msg.Body
+
+	if err := smtp.SendMail(m.host+":"+m.port, auth, m.from, []string{msg.To}, []byte(body)); err != nil {
+		return fmt.Errorf("failed to send mail to %s: %w", msg.To, err)
+	}
+
+	return nil
+}
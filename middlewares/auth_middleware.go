@@ -0,0 +1,114 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/msgutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+// ImpersonatedByHeader is set on every response to an impersonated request and
+// holds the ID of the admin acting as the user
+const ImpersonatedByHeader = "X-Impersonated-By"
+
+// APIKeyRoutes maps "METHOD /route/path" to the scope an API key needs to call
+// the route. Routes that are not listed cannot be called with an API key.
+type APIKeyRoutes map[string]string
+
+// AuthMiddleware authenticates the request either with an access token
+// ("Authorization: Bearer <jwt>") or with an API key ("Authorization: ApiKey <key>")
+func AuthMiddleware(tokenService domain.TokenService, sessionService services.SessionService, apiKeyService services.APIKeyService, apiKeyRoutes APIKeyRoutes) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			authHeader := c.Request().Header.Get("Authorization")
+
+			switch {
+			case strings.HasPrefix(authHeader, "Bearer "):
+				return authenticateToken(c, next, tokenService, sessionService, strings.TrimPrefix(authHeader, "Bearer "))
+			case strings.HasPrefix(authHeader, "ApiKey "):
+				return authenticateAPIKey(c, next, apiKeyService, apiKeyRoutes, strings.TrimPrefix(authHeader, "ApiKey "))
+			}
+
+			return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Missing token"})
+		}
+	}
+}
+
+func authenticateToken(c echo.Context, next echo.HandlerFunc, tokenService domain.TokenService, sessionService services.SessionService, accessToken string) error {
+	token, err := tokenService.ParseAccessToken(accessToken)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Invalid token"})
+	}
+
+	// a token is only valid while its uuid is still present in redis (not logged out)
+	userID, err := tokenService.ReadUserIDFromAccessTokenUUID(token.AccessUuid)
+	if err != nil || userID != token.UserID {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Invalid token"})
+	}
+
+	// tokens from before organizations carry no tenant and are not accepted
+	if token.OrgID == 0 {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Invalid token"})
+	}
+	if !pinOrganization(c, uint(token.OrgID)) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": errutil.ErrOrganizationMismatch.Error()})
+	}
+
+	if token.ImpersonatorID != 0 {
+		// make impersonated responses recognisable to the client and to proxies
+		c.Response().Header().Set(ImpersonatedByHeader, strconv.Itoa(token.ImpersonatorID))
+		if !config.Auth().ImpersonationAllowWrites && !impersonationAllowed(c) {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": errutil.ErrImpersonationReadOnly.Error()})
+		}
+		c.Set("impersonator_id", uint(token.ImpersonatorID))
+	}
+
+	client := types.ClientInfo{IP: c.RealIP(), UserAgent: c.Request().UserAgent()}
+	if err := sessionService.Touch(token, client); err != nil {
+		logger.Error(err)
+	}
+
+	c.Set("user_id", uint(token.UserID))
+	c.Set("role", domain.NormalizeRole(token.Role))
+	c.Set("token", token)
+	return next(c)
+}
+
+// impersonationAllowed reports whether an impersonation token may call the
+// route: reads, and ending the impersonation by logging out
+func impersonationAllowed(c echo.Context) bool {
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return c.Path() == "/api/v1/auth/logout"
+}
+
+func authenticateAPIKey(c echo.Context, next echo.HandlerFunc, apiKeyService services.APIKeyService, apiKeyRoutes APIKeyRoutes, rawKey string) error {
+	key, err := apiKeyService.Authenticate(strings.TrimSpace(rawKey), c.RealIP())
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Invalid API key"})
+	}
+
+	scope, ok := apiKeyRoutes[c.Request().Method+" "+c.Path()]
+	if !ok || !key.HasScope(scope) {
+		return c.JSON(http.StatusForbidden, msgutil.AccessForbiddenMsg())
+	}
+
+	if !pinOrganization(c, key.User.OrganizationID) {
+		return c.JSON(http.StatusForbidden, echo.Map{"error": errutil.ErrOrganizationMismatch.Error()})
+	}
+
+	c.Set("user_id", key.UserID)
+	c.Set("role", domain.NormalizeRole(key.User.Role))
+	c.Set("api_key", key)
+	return next(c)
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/msgutil"
+)
+
+// RequirePermission only lets the request through when the role of the
+// authenticated user grants the permission. It must run after AuthMiddleware.
+func RequirePermission(permissionService services.PermissionService, permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, _ := c.Get("role").(string)
+
+			allowed, err := permissionService.HasPermission(role, permission)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+			}
+			if !allowed {
+				return c.JSON(http.StatusForbidden, msgutil.AccessForbiddenMsg())
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+// SCIMAuth authenticates the provisioning client with the SCIM bearer token
+// of its organization. The organization is taken from the token alone, the
+// X-Organization header and the subdomain are ignored, so a client can never
+// provision users into another organization.
+func SCIMAuth(organizationService services.OrganizationService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+
+			organization, err := organizationService.AuthenticateSCIM(token)
+			if err != nil {
+				if !errors.Is(err, errutil.ErrInvalidSCIMToken) {
+					logger.Error(err)
+				}
+				return SCIMError(c, http.StatusUnauthorized, "", "Invalid bearer token")
+			}
+
+			c.Set("organization_id", organization.ID)
+			return next(c)
+		}
+	}
+}
+
+// SCIMError writes an error in the SCIM error format (RFC 7644 section 3.12)
+func SCIMError(c echo.Context, status int, scimType, detail string) error {
+	c.Response().Header().Set(echo.HeaderContentType, "application/scim+json")
+	return c.JSON(status, dto.SCIMError{
+		Schemas:  []string{dto.SCIMErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
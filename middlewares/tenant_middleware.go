@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/services"
+	"github.com/rijwanansari/vivaLearning/utils/msgutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+// OrganizationHeader names the organization of a request when no subdomain is used
+const OrganizationHeader = "X-Organization"
+
+// Tenant resolves the organization a request is made for, from the
+// X-Organization header or from the subdomain below APP_TENANT_DOMAIN. Without
+// either, the default organization is used. AuthMiddleware later pins
+// authenticated requests to the organization in their token.
+func Tenant(organizationService services.OrganizationService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			slug := requestedOrganization(c.Request())
+			requested := slug != ""
+			if !requested {
+				slug = domain.DefaultOrganizationSlug
+			}
+
+			organization, err := organizationService.GetBySlug(slug)
+			if err != nil {
+				if requested {
+					return c.JSON(http.StatusNotFound, echo.Map{"error": "Organization not found"})
+				}
+				logger.Error(err)
+				return c.JSON(http.StatusInternalServerError, msgutil.SomethingWentWrongMsg())
+			}
+
+			if requested {
+				c.Set("requested_organization_id", organization.ID)
+			}
+			c.Set("organization_id", organization.ID)
+			return next(c)
+		}
+	}
+}
+
+// requestedOrganization returns the slug named by the header or the subdomain
+func requestedOrganization(r *http.Request) string {
+	if slug := strings.TrimSpace(r.Header.Get(OrganizationHeader)); slug != "" {
+		return slug
+	}
+
+	tenantDomain := strings.ToLower(config.App().TenantDomain)
+	if tenantDomain == "" {
+		return ""
+	}
+
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	// only a single label directly below the tenant domain names an organization
+	label := strings.TrimSuffix(host, "."+tenantDomain)
+	if label == host || label == "" || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// pinOrganization binds an authenticated request to the organization of its
+// credentials. It reports false when the request named another organization.
+func pinOrganization(c echo.Context, organizationID uint) bool {
+	if requested, ok := c.Get("requested_organization_id").(uint); ok && requested != organizationID {
+		return false
+	}
+
+	c.Set("organization_id", organizationID)
+	return true
+}
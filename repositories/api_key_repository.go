@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(key *domain.APIKey) error
+	GetByPrefix(prefix string) (*domain.APIKey, error)
+	GetByID(id uint) (*domain.APIKey, error)
+	GetByUser(userID uint) ([]domain.APIKey, error)
+	Revoke(id uint) error
+	TouchLastUsed(id uint, ip string, olderThan time.Time) error
+}
+
+type APIKeyRepositoryImp struct {
+	DB *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &APIKeyRepositoryImp{DB: db}
+}
+
+func (r *APIKeyRepositoryImp) Create(key *domain.APIKey) error {
+	return r.DB.Create(key).Error
+}
+
+// GetByPrefix loads the key together with its owner
+func (r *APIKeyRepositoryImp) GetByPrefix(prefix string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	if err := r.DB.Preload("User").Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepositoryImp) GetByID(id uint) (*domain.APIKey, error) {
+	var key domain.APIKey
+	if err := r.DB.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepositoryImp) GetByUser(userID uint) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	err := r.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *APIKeyRepositoryImp) Revoke(id uint) error {
+	return r.DB.Model(&domain.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// TouchLastUsed records a use of the key, skipping the write when the last
+// recorded use is more recent than olderThan
+func (r *APIKeyRepositoryImp) TouchLastUsed(id uint, ip string, olderThan time.Time) error {
+	return r.DB.Model(&domain.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, olderThan).
+		Updates(map[string]interface{}{"last_used_at": time.Now(), "last_used_ip": ip}).Error
+}
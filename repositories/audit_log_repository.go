@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"gorm.io/gorm"
+)
+
+// AuditLogRepository is append-only, entries are never updated and only
+// removed once they are older than the retention window
+type AuditLogRepository interface {
+	Create(entry *domain.AuditLog) error
+	Find(filter dto.AuditLogFilterRequest) ([]domain.AuditLog, int64, error)
+	FindInBatches(filter dto.AuditLogFilterRequest, batchSize int, fn func(entries []domain.AuditLog) error) error
+	DeleteBefore(before time.Time) (int64, error)
+
+	// ForOrganization returns a copy that only sees the entries of the organization
+	ForOrganization(organizationID uint) AuditLogRepository
+}
+
+type AuditLogRepositoryImp struct {
+	DB             *gorm.DB
+	OrganizationID uint // AllOrganizations for the unscoped repository
+}
+
+func NewAuditLogRepository(db *gorm.DB) AuditLogRepository {
+	return &AuditLogRepositoryImp{DB: db, OrganizationID: AllOrganizations}
+}
+
+func (r *AuditLogRepositoryImp) ForOrganization(organizationID uint) AuditLogRepository {
+	return &AuditLogRepositoryImp{DB: r.DB, OrganizationID: organizationID}
+}
+
+func (r *AuditLogRepositoryImp) tenant() *gorm.DB {
+	return r.DB.Scopes(tenantScope("audit_logs", r.OrganizationID))
+}
+
+func (r *AuditLogRepositoryImp) Create(entry *domain.AuditLog) error {
+	if entry.Changes == "" {
+		entry.Changes = "{}"
+	}
+	return r.DB.Create(entry).Error
+}
+
+func (r *AuditLogRepositoryImp) Find(filter dto.AuditLogFilterRequest) ([]domain.AuditLog, int64, error) {
+	var entries []domain.AuditLog
+	var total int64
+
+	query := r.filter(filter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (filter.Page - 1) * filter.Limit
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(filter.Limit).Find(&entries).Error
+	return entries, total, err
+}
+
+// FindInBatches walks the matching entries oldest first
+func (r *AuditLogRepositoryImp) FindInBatches(filter dto.AuditLogFilterRequest, batchSize int, fn func(entries []domain.AuditLog) error) error {
+	var entries []domain.AuditLog
+	return r.filter(filter).Order("id ASC").FindInBatches(&entries, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(entries)
+	}).Error
+}
+
+func (r *AuditLogRepositoryImp) DeleteBefore(before time.Time) (int64, error) {
+	result := r.tenant().Where("created_at < ?", before).Delete(&domain.AuditLog{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *AuditLogRepositoryImp) filter(filter dto.AuditLogFilterRequest) *gorm.DB {
+	query := r.tenant().Model(&domain.AuditLog{})
+
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	return query
+}
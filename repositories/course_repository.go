@@ -2,6 +2,7 @@ package repository
 
 import (
 	"strings"
+	"time"
 
 	"github.com/rijwanansari/vivaLearning/domain"
 	"github.com/rijwanansari/vivaLearning/dto"
@@ -10,47 +11,86 @@ import (
 
 type CourseRepository interface {
 	// Basic CRUD operations
-	Create(course *domain.Course) error
+	// CreateWithOwner stores the course and its owner staff row, both or neither
+	CreateWithOwner(course *domain.Course, owner *domain.CourseStaff) error
 	GetByID(id uint) (*domain.Course, error)
 	GetByIDWithLessons(id uint) (*domain.Course, error)
 	Update(course *domain.Course) error
+	// SetSchedule replaces the publish and unpublish dates of the course, nil clears one
+	SetSchedule(id uint, publishAt, unpublishAt *time.Time) error
+	// Delete moves the course to the trash, its lessons and enrollments are kept
 	Delete(id uint) error
 	List() ([]domain.Course, error)
 
+	// Trash
+	GetDeleted() ([]domain.Course, error)
+	GetDeletedByID(id uint) (*domain.Course, error)
+	// Restore takes the course out of the trash
+	Restore(id uint) error
+
 	// Advanced operations
 	GetPublishedCourses() ([]domain.Course, error)
+	GetCoursesByStatus(status string) ([]domain.Course, error)
 	GetCoursesByCreator(creatorID uint) ([]domain.Course, error)
 	SearchCourses(filter dto.CourseFilterRequest) ([]domain.Course, int64, error)
 	GetUserEnrolledCourses(userID uint) ([]domain.Course, error)
 
 	// Statistics
 	GetCourseStats(courseID uint) (lessonCount int, enrolledCount int, avgProgress float64, err error)
+
+	// ForOrganization returns a copy that only sees the courses of the organization
+	ForOrganization(organizationID uint) CourseRepository
 }
 
 type CourseRepositoryImp struct {
-	DB *gorm.DB
+	DB             *gorm.DB
+	OrganizationID uint // AllOrganizations for the unscoped repository
 }
 
 func NewCourseRepository(db *gorm.DB) CourseRepository {
-	return &CourseRepositoryImp{DB: db}
+	return &CourseRepositoryImp{DB: db, OrganizationID: AllOrganizations}
 }
 
-func (r *CourseRepositoryImp) Create(course *domain.Course) error {
-	return r.DB.Create(course).Error
+func (r *CourseRepositoryImp) ForOrganization(organizationID uint) CourseRepository {
+	return &CourseRepositoryImp{DB: r.DB, OrganizationID: organizationID}
+}
+
+func (r *CourseRepositoryImp) tenant() *gorm.DB {
+	return r.DB.Scopes(tenantScope("courses", r.OrganizationID))
+}
+
+// CreateWithOwner stores the course in the organization of the repository.
+// A course without its owner row could only be edited by an admin, so both
+// are written in one transaction.
+func (r *CourseRepositoryImp) CreateWithOwner(course *domain.Course, owner *domain.CourseStaff) error {
+	if err := assignOrganization(&course.OrganizationID, r.OrganizationID); err != nil {
+		return err
+	}
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(course).Error; err != nil {
+			return err
+		}
+		owner.CourseID = course.ID
+		return tx.Create(owner).Error
+	})
 }
 
 func (r *CourseRepositoryImp) GetByID(id uint) (*domain.Course, error) {
 	var course domain.Course
-	err := r.DB.First(&course, id).Error
+	err := r.tenant().First(&course, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &course, nil
 }
 
+// GetByIDWithLessons loads the course with its sections and all of its
+// lessons, each ordered by sequence
 func (r *CourseRepositoryImp) GetByIDWithLessons(id uint) (*domain.Course, error) {
 	var course domain.Course
-	err := r.DB.Preload("Lessons", func(db *gorm.DB) *gorm.DB {
+	err := r.tenant().Preload("Sections", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence ASC, id ASC")
+	}).Preload("Lessons", func(db *gorm.DB) *gorm.DB {
 		return db.Order("sequence ASC")
 	}).First(&course, id).Error
 	if err != nil {
@@ -59,29 +99,92 @@ func (r *CourseRepositoryImp) GetByIDWithLessons(id uint) (*domain.Course, error
 	return &course, nil
 }
 
+// Update saves every column. Selecting them explicitly keeps Save from falling
+// back to an upsert when the course belongs to another organization. The
+// published version, the status and the schedule are left alone, only
+// publishing, the review workflow and SetSchedule move them.
 func (r *CourseRepositoryImp) Update(course *domain.Course) error {
-	return r.DB.Save(course).Error
+	return r.tenant().Select("*").Omit("published_version", "status", "is_published", "publish_at", "unpublish_at").Save(course).Error
+}
+
+func (r *CourseRepositoryImp) SetSchedule(id uint, publishAt, unpublishAt *time.Time) error {
+	result := r.tenant().Model(&domain.Course{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"publish_at": publishAt, "unpublish_at": unpublishAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// liveCourses limits a query to the courses in the catalog at the time, see
+// domain.Course.IsLive
+func liveCourses(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("courses.status = ? AND (courses.unpublish_at IS NULL OR courses.unpublish_at > ?)",
+			domain.CourseStatusPublished, now)
+	}
 }
 
 func (r *CourseRepositoryImp) Delete(id uint) error {
-	return r.DB.Delete(&domain.Course{}, id).Error
+	return r.tenant().Delete(&domain.Course{}, id).Error
+}
+
+// GetDeleted returns the courses in the trash, most recently deleted first
+func (r *CourseRepositoryImp) GetDeleted() ([]domain.Course, error) {
+	var courses []domain.Course
+	err := r.tenant().Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&courses).Error
+	return courses, err
+}
+
+func (r *CourseRepositoryImp) GetDeletedByID(id uint) (*domain.Course, error) {
+	var course domain.Course
+	err := r.tenant().Unscoped().Where("deleted_at IS NOT NULL").First(&course, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &course, nil
+}
+
+func (r *CourseRepositoryImp) Restore(id uint) error {
+	result := r.tenant().Unscoped().Model(&domain.Course{}).Where("id = ? AND deleted_at IS NOT NULL", id).
+		UpdateColumn("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 func (r *CourseRepositoryImp) List() ([]domain.Course, error) {
 	var courses []domain.Course
-	err := r.DB.Preload("Lessons").Order("created_at DESC").Find(&courses).Error
+	err := r.tenant().Preload("Lessons").Order("created_at DESC").Find(&courses).Error
 	return courses, err
 }
 
 func (r *CourseRepositoryImp) GetPublishedCourses() ([]domain.Course, error) {
 	var courses []domain.Course
-	err := r.DB.Where("is_published = ?", true).Order("created_at DESC").Find(&courses).Error
+	err := r.tenant().Scopes(liveCourses(time.Now())).Order("created_at DESC").Find(&courses).Error
 	return courses, err
 }
 
+// GetCoursesByStatus returns the courses in the status, oldest change first
+func (r *CourseRepositoryImp) GetCoursesByStatus(status string) ([]domain.Course, error) {
+	var courses []domain.Course
+	err := r.tenant().Where("status = ?", status).Order("updated_at ASC").Find(&courses).Error
+	return courses, err
+}
+
+// GetCoursesByCreator returns the courses the user created or is a staff member of
 func (r *CourseRepositoryImp) GetCoursesByCreator(creatorID uint) ([]domain.Course, error) {
 	var courses []domain.Course
-	err := r.DB.Where("created_by = ?", creatorID).Order("created_at DESC").Find(&courses).Error
+	err := r.tenant().Where("created_by = ? OR id IN (?)", creatorID,
+		r.DB.Model(&domain.CourseStaff{}).Select("course_id").Where("user_id = ?", creatorID)).
+		Order("created_at DESC").Find(&courses).Error
 	return courses, err
 }
 
@@ -89,7 +192,8 @@ func (r *CourseRepositoryImp) SearchCourses(filter dto.CourseFilterRequest) ([]d
 	var courses []domain.Course
 	var total int64
 
-	query := r.DB.Model(&domain.Course{})
+	// the catalog only lists courses that passed review and were published
+	query := r.tenant().Model(&domain.Course{}).Scopes(liveCourses(time.Now()))
 
 	// Apply filters
 	if filter.Category != "" {
@@ -108,10 +212,6 @@ func (r *CourseRepositoryImp) SearchCourses(filter dto.CourseFilterRequest) ([]d
 		query = query.Where("price <= ?", *filter.MaxPrice)
 	}
 
-	if filter.IsPublished != nil {
-		query = query.Where("is_published = ?", *filter.IsPublished)
-	}
-
 	if filter.Search != "" {
 		searchTerm := "%" + filter.Search + "%"
 		query = query.Where("title ILIKE ? OR description ILIKE ?", searchTerm, searchTerm)
@@ -153,7 +253,7 @@ func (r *CourseRepositoryImp) SearchCourses(filter dto.CourseFilterRequest) ([]d
 
 func (r *CourseRepositoryImp) GetUserEnrolledCourses(userID uint) ([]domain.Course, error) {
 	var courses []domain.Course
-	err := r.DB.Joins("JOIN user_courses ON user_courses.course_id = courses.id").
+	err := r.tenant().Joins("JOIN user_courses ON user_courses.course_id = courses.id").
 		Where("user_courses.user_id = ?", userID).
 		Order("user_courses.enrolled_at DESC").
 		Find(&courses).Error
@@ -163,7 +263,7 @@ func (r *CourseRepositoryImp) GetUserEnrolledCourses(userID uint) ([]domain.Cour
 func (r *CourseRepositoryImp) GetCourseStats(courseID uint) (lessonCount int, enrolledCount int, avgProgress float64, err error) {
 	// Get lesson count
 	var lessonCountInt64 int64
-	err = r.DB.Model(&domain.Lesson{}).Where("course_id = ?", courseID).Count(&lessonCountInt64).Error
+	err = r.DB.Model(&domain.Lesson{}).Scopes(tenantScope("lessons", r.OrganizationID)).Where("course_id = ?", courseID).Count(&lessonCountInt64).Error
 	if err != nil {
 		return 0, 0, 0, err
 	}
@@ -171,7 +271,7 @@ func (r *CourseRepositoryImp) GetCourseStats(courseID uint) (lessonCount int, en
 
 	// Get enrolled count
 	var enrolledCountInt64 int64
-	err = r.DB.Model(&domain.UserCourse{}).Where("course_id = ?", courseID).Count(&enrolledCountInt64).Error
+	err = r.DB.Model(&domain.UserCourse{}).Scopes(tenantScope("user_courses", r.OrganizationID)).Where("course_id = ?", courseID).Count(&enrolledCountInt64).Error
 	if err != nil {
 		return 0, 0, 0, err
 	}
@@ -181,7 +281,7 @@ func (r *CourseRepositoryImp) GetCourseStats(courseID uint) (lessonCount int, en
 	var result struct {
 		AvgProgress float64
 	}
-	err = r.DB.Model(&domain.UserCourse{}).
+	err = r.DB.Model(&domain.UserCourse{}).Scopes(tenantScope("user_courses", r.OrganizationID)).
 		Select("COALESCE(AVG(progress), 0) as avg_progress").
 		Where("course_id = ?", courseID).
 		Scan(&result).Error
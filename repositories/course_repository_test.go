@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/utils/testdb"
+)
+
+func TestGetPublishedCoursesWaitsForTheScheduler(t *testing.T) {
+	db := testdb.Open(t)
+	organization := domain.Organization{Name: "Acme", Slug: "acme"}
+	mustCreate(t, db, &organization)
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	courses := map[string]*domain.Course{
+		"published":                 {Status: domain.CourseStatusPublished, IsPublished: true},
+		"published until later":     {Status: domain.CourseStatusPublished, IsPublished: true, UnpublishAt: &future},
+		"published, unpublish due":  {Status: domain.CourseStatusPublished, IsPublished: true, UnpublishAt: &past},
+		"approved, publish due":     {Status: domain.CourseStatusApproved, PublishAt: &past},
+		"approved, publish pending": {Status: domain.CourseStatusApproved, PublishAt: &future},
+		"archived":                  {Status: domain.CourseStatusArchived},
+	}
+	for title, course := range courses {
+		course.OrganizationID = organization.ID
+		course.Title = title
+		mustCreate(t, db, course)
+	}
+
+	live, err := NewCourseRepository(db).ForOrganization(organization.ID).GetPublishedCourses()
+	if err != nil {
+		t.Fatalf("GetPublishedCourses: %v", err)
+	}
+
+	got := map[string]bool{}
+	for _, course := range live {
+		got[course.Title] = true
+	}
+	for title, course := range courses {
+		want := title == "published" || title == "published until later"
+		if got[title] != want {
+			t.Errorf("%s listed = %v, want %v", title, got[title], want)
+		}
+		if live := course.IsLive(time.Now()); live != want {
+			t.Errorf("%s IsLive = %v, want %v", title, live, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+)
+
+type CourseReviewRepository interface {
+	// Transition moves the course of the review from its FromStatus to its
+	// ToStatus and records the review. It reports false, changing nothing,
+	// when the course is no longer in FromStatus.
+	Transition(review *domain.CourseReview) (bool, error)
+	// GetReviews returns the review history of the course, oldest first
+	GetReviews(courseID uint) ([]domain.CourseReview, error)
+
+	// ForOrganization returns a copy that only sees the reviews of the organization
+	ForOrganization(organizationID uint) CourseReviewRepository
+}
+
+type CourseReviewRepositoryImp struct {
+	DB             *gorm.DB
+	OrganizationID uint // AllOrganizations for the unscoped repository
+}
+
+func NewCourseReviewRepository(db *gorm.DB) CourseReviewRepository {
+	return &CourseReviewRepositoryImp{DB: db, OrganizationID: AllOrganizations}
+}
+
+func (r *CourseReviewRepositoryImp) ForOrganization(organizationID uint) CourseReviewRepository {
+	return &CourseReviewRepositoryImp{DB: r.DB, OrganizationID: organizationID}
+}
+
+func (r *CourseReviewRepositoryImp) tenant() *gorm.DB {
+	return r.DB.Scopes(tenantScope("course_reviews", r.OrganizationID))
+}
+
+func (r *CourseReviewRepositoryImp) Transition(review *domain.CourseReview) (bool, error) {
+	moved := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		columns := map[string]interface{}{
+			"status":       review.ToStatus,
+			"is_published": review.ToStatus == domain.CourseStatusPublished,
+		}
+		// a schedule is used once, publishing or archiving by hand also consumes it
+		switch review.ToStatus {
+		case domain.CourseStatusPublished:
+			columns["publish_at"] = nil
+		case domain.CourseStatusArchived:
+			columns["unpublish_at"] = nil
+		}
+
+		// the status is compared in the update itself so concurrent reviews of
+		// one course, or scheduler passes on several replicas, cannot both move it
+		result := tx.Model(&domain.Course{}).Scopes(tenantScope("courses", r.OrganizationID)).
+			Where("id = ? AND status = ?", review.CourseID, review.FromStatus).
+			UpdateColumns(columns)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		moved = true
+		return tx.Create(review).Error
+	})
+
+	return moved, err
+}
+
+func (r *CourseReviewRepositoryImp) GetReviews(courseID uint) ([]domain.CourseReview, error) {
+	var reviews []domain.CourseReview
+	err := r.tenant().Where("course_id = ?", courseID).Order("created_at ASC, id ASC").Find(&reviews).Error
+	return reviews, err
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+)
+
+type CourseStaffRepository interface {
+	AddStaff(staff *domain.CourseStaff) error
+	RemoveStaff(courseID, userID uint) error
+	GetStaff(courseID, userID uint) (*domain.CourseStaff, error)
+	GetCourseStaff(courseID uint) ([]domain.CourseStaff, error)
+	TransferOwnership(courseID, fromUserID, toUserID uint) error
+	// IsOrganizationAdmin reports whether the user is an admin of the organization
+	IsOrganizationAdmin(organizationID, userID uint) (bool, error)
+}
+
+type CourseStaffRepositoryImp struct {
+	DB *gorm.DB
+}
+
+func NewCourseStaffRepository(db *gorm.DB) CourseStaffRepository {
+	return &CourseStaffRepositoryImp{DB: db}
+}
+
+func (r *CourseStaffRepositoryImp) AddStaff(staff *domain.CourseStaff) error {
+	return r.DB.Create(staff).Error
+}
+
+func (r *CourseStaffRepositoryImp) RemoveStaff(courseID, userID uint) error {
+	return r.DB.Where("course_id = ? AND user_id = ?", courseID, userID).
+		Delete(&domain.CourseStaff{}).Error
+}
+
+func (r *CourseStaffRepositoryImp) GetStaff(courseID, userID uint) (*domain.CourseStaff, error) {
+	var staff domain.CourseStaff
+	err := r.DB.Where("course_id = ? AND user_id = ?", courseID, userID).First(&staff).Error
+	if err != nil {
+		return nil, err
+	}
+	return &staff, nil
+}
+
+func (r *CourseStaffRepositoryImp) IsOrganizationAdmin(organizationID, userID uint) (bool, error) {
+	var count int64
+	err := r.DB.Model(&domain.User{}).
+		Where("id = ? AND organization_id = ? AND role = ?", userID, organizationID, domain.RoleAdmin).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *CourseStaffRepositoryImp) GetCourseStaff(courseID uint) ([]domain.CourseStaff, error) {
+	var staff []domain.CourseStaff
+	err := r.DB.Where("course_id = ?", courseID).
+		Preload("User").
+		Order("created_at ASC").
+		Find(&staff).Error
+	return staff, err
+}
+
+// TransferOwnership makes toUserID the owner of the course and demotes the
+// previous owner to co-instructor, keeping courses.created_by in sync
+func (r *CourseStaffRepositoryImp) TransferOwnership(courseID, fromUserID, toUserID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		// Demote the previous owner, creating the row for courses that predate course staff
+		previousOwner := domain.CourseStaff{CourseID: courseID, UserID: fromUserID}
+		err := tx.Where("course_id = ? AND user_id = ?", courseID, fromUserID).
+			Attrs(domain.CourseStaff{AddedBy: fromUserID}).
+			Assign(map[string]interface{}{"role": domain.CourseStaffCoInstructor}).
+			FirstOrCreate(&previousOwner).Error
+		if err != nil {
+			return err
+		}
+
+		newOwner := domain.CourseStaff{CourseID: courseID, UserID: toUserID}
+		err = tx.Where("course_id = ? AND user_id = ?", courseID, toUserID).
+			Attrs(domain.CourseStaff{AddedBy: fromUserID}).
+			Assign(map[string]interface{}{"role": domain.CourseStaffOwner}).
+			FirstOrCreate(&newOwner).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&domain.Course{}).
+			Where("id = ?", courseID).
+			Updates(map[string]interface{}{
+				"created_by": toUserID,
+				"updated_at": time.Now(),
+			}).Error
+	})
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CourseVersionRepository interface {
+	// Publish stores the version as the next one of its course and makes it the
+	// published version. Learners who followed the published version until now
+	// are pinned to it so their content does not change under them.
+	Publish(version *domain.CourseVersion) error
+	// Rollback publishes version, a copy of an earlier one, like Publish and
+	// also moves the learners pinned to a version after the copied one to it
+	Rollback(version *domain.CourseVersion) error
+	GetVersion(courseID uint, number int) (*domain.CourseVersion, error)
+	// GetVersions returns the history of the course, newest first, without the snapshots
+	GetVersions(courseID uint) ([]domain.CourseVersion, error)
+
+	// ForOrganization returns a copy that only sees the versions of the organization
+	ForOrganization(organizationID uint) CourseVersionRepository
+}
+
+type CourseVersionRepositoryImp struct {
+	DB             *gorm.DB
+	OrganizationID uint // AllOrganizations for the unscoped repository
+}
+
+func NewCourseVersionRepository(db *gorm.DB) CourseVersionRepository {
+	return &CourseVersionRepositoryImp{DB: db, OrganizationID: AllOrganizations}
+}
+
+func (r *CourseVersionRepositoryImp) ForOrganization(organizationID uint) CourseVersionRepository {
+	return &CourseVersionRepositoryImp{DB: r.DB, OrganizationID: organizationID}
+}
+
+func (r *CourseVersionRepositoryImp) tenant() *gorm.DB {
+	return r.DB.Scopes(tenantScope("course_versions", r.OrganizationID))
+}
+
+func (r *CourseVersionRepositoryImp) Publish(version *domain.CourseVersion) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return r.publish(tx, version)
+	})
+}
+
+func (r *CourseVersionRepositoryImp) Rollback(version *domain.CourseVersion) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := r.publish(tx, version); err != nil {
+			return err
+		}
+
+		return tx.Model(&domain.UserCourse{}).Scopes(tenantScope("user_courses", r.OrganizationID)).
+			Where("course_id = ? AND course_version > ?", version.CourseID, version.RestoredFrom).
+			UpdateColumn("course_version", version.Version).Error
+	})
+}
+
+// publish numbers and stores the version under a lock on the course row, so
+// concurrent publishes of one course get consecutive numbers
+func (r *CourseVersionRepositoryImp) publish(tx *gorm.DB, version *domain.CourseVersion) error {
+	var course domain.Course
+	err := tx.Scopes(tenantScope("courses", r.OrganizationID)).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "organization_id", "published_version").
+		First(&course, version.CourseID).Error
+	if err != nil {
+		return err
+	}
+
+	var latest struct {
+		MaxVersion int
+	}
+	err = tx.Model(&domain.CourseVersion{}).
+		Select("COALESCE(MAX(version), 0) as max_version").
+		Where("course_id = ?", course.ID).
+		Scan(&latest).Error
+	if err != nil {
+		return err
+	}
+
+	version.OrganizationID = course.OrganizationID
+	version.Version = latest.MaxVersion + 1
+	if err := tx.Create(version).Error; err != nil {
+		return err
+	}
+
+	if course.PublishedVersion != 0 {
+		err = tx.Model(&domain.UserCourse{}).Scopes(tenantScope("user_courses", r.OrganizationID)).
+			Where("course_id = ? AND course_version = ?", course.ID, 0).
+			UpdateColumn("course_version", course.PublishedVersion).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&domain.Course{}).Where("id = ?", course.ID).
+		UpdateColumn("published_version", version.Version).Error
+}
+
+func (r *CourseVersionRepositoryImp) GetVersion(courseID uint, number int) (*domain.CourseVersion, error) {
+	var version domain.CourseVersion
+	err := r.tenant().Where("course_id = ? AND version = ?", courseID, number).First(&version).Error
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (r *CourseVersionRepositoryImp) GetVersions(courseID uint) ([]domain.CourseVersion, error) {
+	var versions []domain.CourseVersion
+	err := r.tenant().Omit("snapshot").Where("course_id = ?", courseID).Order("version DESC").Find(&versions).Error
+	return versions, err
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/utils/scimutil"
+	"gorm.io/gorm"
+)
+
+type GroupRepository interface {
+	Create(group *domain.Group) error
+	GetByID(id uint) (*domain.Group, error)
+	GetAll() ([]domain.Group, error)
+	FindByFilter(filter *scimutil.Filter, offset, limit int) ([]domain.Group, int64, error)
+	DisplayNameExists(displayName string, excludeID uint) (bool, error)
+	Update(group *domain.Group) error
+	Delete(id uint) error
+	AddMembers(group *domain.Group, users []domain.User) error
+	RemoveMembers(group *domain.Group, users []domain.User) error
+	ReplaceMembers(group *domain.Group, users []domain.User) error
+	AddCourse(group *domain.Group, course *domain.Course) error
+	RemoveCourse(group *domain.Group, course *domain.Course) error
+
+	// ForOrganization returns a copy that only sees the groups of the organization
+	ForOrganization(organizationID uint) GroupRepository
+}
+
+type GroupRepositoryImp struct {
+	DB             *gorm.DB
+	OrganizationID uint // AllOrganizations for the unscoped repository
+}
+
+func NewGroupRepository(db *gorm.DB) GroupRepository {
+	return &GroupRepositoryImp{DB: db, OrganizationID: AllOrganizations}
+}
+
+func (r *GroupRepositoryImp) ForOrganization(organizationID uint) GroupRepository {
+	return &GroupRepositoryImp{DB: r.DB, OrganizationID: organizationID}
+}
+
+func (r *GroupRepositoryImp) tenant() *gorm.DB {
+	return r.DB.Scopes(tenantScope("groups", r.OrganizationID))
+}
+
+// scimGroupAttributes are the SCIM attributes groups can be filtered on
+var scimGroupAttributes = map[string]scimutil.Attribute{
+	"id":                {Column: "CAST(id AS TEXT)"},
+	"displayname":       {Column: "display_name"},
+	"externalid":        {Column: "external_id"},
+	"meta.created":      {Column: "created_at"},
+	"meta.lastmodified": {Column: "updated_at"},
+}
+
+// Create stores the group in the organization of the repository together with
+// its members, which must already exist
+func (r *GroupRepositoryImp) Create(group *domain.Group) error {
+	if err := assignOrganization(&group.OrganizationID, r.OrganizationID); err != nil {
+		return err
+	}
+	return r.DB.Omit("Members.*", "Courses.*").Create(group).Error
+}
+
+func (r *GroupRepositoryImp) GetByID(id uint) (*domain.Group, error) {
+	var group domain.Group
+	if err := r.tenant().Preload("Members").Preload("Courses").First(&group, id).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *GroupRepositoryImp) GetAll() ([]domain.Group, error) {
+	var groups []domain.Group
+	err := r.tenant().Preload("Members").Preload("Courses").Order("display_name ASC").Find(&groups).Error
+	return groups, err
+}
+
+func (r *GroupRepositoryImp) FindByFilter(filter *scimutil.Filter, offset, limit int) ([]domain.Group, int64, error) {
+	var groups []domain.Group
+	var total int64
+
+	query := r.tenant().Model(&domain.Group{})
+	if filter != nil {
+		where, args, err := filter.Where(scimGroupAttributes)
+		if err != nil {
+			return nil, 0, err
+		}
+		query = query.Where(where, args...)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Members").Order("id ASC").Offset(offset).Limit(limit).Find(&groups).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return groups, total, nil
+}
+
+func (r *GroupRepositoryImp) DisplayNameExists(displayName string, excludeID uint) (bool, error) {
+	var count int64
+	err := r.tenant().Model(&domain.Group{}).
+		Where("LOWER(display_name) = LOWER(?) AND id <> ?", displayName, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Update saves the group attributes, members are changed through the member methods
+func (r *GroupRepositoryImp) Update(group *domain.Group) error {
+	return r.tenant().Model(group).Select("display_name", "external_id", "updated_at").Updates(group).Error
+}
+
+// Delete removes the group and its memberships, enrollments made through the
+// group are kept
+func (r *GroupRepositoryImp) Delete(id uint) error {
+	// the memberships are deleted by id, so make sure the group is ours first
+	var group domain.Group
+	if err := r.tenant().Select("id").First(&group, id).Error; err != nil {
+		return err
+	}
+	return r.DB.Select("Members", "Courses").Delete(&group).Error
+}
+
+func (r *GroupRepositoryImp) AddMembers(group *domain.Group, users []domain.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	return r.DB.Model(group).Omit("Members.*").Association("Members").Append(users)
+}
+
+func (r *GroupRepositoryImp) RemoveMembers(group *domain.Group, users []domain.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	return r.DB.Model(group).Association("Members").Delete(users)
+}
+
+func (r *GroupRepositoryImp) ReplaceMembers(group *domain.Group, users []domain.User) error {
+	if len(users) == 0 {
+		return r.DB.Model(group).Association("Members").Clear()
+	}
+	return r.DB.Model(group).Omit("Members.*").Association("Members").Replace(users)
+}
+
+func (r *GroupRepositoryImp) AddCourse(group *domain.Group, course *domain.Course) error {
+	return r.DB.Model(group).Omit("Courses.*").Association("Courses").Append(course)
+}
+
+func (r *GroupRepositoryImp) RemoveCourse(group *domain.Group, course *domain.Course) error {
+	return r.DB.Model(group).Association("Courses").Delete(course)
+}
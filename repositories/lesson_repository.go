@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
 	"gorm.io/gorm"
 )
 
@@ -10,84 +13,182 @@ type LessonRepository interface {
 	Create(lesson *domain.Lesson) error
 	GetByID(id uint) (*domain.Lesson, error)
 	Update(lesson *domain.Lesson) error
+	// SetSchedule replaces the publish and unpublish dates of the lesson, nil clears one
+	SetSchedule(id uint, publishAt, unpublishAt *time.Time) error
+	// Delete moves the lesson to the trash, the progress of learners is kept
 	Delete(id uint) error
 
+	// Trash
+	GetDeletedByCourse(courseID uint) ([]domain.Lesson, error)
+	GetDeletedByID(id uint) (*domain.Lesson, error)
+	// Restore takes the lesson out of the trash
+	Restore(id uint) error
+
 	// Course-specific operations
 	GetLessonsByCourse(courseID uint) ([]domain.Lesson, error)
 	GetPublishedLessonsByCourse(courseID uint) ([]domain.Lesson, error)
 	GetFreeLessonsByCourse(courseID uint) ([]domain.Lesson, error)
 
 	// Sequence management
-	GetNextSequence(courseID uint) (int, error)
-	ReorderLessons(courseID uint, lessonSequences []struct {
-		LessonID uint `json:"lesson_id"`
-		Sequence int  `json:"sequence"`
-	}) error
+	GetNextSequence(courseID uint, sectionID *uint) (int, error)
+	// ReorderLessons sets the sequences in one transaction, lessons with a
+	// SectionID also move to that section (0 takes them out of their section)
+	ReorderLessons(courseID uint, lessonSequences []dto.ReorderLessonRequest) error
 
 	// Progress tracking
 	GetUserLessonProgress(userID, courseID uint) ([]domain.UserLesson, error)
 	UpdateUserLessonProgress(userLesson *domain.UserLesson) error
 	MarkLessonCompleted(userID, lessonID, courseID uint, watchTime int) error
+
+	// ForOrganization returns a copy that only sees the lessons of the organization
+	ForOrganization(organizationID uint) LessonRepository
 }
 
 type LessonRepositoryImp struct {
-	DB *gorm.DB
+	DB             *gorm.DB
+	OrganizationID uint // AllOrganizations for the unscoped repository
 }
 
 func NewLessonRepository(db *gorm.DB) LessonRepository {
-	return &LessonRepositoryImp{DB: db}
+	return &LessonRepositoryImp{DB: db, OrganizationID: AllOrganizations}
+}
+
+func (r *LessonRepositoryImp) ForOrganization(organizationID uint) LessonRepository {
+	return &LessonRepositoryImp{DB: r.DB, OrganizationID: organizationID}
+}
+
+func (r *LessonRepositoryImp) tenant() *gorm.DB {
+	return r.DB.Scopes(tenantScope("lessons", r.OrganizationID))
 }
 
+// Create stores the lesson in the organization of the repository
 func (r *LessonRepositoryImp) Create(lesson *domain.Lesson) error {
+	if err := assignOrganization(&lesson.OrganizationID, r.OrganizationID); err != nil {
+		return err
+	}
 	return r.DB.Create(lesson).Error
 }
 
+// GetByID leaves out the lessons of courses in the trash
 func (r *LessonRepositoryImp) GetByID(id uint) (*domain.Lesson, error) {
 	var lesson domain.Lesson
-	err := r.DB.Preload("Course").First(&lesson, id).Error
+	err := r.tenant().Where("course_id IN (?)", r.DB.Model(&domain.Course{}).Select("id")).
+		Preload("Course").First(&lesson, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &lesson, nil
 }
 
+// Update saves every column but the schedule, see CourseRepositoryImp.Update
 func (r *LessonRepositoryImp) Update(lesson *domain.Lesson) error {
-	return r.DB.Save(lesson).Error
+	return r.tenant().Select("*").Omit("publish_at", "unpublish_at").Save(lesson).Error
+}
+
+func (r *LessonRepositoryImp) SetSchedule(id uint, publishAt, unpublishAt *time.Time) error {
+	result := r.tenant().Model(&domain.Lesson{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"publish_at": publishAt, "unpublish_at": unpublishAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 func (r *LessonRepositoryImp) Delete(id uint) error {
-	return r.DB.Delete(&domain.Lesson{}, id).Error
+	return r.tenant().Delete(&domain.Lesson{}, id).Error
+}
+
+// GetDeletedByCourse returns the lessons of the course in the trash, most
+// recently deleted first
+func (r *LessonRepositoryImp) GetDeletedByCourse(courseID uint) ([]domain.Lesson, error) {
+	var lessons []domain.Lesson
+	err := r.tenant().Unscoped().Where("course_id = ? AND deleted_at IS NOT NULL", courseID).
+		Order("deleted_at DESC").Find(&lessons).Error
+	return lessons, err
+}
+
+func (r *LessonRepositoryImp) GetDeletedByID(id uint) (*domain.Lesson, error) {
+	var lesson domain.Lesson
+	err := r.tenant().Unscoped().Where("deleted_at IS NOT NULL").First(&lesson, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &lesson, nil
+}
+
+func (r *LessonRepositoryImp) Restore(id uint) error {
+	result := r.tenant().Unscoped().Model(&domain.Lesson{}).Where("id = ? AND deleted_at IS NOT NULL", id).
+		UpdateColumn("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// liveLessons limits a query to the lessons learners see at the time, the ones
+// due by their schedule included, see domain.Lesson.IsLive
+func liveLessons(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(lessons.is_published = ? OR lessons.publish_at <= ?) AND (lessons.unpublish_at IS NULL OR lessons.unpublish_at > ?)", true, now, now)
+	}
+}
+
+// inCourseOrder orders lessons the way the course presents them: the lessons
+// without a section first, then section by section
+func inCourseOrder(db *gorm.DB) *gorm.DB {
+	return db.Select("lessons.*").
+		Joins("LEFT JOIN sections ON sections.id = lessons.section_id").
+		Order("COALESCE(sections.sequence, 0) ASC, lessons.section_id ASC NULLS FIRST, lessons.sequence ASC")
 }
 
 func (r *LessonRepositoryImp) GetLessonsByCourse(courseID uint) ([]domain.Lesson, error) {
 	var lessons []domain.Lesson
-	err := r.DB.Where("course_id = ?", courseID).Order("sequence ASC").Find(&lessons).Error
+	err := r.tenant().Scopes(inCourseOrder).Where("lessons.course_id = ?", courseID).Find(&lessons).Error
 	return lessons, err
 }
 
+// GetPublishedLessonsByCourse leaves out the lessons of unpublished sections
 func (r *LessonRepositoryImp) GetPublishedLessonsByCourse(courseID uint) ([]domain.Lesson, error) {
 	var lessons []domain.Lesson
-	err := r.DB.Where("course_id = ? AND is_published = ?", courseID, true).
-		Order("sequence ASC").Find(&lessons).Error
+	err := r.tenant().Scopes(inCourseOrder, liveLessons(time.Now())).
+		Where("lessons.course_id = ?", courseID).
+		Where("lessons.section_id IS NULL OR sections.is_published = ?", true).
+		Find(&lessons).Error
 	return lessons, err
 }
 
 func (r *LessonRepositoryImp) GetFreeLessonsByCourse(courseID uint) ([]domain.Lesson, error) {
 	var lessons []domain.Lesson
-	err := r.DB.Where("course_id = ? AND is_free = ? AND is_published = ?",
-		courseID, true, true).Order("sequence ASC").Find(&lessons).Error
+	err := r.tenant().Scopes(inCourseOrder, liveLessons(time.Now())).
+		Where("lessons.course_id = ? AND lessons.is_free = ?", courseID, true).
+		Where("lessons.section_id IS NULL OR sections.is_published = ?", true).
+		Find(&lessons).Error
 	return lessons, err
 }
 
-func (r *LessonRepositoryImp) GetNextSequence(courseID uint) (int, error) {
+// GetNextSequence returns the sequence after the last lesson of the section,
+// or of the lessons without a section when sectionID is nil
+func (r *LessonRepositoryImp) GetNextSequence(courseID uint, sectionID *uint) (int, error) {
 	var maxSequence struct {
 		MaxSeq int
 	}
 
-	err := r.DB.Model(&domain.Lesson{}).
+	query := r.tenant().Model(&domain.Lesson{}).
 		Select("COALESCE(MAX(sequence), 0) as max_seq").
-		Where("course_id = ?", courseID).
-		Scan(&maxSequence).Error
+		Where("course_id = ?", courseID)
+	if sectionID != nil {
+		query = query.Where("section_id = ?", *sectionID)
+	} else {
+		query = query.Where("section_id IS NULL")
+	}
+
+	err := query.Scan(&maxSequence).Error
 
 	if err != nil {
 		return 0, err
@@ -96,17 +197,28 @@ func (r *LessonRepositoryImp) GetNextSequence(courseID uint) (int, error) {
 	return maxSequence.MaxSeq + 1, nil
 }
 
-func (r *LessonRepositoryImp) ReorderLessons(courseID uint, lessonSequences []struct {
-	LessonID uint `json:"lesson_id"`
-	Sequence int  `json:"sequence"`
-}) error {
+// ReorderLessons rolls everything back when one of the lessons is not in the
+// course. The caller checks that the sections belong to the course.
+func (r *LessonRepositoryImp) ReorderLessons(courseID uint, lessonSequences []dto.ReorderLessonRequest) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
 		for _, ls := range lessonSequences {
-			err := tx.Model(&domain.Lesson{}).
+			updates := map[string]interface{}{"sequence": ls.Sequence}
+			if ls.SectionID != nil {
+				if *ls.SectionID == 0 {
+					updates["section_id"] = nil
+				} else {
+					updates["section_id"] = *ls.SectionID
+				}
+			}
+
+			result := tx.Model(&domain.Lesson{}).Scopes(tenantScope("lessons", r.OrganizationID)).
 				Where("id = ? AND course_id = ?", ls.LessonID, courseID).
-				Update("sequence", ls.Sequence).Error
-			if err != nil {
-				return err
+				Updates(updates)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
 			}
 		}
 		return nil
@@ -115,20 +227,30 @@ func (r *LessonRepositoryImp) ReorderLessons(courseID uint, lessonSequences []st
 
 func (r *LessonRepositoryImp) GetUserLessonProgress(userID, courseID uint) ([]domain.UserLesson, error) {
 	var userLessons []domain.UserLesson
-	err := r.DB.Where("user_id = ? AND course_id = ?", userID, courseID).
+	err := r.DB.Scopes(tenantScope("user_lessons", r.OrganizationID)).
+		Where("user_id = ? AND course_id = ?", userID, courseID).
 		Preload("Lesson").Find(&userLessons).Error
 	return userLessons, err
 }
 
+// UpdateUserLessonProgress creates or updates the progress record, new records
+// are stored in the organization of the repository
 func (r *LessonRepositoryImp) UpdateUserLessonProgress(userLesson *domain.UserLesson) error {
-	return r.DB.Save(userLesson).Error
+	if userLesson.ID == 0 {
+		if err := assignOrganization(&userLesson.OrganizationID, r.OrganizationID); err != nil {
+			return err
+		}
+		return r.DB.Create(userLesson).Error
+	}
+	return r.DB.Scopes(tenantScope("user_lessons", r.OrganizationID)).Select("*").Save(userLesson).Error
 }
 
 func (r *LessonRepositoryImp) MarkLessonCompleted(userID, lessonID, courseID uint, watchTime int) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
 		// Create or update user lesson progress
 		var userLesson domain.UserLesson
-		err := tx.Where("user_id = ? AND lesson_id = ?", userID, lessonID).
+		err := tx.Scopes(tenantScope("user_lessons", r.OrganizationID)).
+			Where("user_id = ? AND lesson_id = ?", userID, lessonID).
 			First(&userLesson).Error
 
 		if err == gorm.ErrRecordNotFound {
@@ -140,12 +262,15 @@ func (r *LessonRepositoryImp) MarkLessonCompleted(userID, lessonID, courseID uin
 				IsCompleted: true,
 				WatchTime:   watchTime,
 			}
+			if err := assignOrganization(&userLesson.OrganizationID, r.OrganizationID); err != nil {
+				return err
+			}
 			err = tx.Create(&userLesson).Error
 		} else if err == nil {
 			// Update existing record
 			userLesson.IsCompleted = true
 			userLesson.WatchTime = watchTime
-			err = tx.Save(&userLesson).Error
+			err = tx.Select("*").Save(&userLesson).Error
 		}
 
 		if err != nil {
@@ -160,15 +285,16 @@ func (r *LessonRepositoryImp) MarkLessonCompleted(userID, lessonID, courseID uin
 func (r *LessonRepositoryImp) updateCourseProgress(tx *gorm.DB, userID, courseID uint) error {
 	// Get total lessons count for the course
 	var totalLessons int64
-	err := tx.Model(&domain.Lesson{}).Where("course_id = ?", courseID).Count(&totalLessons).Error
+	err := tx.Model(&domain.Lesson{}).Scopes(tenantScope("lessons", r.OrganizationID)).Where("course_id = ?", courseID).Count(&totalLessons).Error
 	if err != nil {
 		return err
 	}
 
-	// Get completed lessons count for the user
+	// Get completed lessons count for the user, lessons in the trash left out
 	var completedLessons int64
-	err = tx.Model(&domain.UserLesson{}).
+	err = tx.Model(&domain.UserLesson{}).Scopes(tenantScope("user_lessons", r.OrganizationID)).
 		Where("user_id = ? AND course_id = ? AND is_completed = ?", userID, courseID, true).
+		Where("lesson_id IN (?)", tx.Model(&domain.Lesson{}).Select("id")).
 		Count(&completedLessons).Error
 	if err != nil {
 		return err
@@ -182,7 +308,7 @@ func (r *LessonRepositoryImp) updateCourseProgress(tx *gorm.DB, userID, courseID
 
 	// Update user course progress
 	isCompleted := progress >= 100
-	err = tx.Model(&domain.UserCourse{}).
+	err = tx.Model(&domain.UserCourse{}).Scopes(tenantScope("user_courses", r.OrganizationID)).
 		Where("user_id = ? AND course_id = ?", userID, courseID).
 		Updates(map[string]interface{}{
 			"progress":     progress,
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+)
+
+type MFARepository interface {
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	DeleteRecoveryCodes(userID uint) error
+}
+
+type MFARepositoryImp struct {
+	DB *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &MFARepositoryImp{DB: db}
+}
+
+// ReplaceRecoveryCodes drops every previous code of the user and stores the new set
+func (r *MFARepositoryImp) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]domain.MFARecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, domain.MFARecoveryCode{UserID: userID, CodeHash: hash})
+		}
+
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode marks an unused code as used and reports whether one matched
+func (r *MFARepositoryImp) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.DB.Model(&domain.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *MFARepositoryImp) DeleteRecoveryCodes(userID uint) error {
+	return r.DB.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+)
+
+type OrganizationRepository interface {
+	Create(organization *domain.Organization) error
+	GetByID(id uint) (*domain.Organization, error)
+	GetBySlug(slug string) (*domain.Organization, error)
+	GetBySCIMTokenHash(hash string) (*domain.Organization, error)
+	SetSCIMTokenHash(id uint, hash string) error
+	GetAll() ([]domain.Organization, error)
+	SlugExists(slug string) (bool, error)
+}
+
+type OrganizationRepositoryImp struct {
+	DB *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &OrganizationRepositoryImp{DB: db}
+}
+
+func (r *OrganizationRepositoryImp) Create(organization *domain.Organization) error {
+	return r.DB.Create(organization).Error
+}
+
+func (r *OrganizationRepositoryImp) GetByID(id uint) (*domain.Organization, error) {
+	var organization domain.Organization
+	if err := r.DB.First(&organization, id).Error; err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func (r *OrganizationRepositoryImp) GetBySlug(slug string) (*domain.Organization, error) {
+	var organization domain.Organization
+	if err := r.DB.Where("slug = ?", slug).First(&organization).Error; err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func (r *OrganizationRepositoryImp) GetBySCIMTokenHash(hash string) (*domain.Organization, error) {
+	var organization domain.Organization
+	if err := r.DB.Where("scim_token_hash = ? AND scim_token_hash <> ''", hash).First(&organization).Error; err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func (r *OrganizationRepositoryImp) SetSCIMTokenHash(id uint, hash string) error {
+	return r.DB.Model(&domain.Organization{}).Where("id = ?", id).UpdateColumn("scim_token_hash", hash).Error
+}
+
+func (r *OrganizationRepositoryImp) GetAll() ([]domain.Organization, error) {
+	var organizations []domain.Organization
+	err := r.DB.Order("slug ASC").Find(&organizations).Error
+	return organizations, err
+}
+
+func (r *OrganizationRepositoryImp) SlugExists(slug string) (bool, error) {
+	var count int64
+	err := r.DB.Model(&domain.Organization{}).Where("slug = ?", slug).Count(&count).Error
+	return count > 0, err
+}
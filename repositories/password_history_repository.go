@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepository interface {
+	GetRecent(userID uint, limit int) ([]domain.PasswordHistory, error)
+	Add(userID uint, passwordHash string, keep int) error
+}
+
+type PasswordHistoryRepositoryImp struct {
+	DB *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) PasswordHistoryRepository {
+	return &PasswordHistoryRepositoryImp{DB: db}
+}
+
+// GetRecent returns the last passwords of the user, newest first
+func (r *PasswordHistoryRepositoryImp) GetRecent(userID uint, limit int) ([]domain.PasswordHistory, error) {
+	var history []domain.PasswordHistory
+	err := r.DB.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&history).Error
+	return history, err
+}
+
+// Add remembers a new password of the user and forgets all but the last keep ones
+func (r *PasswordHistoryRepositoryImp) Add(userID uint, passwordHash string, keep int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&domain.PasswordHistory{UserID: userID, PasswordHash: passwordHash}).Error; err != nil {
+			return err
+		}
+
+		kept := tx.Model(&domain.PasswordHistory{}).Select("id").Where("user_id = ?", userID).Order("id DESC").Limit(keep)
+		return tx.Where("user_id = ? AND id NOT IN (?)", userID, kept).Delete(&domain.PasswordHistory{}).Error
+	})
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+)
+
+type PermissionRepository interface {
+	GetPermissionsByRole(role string) ([]string, error)
+}
+
+type PermissionRepositoryImp struct {
+	DB *gorm.DB
+}
+
+func NewPermissionRepository(db *gorm.DB) PermissionRepository {
+	return &PermissionRepositoryImp{DB: db}
+}
+
+func (r *PermissionRepositoryImp) GetPermissionsByRole(role string) ([]string, error) {
+	var permissions []string
+	err := r.DB.Model(&domain.RolePermission{}).
+		Where("role = ?", role).
+		Order("permission ASC").
+		Pluck("permission", &permissions).Error
+	return permissions, err
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+)
+
+// PrivacyRepository reads everything stored about a user for a data export
+// and erases the personal data of a user on request
+type PrivacyRepository interface {
+	GetLessonProgress(userID uint) ([]domain.UserLesson, error)
+	GetIdentities(userID uint) ([]domain.UserIdentity, error)
+	GetGroups(userID uint) ([]domain.Group, error)
+	GetCourseRoles(userID uint) ([]domain.CourseStaff, error)
+	OwnsCourses(userID uint) (bool, error)
+	EraseUser(user *domain.User) error
+}
+
+type PrivacyRepositoryImp struct {
+	DB *gorm.DB
+}
+
+func NewPrivacyRepository(db *gorm.DB) PrivacyRepository {
+	return &PrivacyRepositoryImp{DB: db}
+}
+
+func (r *PrivacyRepositoryImp) GetLessonProgress(userID uint) ([]domain.UserLesson, error) {
+	var progress []domain.UserLesson
+	err := r.DB.Where("user_id = ?", userID).
+		Preload("Lesson").
+		Order("created_at ASC").
+		Find(&progress).Error
+
+	return progress, err
+}
+
+func (r *PrivacyRepositoryImp) GetIdentities(userID uint) ([]domain.UserIdentity, error) {
+	var identities []domain.UserIdentity
+	err := r.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error
+	return identities, err
+}
+
+func (r *PrivacyRepositoryImp) GetGroups(userID uint) ([]domain.Group, error) {
+	var groups []domain.Group
+	err := r.DB.Joins("JOIN group_members ON group_members.group_id = groups.id").
+		Where("group_members.user_id = ?", userID).
+		Order("groups.display_name ASC").
+		Find(&groups).Error
+
+	return groups, err
+}
+
+func (r *PrivacyRepositoryImp) GetCourseRoles(userID uint) ([]domain.CourseStaff, error) {
+	var roles []domain.CourseStaff
+	err := r.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&roles).Error
+	return roles, err
+}
+
+// OwnsCourses reports whether the user created or owns a course
+func (r *PrivacyRepositoryImp) OwnsCourses(userID uint) (bool, error) {
+	var count int64
+	err := r.DB.Model(&domain.Course{}).
+		Where("created_by = ? OR id IN (?)", userID,
+			r.DB.Model(&domain.CourseStaff{}).Select("course_id").Where("user_id = ? AND role = ?", userID, domain.CourseStaffOwner)).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+// EraseUser saves the anonymized user and drops everything that links the row
+// back to the person: external identities, recovery codes, previous
+// passwords, API keys, group memberships and course staff roles. Enrollments and lesson progress are kept
+// so that course statistics do not change, audit log entries are kept without
+// the personal data.
+func (r *PrivacyRepositoryImp) EraseUser(user *domain.User) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("*").Save(user).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{&domain.UserIdentity{}, &domain.MFARecoveryCode{}, &domain.PasswordHistory{}, &domain.APIKey{}, &domain.CourseStaff{}} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("DELETE FROM group_members WHERE user_id = ?", user.ID).Error; err != nil {
+			return err
+		}
+
+		return scrubAuditLog(tx, user.ID)
+	})
+}
+
+// erasedAuditFields are the personal fields of the user snapshots written to
+// the audit log, see auditUser in the services
+var erasedAuditFields = []string{"email", "name", "avatar_url", "bio", "timezone", "locale", "external_id"}
+
+// scrubAuditLog keeps the entries of the user but removes what identifies the
+// person: the IP and user agent of their requests and the personal fields of
+// the changes made to their account. The trigger on audit_logs only allows
+// this while the setting is on, SET LOCAL limits it to the transaction.
+func scrubAuditLog(tx *gorm.DB, userID uint) error {
+	if err := tx.Exec("SET LOCAL " + domain.AuditScrubSetting + " = 'on'").Error; err != nil {
+		return err
+	}
+
+	if err := tx.Exec("UPDATE audit_logs SET ip = '', user_agent = '' WHERE actor_id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec("UPDATE audit_logs SET changes = changes - ?::text[] WHERE target_type = ? AND target_id = ?",
+		"{"+strings.Join(erasedAuditFields, ",")+"}", domain.AuditTargetUser, userID).Error
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PublishScheduleRepository finds and applies the publish_at/unpublish_at
+// dates that have come. It works across organizations for the scheduler.
+type PublishScheduleRepository interface {
+	// GetDueCourses returns the approved courses whose publish_at and the
+	// published courses whose unpublish_at has come
+	GetDueCourses(now time.Time) ([]domain.Course, error)
+	// PublishDueLessons publishes the lessons whose publish_at has come, clears
+	// it and returns them. Concurrent calls never return the same lesson.
+	PublishDueLessons(now time.Time) ([]domain.Lesson, error)
+	// UnpublishDueLessons is PublishDueLessons for unpublish_at
+	UnpublishDueLessons(now time.Time) ([]domain.Lesson, error)
+}
+
+type PublishScheduleRepositoryImp struct {
+	DB *gorm.DB
+}
+
+func NewPublishScheduleRepository(db *gorm.DB) PublishScheduleRepository {
+	return &PublishScheduleRepositoryImp{DB: db}
+}
+
+func (r *PublishScheduleRepositoryImp) GetDueCourses(now time.Time) ([]domain.Course, error) {
+	var courses []domain.Course
+	err := r.DB.Where("status = ? AND publish_at <= ?", domain.CourseStatusApproved, now).
+		Or("status = ? AND unpublish_at <= ?", domain.CourseStatusPublished, now).
+		Order("id ASC").Find(&courses).Error
+	return courses, err
+}
+
+func (r *PublishScheduleRepositoryImp) PublishDueLessons(now time.Time) ([]domain.Lesson, error) {
+	return r.applyDue("publish_at", now, map[string]interface{}{"is_published": true, "publish_at": nil})
+}
+
+func (r *PublishScheduleRepositoryImp) UnpublishDueLessons(now time.Time) ([]domain.Lesson, error) {
+	return r.applyDue("unpublish_at", now, map[string]interface{}{"is_published": false, "unpublish_at": nil})
+}
+
+// applyDue updates the lessons due by the column in a single statement. The
+// row lock of the update makes a concurrent statement see the cleared column
+// and skip the lesson, so every lesson is returned by one caller only.
+func (r *PublishScheduleRepositoryImp) applyDue(column string, now time.Time, columns map[string]interface{}) ([]domain.Lesson, error) {
+	var lessons []domain.Lesson
+	err := r.DB.Model(&lessons).Clauses(clause.Returning{}).
+		Where(column+" <= ?", now).
+		UpdateColumns(columns).Error
+	return lessons, err
+}
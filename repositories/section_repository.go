@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"gorm.io/gorm"
+)
+
+type SectionRepository interface {
+	Create(section *domain.Section) error
+	GetByID(id uint) (*domain.Section, error)
+	Update(section *domain.Section) error
+	Delete(id uint) error
+
+	GetSectionsByCourse(courseID uint) ([]domain.Section, error)
+	CountLessons(sectionID uint) (int64, error)
+
+	// Sequence management
+	GetNextSequence(courseID uint) (int, error)
+	ReorderSections(courseID uint, sectionSequences []dto.ReorderSectionRequest) error
+
+	// ForOrganization returns a copy that only sees the sections of the organization
+	ForOrganization(organizationID uint) SectionRepository
+}
+
+type SectionRepositoryImp struct {
+	DB             *gorm.DB
+	OrganizationID uint // AllOrganizations for the unscoped repository
+}
+
+func NewSectionRepository(db *gorm.DB) SectionRepository {
+	return &SectionRepositoryImp{DB: db, OrganizationID: AllOrganizations}
+}
+
+func (r *SectionRepositoryImp) ForOrganization(organizationID uint) SectionRepository {
+	return &SectionRepositoryImp{DB: r.DB, OrganizationID: organizationID}
+}
+
+func (r *SectionRepositoryImp) tenant() *gorm.DB {
+	return r.DB.Scopes(tenantScope("sections", r.OrganizationID))
+}
+
+// Create stores the section in the organization of the repository
+func (r *SectionRepositoryImp) Create(section *domain.Section) error {
+	if err := assignOrganization(&section.OrganizationID, r.OrganizationID); err != nil {
+		return err
+	}
+	return r.DB.Create(section).Error
+}
+
+func (r *SectionRepositoryImp) GetByID(id uint) (*domain.Section, error) {
+	var section domain.Section
+	err := r.tenant().First(&section, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &section, nil
+}
+
+// Update saves every column, see CourseRepositoryImp.Update
+func (r *SectionRepositoryImp) Update(section *domain.Section) error {
+	return r.tenant().Select("*").Save(section).Error
+}
+
+// Delete removes the section. Lessons of the section in the trash are taken
+// out of it, they are restored without a section.
+func (r *SectionRepositoryImp) Delete(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Model(&domain.Lesson{}).Scopes(tenantScope("lessons", r.OrganizationID)).
+			Where("section_id = ? AND deleted_at IS NOT NULL", id).UpdateColumn("section_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Scopes(tenantScope("sections", r.OrganizationID)).Delete(&domain.Section{}, id).Error
+	})
+}
+
+func (r *SectionRepositoryImp) GetSectionsByCourse(courseID uint) ([]domain.Section, error) {
+	var sections []domain.Section
+	err := r.tenant().Where("course_id = ?", courseID).Order("sequence ASC, id ASC").Find(&sections).Error
+	return sections, err
+}
+
+func (r *SectionRepositoryImp) CountLessons(sectionID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&domain.Lesson{}).Scopes(tenantScope("lessons", r.OrganizationID)).
+		Where("section_id = ?", sectionID).Count(&count).Error
+	return count, err
+}
+
+func (r *SectionRepositoryImp) GetNextSequence(courseID uint) (int, error) {
+	var maxSequence struct {
+		MaxSeq int
+	}
+
+	err := r.tenant().Model(&domain.Section{}).
+		Select("COALESCE(MAX(sequence), 0) as max_seq").
+		Where("course_id = ?", courseID).
+		Scan(&maxSequence).Error
+
+	if err != nil {
+		return 0, err
+	}
+
+	return maxSequence.MaxSeq + 1, nil
+}
+
+// ReorderSections rolls everything back when one of the sections is not in the course
+func (r *SectionRepositoryImp) ReorderSections(courseID uint, sectionSequences []dto.ReorderSectionRequest) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		for _, ss := range sectionSequences {
+			result := tx.Model(&domain.Section{}).Scopes(tenantScope("sections", r.OrganizationID)).
+				Where("id = ? AND course_id = ?", ss.SectionID, courseID).
+				Update("sequence", ss.Sequence)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		return nil
+	})
+}
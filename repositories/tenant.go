@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// AllOrganizations is the organization of the unscoped repositories returned
+// by the New...Repository constructors. They are only meant for platform-wide
+// jobs such as CLI commands and the scheduler; request handling always works
+// on a repository returned by ForOrganization.
+const AllOrganizations = ^uint(0)
+
+// ErrNoOrganization is returned by a repository scoped to organization 0,
+// which happens when a request was not resolved to an organization. Such a
+// repository must not fall back to the rows of every organization.
+var ErrNoOrganization = errors.New("repository is not scoped to an organization")
+
+// tenantScope limits a query to the rows of one organization. AllOrganizations
+// leaves the query unscoped and organization 0 fails it.
+func tenantScope(table string, organizationID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch organizationID {
+		case AllOrganizations:
+			return db
+		case 0:
+			_ = db.AddError(ErrNoOrganization)
+			return db
+		}
+		return db.Where(table+".organization_id = ?", organizationID)
+	}
+}
+
+// assignOrganization puts a new row into the organization of the repository,
+// the unscoped repositories keep the organization set by the caller
+func assignOrganization(rowOrganizationID *uint, organizationID uint) error {
+	switch organizationID {
+	case AllOrganizations:
+		return nil
+	case 0:
+		return ErrNoOrganization
+	}
+	*rowOrganizationID = organizationID
+	return nil
+}
@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/utils/testdb"
+	"gorm.io/gorm"
+)
+
+// tenantFixture holds one user, course, lesson, enrollment, group and audit
+// entry in each of the organizations A and B
+type tenantFixture struct {
+	db               *gorm.DB
+	orgA, orgB       uint
+	userA, userB     domain.User
+	courseA, courseB domain.Course
+	lessonA, lessonB domain.Lesson
+	groupA, groupB   domain.Group
+	auditA, auditB   domain.AuditLog
+	enrollA, enrollB domain.UserCourse
+}
+
+func newTenantFixture(t *testing.T) *tenantFixture {
+	t.Helper()
+
+	f := &tenantFixture{db: testdb.Open(t)}
+	orgA := domain.Organization{Name: "A", Slug: "a"}
+	orgB := domain.Organization{Name: "B", Slug: "b"}
+	mustCreate(t, f.db, &orgA, &orgB)
+	f.orgA, f.orgB = orgA.ID, orgB.ID
+
+	f.userA = domain.User{OrganizationID: f.orgA, Email: "a@example.com", Role: domain.RoleLearner}
+	f.userB = domain.User{OrganizationID: f.orgB, Email: "b@example.com", Role: domain.RoleLearner}
+	mustCreate(t, f.db, &f.userA, &f.userB)
+
+	f.courseA = domain.Course{OrganizationID: f.orgA, Title: "Course A"}
+	f.courseB = domain.Course{OrganizationID: f.orgB, Title: "Course B"}
+	mustCreate(t, f.db, &f.courseA, &f.courseB)
+
+	f.lessonA = domain.Lesson{OrganizationID: f.orgA, CourseID: f.courseA.ID, Title: "Lesson A", Sequence: 1}
+	f.lessonB = domain.Lesson{OrganizationID: f.orgB, CourseID: f.courseB.ID, Title: "Lesson B", Sequence: 1}
+	mustCreate(t, f.db, &f.lessonA, &f.lessonB)
+
+	f.enrollA = domain.UserCourse{OrganizationID: f.orgA, UserID: f.userA.ID, CourseID: f.courseA.ID, EnrolledAt: time.Now()}
+	f.enrollB = domain.UserCourse{OrganizationID: f.orgB, UserID: f.userB.ID, CourseID: f.courseB.ID, EnrolledAt: time.Now()}
+	mustCreate(t, f.db, &f.enrollA, &f.enrollB)
+
+	f.groupA = domain.Group{OrganizationID: f.orgA, DisplayName: "Staff"}
+	f.groupB = domain.Group{OrganizationID: f.orgB, DisplayName: "Staff", Members: []domain.User{f.userB}}
+	mustCreate(t, f.db, &f.groupA, &f.groupB)
+
+	f.auditA = domain.AuditLog{OrganizationID: f.orgA, Action: domain.AuditActionCourseCreate, TargetType: domain.AuditTargetCourse, TargetID: f.courseA.ID, Changes: "{}"}
+	f.auditB = domain.AuditLog{OrganizationID: f.orgB, Action: domain.AuditActionCourseCreate, TargetType: domain.AuditTargetCourse, TargetID: f.courseB.ID, Changes: "{}"}
+	mustCreate(t, f.db, &f.auditA, &f.auditB)
+
+	return f
+}
+
+func mustCreate(t *testing.T, db *gorm.DB, rows ...interface{}) {
+	t.Helper()
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("create %T: %v", row, err)
+		}
+	}
+}
+
+func TestCourseRepositoryTenantIsolation(t *testing.T) {
+	f := newTenantFixture(t)
+	repo := NewCourseRepository(f.db).ForOrganization(f.orgA)
+
+	if _, err := repo.GetByID(f.courseB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetByID(course of B) error = %v, want record not found", err)
+	}
+
+	courses, err := repo.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(courses) != 1 || courses[0].ID != f.courseA.ID {
+		t.Errorf("List = %v, want only the course of A", courseIDs(courses))
+	}
+
+	hijacked := f.courseB
+	hijacked.Title = "Hijacked"
+	if err := repo.Update(&hijacked); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := repo.SetSchedule(f.courseB.ID, nil, nil); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("SetSchedule(course of B) error = %v, want record not found", err)
+	}
+	if err := repo.Delete(f.courseB.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	var stored domain.Course
+	if err := f.db.First(&stored, f.courseB.ID).Error; err != nil {
+		t.Fatalf("course of B is gone: %v", err)
+	}
+	if stored.Title != "Course B" {
+		t.Errorf("course of B was renamed to %q", stored.Title)
+	}
+
+	course := &domain.Course{OrganizationID: f.orgB, Title: "New"}
+	if err := repo.CreateWithOwner(course, &domain.CourseStaff{UserID: f.userA.ID, Role: domain.CourseStaffOwner}); err != nil {
+		t.Fatalf("CreateWithOwner: %v", err)
+	}
+	if course.OrganizationID != f.orgA {
+		t.Errorf("created course in organization %d, want %d", course.OrganizationID, f.orgA)
+	}
+}
+
+func TestLessonRepositoryTenantIsolation(t *testing.T) {
+	f := newTenantFixture(t)
+	repo := NewLessonRepository(f.db).ForOrganization(f.orgA)
+
+	if _, err := repo.GetByID(f.lessonB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetByID(lesson of B) error = %v, want record not found", err)
+	}
+
+	lessons, err := repo.GetLessonsByCourse(f.courseB.ID)
+	if err != nil {
+		t.Fatalf("GetLessonsByCourse: %v", err)
+	}
+	if len(lessons) != 0 {
+		t.Errorf("GetLessonsByCourse(course of B) returned %d lessons", len(lessons))
+	}
+
+	hijacked := f.lessonB
+	hijacked.Title = "Hijacked"
+	if err := repo.Update(&hijacked); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := repo.Delete(f.lessonB.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	var stored domain.Lesson
+	if err := f.db.First(&stored, f.lessonB.ID).Error; err != nil {
+		t.Fatalf("lesson of B is gone: %v", err)
+	}
+	if stored.Title != "Lesson B" {
+		t.Errorf("lesson of B was renamed to %q", stored.Title)
+	}
+}
+
+func TestUserCourseRepositoryTenantIsolation(t *testing.T) {
+	f := newTenantFixture(t)
+	repo := NewUserCourseRepository(f.db).ForOrganization(f.orgA)
+
+	if _, err := repo.EnrollUser(f.userA.ID, f.courseB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("EnrollUser(course of B) error = %v, want record not found", err)
+	}
+
+	enrollments, err := repo.GetCourseEnrollments(f.courseB.ID)
+	if err != nil {
+		t.Fatalf("GetCourseEnrollments: %v", err)
+	}
+	if len(enrollments) != 0 {
+		t.Errorf("GetCourseEnrollments(course of B) returned %d enrollments", len(enrollments))
+	}
+
+	if enrolled, err := repo.IsUserEnrolled(f.userB.ID, f.courseB.ID); err != nil || enrolled {
+		t.Errorf("IsUserEnrolled(enrollment of B) = %v, %v, want false", enrolled, err)
+	}
+
+	if err := repo.UpdateProgress(f.userB.ID, f.courseB.ID, 100, f.lessonB.ID); err != nil {
+		t.Fatalf("UpdateProgress: %v", err)
+	}
+	if err := repo.UnenrollUser(f.userB.ID, f.courseB.ID); err != nil {
+		t.Fatalf("UnenrollUser: %v", err)
+	}
+
+	var stored domain.UserCourse
+	if err := f.db.First(&stored, f.enrollB.ID).Error; err != nil {
+		t.Fatalf("enrollment of B is gone: %v", err)
+	}
+	if stored.Progress != 0 || stored.IsCompleted {
+		t.Errorf("enrollment of B was updated: progress %v, completed %v", stored.Progress, stored.IsCompleted)
+	}
+}
+
+func TestGroupRepositoryTenantIsolation(t *testing.T) {
+	f := newTenantFixture(t)
+	repo := NewGroupRepository(f.db).ForOrganization(f.orgA)
+
+	if _, err := repo.GetByID(f.groupB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetByID(group of B) error = %v, want record not found", err)
+	}
+
+	groups, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(groups) != 1 || groups[0].ID != f.groupA.ID {
+		t.Errorf("GetAll returned %d groups, want only the group of A", len(groups))
+	}
+
+	hijacked := f.groupB
+	hijacked.DisplayName = "Hijacked"
+	if err := repo.Update(&hijacked); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := repo.Delete(f.groupB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Delete(group of B) error = %v, want record not found", err)
+	}
+
+	var stored domain.Group
+	if err := f.db.Preload("Members").First(&stored, f.groupB.ID).Error; err != nil {
+		t.Fatalf("group of B is gone: %v", err)
+	}
+	if stored.DisplayName != "Staff" || len(stored.Members) != 1 {
+		t.Errorf("group of B was changed: name %q, %d members", stored.DisplayName, len(stored.Members))
+	}
+}
+
+func TestAuditLogRepositoryTenantIsolation(t *testing.T) {
+	f := newTenantFixture(t)
+	repo := NewAuditLogRepository(f.db).ForOrganization(f.orgA)
+
+	entries, total, err := repo.Find(dto.AuditLogFilterRequest{Page: 1, Limit: 50})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if total != 1 || len(entries) != 1 || entries[0].ID != f.auditA.ID {
+		t.Errorf("Find returned %d of %d entries, want only the entry of A", len(entries), total)
+	}
+
+	entries, _, err = repo.Find(dto.AuditLogFilterRequest{TargetType: domain.AuditTargetCourse, TargetID: f.courseB.ID, Page: 1, Limit: 50})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Find(course of B) returned %d entries", len(entries))
+	}
+
+	if _, err := repo.DeleteBefore(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("DeleteBefore: %v", err)
+	}
+	var stored domain.AuditLog
+	if err := f.db.First(&stored, f.auditB.ID).Error; err != nil {
+		t.Errorf("entry of B is gone: %v", err)
+	}
+}
+
+func TestRepositoryWithoutOrganization(t *testing.T) {
+	f := newTenantFixture(t)
+
+	if _, err := NewCourseRepository(f.db).ForOrganization(0).GetByID(f.courseA.ID); !errors.Is(err, ErrNoOrganization) {
+		t.Errorf("course GetByID error = %v, want %v", err, ErrNoOrganization)
+	}
+	if _, err := NewCourseRepository(f.db).ForOrganization(0).List(); !errors.Is(err, ErrNoOrganization) {
+		t.Errorf("course List error = %v, want %v", err, ErrNoOrganization)
+	}
+	if err := NewLessonRepository(f.db).ForOrganization(0).Delete(f.lessonA.ID); !errors.Is(err, ErrNoOrganization) {
+		t.Errorf("lesson Delete error = %v, want %v", err, ErrNoOrganization)
+	}
+	if err := NewLessonRepository(f.db).ForOrganization(0).Create(&domain.Lesson{CourseID: f.courseA.ID, Title: "New"}); !errors.Is(err, ErrNoOrganization) {
+		t.Errorf("lesson Create error = %v, want %v", err, ErrNoOrganization)
+	}
+	if _, err := NewUserCourseRepository(f.db).ForOrganization(0).GetCourseEnrollments(f.courseA.ID); !errors.Is(err, ErrNoOrganization) {
+		t.Errorf("enrollment GetCourseEnrollments error = %v, want %v", err, ErrNoOrganization)
+	}
+	if _, err := NewGroupRepository(f.db).ForOrganization(0).GetAll(); !errors.Is(err, ErrNoOrganization) {
+		t.Errorf("group GetAll error = %v, want %v", err, ErrNoOrganization)
+	}
+	if _, _, err := NewAuditLogRepository(f.db).ForOrganization(0).Find(dto.AuditLogFilterRequest{Page: 1, Limit: 50}); !errors.Is(err, ErrNoOrganization) {
+		t.Errorf("audit Find error = %v, want %v", err, ErrNoOrganization)
+	}
+
+	// the unscoped repositories still see every organization
+	courses, err := NewCourseRepository(f.db).List()
+	if err != nil || len(courses) != 2 {
+		t.Errorf("unscoped List = %v, %v, want both courses", courseIDs(courses), err)
+	}
+}
+
+func courseIDs(courses []domain.Course) []uint {
+	ids := make([]uint, 0, len(courses))
+	for _, course := range courses {
+		ids = append(ids, course.ID)
+	}
+	return ids
+}
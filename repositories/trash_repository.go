@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+)
+
+// TrashRepository removes deleted courses and lessons for good once they were
+// in the trash long enough. It works across organizations.
+type TrashRepository interface {
+	// PurgeCourses removes the courses deleted before the time with
+	// everything that belongs to them and returns how many it removed
+	PurgeCourses(before time.Time) (int64, error)
+	// PurgeLessons removes the lessons deleted before the time with the
+	// progress of learners and returns how many it removed
+	PurgeLessons(before time.Time) (int64, error)
+}
+
+type TrashRepositoryImp struct {
+	DB *gorm.DB
+}
+
+func NewTrashRepository(db *gorm.DB) TrashRepository {
+	return &TrashRepositoryImp{DB: db}
+}
+
+func (r *TrashRepositoryImp) PurgeCourses(before time.Time) (int64, error) {
+	var purged int64
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var courseIDs []uint
+		err := tx.Unscoped().Model(&domain.Course{}).Where("deleted_at < ?", before).Pluck("id", &courseIDs).Error
+		if err != nil || len(courseIDs) == 0 {
+			return err
+		}
+
+		// rows referencing the lessons go before the lessons
+		for _, model := range []interface{}{&domain.UserLesson{}, &domain.UserCourse{}, &domain.Lesson{}, &domain.Section{}, &domain.CourseStaff{}, &domain.CourseReview{}, &domain.CourseVersion{}} {
+			if err := tx.Unscoped().Where("course_id IN ?", courseIDs).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM group_courses WHERE course_id IN ?", courseIDs).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("id IN ?", courseIDs).Delete(&domain.Course{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+func (r *TrashRepositoryImp) PurgeLessons(before time.Time) (int64, error) {
+	var purged int64
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		deleted := tx.Unscoped().Model(&domain.Lesson{}).Select("id").Where("deleted_at < ?", before)
+		if err := tx.Where("lesson_id IN (?)", deleted).Delete(&domain.UserLesson{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("deleted_at < ?", before).Delete(&domain.Lesson{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
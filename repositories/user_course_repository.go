@@ -17,6 +17,8 @@ type UserCourseRepository interface {
 	// Progress management
 	UpdateProgress(userID, courseID uint, progress float64, lastLessonID uint) error
 	MarkCourseCompleted(userID, courseID uint) error
+	// PinVersion pins the learner to a version of the course
+	PinVersion(userID, courseID uint, version int) error
 
 	// User's learning analytics
 	GetUserEnrollments(userID uint) ([]domain.UserCourse, error)
@@ -26,20 +28,46 @@ type UserCourseRepository interface {
 	// Course analytics
 	GetCourseEnrollments(courseID uint) ([]domain.UserCourse, error)
 	GetCourseCompletionStats(courseID uint) (totalEnrolled int, totalCompleted int, avgProgress float64, err error)
+
+	// ForOrganization returns a copy that only sees the enrollments of the organization
+	ForOrganization(organizationID uint) UserCourseRepository
 }
 
 type UserCourseRepositoryImp struct {
-	DB *gorm.DB
+	DB             *gorm.DB
+	OrganizationID uint // AllOrganizations for the unscoped repository
 }
 
 func NewUserCourseRepository(db *gorm.DB) UserCourseRepository {
-	return &UserCourseRepositoryImp{DB: db}
+	return &UserCourseRepositoryImp{DB: db, OrganizationID: AllOrganizations}
+}
+
+func (r *UserCourseRepositoryImp) ForOrganization(organizationID uint) UserCourseRepository {
+	return &UserCourseRepositoryImp{DB: r.DB, OrganizationID: organizationID}
+}
+
+func (r *UserCourseRepositoryImp) tenant() *gorm.DB {
+	return r.DB.Scopes(tenantScope("user_courses", r.OrganizationID))
+}
+
+// courseNotDeleted leaves out the enrollments in courses that are in the
+// trash, they come back when the course is restored
+func (r *UserCourseRepositoryImp) courseNotDeleted(db *gorm.DB) *gorm.DB {
+	return db.Where("course_id IN (?)", r.DB.Model(&domain.Course{}).Select("id"))
 }
 
+// EnrollUser enrolls the user once. The enrollment takes the organization of
+// the course, courses of other organizations are not found.
 func (r *UserCourseRepositoryImp) EnrollUser(userID, courseID uint) (*domain.UserCourse, error) {
+	var course domain.Course
+	err := r.DB.Scopes(tenantScope("courses", r.OrganizationID)).Select("id", "organization_id").First(&course, courseID).Error
+	if err != nil {
+		return nil, err
+	}
+
 	// Check if already enrolled
 	var existingEnrollment domain.UserCourse
-	err := r.DB.Where("user_id = ? AND course_id = ?", userID, courseID).First(&existingEnrollment).Error
+	err = r.tenant().Where("user_id = ? AND course_id = ?", userID, courseID).First(&existingEnrollment).Error
 
 	if err == nil {
 		// Already enrolled, return existing enrollment
@@ -53,10 +81,11 @@ func (r *UserCourseRepositoryImp) EnrollUser(userID, courseID uint) (*domain.Use
 
 	// Create new enrollment
 	enrollment := &domain.UserCourse{
-		UserID:     userID,
-		CourseID:   courseID,
-		Progress:   0,
-		EnrolledAt: time.Now(),
+		OrganizationID: course.OrganizationID,
+		UserID:         userID,
+		CourseID:       courseID,
+		Progress:       0,
+		EnrolledAt:     time.Now(),
 	}
 
 	err = r.DB.Create(enrollment).Error
@@ -68,13 +97,13 @@ func (r *UserCourseRepositoryImp) EnrollUser(userID, courseID uint) (*domain.Use
 }
 
 func (r *UserCourseRepositoryImp) UnenrollUser(userID, courseID uint) error {
-	return r.DB.Where("user_id = ? AND course_id = ?", userID, courseID).
+	return r.tenant().Where("user_id = ? AND course_id = ?", userID, courseID).
 		Delete(&domain.UserCourse{}).Error
 }
 
 func (r *UserCourseRepositoryImp) IsUserEnrolled(userID, courseID uint) (bool, error) {
 	var count int64
-	err := r.DB.Model(&domain.UserCourse{}).
+	err := r.tenant().Model(&domain.UserCourse{}).
 		Where("user_id = ? AND course_id = ?", userID, courseID).
 		Count(&count).Error
 
@@ -83,7 +112,7 @@ func (r *UserCourseRepositoryImp) IsUserEnrolled(userID, courseID uint) (bool, e
 
 func (r *UserCourseRepositoryImp) GetUserCourseProgress(userID, courseID uint) (*domain.UserCourse, error) {
 	var userCourse domain.UserCourse
-	err := r.DB.Where("user_id = ? AND course_id = ?", userID, courseID).
+	err := r.tenant().Where("user_id = ? AND course_id = ?", userID, courseID).
 		Preload("Course").First(&userCourse).Error
 
 	if err != nil {
@@ -107,14 +136,14 @@ func (r *UserCourseRepositoryImp) UpdateProgress(userID, courseID uint, progress
 		updates["completed_at"] = &completedAt
 	}
 
-	return r.DB.Model(&domain.UserCourse{}).
+	return r.tenant().Model(&domain.UserCourse{}).
 		Where("user_id = ? AND course_id = ?", userID, courseID).
 		Updates(updates).Error
 }
 
 func (r *UserCourseRepositoryImp) MarkCourseCompleted(userID, courseID uint) error {
 	completedAt := time.Now()
-	return r.DB.Model(&domain.UserCourse{}).
+	return r.tenant().Model(&domain.UserCourse{}).
 		Where("user_id = ? AND course_id = ?", userID, courseID).
 		Updates(map[string]interface{}{
 			"progress":     100,
@@ -124,9 +153,19 @@ func (r *UserCourseRepositoryImp) MarkCourseCompleted(userID, courseID uint) err
 		}).Error
 }
 
+func (r *UserCourseRepositoryImp) PinVersion(userID, courseID uint, version int) error {
+	return r.tenant().Model(&domain.UserCourse{}).
+		Where("user_id = ? AND course_id = ?", userID, courseID).
+		Updates(map[string]interface{}{
+			"course_version": version,
+			"updated_at":     time.Now(),
+		}).Error
+}
+
 func (r *UserCourseRepositoryImp) GetUserEnrollments(userID uint) ([]domain.UserCourse, error) {
 	var enrollments []domain.UserCourse
-	err := r.DB.Where("user_id = ?", userID).
+	err := r.tenant().Where("user_id = ?", userID).
+		Scopes(r.courseNotDeleted).
 		Preload("Course").
 		Order("enrolled_at DESC").
 		Find(&enrollments).Error
@@ -136,7 +175,8 @@ func (r *UserCourseRepositoryImp) GetUserEnrollments(userID uint) ([]domain.User
 
 func (r *UserCourseRepositoryImp) GetUserCompletedCourses(userID uint) ([]domain.UserCourse, error) {
 	var enrollments []domain.UserCourse
-	err := r.DB.Where("user_id = ? AND is_completed = ?", userID, true).
+	err := r.tenant().Where("user_id = ? AND is_completed = ?", userID, true).
+		Scopes(r.courseNotDeleted).
 		Preload("Course").
 		Order("completed_at DESC").
 		Find(&enrollments).Error
@@ -146,7 +186,8 @@ func (r *UserCourseRepositoryImp) GetUserCompletedCourses(userID uint) ([]domain
 
 func (r *UserCourseRepositoryImp) GetUserInProgressCourses(userID uint) ([]domain.UserCourse, error) {
 	var enrollments []domain.UserCourse
-	err := r.DB.Where("user_id = ? AND is_completed = ? AND progress > ?", userID, false, 0).
+	err := r.tenant().Where("user_id = ? AND is_completed = ? AND progress > ?", userID, false, 0).
+		Scopes(r.courseNotDeleted).
 		Preload("Course").
 		Order("updated_at DESC").
 		Find(&enrollments).Error
@@ -156,7 +197,7 @@ func (r *UserCourseRepositoryImp) GetUserInProgressCourses(userID uint) ([]domai
 
 func (r *UserCourseRepositoryImp) GetCourseEnrollments(courseID uint) ([]domain.UserCourse, error) {
 	var enrollments []domain.UserCourse
-	err := r.DB.Where("course_id = ?", courseID).
+	err := r.tenant().Where("course_id = ?", courseID).
 		Preload("User").
 		Order("enrolled_at DESC").
 		Find(&enrollments).Error
@@ -167,7 +208,7 @@ func (r *UserCourseRepositoryImp) GetCourseEnrollments(courseID uint) ([]domain.
 func (r *UserCourseRepositoryImp) GetCourseCompletionStats(courseID uint) (totalEnrolled int, totalCompleted int, avgProgress float64, err error) {
 	// Get total enrolled count
 	var totalEnrolledInt64 int64
-	err = r.DB.Model(&domain.UserCourse{}).
+	err = r.tenant().Model(&domain.UserCourse{}).
 		Where("course_id = ?", courseID).
 		Count(&totalEnrolledInt64).Error
 	if err != nil {
@@ -177,7 +218,7 @@ func (r *UserCourseRepositoryImp) GetCourseCompletionStats(courseID uint) (total
 
 	// Get total completed count
 	var totalCompletedInt64 int64
-	err = r.DB.Model(&domain.UserCourse{}).
+	err = r.tenant().Model(&domain.UserCourse{}).
 		Where("course_id = ? AND is_completed = ?", courseID, true).
 		Count(&totalCompletedInt64).Error
 	if err != nil {
@@ -189,7 +230,7 @@ func (r *UserCourseRepositoryImp) GetCourseCompletionStats(courseID uint) (total
 	var result struct {
 		AvgProgress float64
 	}
-	err = r.DB.Model(&domain.UserCourse{}).
+	err = r.tenant().Model(&domain.UserCourse{}).
 		Select("COALESCE(AVG(progress), 0) as avg_progress").
 		Where("course_id = ?", courseID).
 		Scan(&result).Error
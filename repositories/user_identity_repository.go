@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"gorm.io/gorm"
+)
+
+type UserIdentityRepository interface {
+	GetByIssuerSubject(issuer, subject string) (*domain.UserIdentity, error)
+	Create(identity *domain.UserIdentity) error
+	CreateWithUser(user *domain.User, identity *domain.UserIdentity) error
+	TouchLastLogin(id uint, email string) error
+}
+
+type UserIdentityRepositoryImp struct {
+	DB *gorm.DB
+}
+
+func NewUserIdentityRepository(db *gorm.DB) UserIdentityRepository {
+	return &UserIdentityRepositoryImp{DB: db}
+}
+
+func (r *UserIdentityRepositoryImp) GetByIssuerSubject(issuer, subject string) (*domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+	if err := r.DB.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *UserIdentityRepositoryImp) Create(identity *domain.UserIdentity) error {
+	return r.DB.Create(identity).Error
+}
+
+// CreateWithUser provisions a new user together with its identity
+func (r *UserIdentityRepositoryImp) CreateWithUser(user *domain.User, identity *domain.UserIdentity) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+func (r *UserIdentityRepositoryImp) TouchLastLogin(id uint, email string) error {
+	return r.DB.Model(&domain.UserIdentity{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"email":         email,
+		"last_login_at": time.Now(),
+	}).Error
+}
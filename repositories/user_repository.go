@@ -1,39 +1,217 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/utils/scimutil"
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
+	GetByID(id uint) (*domain.User, error)
 	GetByEmail(email string) (*domain.User, error)
+	GetByIDs(ids []uint) ([]domain.User, error)
 	Create(user *domain.User) error
+	Update(user *domain.User) error
+	UpdateProfile(user *domain.User) error
+	UpdatePassword(id uint, passwordHash string) error
+	EmailExists(email string) (bool, error)
+	GetAll(filter dto.UserFilterRequest) ([]domain.User, int64, error)
+	CountActiveByRole(role string) (int64, error)
+	FindByFilter(filter *scimutil.Filter, offset, limit int) ([]domain.User, int64, error)
+
+	// ForOrganization returns a copy that only sees the users of the organization.
+	// Login and token refresh use the unscoped repository, since the tenant is
+	// only known once the user is.
+	ForOrganization(organizationID uint) UserRepository
 }
 
 type userRepository struct {
-	db *gorm.DB
+	db             *gorm.DB
+	organizationID uint
 }
 
 func NewUserRepository(db *gorm.DB) UserRepository {
-	return &userRepository{db}
+	return &userRepository{db: db, organizationID: AllOrganizations}
+}
+
+func (r *userRepository) ForOrganization(organizationID uint) UserRepository {
+	return &userRepository{db: r.db, organizationID: organizationID}
+}
+
+func (r *userRepository) tenant() *gorm.DB {
+	return r.db.Scopes(tenantScope("users", r.organizationID))
+}
+
+func (r *userRepository) GetByID(id uint) (*domain.User, error) {
+	var user domain.User
+	if err := r.tenant().First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
 func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
 	var user domain.User
-	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := r.tenant().Where("email = ?", email).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
+func (r *userRepository) GetByIDs(ids []uint) ([]domain.User, error) {
+	var users []domain.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.tenant().Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+// Create stores the user in the organization of the repository
 func (r *userRepository) Create(user *domain.User) error {
+	if err := assignOrganization(&user.OrganizationID, r.organizationID); err != nil {
+		return err
+	}
 	return r.db.Create(user).Error
 }
 
-func (r *userRepository) GetAll() ([]domain.User, error) {
+// Update saves every column, see CourseRepositoryImp.Update
+func (r *userRepository) Update(user *domain.User) error {
+	return r.tenant().Select("*").Save(user).Error
+}
+
+// UpdateProfile saves only the self-editable profile fields
+func (r *userRepository) UpdateProfile(user *domain.User) error {
+	return r.tenant().Model(user).
+		Select("name", "avatar_url", "bio", "timezone", "locale", "updated_at").
+		Updates(user).Error
+}
+
+// UpdatePassword sets a new password hash, restarts its max age and clears a
+// pending forced reset
+func (r *userRepository) UpdatePassword(id uint, passwordHash string) error {
+	return r.tenant().Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password":                passwordHash,
+		"password_changed_at":     time.Now(),
+		"password_reset_required": false,
+	}).Error
+}
+
+// EmailExists looks at every organization, email addresses are unique across them
+func (r *userRepository) EmailExists(email string) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.User{}).Where("LOWER(email) = LOWER(?)", email).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *userRepository) GetAll(filter dto.UserFilterRequest) ([]domain.User, int64, error) {
 	var users []domain.User
-	if err := r.db.Find(&users).Error; err != nil {
-		return nil, err
+	var total int64
+
+	query := r.tenant().Model(&domain.User{})
+
+	// Apply filters
+	if filter.Search != "" {
+		searchTerm := "%" + filter.Search + "%"
+		query = query.Where("email ILIKE ? OR name ILIKE ?", searchTerm, searchTerm)
+	}
+
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
 	}
-	return users, nil
+
+	switch filter.Status {
+	case "active":
+		query = query.Where("suspended_at IS NULL")
+	case "suspended":
+		query = query.Where("suspended_at IS NOT NULL")
+	}
+
+	if filter.Verified != nil {
+		if *filter.Verified {
+			query = query.Where("verified_at IS NOT NULL")
+		} else {
+			query = query.Where("verified_at IS NULL")
+		}
+	}
+
+	if filter.Service != nil {
+		query = query.Where("is_service_account = ?", *filter.Service)
+	}
+
+	// Count total records
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Apply sorting
+	sortField := "created_at"
+	if filter.SortBy != "" {
+		sortField = filter.SortBy
+	}
+
+	sortOrder := "DESC"
+	if filter.SortOrder == "asc" {
+		sortOrder = "ASC"
+	}
+
+	// Apply pagination
+	offset := (filter.Page - 1) * filter.Limit
+	err := query.Order(sortField + " " + sortOrder).Offset(offset).Limit(filter.Limit).Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// CountActiveByRole counts users of the role that are not suspended
+func (r *userRepository) CountActiveByRole(role string) (int64, error) {
+	var count int64
+	err := r.tenant().Model(&domain.User{}).Where("role = ? AND suspended_at IS NULL", role).Count(&count).Error
+	return count, err
+}
+
+// scimUserAttributes are the SCIM attributes users can be filtered on
+var scimUserAttributes = map[string]scimutil.Attribute{
+	"id":                {Column: "CAST(id AS TEXT)"},
+	"username":          {Column: "email"},
+	"emails":            {Column: "email"},
+	"emails.value":      {Column: "email"},
+	"externalid":        {Column: "external_id"},
+	"displayname":       {Column: "name"},
+	"name.formatted":    {Column: "name"},
+	"active":            {Column: "(suspended_at IS NULL)", Boolean: true},
+	"meta.created":      {Column: "created_at"},
+	"meta.lastmodified": {Column: "updated_at"},
+}
+
+// FindByFilter lists the users matching a SCIM filter, service accounts are
+// never provisioned through SCIM and are left out
+func (r *userRepository) FindByFilter(filter *scimutil.Filter, offset, limit int) ([]domain.User, int64, error) {
+	var users []domain.User
+	var total int64
+
+	query := r.tenant().Model(&domain.User{}).Where("is_service_account = ?", false)
+	if filter != nil {
+		where, args, err := filter.Where(scimUserAttributes)
+		if err != nil {
+			return nil, 0, err
+		}
+		query = query.Where(where, args...)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id ASC").Offset(offset).Limit(limit).Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
 }
@@ -5,42 +5,108 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/rijwanansari/vivaLearning/controllers"
+	"github.com/rijwanansari/vivaLearning/domain"
 	"github.com/rijwanansari/vivaLearning/middlewares"
+	"github.com/rijwanansari/vivaLearning/services"
 )
 
 type Routes struct {
-	echo   *echo.Echo
-	auth   *controllers.AuthController
-	course *controllers.CourseController
-	lesson *controllers.LessonController
+	echo                *echo.Echo
+	tokenService        domain.TokenService
+	permissionService   services.PermissionService
+	auth                *controllers.AuthController
+	course              *controllers.CourseController
+	lesson              *controllers.LessonController
+	jwks                *controllers.JwksController
+	mfa                 *controllers.MFAController
+	session             *controllers.SessionController
+	user                *controllers.UserController
+	apiKey              *controllers.APIKeyController
+	oidc                *controllers.OIDCController
+	group               *controllers.GroupController
+	scim                *controllers.SCIMController
+	audit               *controllers.AuditController
+	privacy             *controllers.PrivacyController
+	section             *controllers.SectionController
+	sessionService      services.SessionService
+	apiKeyService       services.APIKeyService
+	organizationService services.OrganizationService
 }
 
-func New(e *echo.Echo, auth *controllers.AuthController, course *controllers.CourseController, lesson *controllers.LessonController) *Routes {
+func New(e *echo.Echo, tokenService domain.TokenService, permissionService services.PermissionService, auth *controllers.AuthController, course *controllers.CourseController, lesson *controllers.LessonController, jwks *controllers.JwksController, mfa *controllers.MFAController, session *controllers.SessionController, user *controllers.UserController, apiKey *controllers.APIKeyController, oidc *controllers.OIDCController, group *controllers.GroupController, scim *controllers.SCIMController, audit *controllers.AuditController, privacy *controllers.PrivacyController, section *controllers.SectionController, sessionService services.SessionService, apiKeyService services.APIKeyService, organizationService services.OrganizationService) *Routes {
 	return &Routes{
-		echo:   e,
-		auth:   auth,
-		course: course,
-		lesson: lesson,
+		echo:                e,
+		tokenService:        tokenService,
+		permissionService:   permissionService,
+		auth:                auth,
+		course:              course,
+		lesson:              lesson,
+		jwks:                jwks,
+		mfa:                 mfa,
+		session:             session,
+		user:                user,
+		apiKey:              apiKey,
+		oidc:                oidc,
+		group:               group,
+		scim:                scim,
+		audit:               audit,
+		privacy:             privacy,
+		section:             section,
+		sessionService:      sessionService,
+		apiKeyService:       apiKeyService,
+		organizationService: organizationService,
 	}
 }
 
 func (r *Routes) Init() {
 	e := r.echo
+	jwt := middlewares.AuthMiddleware(r.tokenService, r.sessionService, r.apiKeyService, apiKeyRoutes)
+	tenant := middlewares.Tenant(r.organizationService)
 
 	// Health check
 	e.GET("/ping", func(c echo.Context) error {
 		return c.String(http.StatusOK, "VivaLearning API is running!")
 	})
 
+	// Public verification keys for other services
+	e.GET("/.well-known/jwks.json", r.jwks.GetJWKS)
+
+	// SCIM 2.0 provisioning for the HR system, the bearer token names the organization
+	scim := e.Group("/scim/v2", middlewares.SCIMAuth(r.organizationService))
+	scim.GET("/ServiceProviderConfig", r.scim.GetServiceProviderConfig) // GET /scim/v2/ServiceProviderConfig
+	scim.GET("/ResourceTypes", r.scim.GetResourceTypes)                 // GET /scim/v2/ResourceTypes
+	scim.GET("/Users", r.scim.ListUsers)                                // GET /scim/v2/Users
+	scim.POST("/Users", r.scim.CreateUser)                              // POST /scim/v2/Users
+	scim.GET("/Users/:id", r.scim.GetUser)                              // GET /scim/v2/Users/:id
+	scim.PUT("/Users/:id", r.scim.ReplaceUser)                          // PUT /scim/v2/Users/:id
+	scim.PATCH("/Users/:id", r.scim.PatchUser)                          // PATCH /scim/v2/Users/:id
+	scim.DELETE("/Users/:id", r.scim.DeleteUser)                        // DELETE /scim/v2/Users/:id
+	scim.GET("/Groups", r.scim.ListGroups)                              // GET /scim/v2/Groups
+	scim.POST("/Groups", r.scim.CreateGroup)                            // POST /scim/v2/Groups
+	scim.GET("/Groups/:id", r.scim.GetGroup)                            // GET /scim/v2/Groups/:id
+	scim.PUT("/Groups/:id", r.scim.ReplaceGroup)                        // PUT /scim/v2/Groups/:id
+	scim.PATCH("/Groups/:id", r.scim.PatchGroup)                        // PATCH /scim/v2/Groups/:id
+	scim.DELETE("/Groups/:id", r.scim.DeleteGroup)                      // DELETE /scim/v2/Groups/:id
+
 	// API v1 routes
-	api := e.Group("/api/v1")
+	api := e.Group("/api/v1", tenant)
 
 	// Authentication routes (public)
 	auth := api.Group("/auth")
 	auth.POST("/register", r.auth.RegisterUser)
 	auth.POST("/login", r.auth.LoginUser)
-	// auth.POST("/refresh", r.auth.RefreshToken)
-	// auth.POST("/logout", r.auth.LogoutUser)
+	auth.POST("/refresh", r.auth.RefreshToken)
+	auth.POST("/forgot-password", r.auth.ForgotPassword)
+	auth.POST("/reset-password", r.auth.ResetPassword)
+	auth.POST("/verify-email", r.auth.VerifyEmail)
+	auth.POST("/resend-verification", r.auth.ResendVerification)
+	auth.POST("/mfa/verify", r.mfa.VerifyMFA)
+	auth.POST("/mfa/setup", r.mfa.SetupMFAWithChallenge)
+	auth.POST("/mfa/confirm", r.mfa.ConfirmMFAWithChallenge)
+	auth.GET("/oidc/authorize", r.oidc.AuthorizeSSO)
+	auth.POST("/oidc/callback", r.oidc.SSOCallback)
+	auth.POST("/logout", r.auth.LogoutUser, jwt)
+	auth.POST("/logout-all", r.auth.LogoutAllDevices, jwt)
 
 	// Public course routes (no authentication required)
 	publicCourses := api.Group("/courses")
@@ -51,52 +117,179 @@ func (r *Routes) Init() {
 
 	// Protected routes (require authentication)
 	protected := api.Group("")
-	protected.Use(middlewares.JWTMiddleware)
+	protected.Use(jwt)
 
-	// User profile routes (TODO: implement user controller)
-	// profile := protected.Group("/profile")
-	// profile.GET("", r.user.GetProfile)
-	// profile.PUT("", r.user.UpdateProfile)
+	// User profile routes
+	profile := protected.Group("/profile")
+	profile.GET("", r.user.GetProfile)               // GET /api/v1/profile
+	profile.PUT("", r.user.UpdateProfile)            // PUT /api/v1/profile
+	profile.POST("/email", r.user.ChangeEmail)       // POST /api/v1/profile/email
+	profile.POST("/password", r.user.ChangePassword) // POST /api/v1/profile/password
 
 	// User's enrolled courses
 	myCourses := protected.Group("/my")
 	myCourses.GET("/courses", r.course.GetMyCourses)                  // GET /api/v1/my/courses (created courses)
 	myCourses.GET("/enrolled-courses", r.course.GetMyEnrolledCourses) // GET /api/v1/my/enrolled-courses
 
+	// Active sessions (one per login / device)
+	myCourses.GET("/sessions", r.session.GetMySessions)        // GET /api/v1/my/sessions
+	myCourses.DELETE("/sessions/:id", r.session.RevokeSession) // DELETE /api/v1/my/sessions/:id
+
+	// Personal API keys
+	myCourses.GET("/api-keys", r.apiKey.GetMyAPIKeys)             // GET /api/v1/my/api-keys
+	myCourses.POST("/api-keys", r.apiKey.CreateMyAPIKey)          // POST /api/v1/my/api-keys
+	myCourses.DELETE("/api-keys/:keyId", r.apiKey.RevokeMyAPIKey) // DELETE /api/v1/my/api-keys/:keyId
+
+	// Personal data export and erasure
+	myCourses.GET("/data-export", r.privacy.GetDataExport) // GET /api/v1/my/data-export
+	myCourses.POST("/erasure", r.privacy.EraseMyAccount)   // POST /api/v1/my/erasure
+
+	// Two-factor authentication
+	mfa := protected.Group("/my/mfa")
+	mfa.POST("/setup", r.mfa.SetupMFA)     // POST /api/v1/my/mfa/setup
+	mfa.POST("/confirm", r.mfa.ConfirmMFA) // POST /api/v1/my/mfa/confirm
+	mfa.POST("/disable", r.mfa.DisableMFA) // POST /api/v1/my/mfa/disable
+
 	// Course management (for creators)
 	courseAdmin := protected.Group("/courses")
-	courseAdmin.POST("", r.course.CreateCourse)                    // POST /api/v1/courses
-	courseAdmin.PUT("/:id", r.course.UpdateCourse)                 // PUT /api/v1/courses/:id
-	courseAdmin.DELETE("/:id", r.course.DeleteCourse)              // DELETE /api/v1/courses/:id
-	courseAdmin.GET("/:id/analytics", r.course.GetCourseAnalytics) // GET /api/v1/courses/:id/analytics
+	courseAdmin.POST("", r.course.CreateCourse, r.can(domain.PermCourseCreate))                       // POST /api/v1/courses
+	courseAdmin.PUT("/:id", r.course.UpdateCourse, r.can(domain.PermCourseUpdate))                    // PUT /api/v1/courses/:id
+	courseAdmin.DELETE("/:id", r.course.DeleteCourse, r.can(domain.PermCourseDelete))                 // DELETE /api/v1/courses/:id
+	courseAdmin.GET("/trash", r.course.GetCourseTrash, r.can(domain.PermCourseDelete))                // GET /api/v1/courses/trash
+	courseAdmin.POST("/:id/restore", r.course.RestoreCourse, r.can(domain.PermCourseDelete))          // POST /api/v1/courses/:id/restore
+	courseAdmin.GET("/:id/analytics", r.course.GetCourseAnalytics, r.can(domain.PermCourseAnalytics)) // GET /api/v1/courses/:id/analytics
+
+	// Course staff (owner, co-instructors, teaching assistants, reviewers)
+	courseStaff := protected.Group("/courses/:id")
+	courseStaff.GET("/staff", r.course.GetCourseStaff)                                                        // GET /api/v1/courses/:id/staff
+	courseStaff.POST("/staff", r.course.AddCourseStaff, r.can(domain.PermCourseUpdate))                       // POST /api/v1/courses/:id/staff
+	courseStaff.DELETE("/staff/:userId", r.course.RemoveCourseStaff)                                          // DELETE /api/v1/courses/:id/staff/:userId
+	courseStaff.POST("/transfer-ownership", r.course.TransferCourseOwnership, r.can(domain.PermCourseUpdate)) // POST /api/v1/courses/:id/transfer-ownership
+
+	// Course versions, learners read the published version of a course
+	courseVersions := protected.Group("/courses/:id/versions")
+	courseVersions.GET("", r.course.GetCourseVersions)                                                         // GET /api/v1/courses/:id/versions
+	courseVersions.POST("", r.course.PublishCourseDraft, r.can(domain.PermCoursePublish))                      // POST /api/v1/courses/:id/versions
+	courseVersions.GET("/:version", r.course.GetCourseVersion)                                                 // GET /api/v1/courses/:id/versions/:version
+	courseVersions.GET("/:version/diff", r.course.DiffCourseVersion)                                           // GET /api/v1/courses/:id/versions/:version/diff
+	courseVersions.POST("/:version/rollback", r.course.RollbackCourseVersion, r.can(domain.PermCoursePublish)) // POST /api/v1/courses/:id/versions/:version/rollback
+
+	// Course review workflow, courses reach the catalog once a reviewer approved them
+	courseReview := protected.Group("/courses/:id")
+	courseReview.GET("/reviews", r.course.GetCourseReviews)                                      // GET /api/v1/courses/:id/reviews
+	courseReview.POST("/submit", r.course.SubmitCourseForReview, r.can(domain.PermCourseUpdate)) // POST /api/v1/courses/:id/submit
+	courseReview.POST("/approve", r.course.ApproveCourse, r.can(domain.PermCoursePublish))       // POST /api/v1/courses/:id/approve
+	courseReview.POST("/reject", r.course.RejectCourse, r.can(domain.PermCoursePublish))         // POST /api/v1/courses/:id/reject
+	courseReview.POST("/publish", r.course.PublishCourse, r.can(domain.PermCoursePublish))       // POST /api/v1/courses/:id/publish
+	courseReview.POST("/archive", r.course.ArchiveCourse, r.can(domain.PermCoursePublish))       // POST /api/v1/courses/:id/archive
+	courseReview.PUT("/schedule", r.course.ScheduleCourse, r.can(domain.PermCoursePublish))      // PUT /api/v1/courses/:id/schedule
 
 	// Course enrollment
 	enrollment := protected.Group("/courses")
-	enrollment.POST("/:id/enroll", r.course.EnrollInCourse)       // POST /api/v1/courses/:id/enroll
-	enrollment.DELETE("/:id/enroll", r.course.UnenrollFromCourse) // DELETE /api/v1/courses/:id/enroll
-	enrollment.GET("/:id/progress", r.course.GetCourseProgress)   // GET /api/v1/courses/:id/progress
+	enrollment.POST("/:id/enroll", r.course.EnrollInCourse)               // POST /api/v1/courses/:id/enroll
+	enrollment.DELETE("/:id/enroll", r.course.UnenrollFromCourse)         // DELETE /api/v1/courses/:id/enroll
+	enrollment.POST("/:id/enroll/upgrade", r.course.UpgradeCourseVersion) // POST /api/v1/courses/:id/enroll/upgrade
+	enrollment.GET("/:id/progress", r.course.GetCourseProgress)           // GET /api/v1/courses/:id/progress
 
 	// Lesson management (for creators)
-	lessonAdmin := protected.Group("/courses/:courseId/lessons")
+	lessonAdmin := protected.Group("/courses/:courseId/lessons", r.can(domain.PermLessonManage))
 	lessonAdmin.POST("", r.lesson.CreateLesson)          // POST /api/v1/courses/:courseId/lessons
 	lessonAdmin.PUT("/reorder", r.lesson.ReorderLessons) // PUT /api/v1/courses/:courseId/lessons/reorder
+	lessonAdmin.GET("/trash", r.lesson.GetLessonTrash)   // GET /api/v1/courses/:courseId/lessons/trash
+
+	// Sections grouping the lessons of a course (for creators)
+	sectionAdmin := protected.Group("/courses/:courseId/sections", r.can(domain.PermLessonManage))
+	sectionAdmin.POST("", r.section.CreateSection)          // POST /api/v1/courses/:courseId/sections
+	sectionAdmin.PUT("/reorder", r.section.ReorderSections) // PUT /api/v1/courses/:courseId/sections/reorder
+	sections := protected.Group("/sections", r.can(domain.PermLessonManage))
+	sections.PUT("/:id", r.section.UpdateSection)    // PUT /api/v1/sections/:id
+	sections.DELETE("/:id", r.section.DeleteSection) // DELETE /api/v1/sections/:id
 
 	// Lesson access (for enrolled users)
 	lessons := protected.Group("")
-	lessons.GET("/courses/:courseId/lessons", r.lesson.GetCourseLessons)               // GET /api/v1/courses/:courseId/lessons
-	lessons.GET("/courses/:courseId/lessons/progress", r.lesson.GetUserLessonProgress) // GET /api/v1/courses/:courseId/lessons/progress
-	lessons.GET("/lessons/:id", r.lesson.GetLesson)                                    // GET /api/v1/lessons/:id
-	lessons.PUT("/lessons/:id", r.lesson.UpdateLesson)                                 // PUT /api/v1/lessons/:id
-	lessons.DELETE("/lessons/:id", r.lesson.DeleteLesson)                              // DELETE /api/v1/lessons/:id
+	lessons.GET("/courses/:courseId/lessons", r.lesson.GetCourseLessons)                          // GET /api/v1/courses/:courseId/lessons
+	lessons.GET("/courses/:courseId/lessons/progress", r.lesson.GetUserLessonProgress)            // GET /api/v1/courses/:courseId/lessons/progress
+	lessons.GET("/lessons/:id", r.lesson.GetLesson)                                               // GET /api/v1/lessons/:id
+	lessons.PUT("/lessons/:id", r.lesson.UpdateLesson, r.can(domain.PermLessonManage))            // PUT /api/v1/lessons/:id
+	lessons.PUT("/lessons/:id/schedule", r.lesson.ScheduleLesson, r.can(domain.PermLessonManage)) // PUT /api/v1/lessons/:id/schedule
+	lessons.DELETE("/lessons/:id", r.lesson.DeleteLesson, r.can(domain.PermLessonManage))         // DELETE /api/v1/lessons/:id
+	lessons.POST("/lessons/:id/restore", r.lesson.RestoreLesson, r.can(domain.PermLessonManage))  // POST /api/v1/lessons/:id/restore
 
 	// Lesson progress tracking
 	progress := protected.Group("/lessons")
 	progress.POST("/progress", r.lesson.UpdateLessonProgress)    // POST /api/v1/lessons/progress
 	progress.POST("/:id/complete", r.lesson.MarkLessonCompleted) // POST /api/v1/lessons/:id/complete
 
-	// Admin routes (require admin role - can be added later)
+	// Admin routes
 	admin := protected.Group("/admin")
-	admin.GET("/courses", r.course.GetAllCourses) // GET /api/v1/admin/courses
-	// admin.GET("/users", r.user.GetAllUsers)
+	admin.GET("/courses", r.course.GetAllCourses, r.can(domain.PermCourseReadAll))               // GET /api/v1/admin/courses
+	admin.GET("/courses/review-queue", r.course.GetReviewQueue, r.can(domain.PermCoursePublish)) // GET /api/v1/admin/courses/review-queue
 	// admin.GET("/analytics", r.admin.GetPlatformAnalytics)
+
+	// User administration
+	adminUsers := admin.Group("/users", r.can(domain.PermUserManage))
+	adminUsers.GET("", r.user.ListUsers)                                    // GET /api/v1/admin/users
+	adminUsers.PUT("/:id/role", r.user.ChangeUserRole)                      // PUT /api/v1/admin/users/:id/role
+	adminUsers.POST("/:id/suspend", r.user.SuspendUser)                     // POST /api/v1/admin/users/:id/suspend
+	adminUsers.POST("/:id/reactivate", r.user.ReactivateUser)               // POST /api/v1/admin/users/:id/reactivate
+	adminUsers.POST("/:id/force-password-reset", r.user.ForcePasswordReset) // POST /api/v1/admin/users/:id/force-password-reset
+	adminUsers.POST("/:id/logout", r.user.ForceLogout)                      // POST /api/v1/admin/users/:id/logout
+	adminUsers.POST("/:id/unlock", r.auth.UnlockAccount)                    // POST /api/v1/admin/users/:id/unlock
+	adminUsers.POST("/:id/impersonate", r.user.ImpersonateUser)             // POST /api/v1/admin/users/:id/impersonate
+	adminUsers.POST("/:id/erase", r.privacy.EraseUser)                      // POST /api/v1/admin/users/:id/erase
+
+	// Service accounts and their API keys
+	serviceAccounts := admin.Group("/service-accounts", r.can(domain.PermUserManage))
+	serviceAccounts.GET("", r.apiKey.GetServiceAccounts)                             // GET /api/v1/admin/service-accounts
+	serviceAccounts.POST("", r.apiKey.CreateServiceAccount)                          // POST /api/v1/admin/service-accounts
+	serviceAccounts.GET("/:id/api-keys", r.apiKey.GetServiceAccountKeys)             // GET /api/v1/admin/service-accounts/:id/api-keys
+	serviceAccounts.POST("/:id/api-keys", r.apiKey.CreateServiceAccountKey)          // POST /api/v1/admin/service-accounts/:id/api-keys
+	serviceAccounts.DELETE("/:id/api-keys/:keyId", r.apiKey.RevokeServiceAccountKey) // DELETE /api/v1/admin/service-accounts/:id/api-keys/:keyId
+
+	// Provisioned groups and the courses their members are enrolled in
+	groups := admin.Group("/groups", r.can(domain.PermUserManage))
+	groups.GET("", r.group.GetGroups)                                  // GET /api/v1/admin/groups
+	groups.POST("/:id/courses", r.group.AddGroupCourse)                // POST /api/v1/admin/groups/:id/courses
+	groups.DELETE("/:id/courses/:courseId", r.group.RemoveGroupCourse) // DELETE /api/v1/admin/groups/:id/courses/:courseId
+
+	// Audit log
+	admin.GET("/audit-logs", r.audit.GetAuditLogs, r.can(domain.PermUserManage)) // GET /api/v1/admin/audit-logs
+}
+
+// apiKeyRoutes lists the routes that may be called with an API key and the
+// scope each of them requires. Everything else is rejected for API keys, so
+// keys can never manage credentials, sessions or other API keys.
+var apiKeyRoutes = middlewares.APIKeyRoutes{
+	"GET /api/v1/my/courses":                         domain.ScopeCoursesRead,
+	"GET /api/v1/my/enrolled-courses":                domain.ScopeCoursesRead,
+	"GET /api/v1/admin/courses":                      domain.ScopeCoursesRead,
+	"GET /api/v1/courses/:id/analytics":              domain.ScopeCoursesRead,
+	"GET /api/v1/courses/:courseId/lessons":          domain.ScopeCoursesRead,
+	"GET /api/v1/lessons/:id":                        domain.ScopeCoursesRead,
+	"POST /api/v1/courses":                           domain.ScopeCoursesWrite,
+	"PUT /api/v1/courses/:id":                        domain.ScopeCoursesWrite,
+	"POST /api/v1/courses/:courseId/lessons":         domain.ScopeCoursesWrite,
+	"PUT /api/v1/courses/:courseId/lessons/reorder":  domain.ScopeCoursesWrite,
+	"PUT /api/v1/lessons/:id":                        domain.ScopeCoursesWrite,
+	"PUT /api/v1/lessons/:id/schedule":               domain.ScopeCoursesWrite,
+	"POST /api/v1/courses/:courseId/sections":        domain.ScopeCoursesWrite,
+	"PUT /api/v1/courses/:courseId/sections/reorder": domain.ScopeCoursesWrite,
+	"PUT /api/v1/sections/:id":                       domain.ScopeCoursesWrite,
+	"GET /api/v1/courses/:id/versions":               domain.ScopeCoursesRead,
+	"POST /api/v1/courses/:id/versions":              domain.ScopeCoursesWrite,
+	"GET /api/v1/courses/:id/reviews":                domain.ScopeCoursesRead,
+	"POST /api/v1/courses/:id/submit":                domain.ScopeCoursesWrite,
+	"GET /api/v1/courses/:id/progress":               domain.ScopeProgressRead,
+	"GET /api/v1/courses/:courseId/lessons/progress": domain.ScopeProgressRead,
+	"POST /api/v1/lessons/progress":                  domain.ScopeProgressWrite,
+	"POST /api/v1/lessons/:id/complete":              domain.ScopeProgressWrite,
+	"POST /api/v1/courses/:id/enroll":                domain.ScopeProgressWrite,
+	"DELETE /api/v1/courses/:id/enroll":              domain.ScopeProgressWrite,
+	"GET /api/v1/profile":                            domain.ScopeUsersRead,
+	"GET /api/v1/admin/users":                        domain.ScopeUsersRead,
+}
+
+// can guards a route with a permission of the authenticated user's role
+func (r *Routes) can(permission string) echo.MiddlewareFunc {
+	return middlewares.RequirePermission(r.permissionService, permission)
 }
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+// lastUsedResolution limits how often a request rewrites the last used time of a key
+const lastUsedResolution = time.Minute
+
+type APIKeyService interface {
+	CreateKey(userID uint, req dto.CreateAPIKeyRequest, createdBy uint) (*dto.CreatedAPIKeyResponse, error)
+	GetUserKeys(userID uint) ([]dto.APIKeyResponse, error)
+	RevokeKey(userID uint, keyID uint) error
+	Authenticate(rawKey string, ip string) (*domain.APIKey, error)
+
+	// Service accounts
+	CreateServiceAccount(req dto.CreateServiceAccountRequest) (*dto.ServiceAccountResponse, error)
+	GetServiceAccounts() ([]dto.ServiceAccountResponse, error)
+	GetServiceAccount(userID uint) (*domain.User, error)
+
+	// ForOrganization returns a copy that only sees the service accounts of the organization
+	ForOrganization(organizationID uint) APIKeyService
+}
+
+type APIKeyServiceImp struct {
+	APIKeyRepo     repository.APIKeyRepository
+	UserRepo       repository.UserRepository
+	OrganizationID uint
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository, userRepo repository.UserRepository) APIKeyService {
+	return &APIKeyServiceImp{
+		APIKeyRepo: apiKeyRepo,
+		UserRepo:   userRepo,
+	}
+}
+
+func (s *APIKeyServiceImp) ForOrganization(organizationID uint) APIKeyService {
+	return &APIKeyServiceImp{
+		APIKeyRepo:     s.APIKeyRepo,
+		UserRepo:       s.UserRepo.ForOrganization(organizationID),
+		OrganizationID: organizationID,
+	}
+}
+
+// CreateKey issues a key of the form vl_<prefix>_<secret>. The full key is
+// returned once, afterwards only the prefix can be shown.
+func (s *APIKeyServiceImp) CreateKey(userID uint, req dto.CreateAPIKeyRequest, createdBy uint) (*dto.CreatedAPIKeyResponse, error) {
+	prefix, err := utils.GenerateRandomToken(6)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the prefix free of the separator so that a key always splits unambiguously
+	prefix = strings.NewReplacer("_", "x", "-", "y").Replace(prefix)
+
+	key := &domain.APIKey{
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    prefix,
+		KeyHash:   utils.HashToken(secret),
+		UserID:    userID,
+		Scopes:    strings.Join(uniqueScopes(req.Scopes), ","),
+		CreatedBy: createdBy,
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.APIKeyRepo.Create(key); err != nil {
+		return nil, err
+	}
+
+	return &dto.CreatedAPIKeyResponse{
+		APIKeyResponse: *s.buildAPIKeyResponse(key),
+		Key:            domain.APIKeyPrefix + prefix + "_" + secret,
+	}, nil
+}
+
+func (s *APIKeyServiceImp) GetUserKeys(userID uint) ([]dto.APIKeyResponse, error) {
+	keys, err := s.APIKeyRepo.GetByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		responses = append(responses, *s.buildAPIKeyResponse(&keys[i]))
+	}
+
+	return responses, nil
+}
+
+func (s *APIKeyServiceImp) RevokeKey(userID uint, keyID uint) error {
+	key, err := s.APIKeyRepo.GetByID(keyID)
+	if err != nil || key.UserID != userID {
+		return errutil.ErrRecordNotFound
+	}
+
+	return s.APIKeyRepo.Revoke(key.ID)
+}
+
+// Authenticate resolves a raw key to an active key of an active owner and
+// records its use
+func (s *APIKeyServiceImp) Authenticate(rawKey string, ip string) (*domain.APIKey, error) {
+	if !strings.HasPrefix(rawKey, domain.APIKeyPrefix) {
+		return nil, errutil.ErrInvalidAPIKey
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(rawKey, domain.APIKeyPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errutil.ErrInvalidAPIKey
+	}
+
+	key, err := s.APIKeyRepo.GetByPrefix(parts[0])
+	if err != nil {
+		return nil, errutil.ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(utils.HashToken(parts[1]))) != 1 {
+		return nil, errutil.ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !key.IsActive(now) || key.User.IsSuspended() {
+		return nil, errutil.ErrInvalidAPIKey
+	}
+
+	_ = s.APIKeyRepo.TouchLastUsed(key.ID, ip, now.Add(-lastUsedResolution))
+
+	return key, nil
+}
+
+// CreateServiceAccount creates a user that has no password and can only
+// authenticate with API keys
+func (s *APIKeyServiceImp) CreateServiceAccount(req dto.CreateServiceAccountRequest) (*dto.ServiceAccountResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	slug := serviceAccountSlug(name)
+	if slug == "" {
+		return nil, errutil.ErrInvalidInput
+	}
+	email := fmt.Sprintf("%s@%s", slug, serviceAccountDomain(s.OrganizationID))
+
+	if exists, err := s.UserRepo.EmailExists(email); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, errutil.ErrUserIsAlreadyExists
+	}
+
+	user := &domain.User{
+		Email:            email,
+		Name:             name,
+		Role:             req.Role,
+		IsServiceAccount: true,
+	}
+	if err := s.UserRepo.Create(user); err != nil {
+		return nil, err
+	}
+
+	return s.buildServiceAccountResponse(user), nil
+}
+
+func (s *APIKeyServiceImp) GetServiceAccounts() ([]dto.ServiceAccountResponse, error) {
+	serviceAccount := true
+	users, _, err := s.UserRepo.GetAll(dto.UserFilterRequest{
+		Service:   &serviceAccount,
+		Page:      1,
+		Limit:     1000,
+		SortBy:    "name",
+		SortOrder: "asc",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.ServiceAccountResponse, 0, len(users))
+	for i := range users {
+		responses = append(responses, *s.buildServiceAccountResponse(&users[i]))
+	}
+
+	return responses, nil
+}
+
+func (s *APIKeyServiceImp) GetServiceAccount(userID uint) (*domain.User, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+	if !user.IsServiceAccount {
+		return nil, errutil.ErrNotServiceAccount
+	}
+
+	return user, nil
+}
+
+func (s *APIKeyServiceImp) buildAPIKeyResponse(key *domain.APIKey) *dto.APIKeyResponse {
+	response := &dto.APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     domain.APIKeyPrefix + key.Prefix,
+		Scopes:     key.ScopeList(),
+		LastUsedIP: key.LastUsedIP,
+		Revoked:    key.RevokedAt != nil,
+		CreatedAt:  key.CreatedAt.Format(time.RFC3339),
+	}
+
+	if key.ExpiresAt != nil {
+		expiresAt := key.ExpiresAt.Format(time.RFC3339)
+		response.ExpiresAt = &expiresAt
+	}
+	if key.LastUsedAt != nil {
+		lastUsedAt := key.LastUsedAt.Format(time.RFC3339)
+		response.LastUsedAt = &lastUsedAt
+	}
+
+	return response
+}
+
+func (s *APIKeyServiceImp) buildServiceAccountResponse(user *domain.User) *dto.ServiceAccountResponse {
+	return &dto.ServiceAccountResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Role:      domain.NormalizeRole(user.Role),
+		Suspended: user.IsSuspended(),
+		CreatedAt: user.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func uniqueScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+func serviceAccountSlug(name string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// serviceAccountDomain is the reserved email domain of the service accounts of
+// an organization, they never receive mail. Emails are unique across
+// organizations, so each organization gets its own domain and can pick names
+// without running into the service accounts of another.
+func serviceAccountDomain(organizationID uint) string {
+	return fmt.Sprintf("org-%d.service-accounts.%s.invalid", organizationID, strings.ToLower(config.Jwt().Issuer))
+}
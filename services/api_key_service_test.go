@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/testdb"
+)
+
+func TestCreateServiceAccountNamesArePerOrganization(t *testing.T) {
+	db := testdb.Open(t)
+	acme := domain.Organization{Name: "Acme", Slug: "acme"}
+	other := domain.Organization{Name: "Other", Slug: "other"}
+	mustCreateRows(t, db, &acme, &other)
+
+	service := NewAPIKeyService(repository.NewAPIKeyRepository(db), repository.NewUserRepository(db))
+	req := dto.CreateServiceAccountRequest{Name: "CI Pipeline", Role: domain.RoleInstructor}
+
+	acmeAccount, err := service.ForOrganization(acme.ID).CreateServiceAccount(req)
+	if err != nil {
+		t.Fatalf("CreateServiceAccount in acme: %v", err)
+	}
+	otherAccount, err := service.ForOrganization(other.ID).CreateServiceAccount(req)
+	if err != nil {
+		t.Fatalf("CreateServiceAccount with the same name in another organization: %v", err)
+	}
+	if acmeAccount.Email == otherAccount.Email {
+		t.Errorf("both organizations got the service account %s", acmeAccount.Email)
+	}
+
+	var users []domain.User
+	if err := db.Where("is_service_account = ?", true).Order("id").Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].OrganizationID != acme.ID || users[1].OrganizationID != other.ID {
+		t.Errorf("service accounts = %+v, want one in each organization", users)
+	}
+
+	if _, err := service.ForOrganization(acme.ID).CreateServiceAccount(req); !errors.Is(err, errutil.ErrUserIsAlreadyExists) {
+		t.Errorf("second CreateServiceAccount in acme error = %v, want %v", err, errutil.ErrUserIsAlreadyExists)
+	}
+}
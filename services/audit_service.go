@@ -0,0 +1,201 @@
+package services
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+type AuditService interface {
+	// Record appends an entry for an action of actorID on the target. before and
+	// after are snapshots of the target, either may be nil for creations and
+	// deletions; only the fields that differ are stored.
+	Record(organizationID uint, actorID uint, action string, targetType string, targetID uint, before, after interface{}) error
+	GetAuditLogs(filter dto.AuditLogFilterRequest) (*dto.PaginatedResponse, error)
+
+	// ForOrganization returns a copy that only reads the entries of the organization
+	ForOrganization(organizationID uint) AuditService
+	// WithRequest returns a copy that stamps the request metadata on every entry
+	WithRequest(request types.RequestInfo) AuditService
+}
+
+type AuditServiceImp struct {
+	AuditLogRepo repository.AuditLogRepository
+	Request      types.RequestInfo
+}
+
+func NewAuditService(auditLogRepo repository.AuditLogRepository) AuditService {
+	return &AuditServiceImp{
+		AuditLogRepo: auditLogRepo,
+	}
+}
+
+func (s *AuditServiceImp) ForOrganization(organizationID uint) AuditService {
+	return &AuditServiceImp{
+		AuditLogRepo: s.AuditLogRepo.ForOrganization(organizationID),
+		Request:      s.Request,
+	}
+}
+
+func (s *AuditServiceImp) WithRequest(request types.RequestInfo) AuditService {
+	return &AuditServiceImp{
+		AuditLogRepo: s.AuditLogRepo,
+		Request:      request,
+	}
+}
+
+func (s *AuditServiceImp) Record(organizationID uint, actorID uint, action string, targetType string, targetID uint, before, after interface{}) error {
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		return err
+	}
+
+	return s.AuditLogRepo.Create(&domain.AuditLog{
+		OrganizationID: organizationID,
+		ActorID:        actorID,
+		ImpersonatorID: s.Request.ImpersonatorID,
+		Action:         action,
+		TargetType:     targetType,
+		TargetID:       targetID,
+		Changes:        changes,
+		IP:             s.Request.IP,
+		UserAgent:      s.Request.UserAgent,
+		RequestID:      s.Request.RequestID,
+		Method:         s.Request.Method,
+		Path:           s.Request.Path,
+	})
+}
+
+func (s *AuditServiceImp) GetAuditLogs(filter dto.AuditLogFilterRequest) (*dto.PaginatedResponse, error) {
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	entries, total, err := s.AuditLogRepo.Find(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.AuditLogResponse, 0, len(entries))
+	for i := range entries {
+		responses = append(responses, MapAuditLogToResponse(&entries[i]))
+	}
+
+	return &dto.PaginatedResponse{
+		Data:       responses,
+		Total:      total,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.Limit))),
+	}, nil
+}
+
+// MapAuditLogToResponse is shared with the export command so that exported
+// entries look like the ones served by the API
+func MapAuditLogToResponse(entry *domain.AuditLog) dto.AuditLogResponse {
+	return dto.AuditLogResponse{
+		ID:             entry.ID,
+		OrganizationID: entry.OrganizationID,
+		ActorID:        entry.ActorID,
+		ImpersonatorID: entry.ImpersonatorID,
+		Action:         entry.Action,
+		TargetType:     entry.TargetType,
+		TargetID:       entry.TargetID,
+		Changes:        json.RawMessage(entry.Changes),
+		IP:             entry.IP,
+		UserAgent:      entry.UserAgent,
+		RequestID:      entry.RequestID,
+		Method:         entry.Method,
+		Path:           entry.Path,
+		CreatedAt:      entry.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// auditIgnoredFields change on every write and would only add noise
+var auditIgnoredFields = map[string]bool{"updated_at": true}
+
+// auditChanges diffs the JSON representation of two snapshots. Nested objects
+// and lists (loaded relations) are skipped.
+func auditChanges(before, after interface{}) (string, error) {
+	changes, err := fieldChanges(before, after)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(changes)
+	return string(data), err
+}
+
+// fieldChanges returns the fields that differ as {"field": {"old": ..., "new": ...}}
+func fieldChanges(before, after interface{}) (map[string]map[string]interface{}, error) {
+	oldFields, err := auditSnapshot(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := auditSnapshot(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]map[string]interface{}{}
+	for field, value := range oldFields {
+		if !reflect.DeepEqual(value, newFields[field]) {
+			changes[field] = map[string]interface{}{"old": value, "new": newFields[field]}
+		}
+	}
+	for field, value := range newFields {
+		if _, ok := oldFields[field]; !ok {
+			changes[field] = map[string]interface{}{"old": nil, "new": value}
+		}
+	}
+
+	return changes, nil
+}
+
+func auditSnapshot(value interface{}) (map[string]interface{}, error) {
+	snapshot := map[string]interface{}{}
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return snapshot, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for field, v := range fields {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		}
+		if !auditIgnoredFields[field] {
+			snapshot[field] = v
+		}
+	}
+
+	return snapshot, nil
+}
+
+// recordAudit records an entry on behalf of a service. The change itself has
+// already been committed, so a failing audit write is logged instead of failing
+// the request.
+func recordAudit(auditService AuditService, organizationID uint, actorID uint, action string, targetType string, targetID uint, before, after interface{}) {
+	if err := auditService.Record(organizationID, actorID, action, targetType, targetID, before, after); err != nil {
+		logger.Error(err)
+	}
+}
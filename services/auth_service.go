@@ -2,55 +2,444 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rijwanansari/vivaLearning/config"
 	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/mailer"
 	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
 	"github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/jwtutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService interface {
-	Register(email, password string) (*domain.User, error)
-	Login(email, password string) (string, error)
+	Register(organizationID uint, email, password string) (*domain.User, error)
+	Login(email, password string, client types.ClientInfo) (*types.Token, *types.MFAChallenge, error)
+	CompleteLogin(user *domain.User, client types.ClientInfo) (*types.Token, *types.MFAChallenge, error)
+	RefreshToken(refreshToken string, client types.ClientInfo) (*types.Token, error)
+	Logout(token *types.Token) error
+	LogoutAll(userID uint) error
+	ForgotPassword(email string)
+	// SendPasswordReset mails a single-use reset link to the user
+	SendPasswordReset(user *domain.User) error
+	ResetPassword(resetToken, newPassword string) error
+	SendVerificationEmail(user *domain.User) error
+	SendEmailChangeVerification(user *domain.User, newEmail string) error
+	VerifyEmail(verificationToken string) error
+	ResendVerification(email string) error
+	UnlockAccount(organizationID uint, userID uint) error
 }
 
 type AuthServiceImp struct {
-	UserRepo repository.UserRepository
+	UserRepo       repository.UserRepository
+	TokenService   domain.TokenService
+	RedisService   *RedisService
+	Mailer         mailer.Mailer
+	KeySet         *jwtutil.KeySet
+	MFAService     MFAService
+	SessionService SessionService
+	PasswordPolicy PasswordPolicyService
 }
 
-func NewAuthService(userRepo repository.UserRepository) *AuthServiceImp {
-	return &AuthServiceImp{UserRepo: userRepo}
+func NewAuthService(userRepo repository.UserRepository, tokenService domain.TokenService, redisService *RedisService, mail mailer.Mailer, keySet *jwtutil.KeySet, mfaService MFAService, sessionService SessionService, passwordPolicy PasswordPolicyService) *AuthServiceImp {
+	return &AuthServiceImp{
+		UserRepo:       userRepo,
+		TokenService:   tokenService,
+		RedisService:   redisService,
+		Mailer:         mail,
+		KeySet:         keySet,
+		MFAService:     mfaService,
+		SessionService: sessionService,
+		PasswordPolicy: passwordPolicy,
+	}
 }
 
-func (s *AuthServiceImp) Register(email, password string) (*domain.User, error) {
+// Register creates a learner in the organization the request was made for.
+// Email addresses are unique across organizations and the password has to
+// meet the password policy.
+func (s *AuthServiceImp) Register(organizationID uint, email, password string) (*domain.User, error) {
 	if existing, _ := s.UserRepo.GetByEmail(email); existing != nil {
 		return nil, errors.New("email already registered")
 	}
 
-	hashed, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err := s.PasswordPolicy.Check(nil, password); err != nil {
+		return nil, err
+	}
+
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	user := &domain.User{
-		Email:    email,
-		Password: string(hashed),
-		Role:     "user",
+		OrganizationID:    organizationID,
+		Email:             email,
+		Password:          hashed,
+		Role:              domain.RoleLearner,
+		PasswordChangedAt: &now,
 	}
 	if err := s.UserRepo.Create(user); err != nil {
 		return nil, err
 	}
+	if err := s.PasswordPolicy.Remember(user.ID, hashed); err != nil {
+		logger.Error(err)
+	}
+
+	// the account is usable without verification, the link can be resent later
+	if err := s.SendVerificationEmail(user); err != nil {
+		logger.Error(err)
+	}
 
 	return user, nil
 }
 
-func (s *AuthServiceImp) Login(email, password string) (string, error) {
+// dummyPasswordHash is compared against when the email is unknown so that both
+// failure paths take roughly the same time
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// Login checks the credentials of the user. Unknown emails and wrong passwords
+// both yield errutil.ErrInvalidLoginCredentials, and repeated failures are
+// throttled per account and per IP. When the user needs a second factor an
+// MFA challenge is returned instead of the token.
+func (s *AuthServiceImp) Login(email, password string, client types.ClientInfo) (*types.Token, *types.MFAChallenge, error) {
+	ip := client.IP
+	throttle := s.throttle()
+	if err := throttle.checkLoginAllowed(email, ip); err != nil {
+		return nil, nil, err
+	}
+
 	user, err := s.UserRepo.GetByEmail(email)
+	if err != nil || user == nil {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		throttle.recordLoginFailure(email, ip)
+		return nil, nil, errutil.ErrInvalidLoginCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		throttle.recordLoginFailure(email, ip)
+		return nil, nil, errutil.ErrInvalidLoginCredentials
+	}
+
+	if user.PasswordResetRequired && !user.IsSuspended() {
+		return nil, nil, errutil.ErrPasswordResetRequired
+	}
+	if s.PasswordPolicy.IsExpired(user) && !user.IsSuspended() {
+		return nil, nil, errutil.ErrPasswordExpired
+	}
+
+	return s.CompleteLogin(user, client)
+}
+
+// CompleteLogin issues the token of a user whose identity is established, by
+// password or by single sign-on. Suspended accounts are refused and an MFA
+// challenge is returned instead of the token when a second factor is needed,
+// the failed login attempts are only cleared once no factor is outstanding.
+func (s *AuthServiceImp) CompleteLogin(user *domain.User, client types.ClientInfo) (*types.Token, *types.MFAChallenge, error) {
+	if user.IsSuspended() {
+		return nil, nil, errutil.ErrAccountSuspended
+	}
+
+	if s.MFAService.RequiresMFA(user) {
+		challenge, err := s.MFAService.CreateChallenge(user)
+		return nil, challenge, err
+	}
+
+	if err := s.throttle().resetLoginFailures(user.Email); err != nil {
+		logger.Error(err)
+	}
+
+	token, err := s.TokenService.CreateToken(int(user.ID), int(user.OrganizationID), domain.NormalizeRole(user.Role))
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
-	if user == nil {
-		return "", errors.New("user not found")
+	if err := s.TokenService.StoreTokenUUID(token); err != nil {
+		return nil, nil, err
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", errors.New("invalid password")
+
+	if err := s.SessionService.CreateSession(token, client); err != nil {
+		logger.Error(err)
 	}
-	return utils.GenerateJWT(user.ID)
+
+	return token, nil, nil
+}
+
+func (s *AuthServiceImp) RefreshToken(refreshToken string, client types.ClientInfo) (*types.Token, error) {
+	oldToken, err := s.TokenService.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.TokenService.RefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.SessionService.RotateSession(oldToken.RefreshUuid, token, client); err != nil {
+		logger.Error(err)
+	}
+
+	return token, nil
+}
+
+func (s *AuthServiceImp) Logout(token *types.Token) error {
+	if err := s.TokenService.DeleteTokenUUID(token); err != nil {
+		return err
+	}
+
+	return s.SessionService.DeleteSession(token)
+}
+
+func (s *AuthServiceImp) LogoutAll(userID uint) error {
+	return s.TokenService.DeleteAllTokenUUIDs(int(userID))
+}
+
+// ForgotPassword mails a single-use reset link. It never reveals whether the
+// email belongs to an account: the mail goes out in the background and a
+// failure to send it is only logged, so every request gets the same answer in
+// the same time.
+func (s *AuthServiceImp) ForgotPassword(email string) {
+	user, err := s.UserRepo.GetByEmail(email)
+	if err != nil || user == nil || user.IsServiceAccount {
+		return
+	}
+
+	go func() {
+		if err := s.SendPasswordReset(user); err != nil {
+			logger.Error(err)
+		}
+	}()
+}
+
+func (s *AuthServiceImp) SendPasswordReset(user *domain.User) error {
+	resetToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	expiry := config.Auth().GetPasswordResetExpiry()
+	err = s.RedisService.Set(passwordResetCacheKey(resetToken), user.ID, time.Duration(expiry.Seconds()))
+	if err != nil {
+		return err
+	}
+
+	link := config.App().BaseURL + "/reset-password?token=" + url.QueryEscape(resetToken)
+	err = s.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your VivaLearning password",
+		Body: fmt.Sprintf("We received a request to reset your password.\n\n"+
+			"Open the link below to choose a new one. It expires in %d minutes and can only be used once.\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email.", int(expiry.Minutes()), link),
+	})
+	if err != nil {
+		// the token is useless if the mail was not delivered
+		_ = s.RedisService.Del(passwordResetCacheKey(resetToken))
+		return err
+	}
+
+	return nil
+}
+
+// ResetPassword consumes the reset token, sets the new password and revokes
+// every existing session of the user. A password refused by the password
+// policy leaves the token usable for another attempt.
+func (s *AuthServiceImp) ResetPassword(resetToken, newPassword string) error {
+	cacheKey := passwordResetCacheKey(resetToken)
+
+	userID, err := s.RedisService.GetInt(cacheKey)
+	if err != nil {
+		return errutil.ErrInvalidResetToken
+	}
+
+	user, err := s.UserRepo.GetByID(uint(userID))
+	if err != nil {
+		return errutil.ErrInvalidResetToken
+	}
+
+	if err := s.PasswordPolicy.Check(user, newPassword); err != nil {
+		return err
+	}
+
+	// only the caller that actually removes the key may use the token
+	deleted, err := s.RedisService.DelCount(cacheKey)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errutil.ErrInvalidResetToken
+	}
+
+	hashed, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.UserRepo.UpdatePassword(user.ID, hashed); err != nil {
+		return err
+	}
+	if err := s.PasswordPolicy.Remember(user.ID, hashed); err != nil {
+		logger.Error(err)
+	}
+
+	return s.TokenService.DeleteAllTokenUUIDs(int(user.ID))
+}
+
+// SendVerificationEmail mails a signed link confirming the user's email address.
+// The link embeds the address so it stops working once the email is changed.
+func (s *AuthServiceImp) SendVerificationEmail(user *domain.User) error {
+	expiry := config.Auth().GetEmailVerificationExpiry()
+	verificationToken, err := s.KeySet.Sign(jwtutil.EmailVerificationToken, jwt.MapClaims{
+		"uid":   user.ID,
+		"email": user.Email,
+		"exp":   time.Now().Add(expiry).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := config.App().BaseURL + "/verify-email?token=" + url.QueryEscape(verificationToken)
+	return s.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your VivaLearning email address",
+		Body: fmt.Sprintf("Welcome to VivaLearning!\n\n"+
+			"Open the link below to confirm your email address. It expires in %d hours.\n\n%s\n\n"+
+			"If you did not create an account, you can ignore this email.", int(expiry.Hours()), link),
+	})
+}
+
+// SendEmailChangeVerification mails a link to the new address. The address of
+// the account only changes once that link is opened.
+func (s *AuthServiceImp) SendEmailChangeVerification(user *domain.User, newEmail string) error {
+	expiry := config.Auth().GetEmailVerificationExpiry()
+	verificationToken, err := s.KeySet.Sign(jwtutil.EmailVerificationToken, jwt.MapClaims{
+		"uid":       user.ID,
+		"email":     user.Email,
+		"new_email": newEmail,
+		"exp":       time.Now().Add(expiry).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := config.App().BaseURL + "/verify-email?token=" + url.QueryEscape(verificationToken)
+	err = s.Mailer.Send(mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new VivaLearning email address",
+		Body: fmt.Sprintf("Open the link below to use this address for your VivaLearning account. It expires in %d hours.\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email.", int(expiry.Hours()), link),
+	})
+	if err != nil {
+		return err
+	}
+
+	// let the current owner of the account know, in case it was not them
+	if err := s.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your VivaLearning email address is being changed",
+		Body: fmt.Sprintf("A request was made to change the email address of your account to %s.\n\n"+
+			"If this was not you, change your password right away.", newEmail),
+	}); err != nil {
+		logger.Error(err)
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the user's address as verified, or switches the account to
+// the new address of an email change. Verifying an already verified address is
+// not an error.
+func (s *AuthServiceImp) VerifyEmail(verificationToken string) error {
+	claims, err := s.KeySet.Parse(jwtutil.EmailVerificationToken, verificationToken)
+	if err != nil {
+		return errutil.ErrInvalidVerificationToken
+	}
+
+	userID, ok := claims["uid"].(float64)
+	if !ok {
+		return errutil.ErrInvalidVerificationToken
+	}
+	email, _ := claims["email"].(string)
+	newEmail, _ := claims["new_email"].(string)
+
+	user, err := s.UserRepo.GetByID(uint(userID))
+	if err != nil || user.Email != email {
+		return errutil.ErrInvalidVerificationToken
+	}
+
+	now := time.Now()
+	if newEmail != "" {
+		if exists, err := s.UserRepo.EmailExists(newEmail); err != nil {
+			return err
+		} else if exists {
+			return errutil.ErrEmailAlreadyInUse
+		}
+
+		user.Email = newEmail
+		user.VerifiedAt = &now
+		return s.UserRepo.Update(user)
+	}
+
+	if user.IsVerified() {
+		return nil
+	}
+
+	user.VerifiedAt = &now
+	return s.UserRepo.Update(user)
+}
+
+// ResendVerification mails a new verification link. Requests are throttled per
+// email address and unknown or already verified addresses are silently ignored.
+func (s *AuthServiceImp) ResendVerification(email string) error {
+	interval := time.Duration(config.Auth().VerificationResendInterval)
+	ok, err := s.RedisService.SetNX(verificationThrottleCacheKey(email), 1, interval)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errutil.ErrVerificationThrottled
+	}
+
+	user, err := s.UserRepo.GetByEmail(email)
+	if err != nil || user == nil || user.IsVerified() {
+		return nil
+	}
+
+	// sent in the background like the reset link, see ForgotPassword
+	go func() {
+		if err := s.SendVerificationEmail(user); err != nil {
+			logger.Error(err)
+		}
+	}()
+	return nil
+}
+
+// UnlockAccount lifts a login lockout and clears the failed attempts of the user
+func (s *AuthServiceImp) UnlockAccount(organizationID uint, userID uint) error {
+	user, err := s.UserRepo.ForOrganization(organizationID).GetByID(userID)
+	if err != nil {
+		return errutil.ErrRecordNotFound
+	}
+
+	return s.throttle().resetLoginFailures(user.Email)
+}
+
+func (s *AuthServiceImp) throttle() loginThrottle {
+	return loginThrottle{RedisService: s.RedisService}
+}
+
+func passwordResetCacheKey(resetToken string) string {
+	return config.Redis().MandatoryPrefix + config.Redis().PasswordResetPrefix + utils.HashToken(resetToken)
+}
+
+func verificationThrottleCacheKey(email string) string {
+	return config.Redis().MandatoryPrefix + config.Redis().VerificationThrottlePrefix + utils.HashToken(strings.ToLower(email))
 }
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/testdb"
+)
+
+func TestRegisterRefusesEmptyPassword(t *testing.T) {
+	for _, minLength := range []int{8, 0} {
+		previous := config.Password().MinLength
+		config.Password().MinLength = minLength
+		t.Cleanup(func() { config.Password().MinLength = previous })
+
+		db := testdb.Open(t)
+		organization := domain.Organization{Name: "Acme", Slug: "acme"}
+		if err := db.Create(&organization).Error; err != nil {
+			t.Fatal(err)
+		}
+		auth := &AuthServiceImp{
+			UserRepo:       repository.NewUserRepository(db),
+			PasswordPolicy: NewPasswordPolicyService(repository.NewPasswordHistoryRepository(db), nil),
+		}
+
+		if _, err := auth.Register(organization.ID, "jane@example.com", ""); !errors.Is(err, errutil.ErrWeakPassword) {
+			t.Errorf("Register with min length %d error = %v, want %v", minLength, err, errutil.ErrWeakPassword)
+		}
+		var users int64
+		if err := db.Model(&domain.User{}).Count(&users).Error; err != nil {
+			t.Fatal(err)
+		}
+		if users != 0 {
+			t.Errorf("Register with min length %d created %d users", minLength, users)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+// CourseReviewService moves courses through the review workflow: authors
+// submit a draft, a reviewer approves it or sends it back with comments, and
+// only an approved course can be published to the catalog
+type CourseReviewService interface {
+	// SubmitForReview sends a draft or archived course to the reviewers
+	SubmitForReview(courseID uint, req dto.CourseReviewRequest, userID uint) (*dto.CourseReviewResponse, error)
+	Approve(courseID uint, req dto.CourseReviewRequest, userID uint) (*dto.CourseReviewResponse, error)
+	// Reject sends a course in review, or approved but not yet published, back to draft
+	Reject(courseID uint, req dto.RejectCourseRequest, userID uint) (*dto.CourseReviewResponse, error)
+	// Publish puts an approved course in the catalog, publishing its draft as a version
+	Publish(courseID uint, req dto.CourseReviewRequest, userID uint) (*dto.CourseReviewResponse, error)
+	// Archive takes a published course out of the catalog
+	Archive(courseID uint, req dto.CourseReviewRequest, userID uint) (*dto.CourseReviewResponse, error)
+	// Schedule sets when the course is published once approved and when it is archived
+	Schedule(courseID uint, req dto.PublishScheduleRequest, userID uint) (*dto.PublishScheduleResponse, error)
+	GetReviews(courseID uint, userID uint) ([]dto.CourseReviewResponse, error)
+	// GetReviewQueue lists the courses waiting for review, longest waiting first
+	GetReviewQueue() ([]dto.ReviewQueueResponse, error)
+
+	// ForOrganization returns a copy that works on the data of the organization
+	ForOrganization(organizationID uint) CourseReviewService
+	// WithRequest returns a copy that records the request in the audit log
+	WithRequest(request types.RequestInfo) CourseReviewService
+}
+
+type CourseReviewServiceImp struct {
+	ReviewRepo   repository.CourseReviewRepository
+	CourseRepo   repository.CourseRepository
+	VersionRepo  repository.CourseVersionRepository
+	StaffRepo    repository.CourseStaffRepository
+	AuditService AuditService
+}
+
+func NewCourseReviewService(reviewRepo repository.CourseReviewRepository, courseRepo repository.CourseRepository, versionRepo repository.CourseVersionRepository, staffRepo repository.CourseStaffRepository, auditService AuditService) CourseReviewService {
+	return &CourseReviewServiceImp{
+		ReviewRepo:   reviewRepo,
+		CourseRepo:   courseRepo,
+		VersionRepo:  versionRepo,
+		StaffRepo:    staffRepo,
+		AuditService: auditService,
+	}
+}
+
+func (s *CourseReviewServiceImp) ForOrganization(organizationID uint) CourseReviewService {
+	return &CourseReviewServiceImp{
+		ReviewRepo:   s.ReviewRepo.ForOrganization(organizationID),
+		CourseRepo:   s.CourseRepo.ForOrganization(organizationID),
+		VersionRepo:  s.VersionRepo.ForOrganization(organizationID),
+		StaffRepo:    s.StaffRepo,
+		AuditService: s.AuditService,
+	}
+}
+
+func (s *CourseReviewServiceImp) WithRequest(request types.RequestInfo) CourseReviewService {
+	return &CourseReviewServiceImp{
+		ReviewRepo:   s.ReviewRepo,
+		CourseRepo:   s.CourseRepo,
+		VersionRepo:  s.VersionRepo,
+		StaffRepo:    s.StaffRepo,
+		AuditService: s.AuditService.WithRequest(request),
+	}
+}
+
+func (s *CourseReviewServiceImp) SubmitForReview(courseID uint, req dto.CourseReviewRequest, userID uint) (*dto.CourseReviewResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionUpdate) {
+		return nil, errors.New("unauthorized to submit this course for review")
+	}
+
+	return s.transition(course, domain.CourseReviewSubmit, domain.CourseStatusInReview, req.Comment, userID)
+}
+
+func (s *CourseReviewServiceImp) Approve(courseID uint, req dto.CourseReviewRequest, userID uint) (*dto.CourseReviewResponse, error) {
+	course, err := s.reviewedCourse(courseID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transition(course, domain.CourseReviewApprove, domain.CourseStatusApproved, req.Comment, userID)
+}
+
+func (s *CourseReviewServiceImp) Reject(courseID uint, req dto.RejectCourseRequest, userID uint) (*dto.CourseReviewResponse, error) {
+	course, err := s.reviewedCourse(courseID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transition(course, domain.CourseReviewReject, domain.CourseStatusDraft, req.Comment, userID)
+}
+
+func (s *CourseReviewServiceImp) Publish(courseID uint, req dto.CourseReviewRequest, userID uint) (*dto.CourseReviewResponse, error) {
+	course, err := s.CourseRepo.GetByIDWithLessons(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionUpdate) {
+		return nil, errors.New("unauthorized to publish this course")
+	}
+
+	review, err := publishCourse(s.ReviewRepo, s.VersionRepo, s.AuditService, course, req.Comment, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapCourseReviewToResponse(review), nil
+}
+
+func (s *CourseReviewServiceImp) Archive(courseID uint, req dto.CourseReviewRequest, userID uint) (*dto.CourseReviewResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionUpdate) {
+		return nil, errors.New("unauthorized to archive this course")
+	}
+
+	return s.transition(course, domain.CourseReviewArchive, domain.CourseStatusArchived, req.Comment, userID)
+}
+
+func (s *CourseReviewServiceImp) Schedule(courseID uint, req dto.PublishScheduleRequest, userID uint) (*dto.PublishScheduleResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionUpdate) {
+		return nil, errors.New("unauthorized to schedule this course")
+	}
+
+	if err := validateSchedule(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.CourseRepo.SetSchedule(course.ID, req.PublishAt, req.UnpublishAt); err != nil {
+		return nil, err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionCourseUpdate, domain.AuditTargetCourse, course.ID,
+		map[string]interface{}{"publish_at": course.PublishAt, "unpublish_at": course.UnpublishAt},
+		map[string]interface{}{"publish_at": req.PublishAt, "unpublish_at": req.UnpublishAt})
+
+	return mapScheduleToResponse(req.PublishAt, req.UnpublishAt), nil
+}
+
+func (s *CourseReviewServiceImp) GetReviews(courseID uint, userID uint) ([]dto.CourseReviewResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionViewContent) {
+		return nil, errors.New("unauthorized to view the reviews of this course")
+	}
+
+	reviews, err := s.ReviewRepo.GetReviews(courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.CourseReviewResponse, 0, len(reviews))
+	for i := range reviews {
+		responses = append(responses, *mapCourseReviewToResponse(&reviews[i]))
+	}
+
+	return responses, nil
+}
+
+func (s *CourseReviewServiceImp) GetReviewQueue() ([]dto.ReviewQueueResponse, error) {
+	courses, err := s.CourseRepo.GetCoursesByStatus(domain.CourseStatusInReview)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := make([]dto.ReviewQueueResponse, 0, len(courses))
+	for _, course := range courses {
+		item := dto.ReviewQueueResponse{
+			CourseID:  course.ID,
+			Title:     course.Title,
+			Category:  course.Category,
+			CreatedBy: course.CreatedBy,
+		}
+
+		reviews, err := s.ReviewRepo.GetReviews(course.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(reviews) - 1; i >= 0; i-- {
+			if reviews[i].Action == domain.CourseReviewSubmit {
+				item.SubmittedBy = reviews[i].UserID
+				item.SubmittedAt = reviews[i].CreatedAt.Format(time.RFC3339)
+				item.Comment = reviews[i].Comment
+				break
+			}
+		}
+
+		queue = append(queue, item)
+	}
+
+	return queue, nil
+}
+
+// reviewedCourse loads a course for a reviewer. The staff who can edit the
+// course cannot review it, so every course is checked by a second person. An
+// admin who is not on the staff can, even though admins may edit any course.
+func (s *CourseReviewServiceImp) reviewedCourse(courseID uint, userID uint) (*domain.Course, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if isCourseStaffFor(s.StaffRepo, course, userID, domain.CourseActionUpdate) {
+		return nil, errutil.ErrOwnCourseReview
+	}
+
+	return course, nil
+}
+
+// transition moves the course to the status and records the step with its comment
+func (s *CourseReviewServiceImp) transition(course *domain.Course, action, to, comment string, userID uint) (*dto.CourseReviewResponse, error) {
+	review, err := transitionCourse(s.ReviewRepo, s.AuditService, course, action, to, comment, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapCourseReviewToResponse(review), nil
+}
+
+// publishCourse publishes an approved course, loaded with its sections and
+// lessons, and publishes its draft as a version so learners get the approved
+// content rather than whatever an earlier version held
+func publishCourse(reviewRepo repository.CourseReviewRepository, versionRepo repository.CourseVersionRepository, auditService AuditService, course *domain.Course, comment string, userID uint) (*domain.CourseReview, error) {
+	review, err := transitionCourse(reviewRepo, auditService, course, domain.CourseReviewPublish, domain.CourseStatusPublished, comment, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := publishVersion(versionRepo, course, "Published after review", userID)
+	if err != nil {
+		return nil, err
+	}
+	recordAudit(auditService, course.OrganizationID, userID, domain.AuditActionCoursePublishVersion, domain.AuditTargetCourse, course.ID,
+		map[string]interface{}{"published_version": course.PublishedVersion},
+		map[string]interface{}{"published_version": version.Version})
+	course.PublishedVersion = version.Version
+
+	return review, nil
+}
+
+// transitionCourse moves the course to the status and records the step with
+// its comment, userID is 0 for the scheduler
+func transitionCourse(reviewRepo repository.CourseReviewRepository, auditService AuditService, course *domain.Course, action, to, comment string, userID uint) (*domain.CourseReview, error) {
+	if !domain.CourseStatusCanMove(course.Status, to) {
+		return nil, fmt.Errorf("%w: the course is %s", errutil.ErrInvalidCourseStatus, course.Status)
+	}
+
+	review := &domain.CourseReview{
+		OrganizationID: course.OrganizationID,
+		CourseID:       course.ID,
+		UserID:         userID,
+		Action:         action,
+		FromStatus:     course.Status,
+		ToStatus:       to,
+		Comment:        comment,
+		CreatedAt:      time.Now(),
+	}
+	moved, err := reviewRepo.Transition(review)
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		// somebody else moved the course since it was loaded
+		return nil, fmt.Errorf("%w: the course was changed by someone else, reload it", errutil.ErrInvalidCourseStatus)
+	}
+	recordAudit(auditService, course.OrganizationID, userID, reviewAuditActions[action], domain.AuditTargetCourse, course.ID,
+		map[string]interface{}{"status": review.FromStatus},
+		map[string]interface{}{"status": review.ToStatus, "comment": comment})
+
+	course.Status = to
+	course.IsPublished = to == domain.CourseStatusPublished
+
+	return review, nil
+}
+
+var reviewAuditActions = map[string]string{
+	domain.CourseReviewSubmit:  domain.AuditActionCourseSubmitReview,
+	domain.CourseReviewApprove: domain.AuditActionCourseApprove,
+	domain.CourseReviewReject:  domain.AuditActionCourseReject,
+	domain.CourseReviewPublish: domain.AuditActionCoursePublish,
+	domain.CourseReviewArchive: domain.AuditActionCourseArchive,
+}
+
+func mapCourseReviewToResponse(review *domain.CourseReview) *dto.CourseReviewResponse {
+	return &dto.CourseReviewResponse{
+		ID:         review.ID,
+		CourseID:   review.CourseID,
+		UserID:     review.UserID,
+		Action:     review.Action,
+		FromStatus: review.FromStatus,
+		ToStatus:   review.ToStatus,
+		Comment:    review.Comment,
+		CreatedAt:  review.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -6,16 +6,23 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rijwanansari/vivaLearning/config"
 	"github.com/rijwanansari/vivaLearning/domain"
 	"github.com/rijwanansari/vivaLearning/dto"
 	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
 )
 
 type CourseService interface {
 	// Admin operations
 	CreateCourse(req dto.CreateCourseRequest, creatorID uint) (*dto.CourseResponse, error)
 	UpdateCourse(id uint, req dto.UpdateCourseRequest, userID uint) (*dto.CourseResponse, error)
+	// DeleteCourse moves the course to the trash
 	DeleteCourse(id uint, userID uint) error
+	// GetTrash lists the courses in the trash the user can restore
+	GetTrash(userID uint) ([]dto.TrashItemResponse, error)
+	RestoreCourse(id uint, userID uint) (*dto.CourseResponse, error)
 	GetCourseByID(id uint, userID *uint) (*dto.CourseResponse, error)
 	GetAllCourses() ([]dto.CourseListResponse, error)
 	GetCoursesByCreator(creatorID uint) ([]dto.CourseListResponse, error)
@@ -31,20 +38,57 @@ type CourseService interface {
 	GetUserCourseProgress(courseID uint, userID uint) (*dto.UserProgressResponse, error)
 
 	// Statistics
-	GetCourseAnalytics(courseID uint) (map[string]interface{}, error)
+	GetCourseAnalytics(courseID uint, userID uint) (map[string]interface{}, error)
+
+	// ForOrganization returns a copy that works on the data of the organization
+	ForOrganization(organizationID uint) CourseService
+	// WithRequest returns a copy that records the request in the audit log
+	WithRequest(request types.RequestInfo) CourseService
 }
 
 type CourseServiceImp struct {
 	CourseRepo     repository.CourseRepository
 	UserCourseRepo repository.UserCourseRepository
 	LessonRepo     repository.LessonRepository
+	StaffRepo      repository.CourseStaffRepository
+	UserRepo       repository.UserRepository
+	VersionRepo    repository.CourseVersionRepository
+	AuditService   AuditService
 }
 
-func NewCourseService(courseRepo repository.CourseRepository, userCourseRepo repository.UserCourseRepository, lessonRepo repository.LessonRepository) CourseService {
+func NewCourseService(courseRepo repository.CourseRepository, userCourseRepo repository.UserCourseRepository, lessonRepo repository.LessonRepository, staffRepo repository.CourseStaffRepository, userRepo repository.UserRepository, versionRepo repository.CourseVersionRepository, auditService AuditService) CourseService {
 	return &CourseServiceImp{
 		CourseRepo:     courseRepo,
 		UserCourseRepo: userCourseRepo,
 		LessonRepo:     lessonRepo,
+		StaffRepo:      staffRepo,
+		UserRepo:       userRepo,
+		VersionRepo:    versionRepo,
+		AuditService:   auditService,
+	}
+}
+
+func (s *CourseServiceImp) ForOrganization(organizationID uint) CourseService {
+	return &CourseServiceImp{
+		CourseRepo:     s.CourseRepo.ForOrganization(organizationID),
+		UserCourseRepo: s.UserCourseRepo.ForOrganization(organizationID),
+		LessonRepo:     s.LessonRepo.ForOrganization(organizationID),
+		StaffRepo:      s.StaffRepo,
+		UserRepo:       s.UserRepo.ForOrganization(organizationID),
+		VersionRepo:    s.VersionRepo.ForOrganization(organizationID),
+		AuditService:   s.AuditService,
+	}
+}
+
+func (s *CourseServiceImp) WithRequest(request types.RequestInfo) CourseService {
+	return &CourseServiceImp{
+		CourseRepo:     s.CourseRepo,
+		UserCourseRepo: s.UserCourseRepo,
+		LessonRepo:     s.LessonRepo,
+		StaffRepo:      s.StaffRepo,
+		UserRepo:       s.UserRepo,
+		VersionRepo:    s.VersionRepo,
+		AuditService:   s.AuditService.WithRequest(request),
 	}
 }
 
@@ -58,16 +102,23 @@ func (s *CourseServiceImp) CreateCourse(req dto.CreateCourseRequest, creatorID u
 		Category:         req.Category,
 		Tags:             req.Tags,
 		Price:            req.Price,
-		IsPublished:      req.IsPublished,
+		Status:           domain.CourseStatusDraft, // goes live through review only
 		CreatedBy:        creatorID,
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
 	}
 
-	err := s.CourseRepo.Create(course)
+	err := s.CourseRepo.CreateWithOwner(course, &domain.CourseStaff{
+		UserID:    creatorID,
+		Role:      domain.CourseStaffOwner,
+		AddedBy:   creatorID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
 	if err != nil {
 		return nil, err
 	}
+	recordAudit(s.AuditService, course.OrganizationID, creatorID, domain.AuditActionCourseCreate, domain.AuditTargetCourse, course.ID, nil, course)
 
 	return s.mapCourseToResponse(course, nil), nil
 }
@@ -78,11 +129,17 @@ func (s *CourseServiceImp) UpdateCourse(id uint, req dto.UpdateCourseRequest, us
 		return nil, err
 	}
 
-	// Check if user is the creator (authorization should be handled in controller/middleware)
-	if course.CreatedBy != userID {
+	// Check if user is allowed to edit the course (owner or co-instructor)
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionUpdate) {
 		return nil, errors.New("unauthorized to update this course")
 	}
 
+	if err := freezeLiveVersion(s.VersionRepo, s.CourseRepo, course, userID); err != nil {
+		return nil, err
+	}
+
+	before := *course
+
 	// Update fields if provided
 	if req.Title != nil {
 		course.Title = *req.Title
@@ -108,16 +165,13 @@ func (s *CourseServiceImp) UpdateCourse(id uint, req dto.UpdateCourseRequest, us
 	if req.Price != nil {
 		course.Price = *req.Price
 	}
-	if req.IsPublished != nil {
-		course.IsPublished = *req.IsPublished
-	}
-
 	course.UpdatedAt = time.Now()
 
 	err = s.CourseRepo.Update(course)
 	if err != nil {
 		return nil, err
 	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionCourseUpdate, domain.AuditTargetCourse, course.ID, &before, course)
 
 	return s.mapCourseToResponse(course, nil), nil
 }
@@ -128,12 +182,55 @@ func (s *CourseServiceImp) DeleteCourse(id uint, userID uint) error {
 		return err
 	}
 
-	// Check if user is the creator
-	if course.CreatedBy != userID {
+	// Only the owner can delete the course
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionDelete) {
 		return errors.New("unauthorized to delete this course")
 	}
 
-	return s.CourseRepo.Delete(id)
+	if err := s.CourseRepo.Delete(id); err != nil {
+		return err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionCourseDelete, domain.AuditTargetCourse, course.ID, course, nil)
+
+	return nil
+}
+
+func (s *CourseServiceImp) GetTrash(userID uint) ([]dto.TrashItemResponse, error) {
+	courses, err := s.CourseRepo.GetDeleted()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []dto.TrashItemResponse{}
+	for _, course := range courses {
+		if canOnCourse(s.StaffRepo, &course, userID, domain.CourseActionDelete) {
+			responses = append(responses, mapTrashItem(course.ID, course.Title, 0, course.DeletedAt.Time))
+		}
+	}
+
+	return responses, nil
+}
+
+// RestoreCourse takes the course out of the trash in the status it was
+// deleted in, with its lessons and enrollments
+func (s *CourseServiceImp) RestoreCourse(id uint, userID uint) (*dto.CourseResponse, error) {
+	course, err := s.CourseRepo.GetDeletedByID(id)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	// whoever may delete the course may restore it
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionDelete) {
+		return nil, errors.New("unauthorized to restore this course")
+	}
+
+	if err := s.CourseRepo.Restore(id); err != nil {
+		return nil, err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionCourseRestore, domain.AuditTargetCourse, course.ID,
+		map[string]interface{}{"deleted_at": course.DeletedAt.Time}, map[string]interface{}{"deleted_at": nil})
+
+	return s.mapCourseToResponse(course, nil), nil
 }
 
 func (s *CourseServiceImp) GetCourseByID(id uint, userID *uint) (*dto.CourseResponse, error) {
@@ -143,24 +240,94 @@ func (s *CourseServiceImp) GetCourseByID(id uint, userID *uint) (*dto.CourseResp
 	}
 
 	var userProgress *dto.UserProgressResponse
+	isEnrolled, isStaff, pinned := false, false, 0
 	if userID != nil {
+		isStaff = canOnCourse(s.StaffRepo, course, *userID, domain.CourseActionViewContent)
 		if enrolled, _ := s.UserCourseRepo.IsUserEnrolled(*userID, id); enrolled {
+			isEnrolled = true
 			if progress, err := s.UserCourseRepo.GetUserCourseProgress(*userID, id); err == nil {
-				userProgress = &dto.UserProgressResponse{
-					Progress:     progress.Progress,
-					LastLessonID: progress.LastLessonID,
-					IsCompleted:  progress.IsCompleted,
-					EnrolledAt:   progress.EnrolledAt.Format(time.RFC3339),
-				}
-				if progress.CompletedAt != nil {
-					completedAtStr := progress.CompletedAt.Format(time.RFC3339)
-					userProgress.CompletedAt = &completedAtStr
-				}
+				userProgress = mapUserProgressToResponse(progress, course)
+				pinned = progress.CourseVersion
 			}
 		}
 	}
 
-	return s.mapCourseToResponse(course, userProgress), nil
+	// archived courses stay readable for the learners enrolled in them
+	if !canReadCourse(course, isStaff, isEnrolled) {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	// staff work on the draft, everybody else reads the version they are on
+	if !isStaff {
+		snapshot, err := courseSnapshot(s.VersionRepo, course, pinned)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot != nil {
+			snapshot.Apply(course)
+		}
+	}
+
+	response := s.mapCourseToResponse(course, userProgress)
+	response.Lessons, response.Sections = s.mapCourseTree(course, isStaff, isEnrolled)
+
+	return response, nil
+}
+
+// mapCourseTree groups the lessons of the course by section. Course staff see
+// everything, everybody else only published sections and lessons, with the
+// script of paid lessons hidden unless they are enrolled.
+func (s *CourseServiceImp) mapCourseTree(course *domain.Course, isStaff, isEnrolled bool) ([]dto.LessonResponse, []dto.SectionResponse) {
+	now := time.Now()
+	var lessons []dto.LessonResponse
+	var sections []dto.SectionResponse
+	sectionIndex := map[uint]int{}
+	for _, section := range course.Sections {
+		if !section.IsPublished && !isStaff {
+			continue
+		}
+		sectionIndex[section.ID] = len(sections)
+		sections = append(sections, *mapSectionToResponse(&section))
+	}
+
+	for _, lesson := range course.Lessons {
+		if !lesson.IsLive(now) && !isStaff {
+			continue
+		}
+
+		response := dto.LessonResponse{
+			ID:          lesson.ID,
+			Title:       lesson.Title,
+			Description: lesson.Description,
+			VideoURL:    lesson.VideoURL,
+			VideoID:     lesson.VideoID,
+			Script:      lesson.Script,
+			Duration:    lesson.Duration,
+			CourseID:    lesson.CourseID,
+			SectionID:   lesson.SectionID,
+			Sequence:    lesson.Sequence,
+			IsPublished: lesson.IsPublished,
+			IsFree:      lesson.IsFree,
+			PublishAt:   formatOptionalTime(lesson.PublishAt),
+			UnpublishAt: formatOptionalTime(lesson.UnpublishAt),
+			CreatedAt:   lesson.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:   lesson.UpdatedAt.Format(time.RFC3339),
+		}
+		if !isStaff && !isEnrolled && !lesson.IsFree {
+			response.Script = ""
+		}
+
+		if lesson.SectionID == nil {
+			lessons = append(lessons, response)
+			continue
+		}
+		// lessons of a hidden section are left out with it
+		if i, ok := sectionIndex[*lesson.SectionID]; ok {
+			sections[i].Lessons = append(sections[i].Lessons, response)
+		}
+	}
+
+	return lessons, sections
 }
 
 func (s *CourseServiceImp) GetAllCourses() ([]dto.CourseListResponse, error) {
@@ -226,14 +393,37 @@ func (s *CourseServiceImp) EnrollInCourse(courseID uint, userID uint) (*dto.APIR
 		}, err
 	}
 
-	if !course.IsPublished {
+	if !course.IsLive(time.Now()) {
 		return &dto.APIResponse{
 			Success: false,
 			Error:   "Course is not published",
 		}, errors.New("course not published")
 	}
 
-	// Enroll user
+	if config.Auth().RequireVerifiedForEnrollment {
+		user, err := s.UserRepo.GetByID(userID)
+		if err != nil {
+			return &dto.APIResponse{
+				Success: false,
+				Error:   "User not found",
+			}, err
+		}
+		if !user.IsVerified() {
+			return &dto.APIResponse{
+				Success: false,
+				Error:   "Please verify your email address before enrolling",
+			}, errutil.ErrEmailNotVerified
+		}
+	}
+
+	// Enroll user, enrolling twice returns the existing enrollment
+	alreadyEnrolled, err := s.UserCourseRepo.IsUserEnrolled(userID, courseID)
+	if err != nil {
+		return &dto.APIResponse{
+			Success: false,
+			Error:   "Failed to enroll in course",
+		}, err
+	}
 	enrollment, err := s.UserCourseRepo.EnrollUser(userID, courseID)
 	if err != nil {
 		return &dto.APIResponse{
@@ -241,6 +431,9 @@ func (s *CourseServiceImp) EnrollInCourse(courseID uint, userID uint) (*dto.APIR
 			Error:   "Failed to enroll in course",
 		}, err
 	}
+	if !alreadyEnrolled {
+		recordAudit(s.AuditService, enrollment.OrganizationID, userID, domain.AuditActionEnrollmentCreate, domain.AuditTargetEnrollment, enrollment.ID, nil, enrollment)
+	}
 
 	return &dto.APIResponse{
 		Success: true,
@@ -250,6 +443,9 @@ func (s *CourseServiceImp) EnrollInCourse(courseID uint, userID uint) (*dto.APIR
 }
 
 func (s *CourseServiceImp) UnenrollFromCourse(courseID uint, userID uint) (*dto.APIResponse, error) {
+	// looked up first for the audit log, unenrolling twice is not an error
+	enrollment, _ := s.UserCourseRepo.GetUserCourseProgress(userID, courseID)
+
 	err := s.UserCourseRepo.UnenrollUser(userID, courseID)
 	if err != nil {
 		return &dto.APIResponse{
@@ -257,6 +453,9 @@ func (s *CourseServiceImp) UnenrollFromCourse(courseID uint, userID uint) (*dto.
 			Error:   "Failed to unenroll from course",
 		}, err
 	}
+	if enrollment != nil {
+		recordAudit(s.AuditService, enrollment.OrganizationID, userID, domain.AuditActionEnrollmentDelete, domain.AuditTargetEnrollment, enrollment.ID, enrollment, nil)
+	}
 
 	return &dto.APIResponse{
 		Success: true,
@@ -279,16 +478,30 @@ func (s *CourseServiceImp) GetUserEnrolledCourses(userID uint) ([]dto.CourseList
 }
 
 func (s *CourseServiceImp) GetUserCourseProgress(courseID uint, userID uint) (*dto.UserProgressResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, err
+	}
+
 	progress, err := s.UserCourseRepo.GetUserCourseProgress(userID, courseID)
 	if err != nil {
 		return nil, err
 	}
 
+	return mapUserProgressToResponse(progress, course), nil
+}
+
+func mapUserProgressToResponse(progress *domain.UserCourse, course *domain.Course) *dto.UserProgressResponse {
 	response := &dto.UserProgressResponse{
-		Progress:     progress.Progress,
-		LastLessonID: progress.LastLessonID,
-		IsCompleted:  progress.IsCompleted,
-		EnrolledAt:   progress.EnrolledAt.Format(time.RFC3339),
+		Progress:      progress.Progress,
+		LastLessonID:  progress.LastLessonID,
+		IsCompleted:   progress.IsCompleted,
+		EnrolledAt:    progress.EnrolledAt.Format(time.RFC3339),
+		CourseVersion: progress.CourseVersion,
+		LatestVersion: course.PublishedVersion,
+	}
+	if response.CourseVersion == 0 {
+		response.CourseVersion = course.PublishedVersion
 	}
 
 	if progress.CompletedAt != nil {
@@ -296,10 +509,19 @@ func (s *CourseServiceImp) GetUserCourseProgress(courseID uint, userID uint) (*d
 		response.CompletedAt = &completedAtStr
 	}
 
-	return response, nil
+	return response
 }
 
-func (s *CourseServiceImp) GetCourseAnalytics(courseID uint) (map[string]interface{}, error) {
+func (s *CourseServiceImp) GetCourseAnalytics(courseID uint, userID uint) (map[string]interface{}, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionViewAnalytics) {
+		return nil, errors.New("unauthorized to view analytics of this course")
+	}
+
 	lessonCount, enrolledCount, avgProgress, err := s.CourseRepo.GetCourseStats(courseID)
 	if err != nil {
 		return nil, err
@@ -348,7 +570,11 @@ func (s *CourseServiceImp) mapCourseToResponse(course *domain.Course, userProgre
 		Tags:             tags,
 		Duration:         course.Duration,
 		Price:            course.Price,
+		Status:           course.Status,
 		IsPublished:      course.IsPublished,
+		PublishedVersion: course.PublishedVersion,
+		PublishAt:        formatOptionalTime(course.PublishAt),
+		UnpublishAt:      formatOptionalTime(course.UnpublishAt),
 		CreatedBy:        course.CreatedBy,
 		CreatedAt:        course.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:        course.UpdatedAt.Format(time.RFC3339),
@@ -422,3 +648,14 @@ func (s *CourseServiceImp) mapCoursesToListResponse(courses []domain.Course, use
 
 	return responses
 }
+
+// mapTrashItem maps a course or lesson in the trash, courseID is 0 for courses
+func mapTrashItem(id uint, title string, courseID uint, deletedAt time.Time) dto.TrashItemResponse {
+	return dto.TrashItemResponse{
+		ID:        id,
+		Title:     title,
+		CourseID:  courseID,
+		DeletedAt: deletedAt.Format(time.RFC3339),
+		PurgeAt:   deletedAt.AddDate(0, 0, config.Trash().RetentionDays).Format(time.RFC3339),
+	}
+}
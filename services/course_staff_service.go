@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+)
+
+type CourseStaffService interface {
+	GetCourseStaff(courseID uint, userID uint) ([]dto.CourseStaffResponse, error)
+	AddCourseStaff(courseID uint, req dto.AddCourseStaffRequest, userID uint) (*dto.CourseStaffResponse, error)
+	RemoveCourseStaff(courseID uint, staffUserID uint, userID uint) error
+	TransferOwnership(courseID uint, req dto.TransferOwnershipRequest, userID uint) error
+
+	// ForOrganization returns a copy that works on the data of the organization
+	ForOrganization(organizationID uint) CourseStaffService
+	// WithRequest returns a copy that records the request in the audit log
+	WithRequest(request types.RequestInfo) CourseStaffService
+}
+
+type CourseStaffServiceImp struct {
+	CourseRepo   repository.CourseRepository
+	StaffRepo    repository.CourseStaffRepository
+	UserRepo     repository.UserRepository
+	AuditService AuditService
+}
+
+func NewCourseStaffService(courseRepo repository.CourseRepository, staffRepo repository.CourseStaffRepository, userRepo repository.UserRepository, auditService AuditService) CourseStaffService {
+	return &CourseStaffServiceImp{
+		CourseRepo:   courseRepo,
+		StaffRepo:    staffRepo,
+		UserRepo:     userRepo,
+		AuditService: auditService,
+	}
+}
+
+func (s *CourseStaffServiceImp) ForOrganization(organizationID uint) CourseStaffService {
+	return &CourseStaffServiceImp{
+		CourseRepo:   s.CourseRepo.ForOrganization(organizationID),
+		StaffRepo:    s.StaffRepo,
+		UserRepo:     s.UserRepo.ForOrganization(organizationID),
+		AuditService: s.AuditService,
+	}
+}
+
+func (s *CourseStaffServiceImp) WithRequest(request types.RequestInfo) CourseStaffService {
+	return &CourseStaffServiceImp{
+		CourseRepo:   s.CourseRepo,
+		StaffRepo:    s.StaffRepo,
+		UserRepo:     s.UserRepo,
+		AuditService: s.AuditService.WithRequest(request),
+	}
+}
+
+func (s *CourseStaffServiceImp) GetCourseStaff(courseID uint, userID uint) ([]dto.CourseStaffResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionViewContent) {
+		return nil, errors.New("unauthorized to view the staff of this course")
+	}
+
+	staff, err := s.StaffRepo.GetCourseStaff(courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []dto.CourseStaffResponse
+	hasOwner := false
+	for _, member := range staff {
+		if member.Role == domain.CourseStaffOwner {
+			hasOwner = true
+		}
+		responses = append(responses, s.mapStaffToResponse(&member, member.User.Email))
+	}
+
+	// Courses created before course staff existed have no owner row
+	if !hasOwner {
+		if owner, err := s.UserRepo.GetByID(course.CreatedBy); err == nil {
+			responses = append([]dto.CourseStaffResponse{{
+				UserID:  owner.ID,
+				Email:   owner.Email,
+				Role:    domain.CourseStaffOwner,
+				AddedBy: owner.ID,
+				AddedAt: course.CreatedAt.Format(time.RFC3339),
+			}}, responses...)
+		}
+	}
+
+	return responses, nil
+}
+
+func (s *CourseStaffServiceImp) AddCourseStaff(courseID uint, req dto.AddCourseStaffRequest, userID uint) (*dto.CourseStaffResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageStaff) {
+		return nil, errors.New("unauthorized to manage the staff of this course")
+	}
+
+	user, err := s.UserRepo.GetByEmail(req.Email)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	// Authoring routes are gated by the global role as well, so staff must be instructors
+	if role := domain.NormalizeRole(user.Role); role != domain.RoleInstructor && role != domain.RoleAdmin {
+		return nil, errors.New("course staff must have the instructor role")
+	}
+
+	if courseStaffRole(s.StaffRepo, course, user.ID) != "" {
+		return nil, errors.New("user is already a staff member of this course")
+	}
+
+	staff := &domain.CourseStaff{
+		CourseID:  courseID,
+		UserID:    user.ID,
+		Role:      req.Role,
+		AddedBy:   userID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := s.StaffRepo.AddStaff(staff); err != nil {
+		return nil, err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionCourseStaffAdd, domain.AuditTargetCourse, course.ID,
+		nil, map[string]interface{}{"staff_user_id": user.ID, "staff_role": req.Role})
+
+	response := s.mapStaffToResponse(staff, user.Email)
+	return &response, nil
+}
+
+func (s *CourseStaffServiceImp) RemoveCourseStaff(courseID uint, staffUserID uint, userID uint) error {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return err
+	}
+
+	// Staff members may always leave a course on their own
+	if staffUserID != userID && !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageStaff) {
+		return errors.New("unauthorized to manage the staff of this course")
+	}
+
+	role := courseStaffRole(s.StaffRepo, course, staffUserID)
+	if role == "" {
+		return errors.New("user is not a staff member of this course")
+	}
+	if role == domain.CourseStaffOwner {
+		return errors.New("the owner cannot be removed, transfer ownership first")
+	}
+
+	if err := s.StaffRepo.RemoveStaff(courseID, staffUserID); err != nil {
+		return err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionCourseStaffRemove, domain.AuditTargetCourse, course.ID,
+		map[string]interface{}{"staff_user_id": staffUserID, "staff_role": role}, nil)
+
+	return nil
+}
+
+func (s *CourseStaffServiceImp) TransferOwnership(courseID uint, req dto.TransferOwnershipRequest, userID uint) error {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return err
+	}
+
+	if courseStaffRole(s.StaffRepo, course, userID) != domain.CourseStaffOwner {
+		return errors.New("only the owner can transfer ownership of this course")
+	}
+
+	user, err := s.UserRepo.GetByEmail(req.Email)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	if user.ID == userID {
+		return errors.New("user already owns this course")
+	}
+
+	if role := domain.NormalizeRole(user.Role); role != domain.RoleInstructor && role != domain.RoleAdmin {
+		return errors.New("course staff must have the instructor role")
+	}
+
+	if err := s.StaffRepo.TransferOwnership(courseID, userID, user.ID); err != nil {
+		return err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionCourseTransferOwnership, domain.AuditTargetCourse, course.ID,
+		map[string]interface{}{"owner_id": userID}, map[string]interface{}{"owner_id": user.ID})
+
+	return nil
+}
+
+// Helper methods
+func (s *CourseStaffServiceImp) mapStaffToResponse(staff *domain.CourseStaff, email string) dto.CourseStaffResponse {
+	return dto.CourseStaffResponse{
+		UserID:  staff.UserID,
+		Email:   email,
+		Role:    staff.Role,
+		AddedBy: staff.AddedBy,
+		AddedAt: staff.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,434 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+// CourseVersionService publishes the draft of a course (its live rows) as
+// immutable versions. Learners read the published version, or the one they
+// are pinned to, so instructors can edit without learners seeing it.
+type CourseVersionService interface {
+	PublishDraft(courseID uint, req dto.PublishVersionRequest, userID uint) (*dto.CourseVersionResponse, error)
+	GetVersions(courseID uint, userID uint) ([]dto.CourseVersionResponse, error)
+	GetVersion(courseID uint, number int, userID uint) (*dto.CourseVersionDetailResponse, error)
+	// DiffVersion compares the version with the one before it, or with against:
+	// another version number or "draft"
+	DiffVersion(courseID uint, number int, against string, userID uint) (*dto.CourseVersionDiffResponse, error)
+	// RollbackToVersion publishes a copy of an earlier version
+	RollbackToVersion(courseID uint, number int, req dto.PublishVersionRequest, userID uint) (*dto.CourseVersionResponse, error)
+	// UpgradeToLatestVersion moves an enrolled learner to the published version
+	UpgradeToLatestVersion(courseID uint, userID uint) (*dto.UserProgressResponse, error)
+
+	// ForOrganization returns a copy that works on the data of the organization
+	ForOrganization(organizationID uint) CourseVersionService
+	// WithRequest returns a copy that records the request in the audit log
+	WithRequest(request types.RequestInfo) CourseVersionService
+}
+
+type CourseVersionServiceImp struct {
+	VersionRepo    repository.CourseVersionRepository
+	CourseRepo     repository.CourseRepository
+	UserCourseRepo repository.UserCourseRepository
+	StaffRepo      repository.CourseStaffRepository
+	AuditService   AuditService
+}
+
+func NewCourseVersionService(versionRepo repository.CourseVersionRepository, courseRepo repository.CourseRepository, userCourseRepo repository.UserCourseRepository, staffRepo repository.CourseStaffRepository, auditService AuditService) CourseVersionService {
+	return &CourseVersionServiceImp{
+		VersionRepo:    versionRepo,
+		CourseRepo:     courseRepo,
+		UserCourseRepo: userCourseRepo,
+		StaffRepo:      staffRepo,
+		AuditService:   auditService,
+	}
+}
+
+func (s *CourseVersionServiceImp) ForOrganization(organizationID uint) CourseVersionService {
+	return &CourseVersionServiceImp{
+		VersionRepo:    s.VersionRepo.ForOrganization(organizationID),
+		CourseRepo:     s.CourseRepo.ForOrganization(organizationID),
+		UserCourseRepo: s.UserCourseRepo.ForOrganization(organizationID),
+		StaffRepo:      s.StaffRepo,
+		AuditService:   s.AuditService,
+	}
+}
+
+func (s *CourseVersionServiceImp) WithRequest(request types.RequestInfo) CourseVersionService {
+	return &CourseVersionServiceImp{
+		VersionRepo:    s.VersionRepo,
+		CourseRepo:     s.CourseRepo,
+		UserCourseRepo: s.UserCourseRepo,
+		StaffRepo:      s.StaffRepo,
+		AuditService:   s.AuditService.WithRequest(request),
+	}
+}
+
+func (s *CourseVersionServiceImp) PublishDraft(courseID uint, req dto.PublishVersionRequest, userID uint) (*dto.CourseVersionResponse, error) {
+	course, err := s.CourseRepo.GetByIDWithLessons(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if err := s.checkReviewer(course, userID); err != nil {
+		return nil, err
+	}
+
+	version, err := publishVersion(s.VersionRepo, course, req.Note, userID)
+	if err != nil {
+		return nil, err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionCoursePublishVersion, domain.AuditTargetCourse, course.ID,
+		map[string]interface{}{"published_version": course.PublishedVersion},
+		map[string]interface{}{"published_version": version.Version})
+
+	return mapCourseVersionToResponse(version, version.Version), nil
+}
+
+func (s *CourseVersionServiceImp) GetVersions(courseID uint, userID uint) ([]dto.CourseVersionResponse, error) {
+	course, err := s.staffCourse(courseID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.VersionRepo.GetVersions(courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.CourseVersionResponse, 0, len(versions))
+	for i := range versions {
+		responses = append(responses, *mapCourseVersionToResponse(&versions[i], course.PublishedVersion))
+	}
+
+	return responses, nil
+}
+
+func (s *CourseVersionServiceImp) GetVersion(courseID uint, number int, userID uint) (*dto.CourseVersionDetailResponse, error) {
+	course, err := s.staffCourse(courseID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.VersionRepo.GetVersion(courseID, number)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	return &dto.CourseVersionDetailResponse{
+		CourseVersionResponse: *mapCourseVersionToResponse(version, course.PublishedVersion),
+		Content:               json.RawMessage(version.Snapshot),
+	}, nil
+}
+
+func (s *CourseVersionServiceImp) DiffVersion(courseID uint, number int, against string, userID uint) (*dto.CourseVersionDiffResponse, error) {
+	course, err := s.staffCourse(courseID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.VersionRepo.GetVersion(courseID, number)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+	snapshot, err := decodeSnapshot(version)
+	if err != nil {
+		return nil, err
+	}
+
+	switch against {
+	case "":
+		// what the version changed compared to the one before it
+		previous := domain.CourseSnapshot{}
+		if number > 1 {
+			version, err := s.VersionRepo.GetVersion(courseID, number-1)
+			if err != nil {
+				return nil, errutil.ErrRecordNotFound
+			}
+			if previous, err = decodeSnapshot(version); err != nil {
+				return nil, err
+			}
+		}
+		return diffSnapshots(number-1, previous, number, snapshot)
+	case "draft":
+		draft, err := s.CourseRepo.GetByIDWithLessons(course.ID)
+		if err != nil {
+			return nil, err
+		}
+		return diffSnapshots(number, snapshot, 0, domain.NewCourseSnapshot(draft))
+	default:
+		other, err := strconv.Atoi(against)
+		if err != nil {
+			return nil, fmt.Errorf("%w: against must be a version number or draft", errutil.ErrInvalidInput)
+		}
+		version, err := s.VersionRepo.GetVersion(courseID, other)
+		if err != nil {
+			return nil, errutil.ErrRecordNotFound
+		}
+		otherSnapshot, err := decodeSnapshot(version)
+		if err != nil {
+			return nil, err
+		}
+		return diffSnapshots(number, snapshot, other, otherSnapshot)
+	}
+}
+
+func (s *CourseVersionServiceImp) RollbackToVersion(courseID uint, number int, req dto.PublishVersionRequest, userID uint) (*dto.CourseVersionResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if err := s.checkReviewer(course, userID); err != nil {
+		return nil, err
+	}
+
+	if number == course.PublishedVersion {
+		return nil, fmt.Errorf("%w: version %d is already published", errutil.ErrInvalidInput, number)
+	}
+
+	restored, err := s.VersionRepo.GetVersion(courseID, number)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	note := req.Note
+	if note == "" {
+		note = fmt.Sprintf("Rollback to version %d", number)
+	}
+	version := &domain.CourseVersion{
+		CourseID:     course.ID,
+		Snapshot:     restored.Snapshot,
+		Note:         note,
+		RestoredFrom: restored.Version,
+		PublishedBy:  userID,
+	}
+	if err := s.VersionRepo.Rollback(version); err != nil {
+		return nil, err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionCourseRollbackVersion, domain.AuditTargetCourse, course.ID,
+		map[string]interface{}{"published_version": course.PublishedVersion},
+		map[string]interface{}{"published_version": version.Version, "restored_from": restored.Version})
+
+	return mapCourseVersionToResponse(version, version.Version), nil
+}
+
+func (s *CourseVersionServiceImp) UpgradeToLatestVersion(courseID uint, userID uint) (*dto.UserProgressResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	enrollment, err := s.UserCourseRepo.GetUserCourseProgress(userID, courseID)
+	if err != nil {
+		return nil, errutil.ErrNotEnrolled
+	}
+
+	if enrollment.CourseVersion != course.PublishedVersion {
+		if err := s.UserCourseRepo.PinVersion(userID, courseID, course.PublishedVersion); err != nil {
+			return nil, err
+		}
+		recordAudit(s.AuditService, enrollment.OrganizationID, userID, domain.AuditActionEnrollmentUpgrade, domain.AuditTargetEnrollment, enrollment.ID,
+			map[string]interface{}{"course_version": enrollment.CourseVersion},
+			map[string]interface{}{"course_version": course.PublishedVersion})
+		enrollment.CourseVersion = course.PublishedVersion
+	}
+
+	return mapUserProgressToResponse(enrollment, course), nil
+}
+
+// checkReviewer lets a reviewer of the course or an admin, not its authors,
+// publish versions. A version reaches learners right away, so publishing one
+// is a review step like approving the course.
+func (s *CourseVersionServiceImp) checkReviewer(course *domain.Course, userID uint) error {
+	if isCourseStaffFor(s.StaffRepo, course, userID, domain.CourseActionUpdate) {
+		return fmt.Errorf("%w: a reviewer of the course or an admin publishes its versions", errutil.ErrOwnCourseReview)
+	}
+	if !canReviewCourse(s.StaffRepo, course, userID) {
+		return errors.New("unauthorized to publish versions of this course")
+	}
+	return nil
+}
+
+// staffCourse loads the course for the version history, which only the staff can read
+func (s *CourseVersionServiceImp) staffCourse(courseID uint, userID uint) (*domain.Course, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionViewContent) {
+		return nil, errors.New("unauthorized to view the versions of this course")
+	}
+
+	return course, nil
+}
+
+// publishVersion snapshots the course, loaded with its sections and lessons,
+// as its next version
+func publishVersion(versionRepo repository.CourseVersionRepository, course *domain.Course, note string, userID uint) (*domain.CourseVersion, error) {
+	snapshot, err := json.Marshal(domain.NewCourseSnapshot(course))
+	if err != nil {
+		return nil, err
+	}
+
+	version := &domain.CourseVersion{
+		CourseID:    course.ID,
+		Snapshot:    string(snapshot),
+		Note:        note,
+		PublishedBy: userID,
+	}
+	if err := versionRepo.Publish(version); err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// freezeLiveVersion publishes the current content of a course that is live
+// without any version yet, before its first edit. Learners then keep seeing
+// that content until the edits are published.
+func freezeLiveVersion(versionRepo repository.CourseVersionRepository, courseRepo repository.CourseRepository, course *domain.Course, userID uint) error {
+	if !course.IsPublished || course.PublishedVersion != 0 {
+		return nil
+	}
+
+	live, err := courseRepo.GetByIDWithLessons(course.ID)
+	if err != nil {
+		return err
+	}
+
+	version, err := publishVersion(versionRepo, live, "Published content before the first draft edit", userID)
+	if err != nil {
+		return err
+	}
+	course.PublishedVersion = version.Version
+
+	return nil
+}
+
+// courseSnapshot returns the version of the course a learner pinned to the
+// given version (0 for none) sees, nil while the course has no version and its
+// live rows are served
+func courseSnapshot(versionRepo repository.CourseVersionRepository, course *domain.Course, pinned int) (*domain.CourseSnapshot, error) {
+	number := pinned
+	if number == 0 {
+		number = course.PublishedVersion
+	}
+	if number == 0 {
+		return nil, nil
+	}
+
+	version, err := versionRepo.GetVersion(course.ID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := decodeSnapshot(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+func decodeSnapshot(version *domain.CourseVersion) (domain.CourseSnapshot, error) {
+	var snapshot domain.CourseSnapshot
+	err := json.Unmarshal([]byte(version.Snapshot), &snapshot)
+	return snapshot, err
+}
+
+// diffSnapshots lists the changes from one snapshot to another, sections and
+// lessons are matched by ID
+func diffSnapshots(fromNumber int, from domain.CourseSnapshot, toNumber int, to domain.CourseSnapshot) (*dto.CourseVersionDiffResponse, error) {
+	courseChanges, err := fieldChanges(from.Course, to.Course)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &dto.CourseVersionDiffResponse{
+		From:     fromNumber,
+		To:       toNumber,
+		Course:   courseChanges,
+		Sections: []dto.ContentChange{},
+		Lessons:  []dto.ContentChange{},
+	}
+
+	fromSections := map[uint]domain.SnapshotSection{}
+	for _, section := range from.Sections {
+		fromSections[section.ID] = section
+	}
+	for _, section := range to.Sections {
+		change, err := contentChange(section.ID, section.Title, fromSections[section.ID], section, fromSections[section.ID].ID != 0)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			diff.Sections = append(diff.Sections, *change)
+		}
+		delete(fromSections, section.ID)
+	}
+	for _, section := range from.Sections {
+		if _, removed := fromSections[section.ID]; removed {
+			diff.Sections = append(diff.Sections, dto.ContentChange{ID: section.ID, Title: section.Title, Change: "removed"})
+		}
+	}
+
+	fromLessons := map[uint]domain.SnapshotLesson{}
+	for _, lesson := range from.Lessons {
+		fromLessons[lesson.ID] = lesson
+	}
+	for _, lesson := range to.Lessons {
+		change, err := contentChange(lesson.ID, lesson.Title, fromLessons[lesson.ID], lesson, fromLessons[lesson.ID].ID != 0)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			diff.Lessons = append(diff.Lessons, *change)
+		}
+		delete(fromLessons, lesson.ID)
+	}
+	for _, lesson := range from.Lessons {
+		if _, removed := fromLessons[lesson.ID]; removed {
+			diff.Lessons = append(diff.Lessons, dto.ContentChange{ID: lesson.ID, Title: lesson.Title, Change: "removed"})
+		}
+	}
+
+	return diff, nil
+}
+
+// contentChange describes a section or lesson present in the newer snapshot,
+// nil when it did not change
+func contentChange(id uint, title string, before, after interface{}, existed bool) (*dto.ContentChange, error) {
+	if !existed {
+		return &dto.ContentChange{ID: id, Title: title, Change: "added"}, nil
+	}
+
+	fields, err := fieldChanges(before, after)
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+
+	return &dto.ContentChange{ID: id, Title: title, Change: "changed", Fields: fields}, nil
+}
+
+func mapCourseVersionToResponse(version *domain.CourseVersion, publishedVersion int) *dto.CourseVersionResponse {
+	return &dto.CourseVersionResponse{
+		CourseID:     version.CourseID,
+		Version:      version.Version,
+		Note:         version.Note,
+		RestoredFrom: version.RestoredFrom,
+		IsPublished:  version.Version == publishedVersion,
+		PublishedBy:  version.PublishedBy,
+		PublishedAt:  version.CreatedAt.Format(time.RFC3339),
+	}
+}
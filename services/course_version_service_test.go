@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/testdb"
+	"gorm.io/gorm"
+)
+
+func TestInstructorsCannotPublishUnreviewedVersions(t *testing.T) {
+	db := testdb.Open(t)
+	organization := domain.Organization{Name: "Acme", Slug: "acme"}
+	mustCreateRows(t, db, &organization)
+
+	owner := domain.User{OrganizationID: organization.ID, Email: "owner@acme.test", Role: domain.RoleInstructor}
+	coInstructor := domain.User{OrganizationID: organization.ID, Email: "co@acme.test", Role: domain.RoleInstructor}
+	assistant := domain.User{OrganizationID: organization.ID, Email: "ta@acme.test", Role: domain.RoleInstructor}
+	outsider := domain.User{OrganizationID: organization.ID, Email: "outsider@acme.test", Role: domain.RoleInstructor}
+	reviewer := domain.User{OrganizationID: organization.ID, Email: "reviewer@acme.test", Role: domain.RoleInstructor}
+	admin := domain.User{OrganizationID: organization.ID, Email: "admin@acme.test", Role: domain.RoleAdmin}
+	mustCreateRows(t, db, &owner, &coInstructor, &assistant, &outsider, &reviewer, &admin)
+
+	course := domain.Course{OrganizationID: organization.ID, Title: "Reviewed", CreatedBy: owner.ID,
+		Status: domain.CourseStatusPublished, IsPublished: true}
+	mustCreateRows(t, db, &course)
+	mustCreateRows(t, db,
+		&domain.CourseStaff{CourseID: course.ID, UserID: coInstructor.ID, Role: domain.CourseStaffCoInstructor},
+		&domain.CourseStaff{CourseID: course.ID, UserID: assistant.ID, Role: domain.CourseStaffTeachingAssistant},
+		&domain.CourseStaff{CourseID: course.ID, UserID: reviewer.ID, Role: domain.CourseStaffReviewer})
+
+	versionRepo := repository.NewCourseVersionRepository(db)
+	live, err := repository.NewCourseRepository(db).GetByIDWithLessons(course.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := publishVersion(versionRepo, live, "Published after review", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// the authors edit the draft after the course went live
+	if err := db.Model(&domain.Course{}).Where("id = ?", course.ID).Update("title", "Unreviewed").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewCourseVersionService(versionRepo, repository.NewCourseRepository(db), repository.NewUserCourseRepository(db),
+		repository.NewCourseStaffRepository(db), NewAuditService(repository.NewAuditLogRepository(db))).
+		ForOrganization(organization.ID)
+
+	refused := []struct {
+		name    string
+		userID  uint
+		wantErr error // nil for any error
+	}{
+		{"owner", owner.ID, errutil.ErrOwnCourseReview},
+		{"co-instructor", coInstructor.ID, errutil.ErrOwnCourseReview},
+		{"teaching assistant", assistant.ID, nil},
+		{"instructor not on the staff", outsider.ID, nil},
+	}
+	for _, tt := range refused {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.PublishDraft(course.ID, dto.PublishVersionRequest{}, tt.userID)
+			if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("PublishDraft error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := service.RollbackToVersion(course.ID, 1, dto.PublishVersionRequest{}, tt.userID); err == nil {
+				t.Error("RollbackToVersion succeeded")
+			}
+			assertPublishedVersion(t, db, course.ID, 1, 1)
+		})
+	}
+
+	if _, err := service.PublishDraft(course.ID, dto.PublishVersionRequest{}, reviewer.ID); err != nil {
+		t.Fatalf("reviewer PublishDraft: %v", err)
+	}
+	assertPublishedVersion(t, db, course.ID, 2, 2)
+
+	if _, err := service.RollbackToVersion(course.ID, 1, dto.PublishVersionRequest{}, admin.ID); err != nil {
+		t.Fatalf("admin RollbackToVersion: %v", err)
+	}
+	assertPublishedVersion(t, db, course.ID, 3, 3)
+}
+
+func assertPublishedVersion(t *testing.T, db *gorm.DB, courseID uint, published int, versions int64) {
+	t.Helper()
+
+	var course domain.Course
+	if err := db.First(&course, courseID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := db.Model(&domain.CourseVersion{}).Where("course_id = ?", courseID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if course.PublishedVersion != published || count != versions {
+		t.Errorf("published version %d of %d, want %d of %d", course.PublishedVersion, count, published, versions)
+	}
+}
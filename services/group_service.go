@@ -0,0 +1,156 @@
+package services
+
+import (
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+// GroupService attaches courses to groups. Every member of a group is enrolled
+// in its courses, both when a course is attached and when a member joins.
+type GroupService interface {
+	GetGroups() ([]dto.GroupResponse, error)
+	AddGroupCourse(groupID uint, courseID uint, actorID uint) (*dto.GroupEnrollmentResponse, error)
+	RemoveGroupCourse(groupID uint, courseID uint, actorID uint) error
+	EnrollMembers(group *domain.Group, members []domain.User) int
+
+	// ForOrganization returns a copy that works on the data of the organization
+	ForOrganization(organizationID uint) GroupService
+	// WithRequest returns a copy that records the request in the audit log
+	WithRequest(request types.RequestInfo) GroupService
+}
+
+type GroupServiceImp struct {
+	GroupRepo      repository.GroupRepository
+	CourseRepo     repository.CourseRepository
+	UserCourseRepo repository.UserCourseRepository
+	AuditService   AuditService
+}
+
+func NewGroupService(groupRepo repository.GroupRepository, courseRepo repository.CourseRepository, userCourseRepo repository.UserCourseRepository, auditService AuditService) GroupService {
+	return &GroupServiceImp{
+		GroupRepo:      groupRepo,
+		CourseRepo:     courseRepo,
+		UserCourseRepo: userCourseRepo,
+		AuditService:   auditService,
+	}
+}
+
+func (s *GroupServiceImp) ForOrganization(organizationID uint) GroupService {
+	return &GroupServiceImp{
+		GroupRepo:      s.GroupRepo.ForOrganization(organizationID),
+		CourseRepo:     s.CourseRepo.ForOrganization(organizationID),
+		UserCourseRepo: s.UserCourseRepo.ForOrganization(organizationID),
+		AuditService:   s.AuditService,
+	}
+}
+
+func (s *GroupServiceImp) WithRequest(request types.RequestInfo) GroupService {
+	return &GroupServiceImp{
+		GroupRepo:      s.GroupRepo,
+		CourseRepo:     s.CourseRepo,
+		UserCourseRepo: s.UserCourseRepo,
+		AuditService:   s.AuditService.WithRequest(request),
+	}
+}
+
+func (s *GroupServiceImp) GetGroups() ([]dto.GroupResponse, error) {
+	groups, err := s.GroupRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.GroupResponse, 0, len(groups))
+	for _, group := range groups {
+		courses := make([]dto.GroupCourseResponse, 0, len(group.Courses))
+		for _, course := range group.Courses {
+			courses = append(courses, dto.GroupCourseResponse{ID: course.ID, Title: course.Title})
+		}
+
+		responses = append(responses, dto.GroupResponse{
+			ID:          group.ID,
+			DisplayName: group.DisplayName,
+			ExternalID:  group.ExternalID,
+			MemberCount: len(group.Members),
+			Courses:     courses,
+		})
+	}
+
+	return responses, nil
+}
+
+// AddGroupCourse attaches the course to the group and enrolls every member.
+// Attaching a course again enrolls members that unenrolled themselves.
+func (s *GroupServiceImp) AddGroupCourse(groupID uint, courseID uint, actorID uint) (*dto.GroupEnrollmentResponse, error) {
+	group, err := s.GroupRepo.GetByID(groupID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if err := s.GroupRepo.AddCourse(group, course); err != nil {
+		return nil, err
+	}
+
+	enrolled := 0
+	for _, member := range group.Members {
+		if _, err := s.UserCourseRepo.EnrollUser(member.ID, course.ID); err != nil {
+			logger.Error(err)
+			continue
+		}
+		enrolled++
+	}
+	recordAudit(s.AuditService, group.OrganizationID, actorID, domain.AuditActionGroupCourseAdd, domain.AuditTargetGroup, group.ID,
+		nil, map[string]interface{}{"course_id": course.ID, "enrolled": enrolled})
+
+	return &dto.GroupEnrollmentResponse{
+		GroupID:  group.ID,
+		CourseID: course.ID,
+		Enrolled: enrolled,
+	}, nil
+}
+
+// RemoveGroupCourse detaches the course, existing enrollments and progress are kept
+func (s *GroupServiceImp) RemoveGroupCourse(groupID uint, courseID uint, actorID uint) error {
+	group, err := s.GroupRepo.GetByID(groupID)
+	if err != nil {
+		return errutil.ErrRecordNotFound
+	}
+
+	for i := range group.Courses {
+		if group.Courses[i].ID == courseID {
+			if err := s.GroupRepo.RemoveCourse(group, &group.Courses[i]); err != nil {
+				return err
+			}
+			recordAudit(s.AuditService, group.OrganizationID, actorID, domain.AuditActionGroupCourseRemove, domain.AuditTargetGroup, group.ID,
+				map[string]interface{}{"course_id": courseID}, nil)
+			return nil
+		}
+	}
+
+	return errutil.ErrRecordNotFound
+}
+
+// EnrollMembers enrolls new members in the courses of the group and returns
+// the number of enrollments. Failures are logged and do not stop the others.
+func (s *GroupServiceImp) EnrollMembers(group *domain.Group, members []domain.User) int {
+	enrolled := 0
+	for _, course := range group.Courses {
+		for _, member := range members {
+			if _, err := s.UserCourseRepo.EnrollUser(member.ID, course.ID); err != nil {
+				logger.Error(err)
+				continue
+			}
+			enrolled++
+		}
+	}
+
+	return enrolled
+}
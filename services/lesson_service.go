@@ -2,22 +2,28 @@ package services
 
 import (
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/rijwanansari/vivaLearning/domain"
 	"github.com/rijwanansari/vivaLearning/dto"
 	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
 )
 
 type LessonService interface {
 	// Admin operations
 	CreateLesson(courseID uint, req dto.CreateLessonRequest, userID uint) (*dto.LessonResponse, error)
 	UpdateLesson(id uint, req dto.UpdateLessonRequest, userID uint) (*dto.LessonResponse, error)
+	// DeleteLesson moves the lesson to the trash
 	DeleteLesson(id uint, userID uint) error
-	ReorderLessons(courseID uint, lessonSequences []struct {
-		LessonID uint `json:"lesson_id"`
-		Sequence int  `json:"sequence"`
-	}, userID uint) error
+	// GetLessonTrash lists the lessons of the course in the trash
+	GetLessonTrash(courseID uint, userID uint) ([]dto.TrashItemResponse, error)
+	RestoreLesson(id uint, userID uint) (*dto.LessonResponse, error)
+	ReorderLessons(courseID uint, lessonSequences []dto.ReorderLessonRequest, userID uint) error
+	// ScheduleLesson sets when the lesson is published and unpublished
+	ScheduleLesson(id uint, req dto.PublishScheduleRequest, userID uint) (*dto.PublishScheduleResponse, error)
 
 	// Public operations
 	GetLessonByID(id uint, userID *uint) (*dto.LessonResponse, error)
@@ -28,37 +34,83 @@ type LessonService interface {
 	UpdateLessonProgress(userID uint, req dto.UpdateProgressRequest) (*dto.APIResponse, error)
 	GetUserLessonProgress(userID, courseID uint) ([]dto.LessonResponse, error)
 	MarkLessonCompleted(userID, lessonID uint, watchTime int) (*dto.APIResponse, error)
+
+	// ForOrganization returns a copy that works on the data of the organization
+	ForOrganization(organizationID uint) LessonService
+	// WithRequest returns a copy that records the request in the audit log
+	WithRequest(request types.RequestInfo) LessonService
 }
 
 type LessonServiceImp struct {
 	LessonRepo     repository.LessonRepository
 	CourseRepo     repository.CourseRepository
 	UserCourseRepo repository.UserCourseRepository
+	StaffRepo      repository.CourseStaffRepository
+	SectionRepo    repository.SectionRepository
+	VersionRepo    repository.CourseVersionRepository
+	AuditService   AuditService
 }
 
-func NewLessonService(lessonRepo repository.LessonRepository, courseRepo repository.CourseRepository, userCourseRepo repository.UserCourseRepository) LessonService {
+func NewLessonService(lessonRepo repository.LessonRepository, courseRepo repository.CourseRepository, userCourseRepo repository.UserCourseRepository, staffRepo repository.CourseStaffRepository, sectionRepo repository.SectionRepository, versionRepo repository.CourseVersionRepository, auditService AuditService) LessonService {
 	return &LessonServiceImp{
 		LessonRepo:     lessonRepo,
 		CourseRepo:     courseRepo,
 		UserCourseRepo: userCourseRepo,
+		StaffRepo:      staffRepo,
+		SectionRepo:    sectionRepo,
+		VersionRepo:    versionRepo,
+		AuditService:   auditService,
+	}
+}
+
+func (s *LessonServiceImp) ForOrganization(organizationID uint) LessonService {
+	return &LessonServiceImp{
+		LessonRepo:     s.LessonRepo.ForOrganization(organizationID),
+		CourseRepo:     s.CourseRepo.ForOrganization(organizationID),
+		UserCourseRepo: s.UserCourseRepo.ForOrganization(organizationID),
+		StaffRepo:      s.StaffRepo,
+		SectionRepo:    s.SectionRepo.ForOrganization(organizationID),
+		VersionRepo:    s.VersionRepo.ForOrganization(organizationID),
+		AuditService:   s.AuditService,
+	}
+}
+
+func (s *LessonServiceImp) WithRequest(request types.RequestInfo) LessonService {
+	return &LessonServiceImp{
+		LessonRepo:     s.LessonRepo,
+		CourseRepo:     s.CourseRepo,
+		UserCourseRepo: s.UserCourseRepo,
+		StaffRepo:      s.StaffRepo,
+		SectionRepo:    s.SectionRepo,
+		VersionRepo:    s.VersionRepo,
+		AuditService:   s.AuditService.WithRequest(request),
 	}
 }
 
 func (s *LessonServiceImp) CreateLesson(courseID uint, req dto.CreateLessonRequest, userID uint) (*dto.LessonResponse, error) {
-	// Check if course exists and user is the creator
+	// Check if course exists and user can manage its lessons
 	course, err := s.CourseRepo.GetByID(courseID)
 	if err != nil {
 		return nil, err
 	}
 
-	if course.CreatedBy != userID {
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageLessons) {
 		return nil, errors.New("unauthorized to create lesson for this course")
 	}
 
+	if err := freezeLiveVersion(s.VersionRepo, s.CourseRepo, course, userID); err != nil {
+		return nil, err
+	}
+
+	sectionID, err := s.sectionInCourse(courseID, req.SectionID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get next sequence number if not provided
 	sequence := req.Sequence
 	if sequence <= 0 {
-		sequence, err = s.LessonRepo.GetNextSequence(courseID)
+		sequence, err = s.LessonRepo.GetNextSequence(courseID, sectionID)
 		if err != nil {
 			return nil, err
 		}
@@ -72,6 +124,7 @@ func (s *LessonServiceImp) CreateLesson(courseID uint, req dto.CreateLessonReque
 		Script:      req.Script,
 		Duration:    req.Duration,
 		CourseID:    courseID,
+		SectionID:   sectionID,
 		Sequence:    sequence,
 		IsPublished: req.IsPublished,
 		IsFree:      req.IsFree,
@@ -83,6 +136,7 @@ func (s *LessonServiceImp) CreateLesson(courseID uint, req dto.CreateLessonReque
 	if err != nil {
 		return nil, err
 	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionLessonCreate, domain.AuditTargetLesson, lesson.ID, nil, lesson)
 
 	return s.mapLessonToResponse(lesson, false), nil
 }
@@ -93,16 +147,22 @@ func (s *LessonServiceImp) UpdateLesson(id uint, req dto.UpdateLessonRequest, us
 		return nil, err
 	}
 
-	// Check if user is the course creator
+	// Check if user can manage the lessons of the course
 	course, err := s.CourseRepo.GetByID(lesson.CourseID)
 	if err != nil {
 		return nil, err
 	}
 
-	if course.CreatedBy != userID {
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageLessons) {
 		return nil, errors.New("unauthorized to update this lesson")
 	}
 
+	if err := freezeLiveVersion(s.VersionRepo, s.CourseRepo, course, userID); err != nil {
+		return nil, err
+	}
+
+	before := *lesson
+
 	// Update fields if provided
 	if req.Title != nil {
 		lesson.Title = *req.Title
@@ -122,6 +182,12 @@ func (s *LessonServiceImp) UpdateLesson(id uint, req dto.UpdateLessonRequest, us
 	if req.Duration != nil {
 		lesson.Duration = *req.Duration
 	}
+	if req.SectionID != nil {
+		lesson.SectionID, err = s.sectionInCourse(lesson.CourseID, req.SectionID)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if req.Sequence != nil {
 		lesson.Sequence = *req.Sequence
 	}
@@ -138,6 +204,7 @@ func (s *LessonServiceImp) UpdateLesson(id uint, req dto.UpdateLessonRequest, us
 	if err != nil {
 		return nil, err
 	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionLessonUpdate, domain.AuditTargetLesson, lesson.ID, &before, lesson)
 
 	return s.mapLessonToResponse(lesson, false), nil
 }
@@ -148,34 +215,175 @@ func (s *LessonServiceImp) DeleteLesson(id uint, userID uint) error {
 		return err
 	}
 
-	// Check if user is the course creator
+	// Check if user can manage the lessons of the course
 	course, err := s.CourseRepo.GetByID(lesson.CourseID)
 	if err != nil {
 		return err
 	}
 
-	if course.CreatedBy != userID {
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageLessons) {
 		return errors.New("unauthorized to delete this lesson")
 	}
 
-	return s.LessonRepo.Delete(id)
+	if err := freezeLiveVersion(s.VersionRepo, s.CourseRepo, course, userID); err != nil {
+		return err
+	}
+
+	if err := s.LessonRepo.Delete(id); err != nil {
+		return err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionLessonDelete, domain.AuditTargetLesson, lesson.ID, lesson, nil)
+
+	return nil
 }
 
-func (s *LessonServiceImp) ReorderLessons(courseID uint, lessonSequences []struct {
-	LessonID uint `json:"lesson_id"`
-	Sequence int  `json:"sequence"`
-}, userID uint) error {
-	// Check if user is the course creator
+func (s *LessonServiceImp) GetLessonTrash(courseID uint, userID uint) ([]dto.TrashItemResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageLessons) {
+		return nil, errors.New("unauthorized to view the trash of this course")
+	}
+
+	lessons, err := s.LessonRepo.GetDeletedByCourse(courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []dto.TrashItemResponse{}
+	for _, lesson := range lessons {
+		responses = append(responses, mapTrashItem(lesson.ID, lesson.Title, lesson.CourseID, lesson.DeletedAt.Time))
+	}
+
+	return responses, nil
+}
+
+// RestoreLesson takes the lesson out of the trash with the progress of
+// learners. The lessons of a course in the trash come back with the course.
+func (s *LessonServiceImp) RestoreLesson(id uint, userID uint) (*dto.LessonResponse, error) {
+	lesson, err := s.LessonRepo.GetDeletedByID(id)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	course, err := s.CourseRepo.GetByID(lesson.CourseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageLessons) {
+		return nil, errors.New("unauthorized to restore this lesson")
+	}
+
+	if err := freezeLiveVersion(s.VersionRepo, s.CourseRepo, course, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.LessonRepo.Restore(id); err != nil {
+		return nil, err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionLessonRestore, domain.AuditTargetLesson, lesson.ID,
+		map[string]interface{}{"deleted_at": lesson.DeletedAt.Time}, map[string]interface{}{"deleted_at": nil})
+
+	return s.mapLessonToResponse(lesson, false), nil
+}
+
+func (s *LessonServiceImp) ScheduleLesson(id uint, req dto.PublishScheduleRequest, userID uint) (*dto.PublishScheduleResponse, error) {
+	lesson, err := s.LessonRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canOnCourse(s.StaffRepo, &lesson.Course, userID, domain.CourseActionManageLessons) {
+		return nil, errors.New("unauthorized to schedule this lesson")
+	}
+
+	if err := validateSchedule(req); err != nil {
+		return nil, err
+	}
+
+	if err := freezeLiveVersion(s.VersionRepo, s.CourseRepo, &lesson.Course, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.LessonRepo.SetSchedule(lesson.ID, req.PublishAt, req.UnpublishAt); err != nil {
+		return nil, err
+	}
+	recordAudit(s.AuditService, lesson.OrganizationID, userID, domain.AuditActionLessonUpdate, domain.AuditTargetLesson, lesson.ID,
+		map[string]interface{}{"publish_at": lesson.PublishAt, "unpublish_at": lesson.UnpublishAt},
+		map[string]interface{}{"publish_at": req.PublishAt, "unpublish_at": req.UnpublishAt})
+
+	return mapScheduleToResponse(req.PublishAt, req.UnpublishAt), nil
+}
+
+// ReorderLessons moves lessons within and across the sections of the course,
+// all of them or none
+func (s *LessonServiceImp) ReorderLessons(courseID uint, lessonSequences []dto.ReorderLessonRequest, userID uint) error {
+	// Check if user can manage the lessons of the course
 	course, err := s.CourseRepo.GetByID(courseID)
 	if err != nil {
 		return err
 	}
 
-	if course.CreatedBy != userID {
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageLessons) {
 		return errors.New("unauthorized to reorder lessons for this course")
 	}
 
-	return s.LessonRepo.ReorderLessons(courseID, lessonSequences)
+	if err := freezeLiveVersion(s.VersionRepo, s.CourseRepo, course, userID); err != nil {
+		return err
+	}
+
+	lessons, err := s.LessonRepo.GetLessonsByCourse(courseID)
+	if err != nil {
+		return err
+	}
+
+	sections, err := s.SectionRepo.GetSectionsByCourse(courseID)
+	if err != nil {
+		return err
+	}
+	lessonInCourse := map[uint]bool{}
+	for _, lesson := range lessons {
+		lessonInCourse[lesson.ID] = true
+	}
+	sectionInCourse := map[uint]bool{0: true}
+	for _, section := range sections {
+		sectionInCourse[section.ID] = true
+	}
+	for _, item := range lessonSequences {
+		if !lessonInCourse[item.LessonID] || (item.SectionID != nil && !sectionInCourse[*item.SectionID]) {
+			return errutil.ErrNotInCourse
+		}
+	}
+
+	if err := s.LessonRepo.ReorderLessons(courseID, lessonSequences); err != nil {
+		return err
+	}
+
+	// the course is the target, every lesson that moved shows up as lesson_<id>
+	// and lesson_<id>_section
+	before := map[string]interface{}{}
+	for _, lesson := range lessons {
+		field := "lesson_" + strconv.FormatUint(uint64(lesson.ID), 10)
+		before[field] = lesson.Sequence
+		before[field+"_section"] = sectionIDOrZero(lesson.SectionID)
+	}
+	after := map[string]interface{}{}
+	for field, value := range before {
+		after[field] = value
+	}
+	for _, item := range lessonSequences {
+		field := "lesson_" + strconv.FormatUint(uint64(item.LessonID), 10)
+		after[field] = item.Sequence
+		if item.SectionID != nil {
+			after[field+"_section"] = *item.SectionID
+		}
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionLessonReorder, domain.AuditTargetCourse, course.ID, before, after)
+
+	return nil
 }
 
 func (s *LessonServiceImp) GetLessonByID(id uint, userID *uint) (*dto.LessonResponse, error) {
@@ -184,27 +392,55 @@ func (s *LessonServiceImp) GetLessonByID(id uint, userID *uint) (*dto.LessonResp
 		return nil, err
 	}
 
+	isStaff := userID != nil && canOnCourse(s.StaffRepo, &lesson.Course, *userID, domain.CourseActionViewContent)
+	isEnrolled := false
+	if userID != nil {
+		isEnrolled, _ = s.UserCourseRepo.IsUserEnrolled(*userID, lesson.CourseID)
+	}
+
+	// archived courses stay readable for the learners enrolled in them
+	if !canReadCourse(&lesson.Course, isStaff, isEnrolled) {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	// staff work on the draft, everybody else reads the version they are on
+	if !isStaff {
+		snapshot, err := s.learnerSnapshot(&lesson.Course, userID)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot != nil {
+			versioned, ok := snapshot.Lesson(&lesson.Course, lesson.ID)
+			if !ok {
+				return nil, errutil.ErrRecordNotFound
+			}
+			lesson = &versioned
+		}
+	}
+
 	// Check if user has access to the lesson
 	hasAccess := false
 	isCompleted := false
 
-	if lesson.IsFree || lesson.IsPublished {
+	if lesson.IsFree || lesson.IsLive(time.Now()) {
 		hasAccess = true
 	}
 
-	if userID != nil {
-		// Check if user is enrolled in the course
-		if enrolled, _ := s.UserCourseRepo.IsUserEnrolled(*userID, lesson.CourseID); enrolled {
-			hasAccess = true
-
-			// Check if lesson is completed by user
-			userLessons, err := s.LessonRepo.GetUserLessonProgress(*userID, lesson.CourseID)
-			if err == nil {
-				for _, ul := range userLessons {
-					if ul.LessonID == lesson.ID && ul.IsCompleted {
-						isCompleted = true
-						break
-					}
+	if isStaff {
+		// Course staff can always read the full lesson
+		hasAccess = true
+	}
+
+	if isEnrolled {
+		hasAccess = true
+
+		// Check if lesson is completed by user
+		userLessons, err := s.LessonRepo.GetUserLessonProgress(*userID, lesson.CourseID)
+		if err == nil {
+			for _, ul := range userLessons {
+				if ul.LessonID == lesson.ID && ul.IsCompleted {
+					isCompleted = true
+					break
 				}
 			}
 		}
@@ -230,10 +466,27 @@ func (s *LessonServiceImp) GetLessonsByCourse(courseID uint, userID *uint) ([]dt
 		isEnrolled, _ = s.UserCourseRepo.IsUserEnrolled(*userID, courseID)
 	}
 
-	if isEnrolled {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, err
+	}
+	isStaff := userID != nil && canOnCourse(s.StaffRepo, course, *userID, domain.CourseActionViewContent)
+	if !canReadCourse(course, isStaff, isEnrolled) {
+		return nil, errutil.ErrRecordNotFound
+	}
+	snapshot, err := s.learnerSnapshot(course, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case snapshot != nil:
+		// Published lessons for enrolled users, free lessons for everybody else
+		lessons = snapshot.VisibleLessons(course, !isEnrolled)
+	case isEnrolled:
 		// Get all published lessons for enrolled users
 		lessons, err = s.LessonRepo.GetPublishedLessonsByCourse(courseID)
-	} else {
+	default:
 		// Get only free lessons for non-enrolled users
 		lessons, err = s.LessonRepo.GetFreeLessonsByCourse(courseID)
 	}
@@ -274,10 +527,24 @@ func (s *LessonServiceImp) GetLessonsByCourse(courseID uint, userID *uint) ([]dt
 }
 
 func (s *LessonServiceImp) GetFreeLessonsByCourse(courseID uint) ([]dto.LessonResponse, error) {
-	lessons, err := s.LessonRepo.GetFreeLessonsByCourse(courseID)
+	course, err := s.CourseRepo.GetByID(courseID)
 	if err != nil {
 		return nil, err
 	}
+	if !canReadCourse(course, false, false) {
+		return nil, errutil.ErrRecordNotFound
+	}
+	snapshot, err := s.learnerSnapshot(course, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var lessons []domain.Lesson
+	if snapshot != nil {
+		lessons = snapshot.VisibleLessons(course, true)
+	} else if lessons, err = s.LessonRepo.GetFreeLessonsByCourse(courseID); err != nil {
+		return nil, err
+	}
 
 	var responses []dto.LessonResponse
 	for _, lesson := range lessons {
@@ -341,11 +608,22 @@ func (s *LessonServiceImp) GetUserLessonProgress(userID, courseID uint) ([]dto.L
 		return nil, errors.New("user not enrolled in this course")
 	}
 
-	lessons, err := s.LessonRepo.GetPublishedLessonsByCourse(courseID)
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := s.learnerSnapshot(course, &userID)
 	if err != nil {
 		return nil, err
 	}
 
+	var lessons []domain.Lesson
+	if snapshot != nil {
+		lessons = snapshot.VisibleLessons(course, false)
+	} else if lessons, err = s.LessonRepo.GetPublishedLessonsByCourse(courseID); err != nil {
+		return nil, err
+	}
+
 	userLessons, err := s.LessonRepo.GetUserLessonProgress(userID, courseID)
 	if err != nil {
 		return nil, err
@@ -414,11 +692,50 @@ func (s *LessonServiceImp) mapLessonToResponse(lesson *domain.Lesson, isComplete
 		Script:      lesson.Script,
 		Duration:    lesson.Duration,
 		CourseID:    lesson.CourseID,
+		SectionID:   lesson.SectionID,
 		Sequence:    lesson.Sequence,
 		IsPublished: lesson.IsPublished,
 		IsFree:      lesson.IsFree,
+		PublishAt:   formatOptionalTime(lesson.PublishAt),
+		UnpublishAt: formatOptionalTime(lesson.UnpublishAt),
 		CreatedAt:   lesson.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:   lesson.UpdatedAt.Format(time.RFC3339),
 		IsCompleted: isCompleted,
 	}
 }
+
+// learnerSnapshot returns the version of the course the user reads: the one
+// they are pinned to when enrolled, otherwise the published one. It is nil
+// while the course has no version and the live lessons are served.
+func (s *LessonServiceImp) learnerSnapshot(course *domain.Course, userID *uint) (*domain.CourseSnapshot, error) {
+	pinned := 0
+	if userID != nil {
+		if enrollment, err := s.UserCourseRepo.GetUserCourseProgress(*userID, course.ID); err == nil {
+			pinned = enrollment.CourseVersion
+		}
+	}
+
+	return courseSnapshot(s.VersionRepo, course, pinned)
+}
+
+// sectionInCourse resolves the section a lesson is put in: nil for no section
+// (sectionID nil or 0), otherwise the section has to belong to the course
+func (s *LessonServiceImp) sectionInCourse(courseID uint, sectionID *uint) (*uint, error) {
+	if sectionID == nil || *sectionID == 0 {
+		return nil, nil
+	}
+
+	section, err := s.SectionRepo.GetByID(*sectionID)
+	if err != nil || section.CourseID != courseID {
+		return nil, errutil.ErrNotInCourse
+	}
+
+	return &section.ID, nil
+}
+
+func sectionIDOrZero(sectionID *uint) uint {
+	if sectionID == nil {
+		return 0
+	}
+	return *sectionID
+}
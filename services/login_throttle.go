@@ -0,0 +1,125 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+// loginThrottle counts failed logins per account and per IP. Wrong passwords
+// and wrong second factor codes count alike, so a known password does not
+// open up unlimited MFA guesses.
+//
+// Counters are keyed by the submitted email rather than the user id, so that
+// an unknown address is throttled exactly like an existing one and the
+// responses never reveal which accounts exist.
+type loginThrottle struct {
+	RedisService *RedisService
+}
+
+// checkLoginAllowed rejects the attempt while the IP is over its limit, the
+// account is locked or the account is still inside its backoff delay
+func (s loginThrottle) checkLoginAllowed(email, ip string) error {
+	conf := config.Auth()
+
+	if ip != "" {
+		attempts, err := s.RedisService.GetInt(loginIPAttemptsCacheKey(ip))
+		if err == nil && attempts >= conf.LoginMaxAttemptsPerIP {
+			return errutil.ErrTooManyLoginAttempts
+		}
+	}
+
+	if locked, err := s.RedisService.Exists(loginLockCacheKey(email)); err != nil {
+		return err
+	} else if locked {
+		return errutil.ErrAccountLocked
+	}
+
+	if waiting, err := s.RedisService.Exists(loginBackoffCacheKey(email)); err != nil {
+		return err
+	} else if waiting {
+		return errutil.ErrTooManyLoginAttempts
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts the failed attempt and either locks the account or
+// makes the next attempt wait for an exponentially growing delay
+func (s loginThrottle) recordLoginFailure(email, ip string) {
+	conf := config.Auth()
+	window := time.Duration(conf.LoginAttemptWindow)
+
+	if ip != "" {
+		if _, err := s.RedisService.Incr(loginIPAttemptsCacheKey(ip), window); err != nil {
+			logger.Error(err)
+		}
+	}
+
+	attempts, err := s.RedisService.Incr(loginAttemptsCacheKey(email), window)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	if int(attempts) >= conf.LoginMaxAttempts {
+		if err := s.RedisService.Set(loginLockCacheKey(email), 1, time.Duration(conf.LoginLockoutDuration)); err != nil {
+			logger.Error(err)
+		}
+		_ = s.RedisService.Del(loginAttemptsCacheKey(email), loginBackoffCacheKey(email))
+		return
+	}
+
+	if err := s.RedisService.Set(loginBackoffCacheKey(email), 1, loginBackoffDelay(attempts)); err != nil {
+		logger.Error(err)
+	}
+}
+
+// resetLoginFailures clears the per-account state, the IP counter is left to
+// expire on its own
+func (s loginThrottle) resetLoginFailures(email string) error {
+	return s.RedisService.Del(loginAttemptsCacheKey(email), loginBackoffCacheKey(email), loginLockCacheKey(email))
+}
+
+// loginBackoffDelay returns base * 2^(attempts-1) seconds, capped at the configured maximum
+func loginBackoffDelay(attempts int64) time.Duration {
+	conf := config.Auth()
+
+	delay := conf.LoginBackoffBase
+	for i := int64(1); i < attempts && delay < conf.LoginBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > conf.LoginBackoffMax {
+		delay = conf.LoginBackoffMax
+	}
+
+	return time.Duration(delay)
+}
+
+func loginAttemptsCacheKey(email string) string {
+	return loginCacheKeyPrefix() + "account:" + loginEmailHash(email)
+}
+
+func loginBackoffCacheKey(email string) string {
+	return loginCacheKeyPrefix() + "backoff:" + loginEmailHash(email)
+}
+
+func loginLockCacheKey(email string) string {
+	return loginCacheKeyPrefix() + "lock:" + loginEmailHash(email)
+}
+
+func loginIPAttemptsCacheKey(ip string) string {
+	return loginCacheKeyPrefix() + "ip:" + ip
+}
+
+func loginCacheKeyPrefix() string {
+	return config.Redis().MandatoryPrefix + config.Redis().LoginAttemptPrefix
+}
+
+func loginEmailHash(email string) string {
+	return utils.HashToken(strings.ToLower(strings.TrimSpace(email)))
+}
@@ -0,0 +1,14 @@
+package services
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rijwanansari/vivaLearning/config"
+)
+
+// TestMain loads the configuration defaults, the services read it directly
+func TestMain(m *testing.M) {
+	config.LoadConfig()
+	os.Exit(m.Run())
+}
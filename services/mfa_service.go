@@ -0,0 +1,401 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/totputil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+const (
+	recoveryCodeCount     = 10
+	maxChallengeAttempts  = 5
+	totpSkew              = 1 // accept the previous and next 30 second step
+	usedTotpStepRetention = (2*totpSkew + 1) * totputil.Period
+)
+
+type MFAService interface {
+	RequiresMFA(user *domain.User) bool
+	CreateChallenge(user *domain.User) (*types.MFAChallenge, error)
+	VerifyChallenge(challengeToken, code string, client types.ClientInfo) (*types.Token, error)
+
+	BeginSetup(userID uint) (*types.MFASetup, error)
+	ConfirmSetup(userID uint, code string) (*types.MFAEnrollment, error)
+	BeginChallengeSetup(challengeToken string) (*types.MFASetup, error)
+	ConfirmChallengeSetup(challengeToken, code string, client types.ClientInfo) (*types.MFAEnrollment, error)
+	Disable(userID uint, code string) error
+}
+
+type MFAServiceImp struct {
+	UserRepo       repository.UserRepository
+	MFARepo        repository.MFARepository
+	TokenService   domain.TokenService
+	RedisService   *RedisService
+	SessionService SessionService
+}
+
+func NewMFAService(userRepo repository.UserRepository, mfaRepo repository.MFARepository, tokenService domain.TokenService, redisService *RedisService, sessionService SessionService) MFAService {
+	return &MFAServiceImp{
+		UserRepo:       userRepo,
+		MFARepo:        mfaRepo,
+		TokenService:   tokenService,
+		RedisService:   redisService,
+		SessionService: sessionService,
+	}
+}
+
+// mfaChallenge is what a challenge token points to in redis
+type mfaChallenge struct {
+	UserID        uint `json:"uid"`
+	SetupRequired bool `json:"setup"`
+}
+
+// RequiresMFA reports whether a second factor must be presented before the
+// user is given a token
+func (s *MFAServiceImp) RequiresMFA(user *domain.User) bool {
+	if user.MFAEnabled {
+		return true
+	}
+
+	return config.Auth().RequireMFAForAdmins && domain.NormalizeRole(user.Role) == domain.RoleAdmin
+}
+
+// CreateChallenge issues a short-lived token that stands in for the password
+// until the second factor is verified
+func (s *MFAServiceImp) CreateChallenge(user *domain.User) (*types.MFAChallenge, error) {
+	challengeToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := config.Auth().MFAChallengeExpiry
+	challenge := mfaChallenge{UserID: user.ID, SetupRequired: !user.MFAEnabled}
+	if err := s.RedisService.SetStruct(mfaChallengeCacheKey(challengeToken), challenge, time.Duration(expiry)); err != nil {
+		return nil, err
+	}
+
+	return &types.MFAChallenge{
+		ChallengeToken: challengeToken,
+		ExpiresAt:      time.Now().Add(time.Duration(expiry) * time.Second).Unix(),
+		SetupRequired:  challenge.SetupRequired,
+	}, nil
+}
+
+// VerifyChallenge checks a TOTP or recovery code against the challenge and
+// issues the real token pair
+func (s *MFAServiceImp) VerifyChallenge(challengeToken, code string, client types.ClientInfo) (*types.Token, error) {
+	challenge, err := s.getChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.SetupRequired {
+		return nil, errutil.ErrMFARequired
+	}
+
+	user, err := s.UserRepo.GetByID(challenge.UserID)
+	if err != nil || !user.MFAEnabled {
+		return nil, errutil.ErrInvalidMFAChallenge
+	}
+	if err := s.throttle().checkLoginAllowed(user.Email, client.IP); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.checkCode(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.recordChallengeFailure(challengeToken, user, client.IP)
+		return nil, errutil.ErrInvalidMFACode
+	}
+
+	if err := s.consumeChallenge(challengeToken); err != nil {
+		return nil, err
+	}
+
+	return s.issueToken(user, client)
+}
+
+// BeginSetup generates a new secret that is kept aside until ConfirmSetup
+// proves the authenticator app was configured with it
+func (s *MFAServiceImp) BeginSetup(userID uint) (*types.MFASetup, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+	if user.MFAEnabled {
+		return nil, errutil.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totputil.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.RedisService.Set(mfaSetupCacheKey(userID), secret, time.Duration(config.Auth().MFASetupExpiry)); err != nil {
+		return nil, err
+	}
+
+	return &types.MFASetup{
+		Secret: secret,
+		URI:    totputil.URI(config.Jwt().Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmSetup enables MFA once a code generated from the pending secret is
+// presented, and returns the recovery codes. They are shown only this once.
+func (s *MFAServiceImp) ConfirmSetup(userID uint, code string) (*types.MFAEnrollment, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+	if user.MFAEnabled {
+		return nil, errutil.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := s.RedisService.Get(mfaSetupCacheKey(userID))
+	if err != nil {
+		return nil, errutil.ErrMFASetupNotStarted
+	}
+
+	step, ok := totputil.Validate(secret, normalizeMFACode(code), time.Now(), totpSkew)
+	if !ok {
+		return nil, errutil.ErrInvalidMFACode
+	}
+	_, _ = s.RedisService.SetNX(mfaUsedStepCacheKey(userID, step), 1, usedTotpStepRetention)
+
+	recoveryCodes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.MFARepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	user.MFASecret = secret
+	user.MFAEnabled = true
+	if err := s.UserRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	_ = s.RedisService.Del(mfaSetupCacheKey(userID))
+
+	return &types.MFAEnrollment{RecoveryCodes: recoveryCodes}, nil
+}
+
+// BeginChallengeSetup lets a user who is required to use MFA enroll before
+// being issued a token
+func (s *MFAServiceImp) BeginChallengeSetup(challengeToken string) (*types.MFASetup, error) {
+	challenge, err := s.getChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.SetupRequired {
+		return nil, errutil.ErrMFAAlreadyEnabled
+	}
+
+	return s.BeginSetup(challenge.UserID)
+}
+
+// ConfirmChallengeSetup completes a forced enrollment and finishes the login
+func (s *MFAServiceImp) ConfirmChallengeSetup(challengeToken, code string, client types.ClientInfo) (*types.MFAEnrollment, error) {
+	challenge, err := s.getChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.SetupRequired {
+		return nil, errutil.ErrMFAAlreadyEnabled
+	}
+
+	user, err := s.UserRepo.GetByID(challenge.UserID)
+	if err != nil {
+		return nil, errutil.ErrInvalidMFAChallenge
+	}
+	if err := s.throttle().checkLoginAllowed(user.Email, client.IP); err != nil {
+		return nil, err
+	}
+
+	enrollment, err := s.ConfirmSetup(user.ID, code)
+	if err != nil {
+		if errors.Is(err, errutil.ErrInvalidMFACode) {
+			s.recordChallengeFailure(challengeToken, user, client.IP)
+		}
+		return nil, err
+	}
+
+	if err := s.consumeChallenge(challengeToken); err != nil {
+		return nil, err
+	}
+
+	if enrollment.Token, err = s.issueToken(user, client); err != nil {
+		return nil, err
+	}
+
+	return enrollment, nil
+}
+
+// Disable turns MFA off after a valid TOTP or recovery code. Admins cannot
+// disable it while the policy requires it.
+func (s *MFAServiceImp) Disable(userID uint, code string) error {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return errutil.ErrRecordNotFound
+	}
+	if !user.MFAEnabled {
+		return errutil.ErrMFANotEnabled
+	}
+	if config.Auth().RequireMFAForAdmins && domain.NormalizeRole(user.Role) == domain.RoleAdmin {
+		return errutil.ErrMFARequired
+	}
+
+	ok, err := s.checkCode(user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errutil.ErrInvalidMFACode
+	}
+
+	user.MFAEnabled = false
+	user.MFASecret = ""
+	if err := s.UserRepo.Update(user); err != nil {
+		return err
+	}
+
+	return s.MFARepo.DeleteRecoveryCodes(userID)
+}
+
+// checkCode accepts a 6 digit TOTP code, each at most once, or an unused recovery code
+func (s *MFAServiceImp) checkCode(user *domain.User, code string) (bool, error) {
+	code = normalizeMFACode(code)
+
+	if len(code) == totputil.Digits {
+		step, ok := totputil.Validate(user.MFASecret, code, time.Now(), totpSkew)
+		if !ok {
+			return false, nil
+		}
+
+		// a code that was already accepted must not be replayed
+		return s.RedisService.SetNX(mfaUsedStepCacheKey(user.ID, step), 1, usedTotpStepRetention)
+	}
+
+	return s.MFARepo.UseRecoveryCode(user.ID, utils.HashToken(code))
+}
+
+func (s *MFAServiceImp) getChallenge(challengeToken string) (*mfaChallenge, error) {
+	var challenge mfaChallenge
+	if err := s.RedisService.GetStruct(mfaChallengeCacheKey(challengeToken), &challenge); err != nil {
+		return nil, errutil.ErrInvalidMFAChallenge
+	}
+
+	return &challenge, nil
+}
+
+// consumeChallenge deletes the challenge, only the caller that removes it may continue
+func (s *MFAServiceImp) consumeChallenge(challengeToken string) error {
+	deleted, err := s.RedisService.DelCount(mfaChallengeCacheKey(challengeToken), mfaChallengeAttemptsCacheKey(challengeToken))
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errutil.ErrInvalidMFAChallenge
+	}
+
+	return nil
+}
+
+// recordChallengeFailure counts the wrong code as a failed login of the user
+// and drops the challenge after too many of them so that the password has to
+// be entered again
+func (s *MFAServiceImp) recordChallengeFailure(challengeToken string, user *domain.User, ip string) {
+	s.throttle().recordLoginFailure(user.Email, ip)
+
+	attempts, err := s.RedisService.Incr(mfaChallengeAttemptsCacheKey(challengeToken), time.Duration(config.Auth().MFAChallengeExpiry))
+	if err != nil || attempts >= maxChallengeAttempts {
+		_ = s.RedisService.Del(mfaChallengeCacheKey(challengeToken), mfaChallengeAttemptsCacheKey(challengeToken))
+	}
+}
+
+// issueToken finishes the login once the second factor passed, only now are
+// the failed attempts of the account cleared
+func (s *MFAServiceImp) issueToken(user *domain.User, client types.ClientInfo) (*types.Token, error) {
+	if err := s.throttle().resetLoginFailures(user.Email); err != nil {
+		logger.Error(err)
+	}
+
+	token, err := s.TokenService.CreateToken(int(user.ID), int(user.OrganizationID), domain.NormalizeRole(user.Role))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.TokenService.StoreTokenUUID(token); err != nil {
+		return nil, err
+	}
+
+	if err := s.SessionService.CreateSession(token, client); err != nil {
+		logger.Error(err)
+	}
+
+	return token, nil
+}
+
+// generateRecoveryCodes returns the codes formatted as xxxxx-xxxxx together
+// with the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+		codes = append(codes, fmt.Sprintf("%s-%s", raw[:5], raw[5:]))
+		hashes = append(hashes, utils.HashToken(raw))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeMFACode strips the separators users tend to type along with a code
+func normalizeMFACode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+func mfaChallengeCacheKey(challengeToken string) string {
+	return mfaCacheKeyPrefix() + "challenge:" + utils.HashToken(challengeToken)
+}
+
+func mfaChallengeAttemptsCacheKey(challengeToken string) string {
+	return mfaCacheKeyPrefix() + "challenge-attempts:" + utils.HashToken(challengeToken)
+}
+
+func mfaSetupCacheKey(userID uint) string {
+	return fmt.Sprintf("%ssetup:%d", mfaCacheKeyPrefix(), userID)
+}
+
+func mfaUsedStepCacheKey(userID uint, step int64) string {
+	return fmt.Sprintf("%sused:%d:%d", mfaCacheKeyPrefix(), userID, step)
+}
+
+func mfaCacheKeyPrefix() string {
+	return config.Redis().MandatoryPrefix + config.Redis().MFAPrefix
+}
+
+func (s *MFAServiceImp) throttle() loginThrottle {
+	return loginThrottle{RedisService: s.RedisService}
+}
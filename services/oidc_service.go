@@ -0,0 +1,213 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/oidcutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+type OIDCService interface {
+	Authorize(organizationID uint) (*types.OIDCAuthorization, error)
+	Callback(code, state string, client types.ClientInfo) (*types.Token, *types.MFAChallenge, error)
+}
+
+type OIDCServiceImp struct {
+	Provider     *oidcutil.Provider // nil when single sign-on is disabled
+	UserRepo     repository.UserRepository
+	IdentityRepo repository.UserIdentityRepository
+	RedisService *RedisService
+	AuthService  AuthService
+}
+
+func NewOIDCService(provider *oidcutil.Provider, userRepo repository.UserRepository, identityRepo repository.UserIdentityRepository, redisService *RedisService, authService AuthService) OIDCService {
+	return &OIDCServiceImp{
+		Provider:     provider,
+		UserRepo:     userRepo,
+		IdentityRepo: identityRepo,
+		RedisService: redisService,
+		AuthService:  authService,
+	}
+}
+
+// oidcLoginState is what the state parameter points to in redis while the user
+// is at the IdP
+type oidcLoginState struct {
+	CodeVerifier   string `json:"cv"`
+	Nonce          string `json:"nonce"`
+	OrganizationID uint   `json:"org"` // where provisioned users are created
+}
+
+// Authorize starts a login at the IdP. The PKCE verifier and the nonce stay on
+// the server, only the state travels through the browser.
+func (s *OIDCServiceImp) Authorize(organizationID uint) (*types.OIDCAuthorization, error) {
+	if s.Provider == nil {
+		return nil, errutil.ErrOIDCNotConfigured
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	authorizationURL, err := s.Provider.AuthCodeURL(state, nonce, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := config.OIDC().StateExpiry
+	loginState := oidcLoginState{CodeVerifier: codeVerifier, Nonce: nonce, OrganizationID: organizationID}
+	if err := s.RedisService.SetStruct(oidcStateCacheKey(state), loginState, time.Duration(expiry)); err != nil {
+		return nil, err
+	}
+
+	return &types.OIDCAuthorization{
+		AuthorizationURL: authorizationURL,
+		State:            state,
+		ExpiresAt:        time.Now().Add(time.Duration(expiry) * time.Second).Unix(),
+	}, nil
+}
+
+// Callback finishes the login: the state is consumed, the code is redeemed with
+// the PKCE verifier, the ID token is validated and the matching user is logged
+// in. Unknown identities are linked to the account with the same verified email
+// or provisioned when signup is allowed.
+func (s *OIDCServiceImp) Callback(code, state string, client types.ClientInfo) (*types.Token, *types.MFAChallenge, error) {
+	if s.Provider == nil {
+		return nil, nil, errutil.ErrOIDCNotConfigured
+	}
+
+	var loginState oidcLoginState
+	if err := s.RedisService.GetStruct(oidcStateCacheKey(state), &loginState); err != nil {
+		return nil, nil, errutil.ErrInvalidOIDCState
+	}
+
+	// only the caller that actually removes the state may redeem the code
+	deleted, err := s.RedisService.DelCount(oidcStateCacheKey(state))
+	if err != nil {
+		return nil, nil, err
+	}
+	if deleted == 0 {
+		return nil, nil, errutil.ErrInvalidOIDCState
+	}
+
+	rawIDToken, err := s.Provider.Exchange(code, loginState.CodeVerifier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims, err := s.Provider.VerifyIDToken(rawIDToken, loginState.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.resolveUser(claims, loginState.OrganizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.AuthService.CompleteLogin(user, client)
+}
+
+func (s *OIDCServiceImp) resolveUser(claims *oidcutil.IDTokenClaims, organizationID uint) (*domain.User, error) {
+	issuer := s.Provider.Issuer
+
+	if identity, err := s.IdentityRepo.GetByIssuerSubject(issuer, claims.Subject); err == nil {
+		user, err := s.UserRepo.GetByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.IdentityRepo.TouchLastLogin(identity.ID, claims.Email); err != nil {
+			logger.Error(err)
+		}
+
+		return user, nil
+	}
+
+	// linking and provisioning both trust the email, so the IdP must vouch for it
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return nil, errutil.ErrOIDCEmailNotVerified
+	}
+
+	now := time.Now()
+	identity := &domain.UserIdentity{
+		Issuer:      issuer,
+		Subject:     claims.Subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}
+
+	if user, err := s.UserRepo.GetByEmail(email); err == nil && user != nil {
+		if user.IsServiceAccount {
+			return nil, errutil.ErrInvalidLoginCredentials
+		}
+
+		if err := s.linkIdentity(user, identity); err != nil {
+			return nil, err
+		}
+
+		return user, nil
+	}
+
+	if !config.OIDC().AllowSignup {
+		return nil, errutil.ErrOIDCSignupDisabled
+	}
+
+	user := &domain.User{
+		OrganizationID: organizationID,
+		Email:          email,
+		Name:           claims.Name,
+		AvatarURL:      claims.Picture,
+		Role:           domain.NormalizeRole(config.OIDC().DefaultRole),
+		VerifiedAt:     &now,
+	}
+	if err := s.IdentityRepo.CreateWithUser(user, identity); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// linkIdentity attaches the identity to an existing account. An account whose
+// email was never verified may have been registered by someone else before the
+// real owner arrived, so its password is dropped and its sessions are revoked
+// before the owner is let in.
+func (s *OIDCServiceImp) linkIdentity(user *domain.User, identity *domain.UserIdentity) error {
+	identity.UserID = user.ID
+
+	if !user.IsVerified() {
+		now := time.Now()
+		user.VerifiedAt = &now
+		user.Password = ""
+		if err := s.UserRepo.Update(user); err != nil {
+			return err
+		}
+
+		if err := s.AuthService.LogoutAll(user.ID); err != nil {
+			return err
+		}
+	}
+
+	return s.IdentityRepo.Create(identity)
+}
+
+func oidcStateCacheKey(state string) string {
+	return config.Redis().MandatoryPrefix + config.Redis().OIDCPrefix + "state:" + utils.HashToken(state)
+}
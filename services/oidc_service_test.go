@@ -0,0 +1,283 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/oidcutil/oidctest"
+	"github.com/rijwanansari/vivaLearning/utils/testdb"
+	"gorm.io/gorm"
+)
+
+// fakeAuthService records the logins completed by the OIDC service
+type fakeAuthService struct {
+	AuthService
+	loggedIn  []uint
+	loggedOut []uint
+}
+
+func (f *fakeAuthService) CompleteLogin(user *domain.User, client types.ClientInfo) (*types.Token, *types.MFAChallenge, error) {
+	f.loggedIn = append(f.loggedIn, user.ID)
+	return &types.Token{}, nil, nil
+}
+
+func (f *fakeAuthService) LogoutAll(userID uint) error {
+	f.loggedOut = append(f.loggedOut, userID)
+	return nil
+}
+
+type oidcTest struct {
+	idp            *oidctest.IdP
+	db             *gorm.DB
+	auth           *fakeAuthService
+	service        *OIDCServiceImp
+	organizationID uint
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	t.Helper()
+
+	db := testdb.Open(t)
+	organization := domain.Organization{Name: "Acme", Slug: "acme"}
+	if err := db.Create(&organization).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	o := &oidcTest{idp: oidctest.New(t), db: db, auth: &fakeAuthService{}, organizationID: organization.ID}
+	o.service = NewOIDCService(o.idp.Provider(t), repository.NewUserRepository(db), repository.NewUserIdentityRepository(db),
+		newTestRedis(t), o.auth).(*OIDCServiceImp)
+
+	return o
+}
+
+// newTestRedis returns a RedisService backed by an in-memory redis server
+func newTestRedis(t *testing.T) *RedisService {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return NewRedisService(client)
+}
+
+// login starts a login and lets the user authenticate at the IdP, the ID
+// token will carry the claims
+func (o *oidcTest) login(t *testing.T, claims jwt.MapClaims) (code, state string) {
+	t.Helper()
+
+	authorization, err := o.service.Authorize(o.organizationID)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	return o.idp.Login(t, authorization.AuthorizationURL, claims)
+}
+
+// claims are valid ID token claims, the nonce is taken from the login
+func (o *oidcTest) claims(subject, email string, emailVerified bool) jwt.MapClaims {
+	claims := o.idp.Claims(subject, "")
+	delete(claims, "nonce")
+	claims["email"] = email
+	claims["email_verified"] = emailVerified
+	return claims
+}
+
+func (o *oidcTest) createUser(t *testing.T, user *domain.User) {
+	t.Helper()
+
+	user.OrganizationID = o.organizationID
+	if err := o.db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+}
+
+func (o *oidcTest) identities(t *testing.T) []domain.UserIdentity {
+	t.Helper()
+
+	var identities []domain.UserIdentity
+	if err := o.db.Find(&identities).Error; err != nil {
+		t.Fatal(err)
+	}
+	return identities
+}
+
+func TestOIDCCallbackState(t *testing.T) {
+	o := newOIDCTest(t)
+
+	code, state := o.login(t, o.claims("jane", "jane@example.com", true))
+	if _, _, err := o.service.Callback(code, "forged-state", types.ClientInfo{}); !errors.Is(err, errutil.ErrInvalidOIDCState) {
+		t.Errorf("Callback with a forged state error = %v, want %v", err, errutil.ErrInvalidOIDCState)
+	}
+
+	if _, _, err := o.service.Callback(code, state, types.ClientInfo{}); err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if _, _, err := o.service.Callback(code, state, types.ClientInfo{}); !errors.Is(err, errutil.ErrInvalidOIDCState) {
+		t.Errorf("replayed Callback error = %v, want %v", err, errutil.ErrInvalidOIDCState)
+	}
+}
+
+func TestOIDCCallbackPKCEMismatch(t *testing.T) {
+	o := newOIDCTest(t)
+
+	// a code obtained by one login cannot be redeemed with the state, and so
+	// the PKCE verifier, of another
+	code, _ := o.login(t, o.claims("jane", "jane@example.com", true))
+	_, otherState := o.login(t, o.claims("jane", "jane@example.com", true))
+
+	if _, _, err := o.service.Callback(code, otherState, types.ClientInfo{}); !errors.Is(err, errutil.ErrOIDCProvider) {
+		t.Errorf("Callback error = %v, want %v", err, errutil.ErrOIDCProvider)
+	}
+	if len(o.auth.loggedIn) != 0 {
+		t.Errorf("users %v were logged in", o.auth.loggedIn)
+	}
+}
+
+func TestOIDCCallbackNonceMismatch(t *testing.T) {
+	o := newOIDCTest(t)
+
+	claims := o.claims("jane", "jane@example.com", true)
+	claims["nonce"] = "nonce-of-another-login"
+	code, state := o.login(t, claims)
+
+	if _, _, err := o.service.Callback(code, state, types.ClientInfo{}); !errors.Is(err, errutil.ErrInvalidIDToken) {
+		t.Errorf("Callback error = %v, want %v", err, errutil.ErrInvalidIDToken)
+	}
+	if len(o.auth.loggedIn) != 0 {
+		t.Errorf("users %v were logged in", o.auth.loggedIn)
+	}
+}
+
+func TestOIDCCallbackLinksVerifiedAccount(t *testing.T) {
+	o := newOIDCTest(t)
+	verifiedAt := time.Now()
+	user := &domain.User{Email: "jane@example.com", Password: "hash", Role: domain.RoleLearner, VerifiedAt: &verifiedAt}
+	o.createUser(t, user)
+
+	code, state := o.login(t, o.claims("jane-sub", "jane@example.com", true))
+	if _, _, err := o.service.Callback(code, state, types.ClientInfo{}); err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+
+	if len(o.auth.loggedIn) != 1 || o.auth.loggedIn[0] != user.ID {
+		t.Errorf("logged in %v, want user %d", o.auth.loggedIn, user.ID)
+	}
+	identities := o.identities(t)
+	if len(identities) != 1 || identities[0].UserID != user.ID || identities[0].Subject != "jane-sub" || identities[0].Issuer != o.idp.Issuer() {
+		t.Errorf("identities = %+v, want jane-sub linked to user %d", identities, user.ID)
+	}
+
+	var stored domain.User
+	if err := o.db.First(&stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password != "hash" || len(o.auth.loggedOut) != 0 {
+		t.Errorf("verified account lost its password or sessions")
+	}
+
+	// later logins find the user by the identity, even when the email changed
+	code, state = o.login(t, o.claims("jane-sub", "jane.doe@example.com", true))
+	if _, _, err := o.service.Callback(code, state, types.ClientInfo{}); err != nil {
+		t.Fatalf("second Callback: %v", err)
+	}
+	if len(o.auth.loggedIn) != 2 || o.auth.loggedIn[1] != user.ID {
+		t.Errorf("logged in %v, want user %d twice", o.auth.loggedIn, user.ID)
+	}
+	if identities := o.identities(t); len(identities) != 1 || identities[0].Email != "jane.doe@example.com" {
+		t.Errorf("identities = %+v, want one with the new email", identities)
+	}
+}
+
+func TestOIDCCallbackTakesOverUnverifiedAccount(t *testing.T) {
+	o := newOIDCTest(t)
+	user := &domain.User{Email: "jane@example.com", Password: "hash", Role: domain.RoleLearner}
+	o.createUser(t, user)
+
+	code, state := o.login(t, o.claims("jane-sub", "jane@example.com", true))
+	if _, _, err := o.service.Callback(code, state, types.ClientInfo{}); err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+
+	var stored domain.User
+	if err := o.db.First(&stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password != "" || stored.VerifiedAt == nil {
+		t.Errorf("unverified account kept its password %q or stayed unverified", stored.Password)
+	}
+	if len(o.auth.loggedOut) != 1 || o.auth.loggedOut[0] != user.ID {
+		t.Errorf("logged out %v, want the sessions of user %d revoked", o.auth.loggedOut, user.ID)
+	}
+}
+
+func TestOIDCCallbackRefusesLinking(t *testing.T) {
+	setOIDCSignup(t, false)
+
+	tests := []struct {
+		name          string
+		user          *domain.User
+		emailVerified bool
+		wantErr       error
+	}{
+		{"email not verified by the IdP", &domain.User{Email: "jane@example.com", Role: domain.RoleLearner}, false, errutil.ErrOIDCEmailNotVerified},
+		{"service account", &domain.User{Email: "jane@example.com", Role: domain.RoleLearner, IsServiceAccount: true}, true, errutil.ErrInvalidLoginCredentials},
+		{"unknown email without signup", nil, true, errutil.ErrOIDCSignupDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOIDCTest(t)
+			if tt.user != nil {
+				o.createUser(t, tt.user)
+			}
+
+			code, state := o.login(t, o.claims("jane-sub", "jane@example.com", tt.emailVerified))
+			if _, _, err := o.service.Callback(code, state, types.ClientInfo{}); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Callback error = %v, want %v", err, tt.wantErr)
+			}
+			if identities := o.identities(t); len(identities) != 0 {
+				t.Errorf("identities = %+v, want none", identities)
+			}
+			if len(o.auth.loggedIn) != 0 {
+				t.Errorf("users %v were logged in", o.auth.loggedIn)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackProvisionsUser(t *testing.T) {
+	o := newOIDCTest(t)
+	setOIDCSignup(t, true)
+
+	code, state := o.login(t, o.claims("jane-sub", "jane@example.com", true))
+	if _, _, err := o.service.Callback(code, state, types.ClientInfo{}); err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+
+	var user domain.User
+	if err := o.db.Where("email = ?", "jane@example.com").First(&user).Error; err != nil {
+		t.Fatalf("user was not provisioned: %v", err)
+	}
+	if user.OrganizationID != o.organizationID || user.VerifiedAt == nil || user.Password != "" || user.Role != domain.RoleLearner {
+		t.Errorf("provisioned user = %+v", user)
+	}
+	if identities := o.identities(t); len(identities) != 1 || identities[0].UserID != user.ID {
+		t.Errorf("identities = %+v, want one for user %d", identities, user.ID)
+	}
+}
+
+// setOIDCSignup changes OIDC_ALLOW_SIGNUP for the test
+func setOIDCSignup(t *testing.T, allow bool) {
+	previous := config.OIDC().AllowSignup
+	config.OIDC().AllowSignup = allow
+	t.Cleanup(func() { config.OIDC().AllowSignup = previous })
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+// slugs are used as subdomains, so they follow the DNS label rules
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type OrganizationService interface {
+	GetBySlug(slug string) (*domain.Organization, error)
+	GetOrganizations() ([]domain.Organization, error)
+	CreateOrganization(name, slug string) (*domain.Organization, error)
+
+	// RotateSCIMToken issues a new SCIM bearer token for the organization and
+	// returns it once, the previous token stops working
+	RotateSCIMToken(slug string) (string, error)
+	// RevokeSCIMToken disables SCIM provisioning for the organization
+	RevokeSCIMToken(slug string) error
+	// AuthenticateSCIM returns the organization the bearer token was issued for
+	AuthenticateSCIM(token string) (*domain.Organization, error)
+}
+
+type OrganizationServiceImp struct {
+	OrganizationRepo repository.OrganizationRepository
+	RedisService     *RedisService
+}
+
+func NewOrganizationService(organizationRepo repository.OrganizationRepository, redisService *RedisService) OrganizationService {
+	return &OrganizationServiceImp{
+		OrganizationRepo: organizationRepo,
+		RedisService:     redisService,
+	}
+}
+
+// GetBySlug resolves the organization of a request, served from redis when cached
+func (s *OrganizationServiceImp) GetBySlug(slug string) (*domain.Organization, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if !organizationSlugPattern.MatchString(slug) {
+		return nil, errutil.ErrOrganizationNotFound
+	}
+	cacheKey := organizationCacheKey(slug)
+
+	var organization domain.Organization
+	if err := s.RedisService.GetStruct(cacheKey, &organization); err == nil {
+		return &organization, nil
+	}
+
+	found, err := s.OrganizationRepo.GetBySlug(slug)
+	if err != nil {
+		return nil, errutil.ErrOrganizationNotFound
+	}
+
+	ttl := time.Duration(config.Redis().OrganizationCacheTTL.Seconds())
+	if err := s.RedisService.SetStruct(cacheKey, found, ttl); err != nil {
+		logger.Error(err)
+	}
+
+	return found, nil
+}
+
+func (s *OrganizationServiceImp) GetOrganizations() ([]domain.Organization, error) {
+	return s.OrganizationRepo.GetAll()
+}
+
+// CreateOrganization adds a tenant, its first admin is created with the users CLI
+func (s *OrganizationServiceImp) CreateOrganization(name, slug string) (*domain.Organization, error) {
+	name = strings.TrimSpace(name)
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if name == "" || !organizationSlugPattern.MatchString(slug) {
+		return nil, errutil.ErrInvalidInput
+	}
+
+	exists, err := s.OrganizationRepo.SlugExists(slug)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errutil.ErrOrganizationExists
+	}
+
+	organization := &domain.Organization{Name: name, Slug: slug}
+	if err := s.OrganizationRepo.Create(organization); err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+func (s *OrganizationServiceImp) RotateSCIMToken(slug string) (string, error) {
+	organization, err := s.OrganizationRepo.GetBySlug(strings.ToLower(strings.TrimSpace(slug)))
+	if err != nil {
+		return "", errutil.ErrOrganizationNotFound
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := s.OrganizationRepo.SetSCIMTokenHash(organization.ID, utils.HashToken(token)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *OrganizationServiceImp) RevokeSCIMToken(slug string) error {
+	organization, err := s.OrganizationRepo.GetBySlug(strings.ToLower(strings.TrimSpace(slug)))
+	if err != nil {
+		return errutil.ErrOrganizationNotFound
+	}
+
+	return s.OrganizationRepo.SetSCIMTokenHash(organization.ID, "")
+}
+
+// AuthenticateSCIM looks the organization up by the hash of the token, like
+// API keys only the hash is stored
+func (s *OrganizationServiceImp) AuthenticateSCIM(token string) (*domain.Organization, error) {
+	if token == "" {
+		return nil, errutil.ErrInvalidSCIMToken
+	}
+
+	organization, err := s.OrganizationRepo.GetBySCIMTokenHash(utils.HashToken(token))
+	if err != nil {
+		return nil, errutil.ErrInvalidSCIMToken
+	}
+
+	return organization, nil
+}
+
+func organizationCacheKey(slug string) string {
+	return config.Redis().MandatoryPrefix + config.Redis().OrganizationPrefix + slug
+}
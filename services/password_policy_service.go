@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/passwordutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+// PasswordPolicyService applies the password policy from config whenever a
+// password is chosen (registration, reset and change) and tells when a
+// password has outlived its max age
+type PasswordPolicyService interface {
+	// Check validates a new password. user is nil on registration, otherwise
+	// the password must also differ from the last ones of the user.
+	Check(user *domain.User, password string) error
+	// Remember stores the hash of the new password of the user in the history
+	Remember(userID uint, passwordHash string) error
+	// IsExpired reports whether the password of the user is older than the max age
+	IsExpired(user *domain.User) bool
+}
+
+type PasswordPolicyServiceImp struct {
+	PasswordHistoryRepo repository.PasswordHistoryRepository
+	BreachedList        *passwordutil.BreachedList // nil when no list is configured
+}
+
+func NewPasswordPolicyService(passwordHistoryRepo repository.PasswordHistoryRepository, breachedList *passwordutil.BreachedList) PasswordPolicyService {
+	return &PasswordPolicyServiceImp{
+		PasswordHistoryRepo: passwordHistoryRepo,
+		BreachedList:        breachedList,
+	}
+}
+
+func (s *PasswordPolicyServiceImp) Check(user *domain.User, password string) error {
+	cfg := config.Password()
+	policy := passwordutil.Policy{
+		MinLength:        cfg.MinLength,
+		MaxLength:        cfg.MaxLength,
+		RequireUppercase: cfg.RequireUppercase,
+		RequireLowercase: cfg.RequireLowercase,
+		RequireDigit:     cfg.RequireDigit,
+		RequireSymbol:    cfg.RequireSymbol,
+	}
+	if problems := policy.Check(password); len(problems) > 0 {
+		return fmt.Errorf("%w: it needs %s", errutil.ErrWeakPassword, strings.Join(problems, ", "))
+	}
+
+	if s.BreachedList != nil {
+		breached, err := s.BreachedList.Contains(password)
+		if err != nil {
+			// an unreadable list must not lock everybody out of their account
+			logger.Error(err)
+		} else if breached {
+			return errutil.ErrBreachedPassword
+		}
+	}
+
+	if user == nil || cfg.HistorySize <= 0 {
+		return nil
+	}
+
+	// accounts created before the history existed only have their current password
+	if user.Password != "" && utils.CheckPasswordHash(password, user.Password) {
+		return errutil.ErrPasswordReused
+	}
+
+	history, err := s.PasswordHistoryRepo.GetRecent(user.ID, cfg.HistorySize)
+	if err != nil {
+		return err
+	}
+	for _, previous := range history {
+		if utils.CheckPasswordHash(password, previous.PasswordHash) {
+			return errutil.ErrPasswordReused
+		}
+	}
+
+	return nil
+}
+
+func (s *PasswordPolicyServiceImp) Remember(userID uint, passwordHash string) error {
+	keep := config.Password().HistorySize
+	if keep <= 0 {
+		return nil
+	}
+
+	return s.PasswordHistoryRepo.Add(userID, passwordHash, keep)
+}
+
+func (s *PasswordPolicyServiceImp) IsExpired(user *domain.User) bool {
+	maxAge := config.Password().MaxAgeDays
+	if maxAge <= 0 || user.Password == "" {
+		return false
+	}
+
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+
+	return time.Since(changedAt) > time.Duration(maxAge)*24*time.Hour
+}
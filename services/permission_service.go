@@ -0,0 +1,69 @@
+package services
+
+import (
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+type PermissionService interface {
+	GetRolePermissions(role string) ([]string, error)
+	HasPermission(role, permission string) (bool, error)
+}
+
+type PermissionServiceImp struct {
+	PermissionRepo repository.PermissionRepository
+	RedisService   *RedisService
+}
+
+func NewPermissionService(permissionRepo repository.PermissionRepository, redisService *RedisService) PermissionService {
+	return &PermissionServiceImp{
+		PermissionRepo: permissionRepo,
+		RedisService:   redisService,
+	}
+}
+
+// GetRolePermissions reads the permissions of a role, served from redis when cached
+func (s *PermissionServiceImp) GetRolePermissions(role string) ([]string, error) {
+	role = domain.NormalizeRole(role)
+	cacheKey := permissionCacheKey(role)
+
+	var permissions []string
+	if err := s.RedisService.GetStruct(cacheKey, &permissions); err == nil {
+		return permissions, nil
+	}
+
+	permissions, err := s.PermissionRepo.GetPermissionsByRole(role)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(config.Redis().PermissionCacheTTL.Seconds())
+	if err := s.RedisService.SetStruct(cacheKey, permissions, ttl); err != nil {
+		logger.Error(err)
+	}
+
+	return permissions, nil
+}
+
+func (s *PermissionServiceImp) HasPermission(role, permission string) (bool, error) {
+	permissions, err := s.GetRolePermissions(role)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range permissions {
+		if p == permission {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func permissionCacheKey(role string) string {
+	return config.Redis().MandatoryPrefix + config.Redis().PermissionPrefix + role
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	util "github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+// dataExportTimeout bounds a running export, a crashed build can be requested
+// again once it has passed
+const dataExportTimeout = 15 * 60 // in seconds
+
+// Data export states
+const (
+	DataExportPending = "pending"
+	DataExportReady   = "ready"
+)
+
+type PrivacyService interface {
+	// GetDataExport returns the archive of everything stored about the user
+	// once it is ready. Otherwise the archive is built in the background and
+	// only its state is returned.
+	GetDataExport(userID uint) (*dto.DataExportResponse, []byte, error)
+	// EraseMyAccount erases the current user after checking their password
+	EraseMyAccount(userID uint, req dto.EraseAccountRequest) error
+	// EraseUser erases a user on behalf of an admin
+	EraseUser(userID uint, actorID uint) error
+
+	// ForOrganization returns a copy that only sees the users of the organization
+	ForOrganization(organizationID uint) PrivacyService
+	// WithRequest returns a copy that records the request in the audit log
+	WithRequest(request types.RequestInfo) PrivacyService
+}
+
+type PrivacyServiceImp struct {
+	PrivacyRepo    repository.PrivacyRepository
+	UserRepo       repository.UserRepository
+	UserCourseRepo repository.UserCourseRepository
+	AuditLogRepo   repository.AuditLogRepository
+	APIKeyService  APIKeyService
+	TokenService   domain.TokenService
+	RedisService   *RedisService
+	AuditService   AuditService
+}
+
+// dataExportState is kept in redis next to the archive
+type dataExportState struct {
+	Status      string     `json:"status"`
+	RequestedAt time.Time  `json:"requested_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func NewPrivacyService(privacyRepo repository.PrivacyRepository, userRepo repository.UserRepository, userCourseRepo repository.UserCourseRepository, auditLogRepo repository.AuditLogRepository, apiKeyService APIKeyService, tokenService domain.TokenService, redisService *RedisService, auditService AuditService) PrivacyService {
+	return &PrivacyServiceImp{
+		PrivacyRepo:    privacyRepo,
+		UserRepo:       userRepo,
+		UserCourseRepo: userCourseRepo,
+		AuditLogRepo:   auditLogRepo,
+		APIKeyService:  apiKeyService,
+		TokenService:   tokenService,
+		RedisService:   redisService,
+		AuditService:   auditService,
+	}
+}
+
+func (s *PrivacyServiceImp) ForOrganization(organizationID uint) PrivacyService {
+	return &PrivacyServiceImp{
+		PrivacyRepo:    s.PrivacyRepo,
+		UserRepo:       s.UserRepo.ForOrganization(organizationID),
+		UserCourseRepo: s.UserCourseRepo.ForOrganization(organizationID),
+		AuditLogRepo:   s.AuditLogRepo.ForOrganization(organizationID),
+		APIKeyService:  s.APIKeyService.ForOrganization(organizationID),
+		TokenService:   s.TokenService,
+		RedisService:   s.RedisService,
+		AuditService:   s.AuditService,
+	}
+}
+
+func (s *PrivacyServiceImp) WithRequest(request types.RequestInfo) PrivacyService {
+	return &PrivacyServiceImp{
+		PrivacyRepo:    s.PrivacyRepo,
+		UserRepo:       s.UserRepo,
+		UserCourseRepo: s.UserCourseRepo,
+		AuditLogRepo:   s.AuditLogRepo,
+		APIKeyService:  s.APIKeyService,
+		TokenService:   s.TokenService,
+		RedisService:   s.RedisService,
+		AuditService:   s.AuditService.WithRequest(request),
+	}
+}
+
+func (s *PrivacyServiceImp) GetDataExport(userID uint) (*dto.DataExportResponse, []byte, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, nil, errutil.ErrRecordNotFound
+	}
+
+	var state dataExportState
+	if err := s.RedisService.GetStruct(dataExportCacheKey(user.ID), &state); err == nil {
+		if state.Status != DataExportReady {
+			return buildDataExportResponse(&state), nil, nil
+		}
+
+		archive, err := s.RedisService.Get(dataExportArchiveCacheKey(user.ID))
+		if err == nil {
+			return buildDataExportResponse(&state), []byte(archive), nil
+		}
+
+		// the archive expired before its state, start over
+		if err := s.RedisService.Del(dataExportCacheKey(user.ID)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	state = dataExportState{Status: DataExportPending, RequestedAt: time.Now()}
+	serialized, err := json.Marshal(state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// only one export per user runs at a time, across every replica
+	started, err := s.RedisService.SetNX(dataExportCacheKey(user.ID), string(serialized), dataExportTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !started {
+		// another request started the export in the meantime
+		return buildDataExportResponse(&state), nil, nil
+	}
+	recordAudit(s.AuditService, user.OrganizationID, user.ID, domain.AuditActionUserDataExport, domain.AuditTargetUser, user.ID, nil, nil)
+
+	go s.buildDataExport(user, state)
+
+	return buildDataExportResponse(&state), nil, nil
+}
+
+// buildDataExport writes the archive and marks the export as ready. On failure
+// the state is dropped so that the export can be requested again.
+func (s *PrivacyServiceImp) buildDataExport(user *domain.User, state dataExportState) {
+	archive, err := s.writeDataExport(user)
+	if err != nil {
+		logger.Error(err)
+		if err := s.RedisService.Del(dataExportCacheKey(user.ID)); err != nil {
+			logger.Error(err)
+		}
+		return
+	}
+
+	ttl := time.Duration(config.Privacy().ExportTTL)
+	if err := s.RedisService.Set(dataExportArchiveCacheKey(user.ID), archive, ttl); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	now := time.Now()
+	state.Status = DataExportReady
+	state.CompletedAt = &now
+	if err := s.RedisService.SetStruct(dataExportCacheKey(user.ID), state, ttl); err != nil {
+		logger.Error(err)
+	}
+}
+
+// writeDataExport zips one JSON file per kind of data stored about the user
+func (s *PrivacyServiceImp) writeDataExport(user *domain.User) ([]byte, error) {
+	enrollments, err := s.UserCourseRepo.GetUserEnrollments(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	lessonProgress, err := s.PrivacyRepo.GetLessonProgress(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.PrivacyRepo.GetIdentities(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.PrivacyRepo.GetGroups(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	courseRoles, err := s.PrivacyRepo.GetCourseRoles(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := s.APIKeyService.GetUserKeys(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	auditLog, err := s.userAuditLog(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", buildExportProfile(user)},
+		{"enrollments.json", buildExportEnrollments(enrollments)},
+		{"lesson_progress.json", buildExportLessonProgress(lessonProgress)},
+		{"identities.json", buildExportIdentities(identities)},
+		{"groups.json", buildExportGroups(groups)},
+		{"course_roles.json", buildExportCourseRoles(courseRoles)},
+		{"api_keys.json", apiKeys},
+		{"audit_log.json", auditLog},
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		content, err := json.MarshalIndent(file.content, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		w, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// userAuditLog lists the audit entries of the actions of the user and of the
+// changes made to their account
+func (s *PrivacyServiceImp) userAuditLog(userID uint) ([]dto.AuditLogResponse, error) {
+	entries := []dto.AuditLogResponse{}
+	collect := func(batch []domain.AuditLog) error {
+		for i := range batch {
+			entries = append(entries, MapAuditLogToResponse(&batch[i]))
+		}
+		return nil
+	}
+
+	if err := s.AuditLogRepo.FindInBatches(dto.AuditLogFilterRequest{ActorID: userID}, 500, collect); err != nil {
+		return nil, err
+	}
+	if err := s.AuditLogRepo.FindInBatches(dto.AuditLogFilterRequest{TargetType: domain.AuditTargetUser, TargetID: userID}, 500, collect); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (s *PrivacyServiceImp) EraseMyAccount(userID uint, req dto.EraseAccountRequest) error {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return errutil.ErrRecordNotFound
+	}
+
+	// accounts provisioned over SSO or SCIM have no password to confirm with
+	if user.Password != "" && !util.CheckPasswordHash(req.CurrentPassword, user.Password) {
+		return errutil.ErrInvalidCurrentPassword
+	}
+
+	return s.erase(user, user.ID)
+}
+
+func (s *PrivacyServiceImp) EraseUser(userID uint, actorID uint) error {
+	if userID == actorID {
+		return errutil.ErrCannotModifySelf
+	}
+
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return errutil.ErrRecordNotFound
+	}
+
+	return s.erase(user, actorID)
+}
+
+// erase replaces every personal field of the user and blocks the account. The
+// row itself, the enrollments and the lesson progress stay, so course
+// statistics such as GetCourseCompletionStats are not affected.
+func (s *PrivacyServiceImp) erase(user *domain.User, actorID uint) error {
+	if user.IsErased() {
+		return nil
+	}
+
+	if domain.NormalizeRole(user.Role) == domain.RoleAdmin || user.IsServiceAccount {
+		return errutil.ErrCannotErase
+	}
+	if owner, err := s.PrivacyRepo.OwnsCourses(user.ID); err != nil {
+		return err
+	} else if owner {
+		return errutil.ErrCannotErase
+	}
+
+	now := time.Now()
+	user.Email = fmt.Sprintf("erased-%d@erased.invalid", user.ID)
+	user.Password = ""
+	user.VerifiedAt = nil
+	user.MFAEnabled = false
+	user.MFASecret = ""
+	user.Name = ""
+	user.AvatarURL = ""
+	user.Bio = ""
+	user.Timezone = ""
+	user.Locale = ""
+	user.ExternalID = ""
+	user.PasswordResetRequired = false
+	user.SuspendedAt = &now
+	user.ErasedAt = &now
+	user.UpdatedAt = now
+
+	if err := s.PrivacyRepo.EraseUser(user); err != nil {
+		return err
+	}
+	// no snapshot, it would keep the erased data in the audit log
+	recordAudit(s.AuditService, user.OrganizationID, actorID, domain.AuditActionUserErase, domain.AuditTargetUser, user.ID, nil, nil)
+
+	if err := s.RedisService.Del(dataExportCacheKey(user.ID), dataExportArchiveCacheKey(user.ID)); err != nil {
+		return err
+	}
+
+	return s.TokenService.DeleteAllTokenUUIDs(int(user.ID))
+}
+
+func buildDataExportResponse(state *dataExportState) *dto.DataExportResponse {
+	response := &dto.DataExportResponse{
+		Status:      state.Status,
+		RequestedAt: state.RequestedAt.Format(time.RFC3339),
+	}
+
+	if state.CompletedAt != nil {
+		completedAt := state.CompletedAt.Format(time.RFC3339)
+		expiresAt := state.CompletedAt.Add(time.Duration(config.Privacy().ExportTTL) * time.Second).Format(time.RFC3339)
+		response.CompletedAt = &completedAt
+		response.ExpiresAt = &expiresAt
+	}
+
+	return response
+}
+
+func buildExportProfile(user *domain.User) dto.ExportProfile {
+	return dto.ExportProfile{
+		ID:             user.ID,
+		OrganizationID: user.OrganizationID,
+		Email:          user.Email,
+		Role:           domain.NormalizeRole(user.Role),
+		Name:           user.Name,
+		AvatarURL:      user.AvatarURL,
+		Bio:            user.Bio,
+		Timezone:       user.Timezone,
+		Locale:         user.Locale,
+		VerifiedAt:     formatExportTime(user.VerifiedAt),
+		MFAEnabled:     user.MFAEnabled,
+		SuspendedAt:    formatExportTime(user.SuspendedAt),
+		ExternalID:     user.ExternalID,
+		CreatedAt:      user.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      user.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func buildExportEnrollments(enrollments []domain.UserCourse) []dto.ExportEnrollment {
+	result := make([]dto.ExportEnrollment, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		result = append(result, dto.ExportEnrollment{
+			CourseID:     enrollment.CourseID,
+			CourseTitle:  enrollment.Course.Title,
+			Progress:     enrollment.Progress,
+			LastLessonID: enrollment.LastLessonID,
+			IsCompleted:  enrollment.IsCompleted,
+			EnrolledAt:   enrollment.EnrolledAt.Format(time.RFC3339),
+			CompletedAt:  formatExportTime(enrollment.CompletedAt),
+		})
+	}
+	return result
+}
+
+func buildExportLessonProgress(progress []domain.UserLesson) []dto.ExportLessonProgress {
+	result := make([]dto.ExportLessonProgress, 0, len(progress))
+	for _, p := range progress {
+		result = append(result, dto.ExportLessonProgress{
+			CourseID:    p.CourseID,
+			LessonID:    p.LessonID,
+			LessonTitle: p.Lesson.Title,
+			IsCompleted: p.IsCompleted,
+			WatchTime:   p.WatchTime,
+			CompletedAt: formatExportTime(p.CompletedAt),
+			CreatedAt:   p.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:   p.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+	return result
+}
+
+func buildExportIdentities(identities []domain.UserIdentity) []dto.ExportIdentity {
+	result := make([]dto.ExportIdentity, 0, len(identities))
+	for _, identity := range identities {
+		result = append(result, dto.ExportIdentity{
+			Issuer:      identity.Issuer,
+			Subject:     identity.Subject,
+			Email:       identity.Email,
+			LastLoginAt: formatExportTime(identity.LastLoginAt),
+			CreatedAt:   identity.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return result
+}
+
+func buildExportGroups(groups []domain.Group) []dto.ExportGroup {
+	result := make([]dto.ExportGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, dto.ExportGroup{ID: group.ID, DisplayName: group.DisplayName})
+	}
+	return result
+}
+
+func buildExportCourseRoles(roles []domain.CourseStaff) []dto.ExportCourseRole {
+	result := make([]dto.ExportCourseRole, 0, len(roles))
+	for _, role := range roles {
+		result = append(result, dto.ExportCourseRole{
+			CourseID:  role.CourseID,
+			Role:      role.Role,
+			CreatedAt: role.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return result
+}
+
+func formatExportTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.Format(time.RFC3339)
+	return &formatted
+}
+
+func dataExportCacheKey(userID uint) string {
+	return config.Redis().MandatoryPrefix + config.Redis().DataExportPrefix + strconv.FormatUint(uint64(userID), 10)
+}
+
+func dataExportArchiveCacheKey(userID uint) string {
+	return dataExportCacheKey(userID) + ":archive"
+}
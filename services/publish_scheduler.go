@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
+)
+
+// PublishScheduler applies the publish_at/unpublish_at dates of courses and
+// lessons. Every transition is a conditional update, so several replicas can
+// run the scheduler at once and each transition is still applied once.
+type PublishScheduler interface {
+	// RunOnce applies the transitions due at the time and returns how many it applied
+	RunOnce(now time.Time) (int, error)
+	// Run calls RunOnce every interval until stop is closed
+	Run(interval time.Duration, stop <-chan struct{})
+}
+
+type PublishSchedulerImp struct {
+	ScheduleRepo repository.PublishScheduleRepository
+	CourseRepo   repository.CourseRepository
+	ReviewRepo   repository.CourseReviewRepository
+	VersionRepo  repository.CourseVersionRepository
+	AuditService AuditService
+}
+
+// NewPublishScheduler takes unscoped repositories, the scheduler works across organizations
+func NewPublishScheduler(scheduleRepo repository.PublishScheduleRepository, courseRepo repository.CourseRepository, reviewRepo repository.CourseReviewRepository, versionRepo repository.CourseVersionRepository, auditService AuditService) PublishScheduler {
+	return &PublishSchedulerImp{
+		ScheduleRepo: scheduleRepo,
+		CourseRepo:   courseRepo,
+		ReviewRepo:   reviewRepo,
+		VersionRepo:  versionRepo,
+		AuditService: auditService,
+	}
+}
+
+func (s *PublishSchedulerImp) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunOnce(time.Now()); err != nil {
+			logger.Error(err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PublishSchedulerImp) RunOnce(now time.Time) (int, error) {
+	applied := 0
+
+	// lessons first, a course published in this pass then includes the
+	// lessons due with it in its version
+	published, err := s.ScheduleRepo.PublishDueLessons(now)
+	if err != nil {
+		return applied, err
+	}
+	for _, lesson := range published {
+		recordAudit(s.AuditService, lesson.OrganizationID, 0, domain.AuditActionLessonUpdate, domain.AuditTargetLesson, lesson.ID,
+			map[string]interface{}{"is_published": false}, map[string]interface{}{"is_published": true})
+	}
+	applied += len(published)
+
+	unpublished, err := s.ScheduleRepo.UnpublishDueLessons(now)
+	if err != nil {
+		return applied, err
+	}
+	for _, lesson := range unpublished {
+		recordAudit(s.AuditService, lesson.OrganizationID, 0, domain.AuditActionLessonUpdate, domain.AuditTargetLesson, lesson.ID,
+			map[string]interface{}{"is_published": true}, map[string]interface{}{"is_published": false})
+	}
+	applied += len(unpublished)
+
+	courses, err := s.ScheduleRepo.GetDueCourses(now)
+	if err != nil {
+		return applied, err
+	}
+	for i := range courses {
+		ok, err := s.applyCourse(&courses[i])
+		if err != nil {
+			// one broken course must not hold up the others
+			logger.Error(fmt.Errorf("scheduled transition of course %d: %w", courses[i].ID, err))
+			continue
+		}
+		if ok {
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+// applyCourse publishes or archives a due course. It reports false when
+// another replica got to the course first.
+func (s *PublishSchedulerImp) applyCourse(course *domain.Course) (bool, error) {
+	var err error
+	if course.Status == domain.CourseStatusApproved {
+		if course, err = s.CourseRepo.GetByIDWithLessons(course.ID); err == nil {
+			_, err = publishCourse(s.ReviewRepo, s.VersionRepo, s.AuditService, course, "Scheduled publish", 0)
+		}
+	} else {
+		_, err = transitionCourse(s.ReviewRepo, s.AuditService, course, domain.CourseReviewArchive, domain.CourseStatusArchived, "Scheduled unpublish", 0)
+	}
+
+	if errors.Is(err, errutil.ErrInvalidCourseStatus) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// validateSchedule checks that a schedule unpublishes after it publishes
+func validateSchedule(req dto.PublishScheduleRequest) error {
+	if req.PublishAt != nil && req.UnpublishAt != nil && !req.UnpublishAt.After(*req.PublishAt) {
+		return fmt.Errorf("%w: unpublish_at must be after publish_at", errutil.ErrInvalidInput)
+	}
+	return nil
+}
+
+func mapScheduleToResponse(publishAt, unpublishAt *time.Time) *dto.PublishScheduleResponse {
+	return &dto.PublishScheduleResponse{
+		PublishAt:   formatOptionalTime(publishAt),
+		UnpublishAt: formatOptionalTime(unpublishAt),
+	}
+}
+
+// formatOptionalTime formats the time as RFC 3339, nil stays nil
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.Format(time.RFC3339)
+	return &formatted
+}
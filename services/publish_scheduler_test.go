@@ -0,0 +1,124 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/utils/testdb"
+	"gorm.io/gorm"
+)
+
+func TestPublishSchedulerConcurrentRunsApplyEachTransitionOnce(t *testing.T) {
+	db := testdb.Open(t)
+	organization := domain.Organization{Name: "Acme", Slug: "acme"}
+	if err := db.Create(&organization).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	due := now.Add(-time.Minute)
+	toPublish := domain.Course{OrganizationID: organization.ID, Title: "To publish", Status: domain.CourseStatusApproved, PublishAt: &due}
+	toArchive := domain.Course{OrganizationID: organization.ID, Title: "To archive", Status: domain.CourseStatusPublished, IsPublished: true, UnpublishAt: &due}
+	mustCreateRows(t, db, &toPublish, &toArchive)
+	lessonToPublish := domain.Lesson{OrganizationID: organization.ID, CourseID: toPublish.ID, Title: "To publish", Sequence: 1, PublishAt: &due}
+	lessonToUnpublish := domain.Lesson{OrganizationID: organization.ID, CourseID: toArchive.ID, Title: "To unpublish", Sequence: 1, IsPublished: true, UnpublishAt: &due}
+	mustCreateRows(t, db, &lessonToPublish, &lessonToUnpublish)
+
+	const runs = 2
+	scheduleRepo := &racingScheduleRepository{PublishScheduleRepository: repository.NewPublishScheduleRepository(db)}
+	scheduleRepo.loaded.Add(runs)
+	scheduler := NewPublishScheduler(scheduleRepo, repository.NewCourseRepository(db),
+		repository.NewCourseReviewRepository(db), repository.NewCourseVersionRepository(db),
+		NewAuditService(repository.NewAuditLogRepository(db)))
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	applied := make([]int, runs)
+	errs := make([]error, runs)
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			applied[i], errs[i] = scheduler.RunOnce(now)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	total := 0
+	for i := range applied {
+		if errs[i] != nil {
+			t.Fatalf("RunOnce: %v", errs[i])
+		}
+		total += applied[i]
+	}
+	if total != 4 {
+		t.Errorf("the runs applied %v transitions, want 4 in total", applied)
+	}
+
+	var published, archived domain.Course
+	if err := db.First(&published, toPublish.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&archived, toArchive.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if published.Status != domain.CourseStatusPublished || published.PublishedVersion != 1 || published.PublishAt != nil {
+		t.Errorf("scheduled publish left the course %s at version %d", published.Status, published.PublishedVersion)
+	}
+	if archived.Status != domain.CourseStatusArchived || archived.UnpublishAt != nil {
+		t.Errorf("scheduled unpublish left the course %s", archived.Status)
+	}
+
+	counts := []struct {
+		name  string
+		model interface{}
+		where string
+		args  []interface{}
+	}{
+		{"versions", &domain.CourseVersion{}, "course_id = ?", []interface{}{toPublish.ID}},
+		{"publish reviews", &domain.CourseReview{}, "course_id = ? AND action = ?", []interface{}{toPublish.ID, domain.CourseReviewPublish}},
+		{"archive reviews", &domain.CourseReview{}, "course_id = ? AND action = ?", []interface{}{toArchive.ID, domain.CourseReviewArchive}},
+		{"course publish audits", &domain.AuditLog{}, "target_id = ? AND action = ?", []interface{}{toPublish.ID, domain.AuditActionCoursePublish}},
+		{"version audits", &domain.AuditLog{}, "target_id = ? AND action = ?", []interface{}{toPublish.ID, domain.AuditActionCoursePublishVersion}},
+		{"course archive audits", &domain.AuditLog{}, "target_id = ? AND action = ?", []interface{}{toArchive.ID, domain.AuditActionCourseArchive}},
+		{"lesson publish audits", &domain.AuditLog{}, "target_type = ? AND target_id = ?", []interface{}{domain.AuditTargetLesson, lessonToPublish.ID}},
+		{"lesson unpublish audits", &domain.AuditLog{}, "target_type = ? AND target_id = ?", []interface{}{domain.AuditTargetLesson, lessonToUnpublish.ID}},
+	}
+	for _, c := range counts {
+		var n int64
+		if err := db.Model(c.model).Where(c.where, c.args...).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("%s = %d, want 1", c.name, n)
+		}
+	}
+}
+
+// racingScheduleRepository holds every run back until all of them loaded the
+// due courses, so they all try to apply the same transitions
+type racingScheduleRepository struct {
+	repository.PublishScheduleRepository
+	loaded sync.WaitGroup
+}
+
+func (r *racingScheduleRepository) GetDueCourses(now time.Time) ([]domain.Course, error) {
+	courses, err := r.PublishScheduleRepository.GetDueCourses(now)
+	r.loaded.Done()
+	r.loaded.Wait()
+	return courses, err
+}
+
+func mustCreateRows(t *testing.T, db *gorm.DB, rows ...interface{}) {
+	t.Helper()
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("create %T: %v", row, err)
+		}
+	}
+}
@@ -30,6 +30,32 @@ func (svc *RedisService) SetStruct(key string, value interface{}, ttl time.Durat
 	return svc.client.Set(key, string(serializedValue), ttl*time.Second).Err()
 }
 
+// SetNX sets the key only when it does not exist yet and reports whether it did
+func (svc *RedisService) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return svc.client.SetNX(key, value, ttl*time.Second).Result()
+}
+
+// Incr increments the counter and starts its ttl when the key is created
+func (svc *RedisService) Incr(key string, ttl time.Duration) (int64, error) {
+	count, err := svc.client.Incr(key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 1 {
+		if err := svc.client.Expire(key, ttl*time.Second).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+func (svc *RedisService) Exists(key string) (bool, error) {
+	count, err := svc.client.Exists(key).Result()
+	return count > 0, err
+}
+
 func (svc *RedisService) Get(key string) (string, error) {
 	return svc.client.Get(key).Result()
 }
@@ -59,3 +85,24 @@ func (svc *RedisService) GetStruct(key string, outputStruct interface{}) error {
 func (svc *RedisService) Del(keys ...string) error {
 	return svc.client.Del(keys...).Err()
 }
+
+// DelCount deletes the given keys and reports how many of them actually existed
+func (svc *RedisService) DelCount(keys ...string) (int64, error) {
+	return svc.client.Del(keys...).Result()
+}
+
+func (svc *RedisService) SAdd(key string, ttl time.Duration, members ...interface{}) error {
+	if err := svc.client.SAdd(key, members...).Err(); err != nil {
+		return err
+	}
+
+	return svc.client.Expire(key, ttl*time.Second).Err()
+}
+
+func (svc *RedisService) SMembers(key string) ([]string, error) {
+	return svc.client.SMembers(key).Result()
+}
+
+func (svc *RedisService) SRem(key string, members ...interface{}) error {
+	return svc.client.SRem(key, members...).Err()
+}
@@ -0,0 +1,836 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/scimutil"
+)
+
+const scimBasePath = "/scim/v2"
+
+// SCIMService maps the SCIM Users and Groups resources onto domain.User and
+// domain.Group. Deactivating a user suspends it and revokes its sessions.
+type SCIMService interface {
+	ListUsers(req dto.SCIMListRequest) (*dto.SCIMListResponse, error)
+	GetUser(id string) (*dto.SCIMUser, error)
+	CreateUser(resource dto.SCIMUser) (*dto.SCIMUser, error)
+	ReplaceUser(id string, resource dto.SCIMUser) (*dto.SCIMUser, error)
+	PatchUser(id string, req dto.SCIMPatchRequest) (*dto.SCIMUser, error)
+	DeleteUser(id string) error
+
+	ListGroups(req dto.SCIMListRequest) (*dto.SCIMListResponse, error)
+	GetGroup(id string) (*dto.SCIMGroup, error)
+	CreateGroup(resource dto.SCIMGroup) (*dto.SCIMGroup, error)
+	ReplaceGroup(id string, resource dto.SCIMGroup) (*dto.SCIMGroup, error)
+	PatchGroup(id string, req dto.SCIMPatchRequest) (*dto.SCIMGroup, error)
+	DeleteGroup(id string) error
+
+	// ForOrganization returns a copy that provisions into the organization
+	ForOrganization(organizationID uint) SCIMService
+	// WithRequest returns a copy that records the request in the audit log
+	WithRequest(request types.RequestInfo) SCIMService
+}
+
+// SCIMServiceImp records its changes in the audit log without an actor, the
+// provisioning client is not a user
+type SCIMServiceImp struct {
+	UserRepo     repository.UserRepository
+	GroupRepo    repository.GroupRepository
+	TokenService domain.TokenService
+	GroupService GroupService
+	AuditService AuditService
+}
+
+func NewSCIMService(userRepo repository.UserRepository, groupRepo repository.GroupRepository, tokenService domain.TokenService, groupService GroupService, auditService AuditService) SCIMService {
+	return &SCIMServiceImp{
+		UserRepo:     userRepo,
+		GroupRepo:    groupRepo,
+		TokenService: tokenService,
+		GroupService: groupService,
+		AuditService: auditService,
+	}
+}
+
+// memberValuePath matches the PATCH path members[value eq "42"]
+var memberValuePath = regexp.MustCompile(`(?i)^members\[value eq "([^"]*)"\]$`)
+
+func (s *SCIMServiceImp) ForOrganization(organizationID uint) SCIMService {
+	return &SCIMServiceImp{
+		UserRepo:     s.UserRepo.ForOrganization(organizationID),
+		GroupRepo:    s.GroupRepo.ForOrganization(organizationID),
+		TokenService: s.TokenService,
+		GroupService: s.GroupService.ForOrganization(organizationID),
+		AuditService: s.AuditService,
+	}
+}
+
+func (s *SCIMServiceImp) WithRequest(request types.RequestInfo) SCIMService {
+	return &SCIMServiceImp{
+		UserRepo:     s.UserRepo,
+		GroupRepo:    s.GroupRepo,
+		TokenService: s.TokenService,
+		GroupService: s.GroupService.WithRequest(request),
+		AuditService: s.AuditService.WithRequest(request),
+	}
+}
+
+func (s *SCIMServiceImp) ListUsers(req dto.SCIMListRequest) (*dto.SCIMListResponse, error) {
+	filter, startIndex, count, err := scimListParams(req)
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := s.UserRepo.FindByFilter(filter, startIndex-1, count)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]interface{}, 0, len(users))
+	for i := range users {
+		resources = append(resources, buildSCIMUser(&users[i]))
+	}
+
+	return scimListResponse(resources, total, startIndex), nil
+}
+
+func (s *SCIMServiceImp) GetUser(id string) (*dto.SCIMUser, error) {
+	user, err := s.getUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildSCIMUser(user), nil
+}
+
+// CreateUser provisions a user without a password, the user logs in through
+// single sign-on or sets a password with the forgot password flow. The email
+// is trusted to be verified by the provisioning client.
+func (s *SCIMServiceImp) CreateUser(resource dto.SCIMUser) (*dto.SCIMUser, error) {
+	email := scimUserEmail(resource)
+	if email == "" {
+		return nil, fmt.Errorf("%w: userName is required", errutil.ErrInvalidInput)
+	}
+
+	if exists, err := s.UserRepo.EmailExists(email); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, errutil.ErrUserIsAlreadyExists
+	}
+
+	now := time.Now()
+	user := &domain.User{
+		Email:      email,
+		Name:       scimUserName(resource),
+		Role:       domain.RoleLearner,
+		VerifiedAt: &now,
+		ExternalID: resource.ExternalID,
+		Locale:     resource.Locale,
+		Timezone:   resource.Timezone,
+	}
+	if resource.Active != nil && !*resource.Active {
+		user.SuspendedAt = &now
+	}
+
+	if err := s.UserRepo.Create(user); err != nil {
+		return nil, err
+	}
+	recordAudit(s.AuditService, user.OrganizationID, 0, domain.AuditActionUserCreate, domain.AuditTargetUser, user.ID, nil, auditUser(user))
+
+	return buildSCIMUser(user), nil
+}
+
+// ReplaceUser overwrites the attributes of the user. Active is left unchanged
+// when it is not sent.
+func (s *SCIMServiceImp) ReplaceUser(id string, resource dto.SCIMUser) (*dto.SCIMUser, error) {
+	user, err := s.getUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := auditUser(user)
+
+	email := scimUserEmail(resource)
+	if email == "" {
+		return nil, fmt.Errorf("%w: userName is required", errutil.ErrInvalidInput)
+	}
+	if err := s.changeEmail(user, email); err != nil {
+		return nil, err
+	}
+
+	user.Name = scimUserName(resource)
+	user.ExternalID = resource.ExternalID
+	user.Locale = resource.Locale
+	user.Timezone = resource.Timezone
+
+	deactivated := false
+	if resource.Active != nil {
+		if deactivated, err = s.setActive(user, *resource.Active); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.saveUser(user, before, domain.AuditActionUserUpdate, deactivated); err != nil {
+		return nil, err
+	}
+
+	return buildSCIMUser(user), nil
+}
+
+// PatchUser applies add, replace and remove operations. Attributes the API does
+// not store (e.g. title or department) are ignored.
+func (s *SCIMServiceImp) PatchUser(id string, req dto.SCIMPatchRequest) (*dto.SCIMUser, error) {
+	user, err := s.getUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := auditUser(user)
+	deactivated := false
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return nil, fmt.Errorf("%w: unknown op %q", errutil.ErrInvalidInput, operation.Op)
+		}
+
+		values := map[string]interface{}{}
+		if operation.Path == "" {
+			// without a path the value is an object of attributes
+			if op == "remove" || json.Unmarshal(operation.Value, &values) != nil {
+				return nil, fmt.Errorf("%w: value must be an object when no path is given", errutil.ErrInvalidInput)
+			}
+		} else {
+			var value interface{}
+			if op != "remove" {
+				if err := json.Unmarshal(operation.Value, &value); err != nil {
+					return nil, fmt.Errorf("%w: invalid value for %s", errutil.ErrInvalidInput, operation.Path)
+				}
+			}
+			values[operation.Path] = value
+		}
+
+		for path, value := range values {
+			changed, err := s.patchUserAttribute(user, strings.ToLower(path), value)
+			if err != nil {
+				return nil, err
+			}
+			deactivated = deactivated || changed
+		}
+	}
+
+	if err := s.saveUser(user, before, domain.AuditActionUserUpdate, deactivated); err != nil {
+		return nil, err
+	}
+
+	return buildSCIMUser(user), nil
+}
+
+// DeleteUser deactivates the user instead of deleting it, so that course
+// statistics and certificates survive offboarding
+func (s *SCIMServiceImp) DeleteUser(id string) error {
+	user, err := s.getUser(id)
+	if err != nil {
+		return err
+	}
+
+	before := auditUser(user)
+	deactivated, err := s.setActive(user, false)
+	if err != nil {
+		return err
+	}
+
+	return s.saveUser(user, before, domain.AuditActionUserSuspend, deactivated)
+}
+
+func (s *SCIMServiceImp) ListGroups(req dto.SCIMListRequest) (*dto.SCIMListResponse, error) {
+	filter, startIndex, count, err := scimListParams(req)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, total, err := s.GroupRepo.FindByFilter(filter, startIndex-1, count)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]interface{}, 0, len(groups))
+	for i := range groups {
+		resources = append(resources, buildSCIMGroup(&groups[i]))
+	}
+
+	return scimListResponse(resources, total, startIndex), nil
+}
+
+func (s *SCIMServiceImp) GetGroup(id string) (*dto.SCIMGroup, error) {
+	group, err := s.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildSCIMGroup(group), nil
+}
+
+func (s *SCIMServiceImp) CreateGroup(resource dto.SCIMGroup) (*dto.SCIMGroup, error) {
+	displayName := strings.TrimSpace(resource.DisplayName)
+	if displayName == "" {
+		return nil, fmt.Errorf("%w: displayName is required", errutil.ErrInvalidInput)
+	}
+
+	if exists, err := s.GroupRepo.DisplayNameExists(displayName, 0); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, errutil.ErrGroupAlreadyExists
+	}
+
+	members, err := s.memberUsers(resource.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &domain.Group{
+		DisplayName: displayName,
+		ExternalID:  resource.ExternalID,
+		Members:     members,
+	}
+	if err := s.GroupRepo.Create(group); err != nil {
+		return nil, err
+	}
+
+	return buildSCIMGroup(group), nil
+}
+
+func (s *SCIMServiceImp) ReplaceGroup(id string, resource dto.SCIMGroup) (*dto.SCIMGroup, error) {
+	group, err := s.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.renameGroup(group, resource.DisplayName); err != nil {
+		return nil, err
+	}
+	group.ExternalID = resource.ExternalID
+	if err := s.GroupRepo.Update(group); err != nil {
+		return nil, err
+	}
+
+	members, err := s.memberUsers(resource.Members)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.replaceMembers(group, members); err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(id)
+}
+
+// PatchGroup applies add, replace and remove operations on displayName,
+// externalId and members. New members are enrolled in the group's courses.
+func (s *SCIMServiceImp) PatchGroup(id string, req dto.SCIMPatchRequest) (*dto.SCIMGroup, error) {
+	group, err := s.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, operation := range req.Operations {
+		if err := s.patchGroupOperation(group, operation); err != nil {
+			return nil, err
+		}
+
+		// reload so the next operation sees the current members
+		if group, err = s.getGroup(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return buildSCIMGroup(group), nil
+}
+
+func (s *SCIMServiceImp) patchGroupOperation(group *domain.Group, operation dto.SCIMPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	path := strings.ToLower(operation.Path)
+
+	switch {
+	case op == "remove" && memberValuePath.MatchString(operation.Path):
+		memberID := memberValuePath.FindStringSubmatch(operation.Path)[1]
+		members, err := s.memberUsers([]dto.SCIMMember{{Value: memberID}})
+		if err != nil {
+			return err
+		}
+		return s.GroupRepo.RemoveMembers(group, members)
+	case path == "members":
+		return s.patchGroupMembers(group, op, operation.Value)
+	case path == "" && (op == "add" || op == "replace"):
+		var resource dto.SCIMGroup
+		if json.Unmarshal(operation.Value, &resource) != nil {
+			return fmt.Errorf("%w: value must be an object when no path is given", errutil.ErrInvalidInput)
+		}
+		return s.patchGroupObject(group, op, resource)
+	case path == "displayname" && (op == "add" || op == "replace"):
+		var displayName string
+		if json.Unmarshal(operation.Value, &displayName) != nil {
+			return fmt.Errorf("%w: displayName must be a string", errutil.ErrInvalidInput)
+		}
+		if err := s.renameGroup(group, displayName); err != nil {
+			return err
+		}
+		return s.GroupRepo.Update(group)
+	case path == "externalid":
+		var externalID string
+		if op != "remove" && json.Unmarshal(operation.Value, &externalID) != nil {
+			return fmt.Errorf("%w: externalId must be a string", errutil.ErrInvalidInput)
+		}
+		group.ExternalID = externalID
+		return s.GroupRepo.Update(group)
+	}
+
+	return fmt.Errorf("%w: unsupported operation %s %s", errutil.ErrInvalidInput, operation.Op, operation.Path)
+}
+
+// DeleteGroup removes the group, enrollments made through it are kept
+func (s *SCIMServiceImp) DeleteGroup(id string) error {
+	group, err := s.getGroup(id)
+	if err != nil {
+		return err
+	}
+
+	return s.GroupRepo.Delete(group.ID)
+}
+
+func (s *SCIMServiceImp) patchUserAttribute(user *domain.User, path string, value interface{}) (bool, error) {
+	text, _ := value.(string)
+
+	switch path {
+	case "active":
+		active, ok := scimBool(value)
+		if !ok {
+			return false, fmt.Errorf("%w: active must be a boolean", errutil.ErrInvalidInput)
+		}
+		return s.setActive(user, active)
+	case "username", "emails":
+		if path == "emails" {
+			text = scimEmailValue(value)
+		}
+		if text == "" {
+			return false, fmt.Errorf("%w: %s cannot be removed", errutil.ErrInvalidInput, path)
+		}
+		return false, s.changeEmail(user, text)
+	case `emails[type eq "work"].value`, `emails[primary eq true].value`:
+		if text == "" {
+			return false, fmt.Errorf("%w: email cannot be removed", errutil.ErrInvalidInput)
+		}
+		return false, s.changeEmail(user, text)
+	case "externalid":
+		user.ExternalID = text
+	case "displayname", "name.formatted":
+		user.Name = text
+	case "name":
+		var name dto.SCIMName
+		if data, err := json.Marshal(value); err == nil {
+			_ = json.Unmarshal(data, &name)
+		}
+		user.Name = scimUserName(dto.SCIMUser{Name: &name})
+	case "name.givenname":
+		_, family := splitName(user.Name)
+		user.Name = strings.TrimSpace(text + " " + family)
+	case "name.familyname":
+		given, _ := splitName(user.Name)
+		user.Name = strings.TrimSpace(given + " " + text)
+	case "locale":
+		user.Locale = text
+	case "timezone":
+		user.Timezone = text
+	}
+
+	return false, nil
+}
+
+func (s *SCIMServiceImp) patchGroupMembers(group *domain.Group, op string, value json.RawMessage) error {
+	var members []dto.SCIMMember
+	if len(value) > 0 && json.Unmarshal(value, &members) != nil {
+		return fmt.Errorf("%w: members must be a list", errutil.ErrInvalidInput)
+	}
+
+	users, err := s.memberUsers(members)
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case "add":
+		return s.addMembers(group, users)
+	case "replace":
+		return s.replaceMembers(group, users)
+	case "remove":
+		// without a value every member is removed
+		if len(members) == 0 {
+			return s.GroupRepo.ReplaceMembers(group, nil)
+		}
+		return s.GroupRepo.RemoveMembers(group, users)
+	}
+
+	return fmt.Errorf("%w: unknown op %q", errutil.ErrInvalidInput, op)
+}
+
+func (s *SCIMServiceImp) patchGroupObject(group *domain.Group, op string, resource dto.SCIMGroup) error {
+	if resource.DisplayName != "" {
+		if err := s.renameGroup(group, resource.DisplayName); err != nil {
+			return err
+		}
+	}
+	if resource.ExternalID != "" {
+		group.ExternalID = resource.ExternalID
+	}
+	if err := s.GroupRepo.Update(group); err != nil {
+		return err
+	}
+
+	if resource.Members == nil {
+		return nil
+	}
+
+	users, err := s.memberUsers(resource.Members)
+	if err != nil {
+		return err
+	}
+	if op == "replace" {
+		return s.replaceMembers(group, users)
+	}
+	return s.addMembers(group, users)
+}
+
+func (s *SCIMServiceImp) renameGroup(group *domain.Group, displayName string) error {
+	displayName = strings.TrimSpace(displayName)
+	if displayName == "" {
+		return fmt.Errorf("%w: displayName is required", errutil.ErrInvalidInput)
+	}
+
+	if exists, err := s.GroupRepo.DisplayNameExists(displayName, group.ID); err != nil {
+		return err
+	} else if exists {
+		return errutil.ErrGroupAlreadyExists
+	}
+
+	group.DisplayName = displayName
+	return nil
+}
+
+// addMembers adds the users and enrolls the ones that were not members yet in
+// the group's courses
+func (s *SCIMServiceImp) addMembers(group *domain.Group, users []domain.User) error {
+	newMembers := newGroupMembers(group, users)
+	if err := s.GroupRepo.AddMembers(group, users); err != nil {
+		return err
+	}
+
+	s.GroupService.EnrollMembers(group, newMembers)
+	return nil
+}
+
+func (s *SCIMServiceImp) replaceMembers(group *domain.Group, users []domain.User) error {
+	newMembers := newGroupMembers(group, users)
+	if err := s.GroupRepo.ReplaceMembers(group, users); err != nil {
+		return err
+	}
+
+	s.GroupService.EnrollMembers(group, newMembers)
+	return nil
+}
+
+// memberUsers loads the users referenced by the members, unknown ids are rejected
+func (s *SCIMServiceImp) memberUsers(members []dto.SCIMMember) ([]domain.User, error) {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseUint(member.Value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid member %q", errutil.ErrInvalidInput, member.Value)
+		}
+		ids = append(ids, uint(id))
+	}
+
+	users, err := s.UserRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[uint]bool, len(users))
+	for _, user := range users {
+		found[user.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("%w: unknown member %d", errutil.ErrInvalidInput, id)
+		}
+	}
+
+	return users, nil
+}
+
+func (s *SCIMServiceImp) changeEmail(user *domain.User, email string) error {
+	if strings.EqualFold(user.Email, email) {
+		return nil
+	}
+
+	if exists, err := s.UserRepo.EmailExists(email); err != nil {
+		return err
+	} else if exists {
+		return errutil.ErrEmailAlreadyInUse
+	}
+
+	now := time.Now()
+	user.Email = email
+	user.VerifiedAt = &now
+	return nil
+}
+
+// setActive suspends or reactivates the user and reports whether it was
+// deactivated. The last active admin cannot be deactivated and erased users
+// cannot be reactivated.
+func (s *SCIMServiceImp) setActive(user *domain.User, active bool) (bool, error) {
+	if active != user.IsSuspended() {
+		return false, nil
+	}
+
+	if active {
+		if user.IsErased() {
+			return false, errutil.ErrUserErased
+		}
+		user.SuspendedAt = nil
+		return false, nil
+	}
+
+	if domain.NormalizeRole(user.Role) == domain.RoleAdmin {
+		count, err := s.UserRepo.CountActiveByRole(domain.RoleAdmin)
+		if err != nil {
+			return false, err
+		}
+		if count <= 1 {
+			return false, errutil.ErrLastAdmin
+		}
+	}
+
+	now := time.Now()
+	user.SuspendedAt = &now
+	return true, nil
+}
+
+// saveUser stores the user, records the change against the before snapshot and
+// signs a deactivated user out everywhere
+func (s *SCIMServiceImp) saveUser(user *domain.User, before map[string]interface{}, action string, deactivated bool) error {
+	if err := s.UserRepo.Update(user); err != nil {
+		return err
+	}
+	recordAudit(s.AuditService, user.OrganizationID, 0, action, domain.AuditTargetUser, user.ID, before, auditUser(user))
+
+	if deactivated {
+		return s.TokenService.DeleteAllTokenUUIDs(int(user.ID))
+	}
+
+	return nil
+}
+
+func (s *SCIMServiceImp) getUser(id string) (*domain.User, error) {
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	user, err := s.UserRepo.GetByID(uint(userID))
+	if err != nil || user.IsServiceAccount {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	return user, nil
+}
+
+func (s *SCIMServiceImp) getGroup(id string) (*domain.Group, error) {
+	groupID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	group, err := s.GroupRepo.GetByID(uint(groupID))
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	return group, nil
+}
+
+func buildSCIMUser(user *domain.User) *dto.SCIMUser {
+	active := !user.IsSuspended()
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	given, family := splitName(user.Name)
+
+	return &dto.SCIMUser{
+		Schemas:     []string{dto.SCIMUserSchema},
+		ID:          id,
+		ExternalID:  user.ExternalID,
+		UserName:    user.Email,
+		Name:        &dto.SCIMName{Formatted: user.Name, GivenName: given, FamilyName: family},
+		DisplayName: user.Name,
+		Emails:      []dto.SCIMEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		Meta: &dto.SCIMMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     scimBasePath + "/Users/" + id,
+		},
+	}
+}
+
+func buildSCIMGroup(group *domain.Group) *dto.SCIMGroup {
+	id := strconv.FormatUint(uint64(group.ID), 10)
+
+	members := make([]dto.SCIMMember, 0, len(group.Members))
+	for _, member := range group.Members {
+		memberID := strconv.FormatUint(uint64(member.ID), 10)
+		members = append(members, dto.SCIMMember{
+			Value:   memberID,
+			Display: member.Email,
+			Ref:     scimBasePath + "/Users/" + memberID,
+		})
+	}
+
+	return &dto.SCIMGroup{
+		Schemas:     []string{dto.SCIMGroupSchema},
+		ID:          id,
+		ExternalID:  group.ExternalID,
+		DisplayName: group.DisplayName,
+		Members:     members,
+		Meta: &dto.SCIMMeta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     scimBasePath + "/Groups/" + id,
+		},
+	}
+}
+
+// scimListParams parses the filter and clamps startIndex (1-based) and count
+func scimListParams(req dto.SCIMListRequest) (*scimutil.Filter, int, int, error) {
+	var filter *scimutil.Filter
+	if strings.TrimSpace(req.Filter) != "" {
+		var err error
+		if filter, err = scimutil.ParseFilter(req.Filter); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	startIndex := req.StartIndex
+	if startIndex < 1 {
+		startIndex = 1
+	}
+
+	maxResults := config.SCIM().MaxResults
+	count := maxResults
+	if req.Count != nil && *req.Count < maxResults {
+		count = *req.Count
+	}
+	if count < 0 {
+		count = 0
+	}
+
+	return filter, startIndex, count, nil
+}
+
+func scimListResponse(resources []interface{}, total int64, startIndex int) *dto.SCIMListResponse {
+	return &dto.SCIMListResponse{
+		Schemas:      []string{dto.SCIMListSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// scimUserEmail picks the login email: userName, else the primary email
+func scimUserEmail(resource dto.SCIMUser) string {
+	if email := strings.TrimSpace(resource.UserName); email != "" {
+		return email
+	}
+
+	for _, email := range resource.Emails {
+		if email.Primary {
+			return strings.TrimSpace(email.Value)
+		}
+	}
+	if len(resource.Emails) > 0 {
+		return strings.TrimSpace(resource.Emails[0].Value)
+	}
+
+	return ""
+}
+
+func scimUserName(resource dto.SCIMUser) string {
+	if resource.DisplayName != "" {
+		return resource.DisplayName
+	}
+	if resource.Name == nil {
+		return ""
+	}
+	if resource.Name.Formatted != "" {
+		return resource.Name.Formatted
+	}
+	return strings.TrimSpace(resource.Name.GivenName + " " + resource.Name.FamilyName)
+}
+
+// scimEmailValue reads the primary address from an emails value
+func scimEmailValue(value interface{}) string {
+	var emails []dto.SCIMEmail
+	if data, err := json.Marshal(value); err == nil {
+		_ = json.Unmarshal(data, &emails)
+	}
+
+	return scimUserEmail(dto.SCIMUser{Emails: emails})
+}
+
+// scimBool accepts JSON booleans as well as "True"/"False" strings, which some
+// clients send in PATCH values
+func scimBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.ToLower(v))
+		return b, err == nil
+	}
+	return false, false
+}
+
+func splitName(name string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(name), " ", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+func newGroupMembers(group *domain.Group, users []domain.User) []domain.User {
+	existing := make(map[uint]bool, len(group.Members))
+	for _, member := range group.Members {
+		existing[member.ID] = true
+	}
+
+	var added []domain.User
+	for _, user := range users {
+		if !existing[user.ID] {
+			added = append(added, user)
+		}
+	}
+
+	return added
+}
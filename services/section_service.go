@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+// SectionService manages the sections that group the lessons of a course.
+// Moving lessons between sections is done by LessonService.ReorderLessons.
+type SectionService interface {
+	CreateSection(courseID uint, req dto.CreateSectionRequest, userID uint) (*dto.SectionResponse, error)
+	UpdateSection(id uint, req dto.UpdateSectionRequest, userID uint) (*dto.SectionResponse, error)
+	DeleteSection(id uint, userID uint) error
+	ReorderSections(courseID uint, sectionSequences []dto.ReorderSectionRequest, userID uint) error
+
+	// ForOrganization returns a copy that works on the data of the organization
+	ForOrganization(organizationID uint) SectionService
+	// WithRequest returns a copy that records the request in the audit log
+	WithRequest(request types.RequestInfo) SectionService
+}
+
+type SectionServiceImp struct {
+	SectionRepo  repository.SectionRepository
+	CourseRepo   repository.CourseRepository
+	StaffRepo    repository.CourseStaffRepository
+	VersionRepo  repository.CourseVersionRepository
+	AuditService AuditService
+}
+
+func NewSectionService(sectionRepo repository.SectionRepository, courseRepo repository.CourseRepository, staffRepo repository.CourseStaffRepository, versionRepo repository.CourseVersionRepository, auditService AuditService) SectionService {
+	return &SectionServiceImp{
+		SectionRepo:  sectionRepo,
+		CourseRepo:   courseRepo,
+		StaffRepo:    staffRepo,
+		VersionRepo:  versionRepo,
+		AuditService: auditService,
+	}
+}
+
+func (s *SectionServiceImp) ForOrganization(organizationID uint) SectionService {
+	return &SectionServiceImp{
+		SectionRepo:  s.SectionRepo.ForOrganization(organizationID),
+		CourseRepo:   s.CourseRepo.ForOrganization(organizationID),
+		StaffRepo:    s.StaffRepo,
+		VersionRepo:  s.VersionRepo.ForOrganization(organizationID),
+		AuditService: s.AuditService,
+	}
+}
+
+func (s *SectionServiceImp) WithRequest(request types.RequestInfo) SectionService {
+	return &SectionServiceImp{
+		SectionRepo:  s.SectionRepo,
+		CourseRepo:   s.CourseRepo,
+		StaffRepo:    s.StaffRepo,
+		VersionRepo:  s.VersionRepo,
+		AuditService: s.AuditService.WithRequest(request),
+	}
+}
+
+func (s *SectionServiceImp) CreateSection(courseID uint, req dto.CreateSectionRequest, userID uint) (*dto.SectionResponse, error) {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageLessons) {
+		return nil, errors.New("unauthorized to create section for this course")
+	}
+
+	if err := freezeLiveVersion(s.VersionRepo, s.CourseRepo, course, userID); err != nil {
+		return nil, err
+	}
+
+	sequence := req.Sequence
+	if sequence <= 0 {
+		sequence, err = s.SectionRepo.GetNextSequence(courseID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	section := &domain.Section{
+		CourseID:    courseID,
+		Title:       req.Title,
+		Description: req.Description,
+		Sequence:    sequence,
+		IsPublished: req.IsPublished,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if err := s.SectionRepo.Create(section); err != nil {
+		return nil, err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionSectionCreate, domain.AuditTargetSection, section.ID, nil, section)
+
+	return mapSectionToResponse(section), nil
+}
+
+func (s *SectionServiceImp) UpdateSection(id uint, req dto.UpdateSectionRequest, userID uint) (*dto.SectionResponse, error) {
+	section, course, err := s.getManagedSection(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *section
+
+	if req.Title != nil {
+		section.Title = *req.Title
+	}
+	if req.Description != nil {
+		section.Description = *req.Description
+	}
+	if req.Sequence != nil {
+		section.Sequence = *req.Sequence
+	}
+	if req.IsPublished != nil {
+		section.IsPublished = *req.IsPublished
+	}
+
+	section.UpdatedAt = time.Now()
+
+	if err := s.SectionRepo.Update(section); err != nil {
+		return nil, err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionSectionUpdate, domain.AuditTargetSection, section.ID, &before, section)
+
+	return mapSectionToResponse(section), nil
+}
+
+// DeleteSection only deletes empty sections so no lesson disappears with its section
+func (s *SectionServiceImp) DeleteSection(id uint, userID uint) error {
+	section, course, err := s.getManagedSection(id, userID)
+	if err != nil {
+		return err
+	}
+
+	lessons, err := s.SectionRepo.CountLessons(section.ID)
+	if err != nil {
+		return err
+	}
+	if lessons > 0 {
+		return errutil.ErrSectionNotEmpty
+	}
+
+	if err := s.SectionRepo.Delete(section.ID); err != nil {
+		return err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionSectionDelete, domain.AuditTargetSection, section.ID, section, nil)
+
+	return nil
+}
+
+func (s *SectionServiceImp) ReorderSections(courseID uint, sectionSequences []dto.ReorderSectionRequest, userID uint) error {
+	course, err := s.CourseRepo.GetByID(courseID)
+	if err != nil {
+		return errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageLessons) {
+		return errors.New("unauthorized to reorder sections for this course")
+	}
+
+	if err := freezeLiveVersion(s.VersionRepo, s.CourseRepo, course, userID); err != nil {
+		return err
+	}
+
+	sections, err := s.SectionRepo.GetSectionsByCourse(courseID)
+	if err != nil {
+		return err
+	}
+
+	// the course is the target, every section that moved shows up as section_<id>
+	before := map[string]interface{}{}
+	for _, section := range sections {
+		before["section_"+strconv.FormatUint(uint64(section.ID), 10)] = section.Sequence
+	}
+	after := map[string]interface{}{}
+	for field, sequence := range before {
+		after[field] = sequence
+	}
+	for _, item := range sectionSequences {
+		field := "section_" + strconv.FormatUint(uint64(item.SectionID), 10)
+		if _, ok := before[field]; !ok {
+			return errutil.ErrNotInCourse
+		}
+		after[field] = item.Sequence
+	}
+
+	if err := s.SectionRepo.ReorderSections(courseID, sectionSequences); err != nil {
+		return err
+	}
+	recordAudit(s.AuditService, course.OrganizationID, userID, domain.AuditActionSectionReorder, domain.AuditTargetCourse, course.ID, before, after)
+
+	return nil
+}
+
+// getManagedSection loads the section and its course, checking that the user
+// can manage the lessons of the course. The course is frozen as a version
+// first when learners still read its live content.
+func (s *SectionServiceImp) getManagedSection(id uint, userID uint) (*domain.Section, *domain.Course, error) {
+	section, err := s.SectionRepo.GetByID(id)
+	if err != nil {
+		return nil, nil, errutil.ErrRecordNotFound
+	}
+
+	course, err := s.CourseRepo.GetByID(section.CourseID)
+	if err != nil {
+		return nil, nil, errutil.ErrRecordNotFound
+	}
+
+	if !canOnCourse(s.StaffRepo, course, userID, domain.CourseActionManageLessons) {
+		return nil, nil, errors.New("unauthorized to manage this section")
+	}
+
+	if err := freezeLiveVersion(s.VersionRepo, s.CourseRepo, course, userID); err != nil {
+		return nil, nil, err
+	}
+
+	return section, course, nil
+}
+
+func mapSectionToResponse(section *domain.Section) *dto.SectionResponse {
+	return &dto.SectionResponse{
+		ID:          section.ID,
+		CourseID:    section.CourseID,
+		Title:       section.Title,
+		Description: section.Description,
+		Sequence:    section.Sequence,
+		IsPublished: section.IsPublished,
+		CreatedAt:   section.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   section.UpdatedAt.Format(time.RFC3339),
+	}
+}
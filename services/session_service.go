@@ -0,0 +1,193 @@
+package services
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
+	"github.com/rijwanansari/vivaLearning/types"
+	"github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+// lastSeenResolution limits how often a request rewrites the session record
+const lastSeenResolution = 60 // seconds
+
+type SessionService interface {
+	CreateSession(token *types.Token, client types.ClientInfo) error
+	RotateSession(oldRefreshUuid string, token *types.Token, client types.ClientInfo) error
+	Touch(token *types.Token, client types.ClientInfo) error
+	DeleteSession(token *types.Token) error
+	GetUserSessions(userID uint, currentRefreshUuid string) ([]dto.SessionResponse, error)
+	RevokeSession(userID uint, sessionID string) error
+}
+
+type SessionServiceImp struct {
+	RedisService *RedisService
+	TokenService domain.TokenService
+}
+
+func NewSessionService(redisService *RedisService, tokenService domain.TokenService) SessionService {
+	return &SessionServiceImp{
+		RedisService: redisService,
+		TokenService: tokenService,
+	}
+}
+
+// CreateSession records a new login
+func (s *SessionServiceImp) CreateSession(token *types.Token, client types.ClientInfo) error {
+	now := time.Now().Unix()
+
+	return s.store(&types.Session{
+		ID:         token.RefreshUuid,
+		UserID:     token.UserID,
+		AccessUuid: token.AccessUuid,
+		Device:     utils.DeviceFromUserAgent(client.UserAgent),
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  token.RefreshExpiry,
+	})
+}
+
+// RotateSession moves the session to the refresh UUID of a rotated token pair,
+// keeping its creation time
+func (s *SessionServiceImp) RotateSession(oldRefreshUuid string, token *types.Token, client types.ClientInfo) error {
+	var session types.Session
+	if err := s.RedisService.GetStruct(sessionCacheKey(oldRefreshUuid), &session); err != nil {
+		// sessions created before session tracking existed start fresh
+		return s.CreateSession(token, client)
+	}
+
+	if err := s.remove(session.UserID, oldRefreshUuid); err != nil {
+		return err
+	}
+
+	session.ID = token.RefreshUuid
+	session.AccessUuid = token.AccessUuid
+	session.IP = client.IP
+	session.UserAgent = client.UserAgent
+	session.Device = utils.DeviceFromUserAgent(client.UserAgent)
+	session.LastSeenAt = time.Now().Unix()
+	session.ExpiresAt = token.RefreshExpiry
+
+	return s.store(&session)
+}
+
+// Touch updates the last seen time of the session, at most once per lastSeenResolution
+func (s *SessionServiceImp) Touch(token *types.Token, client types.ClientInfo) error {
+	var session types.Session
+	if err := s.RedisService.GetStruct(sessionCacheKey(token.RefreshUuid), &session); err != nil {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	if now-session.LastSeenAt < lastSeenResolution {
+		return nil
+	}
+
+	session.LastSeenAt = now
+	session.IP = client.IP
+
+	return s.store(&session)
+}
+
+func (s *SessionServiceImp) DeleteSession(token *types.Token) error {
+	return s.remove(token.UserID, token.RefreshUuid)
+}
+
+// GetUserSessions lists the live sessions of the user, most recently used first.
+// Sessions whose refresh token was revoked elsewhere are cleaned up on the way.
+func (s *SessionServiceImp) GetUserSessions(userID uint, currentRefreshUuid string) ([]dto.SessionResponse, error) {
+	ids, err := s.RedisService.SMembers(userSessionsCacheKey(int(userID)))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]types.Session, 0, len(ids))
+	for _, id := range ids {
+		var session types.Session
+		alive, err := s.RedisService.Exists(refreshUuidCacheKey(id))
+		if err != nil {
+			return nil, err
+		}
+		if !alive || s.RedisService.GetStruct(sessionCacheKey(id), &session) != nil {
+			_ = s.remove(int(userID), id)
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt > sessions[j].LastSeenAt
+	})
+
+	responses := make([]dto.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, dto.SessionResponse{
+			ID:         session.ID,
+			Device:     session.Device,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  time.Unix(session.CreatedAt, 0).Format(time.RFC3339),
+			LastSeenAt: time.Unix(session.LastSeenAt, 0).Format(time.RFC3339),
+			ExpiresAt:  time.Unix(session.ExpiresAt, 0).Format(time.RFC3339),
+			Current:    session.ID == currentRefreshUuid,
+		})
+	}
+
+	return responses, nil
+}
+
+// RevokeSession signs one of the user's sessions out
+func (s *SessionServiceImp) RevokeSession(userID uint, sessionID string) error {
+	var session types.Session
+	if err := s.RedisService.GetStruct(sessionCacheKey(sessionID), &session); err != nil || session.UserID != int(userID) {
+		return errutil.ErrRecordNotFound
+	}
+
+	err := s.TokenService.DeleteTokenUUID(&types.Token{
+		UserID:      session.UserID,
+		AccessUuid:  session.AccessUuid,
+		RefreshUuid: session.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.remove(session.UserID, session.ID)
+}
+
+func (s *SessionServiceImp) store(session *types.Session) error {
+	ttl := time.Duration(session.ExpiresAt - time.Now().Unix())
+	if ttl <= 0 {
+		return nil
+	}
+
+	if err := s.RedisService.SetStruct(sessionCacheKey(session.ID), session, ttl); err != nil {
+		return err
+	}
+
+	return s.RedisService.SAdd(userSessionsCacheKey(session.UserID), time.Duration(config.Jwt().RefreshTokenExpiry), session.ID)
+}
+
+func (s *SessionServiceImp) remove(userID int, sessionID string) error {
+	if err := s.RedisService.Del(sessionCacheKey(sessionID)); err != nil {
+		return err
+	}
+
+	return s.RedisService.SRem(userSessionsCacheKey(userID), sessionID)
+}
+
+func sessionCacheKey(refreshUuid string) string {
+	return config.Redis().MandatoryPrefix + config.Redis().SessionPrefix + refreshUuid
+}
+
+func userSessionsCacheKey(userID int) string {
+	return config.Redis().MandatoryPrefix + config.Redis().UserPrefix + strconv.Itoa(userID) + ":sessions"
+}
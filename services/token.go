@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -9,25 +10,30 @@ import (
 	"github.com/rijwanansari/vivaLearning/config"
 	"github.com/rijwanansari/vivaLearning/types"
 	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/jwtutil"
 	"github.com/vivasoft-ltd/golang-course-utils/logger"
 )
 
 type TokenServiceImpl struct {
 	RedisService *RedisService
+	KeySet       *jwtutil.KeySet
 }
 
-func NewTokenService(redisService *RedisService) *TokenServiceImpl {
+func NewTokenService(redisService *RedisService, keySet *jwtutil.KeySet) *TokenServiceImpl {
 	return &TokenServiceImpl{
 		RedisService: redisService,
+		KeySet:       keySet,
 	}
 }
 
 // create a new token
-func (s *TokenServiceImpl) CreateToken(userId int) (*types.Token, error) {
+func (s *TokenServiceImpl) CreateToken(userId int, orgId int, role string) (*types.Token, error) {
 	jwtConf := config.Jwt()
 	token := &types.Token{}
 
 	token.UserID = userId
+	token.OrgID = orgId
+	token.Role = role
 	token.AccessExpiry = time.Now().Add(jwtConf.GetAccessTokenExpiry()).Unix()
 	token.RefreshExpiry = time.Now().Add(jwtConf.GetRefreshTokenExpiry()).Unix()
 	token.AccessUuid = uuid.New().String()
@@ -35,75 +41,137 @@ func (s *TokenServiceImpl) CreateToken(userId int) (*types.Token, error) {
 
 	atClaims := jwt.MapClaims{}
 	atClaims["uid"] = userId
+	atClaims["org"] = orgId
+	atClaims["rol"] = role
 	atClaims["aid"] = token.AccessUuid
 	atClaims["exp"] = token.AccessExpiry
 	atClaims["rid"] = token.RefreshUuid
 
-	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
-
 	var err error
-	token.AccessToken, err = at.SignedString([]byte(jwtConf.AccessTokenSecret))
+	token.AccessToken, err = s.KeySet.Sign(jwtutil.AccessToken, atClaims)
 	if err != nil {
-		return nil, err
+		logger.Error(err)
+		return nil, errutil.ErrAccessTokenSign
 	}
 
 	rtClaims := jwt.MapClaims{}
 	rtClaims["uid"] = userId
+	rtClaims["org"] = orgId
+	rtClaims["rol"] = role
 	rtClaims["aid"] = token.AccessUuid
 	rtClaims["rid"] = token.RefreshUuid
 	rtClaims["exp"] = token.RefreshExpiry
 
-	rt := jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims)
-	token.RefreshToken, err = rt.SignedString([]byte(jwtConf.RefreshTokenSecret))
+	token.RefreshToken, err = s.KeySet.Sign(jwtutil.RefreshToken, rtClaims)
 	if err != nil {
 		logger.Error(err)
-		return nil, err
+		return nil, errutil.ErrRefreshTokenSign
 	}
 
 	return token, nil
 
 }
 
+// CreateImpersonationToken issues a short-lived access token for userID that
+// also names the acting admin. There is no refresh token, the admin has to
+// start a new impersonation once it expires.
+func (s *TokenServiceImpl) CreateImpersonationToken(actorId int, userId int, orgId int, role string) (*types.Token, error) {
+	token := &types.Token{}
+
+	token.UserID = userId
+	token.OrgID = orgId
+	token.ImpersonatorID = actorId
+	token.Role = role
+	token.AccessExpiry = time.Now().Add(config.Auth().GetImpersonationExpiry()).Unix()
+	token.RefreshExpiry = token.AccessExpiry
+	token.AccessUuid = uuid.New().String()
+	token.RefreshUuid = uuid.New().String()
+
+	atClaims := jwt.MapClaims{}
+	atClaims["uid"] = userId
+	atClaims["org"] = orgId
+	atClaims["imp"] = actorId
+	atClaims["rol"] = role
+	atClaims["aid"] = token.AccessUuid
+	atClaims["exp"] = token.AccessExpiry
+	atClaims["rid"] = token.RefreshUuid
+
+	var err error
+	token.AccessToken, err = s.KeySet.Sign(jwtutil.AccessToken, atClaims)
+	if err != nil {
+		logger.Error(err)
+		return nil, errutil.ErrAccessTokenSign
+	}
+
+	return token, nil
+}
+
 func (svc *TokenServiceImpl) StoreTokenUUID(token *types.Token) error {
-	accessTokenCacheKey := config.Redis().MandatoryPrefix + config.Redis().AccessUuidPrefix + token.AccessUuid
+	now := time.Now().Unix()
 
-	err := svc.RedisService.Set(accessTokenCacheKey, token.UserID, time.Duration(token.AccessExpiry))
+	err := svc.RedisService.Set(accessUuidCacheKey(token.AccessUuid), token.UserID, time.Duration(token.AccessExpiry-now))
 	if err != nil {
 		return err
 	}
 
-	refreshTokenCacheKey := config.Redis().MandatoryPrefix + config.Redis().RefreshUuidPrefix + token.RefreshUuid
-
-	err = svc.RedisService.Set(refreshTokenCacheKey, token.UserID, time.Duration(token.RefreshExpiry))
+	err = svc.RedisService.Set(refreshUuidCacheKey(token.RefreshUuid), token.UserID, time.Duration(token.RefreshExpiry-now))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// keep an index of every live uuid per user so that all sessions can be revoked at once
+	return svc.RedisService.SAdd(userTokensCacheKey(token.UserID), time.Duration(token.RefreshExpiry-now),
+		accessUuidCacheKey(token.AccessUuid), refreshUuidCacheKey(token.RefreshUuid))
 }
 
-func (svc *TokenServiceImpl) ParseAccessToken(accessToken string) (*types.Token, error) {
-	parsedToken, err := ParseJwtToken(accessToken, config.Jwt().AccessTokenSecret)
+// DeleteTokenUUID revokes a single login by removing both of its uuids
+func (svc *TokenServiceImpl) DeleteTokenUUID(token *types.Token) error {
+	accessKey := accessUuidCacheKey(token.AccessUuid)
+	refreshKey := refreshUuidCacheKey(token.RefreshUuid)
+
+	if err := svc.RedisService.Del(accessKey, refreshKey); err != nil {
+		return err
+	}
+
+	return svc.RedisService.SRem(userTokensCacheKey(token.UserID), accessKey, refreshKey)
+}
+
+// DeleteAllTokenUUIDs revokes every login of the given user
+func (svc *TokenServiceImpl) DeleteAllTokenUUIDs(userID int) error {
+	userTokensKey := userTokensCacheKey(userID)
+
+	keys, err := svc.RedisService.SMembers(userTokensKey)
 	if err != nil {
-		return nil, errutil.ErrParseJwt
+		return err
 	}
 
-	if _, ok := parsedToken.Claims.(jwt.Claims); !ok || !parsedToken.Valid {
+	return svc.RedisService.Del(append(keys, userTokensKey)...)
+}
+
+func (svc *TokenServiceImpl) ParseAccessToken(accessToken string) (*types.Token, error) {
+	claims, err := svc.KeySet.Parse(jwtutil.AccessToken, accessToken)
+	if err != nil {
 		return nil, errutil.ErrInvalidAccessToken
 	}
 
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errutil.ErrInvalidAccessToken
+	return mapClaimsToToken(claims)
+}
+
+func (svc *TokenServiceImpl) ParseRefreshToken(refreshToken string) (*types.Token, error) {
+	claims, err := svc.KeySet.Parse(jwtutil.RefreshToken, refreshToken)
+	if err != nil {
+		return nil, errutil.ErrInvalidRefreshToken
 	}
 
 	return mapClaimsToToken(claims)
 }
 
-func (svc *TokenServiceImpl) ReadUserIDFromAccessTokenUUID(accessTokenUuid string) (int, error) {
-	accessTokenCacheKey := config.Redis().MandatoryPrefix + config.Redis().AccessUuidPrefix + accessTokenUuid
+func (svc *TokenServiceImpl) JWKS() *types.JWKS {
+	return svc.KeySet.JWKS()
+}
 
-	userID, err := svc.RedisService.GetInt(accessTokenCacheKey)
+func (svc *TokenServiceImpl) ReadUserIDFromAccessTokenUUID(accessTokenUuid string) (int, error) {
+	userID, err := svc.RedisService.GetInt(accessUuidCacheKey(accessTokenUuid))
 
 	if err != nil {
 		return 0, err
@@ -111,15 +179,47 @@ func (svc *TokenServiceImpl) ReadUserIDFromAccessTokenUUID(accessTokenUuid strin
 	return userID, nil
 }
 
-func ParseJwtToken(token, secret string) (*jwt.Token, error) {
-	keyFunc := func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errutil.ErrInvalidJwtSigningMethod
-		}
-		return []byte(secret), nil
+// RefreshToken rotates a token pair. The refresh UUID is deleted before the new
+// pair is issued, so a refresh token can only ever be exchanged once.
+func (svc *TokenServiceImpl) RefreshToken(refreshToken string) (*types.Token, error) {
+	oldToken, err := svc.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens from before organizations carry no tenant, their owners log in again
+	if oldToken.OrgID == 0 {
+		return nil, errutil.ErrInvalidRefreshToken
+	}
+
+	userID, err := svc.RedisService.GetInt(refreshUuidCacheKey(oldToken.RefreshUuid))
+	if err != nil || userID != oldToken.UserID {
+		return nil, errutil.ErrInvalidRefreshToken
 	}
 
-	return jwt.Parse(token, keyFunc)
+	// only the caller that actually removes the key is allowed to rotate
+	deleted, err := svc.RedisService.DelCount(refreshUuidCacheKey(oldToken.RefreshUuid))
+	if err != nil {
+		return nil, err
+	}
+	if deleted == 0 {
+		return nil, errutil.ErrInvalidRefreshToken
+	}
+
+	if err := svc.DeleteTokenUUID(oldToken); err != nil {
+		logger.Error(err)
+	}
+
+	token, err := svc.CreateToken(oldToken.UserID, oldToken.OrgID, oldToken.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.StoreTokenUUID(token); err != nil {
+		return nil, err
+	}
+
+	return token, nil
 }
 
 func mapClaimsToToken(claims jwt.MapClaims) (*types.Token, error) {
@@ -136,3 +236,15 @@ func mapClaimsToToken(claims jwt.MapClaims) (*types.Token, error) {
 
 	return &token, nil
 }
+
+func accessUuidCacheKey(accessUuid string) string {
+	return config.Redis().MandatoryPrefix + config.Redis().AccessUuidPrefix + accessUuid
+}
+
+func refreshUuidCacheKey(refreshUuid string) string {
+	return config.Redis().MandatoryPrefix + config.Redis().RefreshUuidPrefix + refreshUuid
+}
+
+func userTokensCacheKey(userID int) string {
+	return config.Redis().MandatoryPrefix + config.Redis().UserPrefix + strconv.Itoa(userID) + ":tokens"
+}
@@ -1,46 +1,451 @@
 package services
 
 import (
-	"errors"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/rijwanansari/vivaLearning/domain"
+	"github.com/rijwanansari/vivaLearning/dto"
 	repository "github.com/rijwanansari/vivaLearning/repositories"
+	"github.com/rijwanansari/vivaLearning/types"
 	util "github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/vivasoft-ltd/golang-course-utils/logger"
 )
 
 type UserService interface {
-	RegisterUser(email, password string) (*domain.User, error)
+	GetProfile(userID uint) (*dto.ProfileResponse, error)
+	UpdateProfile(userID uint, req dto.UpdateProfileRequest) (*dto.ProfileResponse, error)
+	ChangeEmail(userID uint, req dto.ChangeEmailRequest) error
+	ChangePassword(userID uint, req dto.ChangePasswordRequest) error
+
+	// Admin operations
+	ListUsers(filter dto.UserFilterRequest) (*dto.PaginatedResponse, error)
+	ChangeRole(userID uint, role string, actorID uint) (*dto.AdminUserResponse, error)
+	SuspendUser(userID uint, actorID uint) (*dto.AdminUserResponse, error)
+	ReactivateUser(userID uint, actorID uint) (*dto.AdminUserResponse, error)
+	ForcePasswordReset(userID uint, actorID uint) error
+	ForceLogout(userID uint, actorID uint) error
+	Impersonate(userID uint, actorID uint) (*dto.ImpersonationResponse, error)
+
+	// ForOrganization returns a copy that only sees the users of the organization,
+	// so admins can only manage the users of their own organization
+	ForOrganization(organizationID uint) UserService
+	// WithRequest returns a copy that records the request in the audit log
+	WithRequest(request types.RequestInfo) UserService
 }
 
 type userServiceImpl struct {
-	repo repository.UserRepository
+	repo           repository.UserRepository
+	authService    AuthService
+	tokenService   domain.TokenService
+	auditService   AuditService
+	passwordPolicy PasswordPolicyService
+}
+
+func NewUserService(repo repository.UserRepository, authService AuthService, tokenService domain.TokenService, auditService AuditService, passwordPolicy PasswordPolicyService) *userServiceImpl {
+	return &userServiceImpl{
+		repo:           repo,
+		authService:    authService,
+		tokenService:   tokenService,
+		auditService:   auditService,
+		passwordPolicy: passwordPolicy,
+	}
+}
+
+func (s *userServiceImpl) ForOrganization(organizationID uint) UserService {
+	return &userServiceImpl{
+		repo:           s.repo.ForOrganization(organizationID),
+		authService:    s.authService,
+		tokenService:   s.tokenService,
+		auditService:   s.auditService,
+		passwordPolicy: s.passwordPolicy,
+	}
+}
+
+func (s *userServiceImpl) WithRequest(request types.RequestInfo) UserService {
+	return &userServiceImpl{
+		repo:           s.repo,
+		authService:    s.authService,
+		tokenService:   s.tokenService,
+		auditService:   s.auditService.WithRequest(request),
+		passwordPolicy: s.passwordPolicy,
+	}
+}
+
+func (s *userServiceImpl) GetProfile(userID uint) (*dto.ProfileResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	return s.buildProfileResponse(user), nil
+}
+
+func (s *userServiceImpl) UpdateProfile(userID uint, req dto.UpdateProfileRequest) (*dto.ProfileResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	before := auditUser(user)
+
+	// Update fields if provided
+	if req.Name != nil {
+		user.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.AvatarURL != nil {
+		user.AvatarURL = *req.AvatarURL
+	}
+	if req.Bio != nil {
+		user.Bio = *req.Bio
+	}
+	if req.Timezone != nil {
+		user.Timezone = *req.Timezone
+	}
+	if req.Locale != nil {
+		user.Locale = *req.Locale
+	}
+
+	user.UpdatedAt = time.Now()
+	if err := s.repo.UpdateProfile(user); err != nil {
+		return nil, err
+	}
+	recordAudit(s.auditService, user.OrganizationID, user.ID, domain.AuditActionUserUpdate, domain.AuditTargetUser, user.ID, before, auditUser(user))
+
+	return s.buildProfileResponse(user), nil
+}
+
+// ChangeEmail sends a verification link to the new address. The account keeps
+// its current address until the link is opened.
+func (s *userServiceImpl) ChangeEmail(userID uint, req dto.ChangeEmailRequest) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return errutil.ErrRecordNotFound
+	}
+
+	if !util.CheckPasswordHash(req.CurrentPassword, user.Password) {
+		return errutil.ErrInvalidCurrentPassword
+	}
+
+	if strings.EqualFold(user.Email, req.NewEmail) {
+		return errutil.ErrEmailAlreadyInUse
+	}
+	if exists, err := s.repo.EmailExists(req.NewEmail); err != nil {
+		return err
+	} else if exists {
+		return errutil.ErrEmailAlreadyInUse
+	}
+
+	return s.authService.SendEmailChangeVerification(user, req.NewEmail)
 }
 
-func NewUserService(repo repository.UserRepository) *userServiceImpl {
-	return &userServiceImpl{repo: repo}
+// ChangePassword replaces the password after checking the current one and the
+// password policy, and signs the user out of every session, including the
+// calling one
+func (s *userServiceImpl) ChangePassword(userID uint, req dto.ChangePasswordRequest) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return errutil.ErrRecordNotFound
+	}
+
+	if !util.CheckPasswordHash(req.CurrentPassword, user.Password) {
+		return errutil.ErrInvalidCurrentPassword
+	}
+
+	if err := s.passwordPolicy.Check(user, req.NewPassword); err != nil {
+		return err
+	}
+
+	hashed, err := util.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(user.ID, hashed); err != nil {
+		return err
+	}
+	if err := s.passwordPolicy.Remember(user.ID, hashed); err != nil {
+		logger.Error(err)
+	}
+	recordAudit(s.auditService, user.OrganizationID, user.ID, domain.AuditActionUserPasswordChange, domain.AuditTargetUser, user.ID, nil, nil)
+
+	return s.tokenService.DeleteAllTokenUUIDs(int(user.ID))
 }
 
-func (s *userServiceImpl) RegisterUser(email, password string) (*domain.User, error) {
-	if existing, _ := s.repo.GetByEmail(email); existing != nil {
-		return nil, errors.New("email already registered")
+func (s *userServiceImpl) ListUsers(filter dto.UserFilterRequest) (*dto.PaginatedResponse, error) {
+	// Set default pagination values
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 20
 	}
 
-	hashed, err := util.HashPassword(password)
+	users, total, err := s.repo.GetAll(filter)
 	if err != nil {
 		return nil, err
 	}
 
-	user := &domain.User{
-		Email:     email,
-		Password:  hashed,
-		Role:      "user",
-		CreatedAt: time.Now(),
+	userList := make([]dto.AdminUserResponse, 0, len(users))
+	for i := range users {
+		userList = append(userList, *s.buildAdminUserResponse(&users[i]))
+	}
+
+	return &dto.PaginatedResponse{
+		Data:       userList,
+		Total:      total,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.Limit))),
+	}, nil
+}
+
+// ChangeRole assigns a new role. Existing tokens carry the old role, so every
+// session of the user is revoked.
+func (s *userServiceImpl) ChangeRole(userID uint, role string, actorID uint) (*dto.AdminUserResponse, error) {
+	if userID == actorID {
+		return nil, errutil.ErrCannotModifySelf
+	}
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if domain.NormalizeRole(user.Role) == domain.RoleAdmin && role != domain.RoleAdmin {
+		if err := s.ensureAnotherAdmin(); err != nil {
+			return nil, err
+		}
+	}
+
+	before := auditUser(user)
+	user.Role = role
+	if err := s.repo.Update(user); err != nil {
+		return nil, err
 	}
+	recordAudit(s.auditService, user.OrganizationID, actorID, domain.AuditActionUserRoleChange, domain.AuditTargetUser, user.ID, before, auditUser(user))
 
-	if err := s.repo.Create(user); err != nil {
+	if err := s.tokenService.DeleteAllTokenUUIDs(int(user.ID)); err != nil {
 		return nil, err
 	}
 
-	return user, nil
+	return s.buildAdminUserResponse(user), nil
+}
+
+// SuspendUser blocks the account from logging in and signs out every session
+func (s *userServiceImpl) SuspendUser(userID uint, actorID uint) (*dto.AdminUserResponse, error) {
+	if userID == actorID {
+		return nil, errutil.ErrCannotModifySelf
+	}
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if user.IsSuspended() {
+		return s.buildAdminUserResponse(user), nil
+	}
+
+	if domain.NormalizeRole(user.Role) == domain.RoleAdmin {
+		if err := s.ensureAnotherAdmin(); err != nil {
+			return nil, err
+		}
+	}
+
+	before := auditUser(user)
+	now := time.Now()
+	user.SuspendedAt = &now
+	if err := s.repo.Update(user); err != nil {
+		return nil, err
+	}
+	recordAudit(s.auditService, user.OrganizationID, actorID, domain.AuditActionUserSuspend, domain.AuditTargetUser, user.ID, before, auditUser(user))
+
+	if err := s.tokenService.DeleteAllTokenUUIDs(int(user.ID)); err != nil {
+		return nil, err
+	}
+
+	return s.buildAdminUserResponse(user), nil
+}
+
+// ReactivateUser lifts a suspension, erased users stay suspended
+func (s *userServiceImpl) ReactivateUser(userID uint, actorID uint) (*dto.AdminUserResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if user.IsErased() {
+		return nil, errutil.ErrUserErased
+	}
+
+	before := auditUser(user)
+	user.SuspendedAt = nil
+	if err := s.repo.Update(user); err != nil {
+		return nil, err
+	}
+	recordAudit(s.auditService, user.OrganizationID, actorID, domain.AuditActionUserReactivate, domain.AuditTargetUser, user.ID, before, auditUser(user))
+
+	return s.buildAdminUserResponse(user), nil
+}
+
+// ForcePasswordReset refuses further logins until the user resets the
+// password through the link that is mailed to them, and signs out every session
+func (s *userServiceImpl) ForcePasswordReset(userID uint, actorID uint) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return errutil.ErrRecordNotFound
+	}
+
+	before := auditUser(user)
+	user.PasswordResetRequired = true
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	recordAudit(s.auditService, user.OrganizationID, actorID, domain.AuditActionUserForcePasswordReset, domain.AuditTargetUser, user.ID, before, auditUser(user))
+
+	if err := s.tokenService.DeleteAllTokenUUIDs(int(user.ID)); err != nil {
+		return err
+	}
+
+	return s.authService.SendPasswordReset(user)
+}
+
+// ForceLogout signs out every session of the user
+func (s *userServiceImpl) ForceLogout(userID uint, actorID uint) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return errutil.ErrRecordNotFound
+	}
+
+	if err := s.tokenService.DeleteAllTokenUUIDs(int(userID)); err != nil {
+		return err
+	}
+	recordAudit(s.auditService, user.OrganizationID, actorID, domain.AuditActionUserLogout, domain.AuditTargetUser, user.ID, nil, nil)
+
+	return nil
+}
+
+// Impersonate issues a short-lived token that lets an admin see the platform
+// as the user does. The token names both users and every impersonation is
+// recorded in the audit log before the token is handed out.
+func (s *userServiceImpl) Impersonate(userID uint, actorID uint) (*dto.ImpersonationResponse, error) {
+	if userID == actorID {
+		return nil, errutil.ErrCannotModifySelf
+	}
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, errutil.ErrRecordNotFound
+	}
+
+	if domain.NormalizeRole(user.Role) == domain.RoleAdmin || user.IsServiceAccount {
+		return nil, errutil.ErrCannotImpersonate
+	}
+	if user.IsSuspended() {
+		return nil, errutil.ErrAccountSuspended
+	}
+
+	if err := s.auditService.Record(user.OrganizationID, actorID, domain.AuditActionUserImpersonate, domain.AuditTargetUser, user.ID, nil, nil); err != nil {
+		return nil, err
+	}
+
+	token, err := s.tokenService.CreateImpersonationToken(int(actorID), int(user.ID), int(user.OrganizationID), domain.NormalizeRole(user.Role))
+	if err != nil {
+		return nil, err
+	}
+
+	// stored like any login, so logging the user out also ends the impersonation
+	if err := s.tokenService.StoreTokenUUID(token); err != nil {
+		return nil, err
+	}
+
+	return &dto.ImpersonationResponse{
+		AccessToken:    token.AccessToken,
+		ExpiresAt:      token.AccessExpiry,
+		UserID:         user.ID,
+		ImpersonatorID: actorID,
+	}, nil
+}
+
+// ensureAnotherAdmin makes sure demoting or suspending an admin leaves at least one active admin
+func (s *userServiceImpl) ensureAnotherAdmin() error {
+	admins, err := s.repo.CountActiveByRole(domain.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return errutil.ErrLastAdmin
+	}
+
+	return nil
+}
+
+// auditUser is the snapshot of a user kept in the audit log, without
+// credentials. Erasure removes the personal fields from the log again, keep
+// erasedAuditFields of the privacy repository in sync when adding one.
+func auditUser(user *domain.User) map[string]interface{} {
+	return map[string]interface{}{
+		"email":                   user.Email,
+		"role":                    domain.NormalizeRole(user.Role),
+		"name":                    user.Name,
+		"avatar_url":              user.AvatarURL,
+		"bio":                     user.Bio,
+		"timezone":                user.Timezone,
+		"locale":                  user.Locale,
+		"verified_at":             user.VerifiedAt,
+		"mfa_enabled":             user.MFAEnabled,
+		"suspended_at":            user.SuspendedAt,
+		"password_reset_required": user.PasswordResetRequired,
+		"service_account":         user.IsServiceAccount,
+		"external_id":             user.ExternalID,
+	}
+}
+
+func (s *userServiceImpl) buildAdminUserResponse(user *domain.User) *dto.AdminUserResponse {
+	response := &dto.AdminUserResponse{
+		ID:                    user.ID,
+		Email:                 user.Email,
+		Role:                  domain.NormalizeRole(user.Role),
+		Name:                  user.Name,
+		Verified:              user.IsVerified(),
+		MFAEnabled:            user.MFAEnabled,
+		Suspended:             user.IsSuspended(),
+		PasswordResetRequired: user.PasswordResetRequired,
+		ServiceAccount:        user.IsServiceAccount,
+		CreatedAt:             user.CreatedAt.Format(time.RFC3339),
+	}
+
+	if user.SuspendedAt != nil {
+		suspendedAt := user.SuspendedAt.Format(time.RFC3339)
+		response.SuspendedAt = &suspendedAt
+	}
+
+	return response
+}
+
+func (s *userServiceImpl) buildProfileResponse(user *domain.User) *dto.ProfileResponse {
+	response := &dto.ProfileResponse{
+		ID:         user.ID,
+		Email:      user.Email,
+		Role:       domain.NormalizeRole(user.Role),
+		Name:       user.Name,
+		AvatarURL:  user.AvatarURL,
+		Bio:        user.Bio,
+		Timezone:   user.Timezone,
+		Locale:     user.Locale,
+		Verified:   user.IsVerified(),
+		MFAEnabled: user.MFAEnabled,
+		CreatedAt:  user.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:  user.UpdatedAt.Format(time.RFC3339),
+	}
+
+	if user.VerifiedAt != nil {
+		verifiedAt := user.VerifiedAt.Format(time.RFC3339)
+		response.VerifiedAt = &verifiedAt
+	}
+
+	return response
 }
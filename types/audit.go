@@ -0,0 +1,13 @@
+package types
+
+type (
+	// RequestInfo is the request metadata stored with audit log entries
+	RequestInfo struct {
+		ImpersonatorID uint // admin acting as the user, 0 for regular requests
+		IP             string
+		UserAgent      string
+		RequestID      string
+		Method         string
+		Path           string
+	}
+)
@@ -0,0 +1,22 @@
+package types
+
+type (
+	// MFAChallenge is returned by login instead of a Token when a second factor
+	// is needed. SetupRequired is set when the policy demands MFA but the user
+	// has not enrolled yet.
+	MFAChallenge struct {
+		ChallengeToken string `json:"challenge_token"`
+		ExpiresAt      int64  `json:"expires_at"`
+		SetupRequired  bool   `json:"setup_required"`
+	}
+
+	MFASetup struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}
+
+	MFAEnrollment struct {
+		Token         *Token   `json:"token,omitempty"`
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+)
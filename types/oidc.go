@@ -0,0 +1,11 @@
+package types
+
+type (
+	// OIDCAuthorization is where the browser is sent to log in at the IdP. The
+	// state comes back on the redirect and is posted to the callback endpoint.
+	OIDCAuthorization struct {
+		AuthorizationURL string `json:"authorization_url"`
+		State            string `json:"state"`
+		ExpiresAt        int64  `json:"expires_at"`
+	}
+)
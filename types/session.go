@@ -0,0 +1,23 @@
+package types
+
+type (
+	// ClientInfo describes the client a request came from
+	ClientInfo struct {
+		IP        string
+		UserAgent string
+	}
+
+	// Session is the record kept in redis for every login, keyed by the
+	// current refresh UUID of the login
+	Session struct {
+		ID         string `json:"id"`
+		UserID     int    `json:"uid"`
+		AccessUuid string `json:"aid"`
+		Device     string `json:"device"`
+		UserAgent  string `json:"user_agent"`
+		IP         string `json:"ip"`
+		CreatedAt  int64  `json:"created_at"`
+		LastSeenAt int64  `json:"last_seen_at"`
+		ExpiresAt  int64  `json:"expires_at"`
+	}
+)
@@ -2,12 +2,15 @@ package types
 
 type (
 	Token struct {
-		UserID        int    `json:"uid"`
-		AccessToken   string `json:"act"`
-		RefreshToken  string `json:"rft"`
-		AccessUuid    string `json:"aid"`
-		RefreshUuid   string `json:"rid"`
-		AccessExpiry  int64  `json:"axp"`
-		RefreshExpiry int64  `json:"rxp"`
+		UserID         int    `json:"uid"`
+		OrgID          int    `json:"org"`           // organization of the user, 0 in tokens issued before organizations
+		ImpersonatorID int    `json:"imp,omitempty"` // admin acting as the user, set on impersonation tokens only
+		Role           string `json:"rol"`
+		AccessToken    string `json:"act"`
+		RefreshToken   string `json:"rft"`
+		AccessUuid     string `json:"aid"`
+		RefreshUuid    string `json:"rid"`
+		AccessExpiry   int64  `json:"axp"`
+		RefreshExpiry  int64  `json:"rxp"`
 	}
 )
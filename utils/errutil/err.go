@@ -15,6 +15,55 @@ var (
 	ErrInvalidJwtSigningMethod   = errors.New("invalid jwt signing method")
 	ErrParseJwt                  = errors.New("failed to parse JWT token")
 	ErrInvalidAccessToken        = errors.New("invalid access token")
+	ErrInvalidRefreshToken       = errors.New("invalid refresh token")
+	ErrInvalidJwtKey             = errors.New("invalid jwt key configuration")
+	ErrUnknownJwtKeyID           = errors.New("unknown jwt key id")
+	ErrInvalidJwtTokenType       = errors.New("invalid jwt token type")
+	ErrInvalidResetToken         = errors.New("invalid or expired reset token")
+	ErrInvalidVerificationToken  = errors.New("invalid or expired verification token")
+	ErrVerificationThrottled     = errors.New("verification email was sent recently, please try again later")
+	ErrEmailAlreadyInUse         = errors.New("email address is already in use")
+	ErrInvalidCurrentPassword    = errors.New("current password is incorrect")
+	ErrEmailNotVerified          = errors.New("email address is not verified")
+	ErrTooManyLoginAttempts      = errors.New("too many login attempts, please try again later")
+	ErrInvalidMFACode            = errors.New("invalid authentication code")
+	ErrInvalidMFAChallenge       = errors.New("invalid or expired MFA challenge")
+	ErrMFAAlreadyEnabled         = errors.New("two-factor authentication is already enabled")
+	ErrMFASetupNotStarted        = errors.New("no pending two-factor setup, please start again")
+	ErrMFANotEnabled             = errors.New("two-factor authentication is not enabled")
+	ErrMFARequired               = errors.New("two-factor authentication is required for this account")
+	ErrAccountSuspended          = errors.New("account is suspended")
+	ErrPasswordResetRequired     = errors.New("password reset required, check your email for a reset link")
+	ErrCannotModifySelf          = errors.New("admins cannot change their own role or suspend themselves")
+	ErrLastAdmin                 = errors.New("at least one active admin must remain")
+	ErrInvalidAPIKey             = errors.New("invalid or expired api key")
+	ErrNotServiceAccount         = errors.New("user is not a service account")
+	ErrOIDCNotConfigured         = errors.New("single sign-on is not configured")
+	ErrOIDCProvider              = errors.New("identity provider request failed")
+	ErrInvalidOIDCState          = errors.New("invalid or expired single sign-on state")
+	ErrInvalidIDToken            = errors.New("invalid id token")
+	ErrOIDCSignupDisabled        = errors.New("no account exists for this identity")
+	ErrOIDCEmailNotVerified      = errors.New("identity provider did not return a verified email address")
+	ErrInvalidSCIMFilter         = errors.New("invalid filter")
+	ErrGroupAlreadyExists        = errors.New("group already exists")
+	ErrOrganizationNotFound      = errors.New("organization not found")
+	ErrOrganizationExists        = errors.New("organization already exists")
+	ErrOrganizationMismatch      = errors.New("token belongs to another organization")
+	ErrInvalidSCIMToken          = errors.New("invalid SCIM bearer token")
+	ErrCannotImpersonate         = errors.New("admins and service accounts cannot be impersonated")
+	ErrImpersonationReadOnly     = errors.New("write operations are not allowed while impersonating")
+	ErrAccountLocked             = errors.New("account is temporarily locked due to too many failed login attempts")
+	ErrCannotErase               = errors.New("admins, service accounts and course owners cannot be erased")
+	ErrUserErased                = errors.New("the personal data of this user has been erased")
+	ErrWeakPassword              = errors.New("password does not meet the password policy")
+	ErrBreachedPassword          = errors.New("password has appeared in a data breach, please choose another one")
+	ErrPasswordReused            = errors.New("password was used recently, please choose another one")
+	ErrPasswordExpired           = errors.New("password has expired, please reset it")
+	ErrSectionNotEmpty           = errors.New("section still has lessons, move or delete them first")
+	ErrNotInCourse               = errors.New("lesson or section does not belong to this course")
+	ErrNotEnrolled               = errors.New("user is not enrolled in this course")
+	ErrInvalidCourseStatus       = errors.New("course cannot take this step in its current status")
+	ErrOwnCourseReview           = errors.New("course owners and co-instructors cannot review their own course")
 )
 
 func Exists(err error, errs []error) bool {
@@ -0,0 +1,46 @@
+package jwtutil
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+
+	"github.com/rijwanansari/vivaLearning/types"
+)
+
+// JWKS returns the public part of every asymmetric key accepted for verification.
+// HMAC secrets are never published.
+func (ks *KeySet) JWKS() *types.JWKS {
+	jwks := &types.JWKS{Keys: []types.JWK{}}
+
+	for _, key := range ks.keys {
+		jwk := types.JWK{
+			Use: "sig",
+			Alg: key.Method.Alg(),
+			Kid: key.ID,
+		}
+
+		switch publicKey := key.VerifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+
+	return jwks
+}
@@ -0,0 +1,247 @@
+package jwtutil
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+// Token types, stored in the "typ" claim so that a refresh token can never be
+// used as an access token even when both are signed with the same key
+const (
+	AccessToken            = "access"
+	RefreshToken           = "refresh"
+	EmailVerificationToken = "email_verification"
+)
+
+// Supported values of config.JwtConfig.SigningMethod
+const (
+	MethodHS256 = "HS256"
+	MethodRS256 = "RS256"
+	MethodEdDSA = "EdDSA"
+)
+
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{} // nil for verification-only keys
+	VerifyKey interface{}
+}
+
+type KeySet struct {
+	issuer  string
+	signing map[string]*Key // token type -> active signing key
+	keys    map[string]*Key // kid -> every key accepted for verification
+}
+
+// NewKeySet builds the signing and verification keys described by the jwt config.
+// HS256 signs access and refresh tokens with their own secrets, RS256 and EdDSA
+// sign both with the PEM private key. Email verification links share the access
+// token key. Extra public keys listed in
+// PublicKeyPaths are accepted for verification only, which allows rotating the
+// private key without invalidating tokens that are still in flight.
+func NewKeySet(conf *config.JwtConfig) (*KeySet, error) {
+	ks := &KeySet{
+		issuer:  conf.Issuer,
+		signing: make(map[string]*Key),
+		keys:    make(map[string]*Key),
+	}
+
+	switch conf.SigningMethod {
+	case "", MethodHS256:
+		if conf.AccessTokenSecret == "" || conf.RefreshTokenSecret == "" {
+			return nil, errutil.ErrInvalidJwtKey
+		}
+		ks.signing[AccessToken] = ks.add(hmacKey(conf.AccessTokenSecret))
+		ks.signing[RefreshToken] = ks.add(hmacKey(conf.RefreshTokenSecret))
+		ks.signing[EmailVerificationToken] = ks.signing[AccessToken]
+	case MethodRS256, MethodEdDSA:
+		key, err := loadPrivateKey(conf.PrivateKeyPath, conf.SigningMethod, conf.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		ks.signing[AccessToken] = ks.add(key)
+		ks.signing[RefreshToken] = key
+		ks.signing[EmailVerificationToken] = key
+	default:
+		return nil, errutil.ErrInvalidJwtSigningMethod
+	}
+
+	for _, entry := range strings.Split(conf.PublicKeyPaths, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, path := "", entry
+		if parts := strings.SplitN(entry, "=", 2); len(parts) == 2 {
+			kid, path = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		}
+
+		key, err := loadPublicKey(path, kid)
+		if err != nil {
+			return nil, err
+		}
+		ks.add(key)
+	}
+
+	return ks, nil
+}
+
+// Sign signs the claims with the active key of the given token type
+func (ks *KeySet) Sign(tokenType string, claims jwt.MapClaims) (string, error) {
+	key, ok := ks.signing[tokenType]
+	if !ok {
+		return "", errutil.ErrInvalidJwtKey
+	}
+
+	claims["typ"] = tokenType
+	if ks.issuer != "" {
+		claims["iss"] = ks.issuer
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.SignKey)
+}
+
+// Parse verifies the token against the key referenced by its kid header and
+// makes sure it is of the expected token type
+func (ks *KeySet) Parse(tokenType, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+
+	parsedToken, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		key := ks.signing[tokenType]
+		if kid, ok := t.Header["kid"].(string); ok {
+			if key, ok = ks.keys[kid]; !ok {
+				return nil, errutil.ErrUnknownJwtKeyID
+			}
+		}
+
+		// the algorithm is pinned by the key, never by the token header
+		if key == nil || t.Method.Alg() != key.Method.Alg() {
+			return nil, errutil.ErrInvalidJwtSigningMethod
+		}
+
+		return key.VerifyKey, nil
+	})
+	if err != nil || !parsedToken.Valid {
+		return nil, errutil.ErrParseJwt
+	}
+
+	if typ, _ := claims["typ"].(string); typ != tokenType {
+		return nil, errutil.ErrInvalidJwtTokenType
+	}
+
+	if ks.issuer != "" && !claims.VerifyIssuer(ks.issuer, true) {
+		return nil, errutil.ErrParseJwt
+	}
+
+	return claims, nil
+}
+
+func (ks *KeySet) add(key *Key) *Key {
+	ks.keys[key.ID] = key
+	return key
+}
+
+func hmacKey(secret string) *Key {
+	sum := sha256.Sum256([]byte(secret))
+
+	return &Key{
+		ID:        "hs-" + hex.EncodeToString(sum[:8]),
+		Method:    jwt.SigningMethodHS256,
+		SignKey:   []byte(secret),
+		VerifyKey: []byte(secret),
+	}
+}
+
+func loadPrivateKey(path, method, kid string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errutil.ErrInvalidJwtKey, err)
+	}
+
+	key := &Key{ID: kid}
+	switch method {
+	case MethodRS256:
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errutil.ErrInvalidJwtKey, err)
+		}
+		key.Method, key.SignKey, key.VerifyKey = jwt.SigningMethodRS256, privateKey, &privateKey.PublicKey
+	case MethodEdDSA:
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errutil.ErrInvalidJwtKey, err)
+		}
+		key.Method, key.SignKey, key.VerifyKey = jwt.SigningMethodEdDSA, privateKey, privateKey.(ed25519.PrivateKey).Public()
+	}
+
+	if key.ID == "" {
+		if key.ID, err = thumbprint(key.VerifyKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func loadPublicKey(path, kid string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errutil.ErrInvalidJwtKey, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s is not PEM encoded", errutil.ErrInvalidJwtKey, path)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errutil.ErrInvalidJwtKey, err)
+	}
+
+	key := &Key{ID: kid, VerifyKey: publicKey}
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: unsupported public key type in %s", errutil.ErrInvalidJwtKey, path)
+	}
+
+	if key.ID == "" {
+		if key.ID, err = thumbprint(publicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// thumbprint derives a stable kid from the public key when none is configured
+func thumbprint(publicKey crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errutil.ErrInvalidJwtKey, err)
+	}
+
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
@@ -0,0 +1,277 @@
+package oidcutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+// keyRefreshInterval limits how often an unknown kid triggers a JWKS download,
+// so forged tokens cannot be used to hammer the IdP
+const keyRefreshInterval = time.Minute
+
+// asymmetric algorithms accepted for ID tokens, HS256 and "none" never are
+var idTokenMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// IDTokenClaims are the verified claims of an ID token used for login
+type IDTokenClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+type verificationKey struct {
+	key  interface{}
+	algs []string
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// VerifyIDToken checks the signature of the ID token against the IdP keys and
+// validates issuer, audience, authorized party, expiry, issue time and nonce
+func (p *Provider) VerifyIDToken(rawIDToken, nonce string) (*IDTokenClaims, error) {
+	metadata, err := p.Metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(idTokenMethods), jwt.WithoutClaimsValidation())
+	_, err = parser.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := p.verificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+
+		// the algorithm must fit the key, never trust the header alone
+		if !contains(key.algs, t.Method.Alg()) {
+			return nil, errutil.ErrInvalidJwtSigningMethod
+		}
+
+		return key.key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errutil.ErrInvalidIDToken, err)
+	}
+
+	now := p.Now()
+	skew := int64(p.ClockSkew.Seconds())
+
+	if iss, _ := claims["iss"].(string); iss != metadata.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", errutil.ErrInvalidIDToken)
+	}
+	if !claims.VerifyAudience(p.ClientID, true) {
+		return nil, fmt.Errorf("%w: unexpected audience", errutil.ErrInvalidIDToken)
+	}
+	// azp must name us when present, and is required with several audiences
+	azp, _ := claims["azp"].(string)
+	aud, _ := claims["aud"].([]interface{})
+	if (azp != "" || len(aud) > 1) && azp != p.ClientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party", errutil.ErrInvalidIDToken)
+	}
+	if !claims.VerifyExpiresAt(now.Unix()-skew, true) {
+		return nil, fmt.Errorf("%w: token is expired", errutil.ErrInvalidIDToken)
+	}
+	if !claims.VerifyIssuedAt(now.Unix()+skew, true) || !claims.VerifyNotBefore(now.Unix()+skew, false) {
+		return nil, fmt.Errorf("%w: token is not valid yet", errutil.ErrInvalidIDToken)
+	}
+	if tokenNonce, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", errutil.ErrInvalidIDToken)
+	}
+
+	idClaims := &IDTokenClaims{}
+	idClaims.Subject, _ = claims["sub"].(string)
+	idClaims.Email, _ = claims["email"].(string)
+	idClaims.Name, _ = claims["name"].(string)
+	idClaims.Picture, _ = claims["picture"].(string)
+
+	// some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		idClaims.EmailVerified = verified
+	case string:
+		idClaims.EmailVerified = verified == "true"
+	}
+
+	if idClaims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", errutil.ErrInvalidIDToken)
+	}
+
+	return idClaims, nil
+}
+
+// verificationKey returns the IdP key with the given kid, downloading the key
+// set again when the kid is unknown (the IdP rotated its keys)
+func (p *Provider) verificationKey(kid string) (*verificationKey, error) {
+	metadata, err := p.Metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := p.lookupKey(kid); key != nil && p.Now().Sub(p.keysAt) < p.CacheTTL {
+		return key, nil
+	}
+
+	if p.keys != nil && p.Now().Sub(p.keysAt) < keyRefreshInterval {
+		if key := p.lookupKey(kid); key != nil {
+			return key, nil
+		}
+		return nil, errutil.ErrUnknownJwtKeyID
+	}
+
+	keys, err := p.fetchKeys(metadata.JwksURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys, p.keysAt = keys, p.Now()
+
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, errutil.ErrUnknownJwtKeyID
+}
+
+// lookupKey finds the key by kid. Tokens without a kid are only accepted when
+// the IdP publishes a single key.
+func (p *Provider) lookupKey(kid string) *verificationKey {
+	if kid != "" {
+		return p.keys[kid]
+	}
+	if len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return nil
+}
+
+func (p *Provider) fetchKeys(jwksURI string) (map[string]*verificationKey, error) {
+	req, err := http.NewRequest(http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	status, err := p.do(req, &jwks)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: jwks answered %d", errutil.ErrOIDCProvider, status)
+	}
+
+	keys := make(map[string]*verificationKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := parseJWK(jwk)
+		if err != nil {
+			// skip key types we do not understand instead of failing the whole set
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func parseJWK(jwk jsonWebKey) (*verificationKey, error) {
+	key := &verificationKey{}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		key.key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		key.algs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	case "EC":
+		curves := map[string]struct {
+			curve elliptic.Curve
+			alg   string
+		}{
+			"P-256": {elliptic.P256(), "ES256"},
+			"P-384": {elliptic.P384(), "ES384"},
+			"P-521": {elliptic.P521(), "ES512"},
+		}
+		curve, ok := curves[jwk.Crv]
+		if !ok {
+			return nil, errutil.ErrInvalidJwtKey
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.curve.IsOnCurve(x, y) {
+			return nil, errutil.ErrInvalidJwtKey
+		}
+		key.key = &ecdsa.PublicKey{Curve: curve.curve, X: x, Y: y}
+		key.algs = []string{curve.alg}
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errutil.ErrInvalidJwtKey
+		}
+		key.key = ed25519.PublicKey(x)
+		key.algs = []string{"EdDSA"}
+	default:
+		return nil, errutil.ErrInvalidJwtKey
+	}
+
+	// a key that names its algorithm may only be used with that algorithm
+	if jwk.Alg != "" {
+		if !contains(key.algs, jwk.Alg) {
+			return nil, errutil.ErrInvalidJwtKey
+		}
+		key.algs = []string{jwk.Alg}
+	}
+
+	return key, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errutil.ErrInvalidJwtKey
+	}
+	return new(big.Int).SetBytes(data), nil
+}
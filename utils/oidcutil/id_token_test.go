@@ -0,0 +1,90 @@
+package oidcutil_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/oidcutil/oidctest"
+)
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := oidctest.New(t)
+	provider := idp.Provider(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		token   func(claims jwt.MapClaims) string
+		nonce   string
+		wantErr bool
+	}{
+		{"valid", idpSigned(t, idp, nil), "n-1", false},
+		{"signed by another key", func(claims jwt.MapClaims) string { return oidctest.Sign(t, otherKey, claims) }, "n-1", true},
+		{"unsigned", func(claims jwt.MapClaims) string {
+			token, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return token
+		}, "n-1", true},
+		{"symmetric algorithm", func(claims jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			token.Header["kid"] = oidctest.KeyID
+			signed, _ := token.SignedString([]byte("shared"))
+			return signed
+		}, "n-1", true},
+		{"other issuer", idpSigned(t, idp, jwt.MapClaims{"iss": "https://evil.example.com"}), "n-1", true},
+		{"other audience", idpSigned(t, idp, jwt.MapClaims{"aud": "someone-else"}), "n-1", true},
+		{"audience list with us", idpSigned(t, idp, jwt.MapClaims{"aud": []string{oidctest.ClientID, "other"}, "azp": oidctest.ClientID}), "n-1", false},
+		{"audience list without azp", idpSigned(t, idp, jwt.MapClaims{"aud": []string{oidctest.ClientID, "other"}}), "n-1", true},
+		{"other authorized party", idpSigned(t, idp, jwt.MapClaims{"azp": "someone-else"}), "n-1", true},
+		{"expired", idpSigned(t, idp, jwt.MapClaims{"exp": now.Add(-2 * time.Minute).Unix()}), "n-1", true},
+		{"expired within clock skew", idpSigned(t, idp, jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()}), "n-1", false},
+		{"without expiry", idpSigned(t, idp, jwt.MapClaims{"exp": nil}), "n-1", true},
+		{"issued in the future", idpSigned(t, idp, jwt.MapClaims{"iat": now.Add(5 * time.Minute).Unix()}), "n-1", true},
+		{"not valid yet", idpSigned(t, idp, jwt.MapClaims{"nbf": now.Add(5 * time.Minute).Unix()}), "n-1", true},
+		{"other nonce", idpSigned(t, idp, nil), "n-2", true},
+		{"without nonce", idpSigned(t, idp, jwt.MapClaims{"nonce": nil}), "n-1", true},
+		{"without subject", idpSigned(t, idp, jwt.MapClaims{"sub": nil}), "n-1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := provider.VerifyIDToken(tt.token(idp.Claims("jane", "n-1")), tt.nonce)
+			if tt.wantErr {
+				if !errors.Is(err, errutil.ErrInvalidIDToken) {
+					t.Fatalf("VerifyIDToken error = %v, want %v", err, errutil.ErrInvalidIDToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if claims.Subject != "jane" || claims.Email != "jane@example.com" || !claims.EmailVerified {
+				t.Errorf("VerifyIDToken claims = %+v", claims)
+			}
+		})
+	}
+}
+
+// idpSigned returns a token builder that applies the changes to the valid
+// claims, a nil value removes the claim, and signs them with the IdP key
+func idpSigned(t *testing.T, idp *oidctest.IdP, changes jwt.MapClaims) func(jwt.MapClaims) string {
+	return func(claims jwt.MapClaims) string {
+		for name, value := range changes {
+			if value == nil {
+				delete(claims, name)
+				continue
+			}
+			claims[name] = value
+		}
+		return idp.Sign(t, claims)
+	}
+}
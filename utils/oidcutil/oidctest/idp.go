@@ -0,0 +1,210 @@
+// Package oidctest runs a fake OpenID Connect identity provider for tests. It
+// serves discovery, a key set and a token endpoint that checks the PKCE
+// verifier, and signs ID tokens with claims chosen by the test.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/utils"
+	"github.com/rijwanansari/vivaLearning/utils/oidcutil"
+)
+
+// ClientID is the client the fake IdP issues its tokens for
+const ClientID = "vivalearning"
+
+// KeyID names the signing key in the key set
+const KeyID = "test-key"
+
+// IdP is a running fake identity provider
+type IdP struct {
+	Server *httptest.Server
+	Key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+// grant is an authorization code waiting to be redeemed
+type grant struct {
+	codeChallenge string
+	claims        jwt.MapClaims
+}
+
+// New starts a fake IdP that is stopped when the test ends
+func New(t *testing.T) *IdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate idp key: %v", err)
+	}
+
+	idp := &IdP{Key: key, grants: make(map[string]grant)}
+	idp.Server = httptest.NewServer(http.HandlerFunc(idp.serve))
+	t.Cleanup(idp.Server.Close)
+
+	return idp
+}
+
+// Issuer is the issuer URL of the fake IdP
+func (idp *IdP) Issuer() string {
+	return idp.Server.URL
+}
+
+// Provider returns a relying party configured for the fake IdP
+func (idp *IdP) Provider(t *testing.T) *oidcutil.Provider {
+	t.Helper()
+
+	provider, err := oidcutil.NewProvider(&config.OIDCConfig{
+		Issuer:      idp.Issuer(),
+		ClientID:    ClientID,
+		RedirectURL: "http://localhost/sso/callback",
+		ClockSkew:   60,
+		CacheTTL:    3600,
+	})
+	if err != nil {
+		t.Fatalf("configure provider: %v", err)
+	}
+	provider.HTTPClient = idp.Server.Client()
+
+	return provider
+}
+
+// Claims returns valid ID token claims for the subject, tests change them to
+// produce invalid tokens
+func (idp *IdP) Claims(subject, nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.Issuer(),
+		"aud":            ClientID,
+		"sub":            subject,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"email":          subject + "@example.com",
+		"email_verified": true,
+	}
+}
+
+// Sign signs the claims with the key of the IdP
+func (idp *IdP) Sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	return Sign(t, idp.Key, claims)
+}
+
+// Sign signs the claims as an RS256 ID token with the kid of the IdP, tests
+// pass another key to forge a signature
+func Sign(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = KeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign id token: %v", err)
+	}
+	return signed
+}
+
+// Login plays the user logging in at the IdP: it follows the authorization
+// URL and returns the code and state the browser brings back. The ID token
+// will carry the claims, with the nonce of the request unless they set one.
+func (idp *IdP) Login(t *testing.T, authorizationURL string, claims jwt.MapClaims) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("parse authorization url: %v", err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorization url without S256 challenge: %s", authorizationURL)
+	}
+
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = query.Get("nonce")
+	}
+
+	code, err = utils.GenerateRandomToken(16)
+	if err != nil {
+		t.Fatalf("generate code: %v", err)
+	}
+	idp.mu.Lock()
+	idp.grants[code] = grant{codeChallenge: query.Get("code_challenge"), claims: claims}
+	idp.mu.Unlock()
+
+	return code, query.Get("state")
+}
+
+func (idp *IdP) serve(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                           idp.Issuer(),
+			"authorization_endpoint":           idp.Issuer() + "/authorize",
+			"token_endpoint":                   idp.Issuer() + "/token",
+			"jwks_uri":                         idp.Issuer() + "/jwks",
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	case "/jwks":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]interface{}{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": KeyID,
+			"n":   base64.RawURLEncoding.EncodeToString(idp.Key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.Key.E)).Bytes()),
+		}}})
+	case "/token":
+		idp.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// token redeems a code once, and only with the verifier of its challenge
+func (idp *IdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": "invalid_request"})
+		return
+	}
+
+	idp.mu.Lock()
+	grant, ok := idp.grants[r.Form.Get("code")]
+	delete(idp.grants, r.Form.Get("code"))
+	idp.mu.Unlock()
+
+	if !ok || oidcutil.CodeChallenge(r.Form.Get("code_verifier")) != grant.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	token.Header["kid"] = KeyID
+	signed, err := token.SignedString(idp.Key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id_token": signed, "token_type": "Bearer"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,245 @@
+package oidcutil
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rijwanansari/vivaLearning/config"
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+// Metadata is the part of the discovery document the relying party needs
+type Metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JwksURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// Provider is an OpenID Connect relying party for a single identity provider.
+// The discovery document and signing keys are fetched lazily and cached, so the
+// API starts even when the IdP is unreachable.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	ClockSkew    time.Duration
+	CacheTTL     time.Duration
+
+	// HTTPClient and Now can be replaced to run against a fake IdP
+	HTTPClient *http.Client
+	Now        func() time.Time
+
+	mu        sync.Mutex
+	metadata  *Metadata
+	keys      map[string]*verificationKey
+	fetchedAt time.Time
+	keysAt    time.Time
+}
+
+// NewProvider builds the relying party described by the oidc config. The issuer
+// must use https, plain http is only accepted on a loopback host.
+func NewProvider(conf *config.OIDCConfig) (*Provider, error) {
+	issuer := strings.TrimSuffix(conf.Issuer, "/")
+	if err := checkIssuer(issuer); err != nil {
+		return nil, err
+	}
+	if conf.ClientID == "" {
+		return nil, fmt.Errorf("%w: client id is required", errutil.ErrOIDCNotConfigured)
+	}
+
+	scopes := []string{"openid"}
+	for _, scope := range strings.Fields(conf.Scopes) {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &Provider{
+		Issuer:       issuer,
+		ClientID:     conf.ClientID,
+		ClientSecret: conf.ClientSecret,
+		RedirectURL:  conf.GetRedirectURL(),
+		Scopes:       scopes,
+		ClockSkew:    time.Duration(conf.ClockSkew) * time.Second,
+		CacheTTL:     time.Duration(conf.CacheTTL) * time.Second,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+		Now:          time.Now,
+	}, nil
+}
+
+// AuthCodeURL returns the URL the browser is sent to in order to log in at the
+// IdP, using the authorization code flow with a S256 PKCE challenge
+func (p *Provider) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.Metadata()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code at the token endpoint and returns the
+// raw ID token. The access token of the IdP is not used.
+func (p *Provider) Exchange(code, codeVerifier string) (string, error) {
+	metadata, err := p.Metadata()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if p.ClientSecret == "" {
+		form.Set("client_id", p.ClientID)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(req, &tokenResponse)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || tokenResponse.IDToken == "" {
+		return "", fmt.Errorf("%w: token endpoint answered %d %s %s", errutil.ErrOIDCProvider,
+			status, tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+
+	return tokenResponse.IDToken, nil
+}
+
+// Metadata returns the cached discovery document, fetching it when it is
+// missing or older than CacheTTL
+func (p *Provider) Metadata() (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil && p.Now().Sub(p.fetchedAt) < p.CacheTTL {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata Metadata
+	status, err := p.do(req, &metadata)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: discovery answered %d", errutil.ErrOIDCProvider, status)
+	}
+
+	// the document must describe the configured issuer, not some other one
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("%w: discovery issuer %q does not match %q", errutil.ErrOIDCProvider, metadata.Issuer, p.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JwksURI == "" {
+		return nil, fmt.Errorf("%w: discovery document is incomplete", errutil.ErrOIDCProvider)
+	}
+	if len(metadata.CodeChallengeMethods) > 0 && !contains(metadata.CodeChallengeMethods, "S256") {
+		return nil, fmt.Errorf("%w: provider does not support S256 PKCE", errutil.ErrOIDCProvider)
+	}
+
+	p.metadata, p.fetchedAt = &metadata, p.Now()
+	return p.metadata, nil
+}
+
+func (p *Provider) do(req *http.Request, out interface{}) (int, error) {
+	res, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errutil.ErrOIDCProvider, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errutil.ErrOIDCProvider, err)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil && res.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("%w: %v", errutil.ErrOIDCProvider, err)
+	}
+
+	return res.StatusCode, nil
+}
+
+// CodeChallenge derives the S256 PKCE challenge of a code verifier
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func checkIssuer(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: invalid issuer %q", errutil.ErrOIDCNotConfigured, issuer)
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: issuer must use https", errutil.ErrOIDCNotConfigured)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package oidcutil_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+	"github.com/rijwanansari/vivaLearning/utils/oidcutil"
+	"github.com/rijwanansari/vivaLearning/utils/oidcutil/oidctest"
+)
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := oidctest.New(t)
+	provider := idp.Provider(t)
+
+	authorizationURL, err := provider.AuthCodeURL("state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             oidctest.ClientID,
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        oidcutil.CodeChallenge("verifier-1"),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if query.Get("code_verifier") != "" {
+		t.Error("the code verifier must not leave the server")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		wantErr  bool
+	}{
+		{"matching verifier", "verifier-1", false},
+		{"other verifier", "verifier-2", true},
+		{"without verifier", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := oidctest.New(t)
+			provider := idp.Provider(t)
+
+			authorizationURL, err := provider.AuthCodeURL("state-1", "nonce-1", "verifier-1")
+			if err != nil {
+				t.Fatalf("AuthCodeURL: %v", err)
+			}
+			claims := idp.Claims("jane", "")
+			delete(claims, "nonce") // taken from the authorization request
+			code, _ := idp.Login(t, authorizationURL, claims)
+
+			rawIDToken, err := provider.Exchange(code, tt.verifier)
+			if tt.wantErr {
+				if !errors.Is(err, errutil.ErrOIDCProvider) {
+					t.Fatalf("Exchange error = %v, want %v", err, errutil.ErrOIDCProvider)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if _, err := provider.VerifyIDToken(rawIDToken, "nonce-1"); err != nil {
+				t.Errorf("VerifyIDToken: %v", err)
+			}
+
+			// a code is redeemed only once
+			if _, err := provider.Exchange(code, tt.verifier); !errors.Is(err, errutil.ErrOIDCProvider) {
+				t.Errorf("second Exchange error = %v, want %v", err, errutil.ErrOIDCProvider)
+			}
+		})
+	}
+}
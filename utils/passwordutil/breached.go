@@ -0,0 +1,73 @@
+package passwordutil
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PrefixLength is the number of hex characters of the SHA-1 used to pick a
+// range file, as in the k-anonymity API of Pwned Passwords
+const PrefixLength = 5
+
+// BreachedList looks passwords up in a local copy of the Pwned Passwords
+// range files: one file per 5 character SHA-1 prefix, named after the prefix
+// (optionally with a .txt extension), holding "SUFFIX:COUNT" lines. Only the
+// file of the prefix is read, the full list is never loaded into memory.
+type BreachedList struct {
+	dir string
+}
+
+// OpenBreachedList checks that the directory of range files exists
+func OpenBreachedList(dir string) (*BreachedList, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory of range files", dir)
+	}
+
+	return &BreachedList{dir: dir}, nil
+}
+
+// Contains reports whether the password appears in the list
+func (l *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:PrefixLength], hash[PrefixLength:]
+
+	file, err := l.openRange(prefix)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		candidate, count, _ := strings.Cut(line, ":")
+		// padding entries of the range API have a count of 0
+		if strings.EqualFold(candidate, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+func (l *BreachedList) openRange(prefix string) (*os.File, error) {
+	file, err := os.Open(filepath.Join(l.dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return os.Open(filepath.Join(l.dir, prefix))
+	}
+	return file, err
+}
@@ -0,0 +1,71 @@
+package passwordutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// SHA-1 of "password" is 5BAA6 1E4C9B93F3F0682250B6CF8331B7EE68FD8
+const (
+	passwordPrefix = "5BAA6"
+	passwordSuffix = "1E4C9B93F3F0682250B6CF8331B7EE68FD8"
+)
+
+func TestBreachedListContains(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string // name of the range file, empty for none
+		content  string
+		password string
+		want     bool
+	}{
+		{"listed", passwordPrefix + ".txt", "003D68EB55068C33ACE09247EE4C639306B:3\n" + passwordSuffix + ":9659365\n", "password", true},
+		{"file without extension", passwordPrefix, passwordSuffix + ":9659365\n", "password", true},
+		{"lowercase suffix", passwordPrefix + ".txt", "1e4c9b93f3f0682250b6cf8331b7ee68fd8:12\n", "password", true},
+		{"windows line endings", passwordPrefix + ".txt", "003D68EB55068C33ACE09247EE4C639306B:3\r\n" + passwordSuffix + ":12\r\n", "password", true},
+		{"padding entry", passwordPrefix + ".txt", passwordSuffix + ":0\n", "password", false},
+		{"other suffixes only", passwordPrefix + ".txt", "003D68EB55068C33ACE09247EE4C639306B:3\n", "password", false},
+		{"no range file", "", "", "password", false},
+		{"other prefix", passwordPrefix + ".txt", passwordSuffix + ":12\n", "Password", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.file != "" {
+				if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			list, err := OpenBreachedList(dir)
+			if err != nil {
+				t.Fatalf("OpenBreachedList: %v", err)
+			}
+
+			got, err := list.Contains(tt.password)
+			if err != nil {
+				t.Fatalf("Contains: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Contains(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenBreachedList(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, passwordPrefix+".txt")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenBreachedList(filepath.Join(dir, "missing")); err == nil {
+		t.Error("OpenBreachedList accepted a missing directory")
+	}
+	if _, err := OpenBreachedList(file); err == nil {
+		t.Error("OpenBreachedList accepted a file")
+	}
+}
@@ -0,0 +1,73 @@
+package passwordutil
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Policy lists the requirements a new password has to meet
+type Policy struct {
+	MinLength        int
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+}
+
+// Check returns the requirements the password does not meet, empty when it is
+// acceptable. The length is counted in characters, the maximum in bytes since
+// that is what bcrypt looks at. An empty password is refused even when the
+// minimum length is configured as 0.
+func (p Policy) Check(password string) []string {
+	var upper, lower, digit, symbol bool
+	length := 0
+	for _, r := range password {
+		length++
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	minLength := p.MinLength
+	if minLength < 1 {
+		minLength = 1
+	}
+
+	var problems []string
+	if length < minLength {
+		problems = append(problems, "at least "+pluralize(minLength, "character"))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		problems = append(problems, "at most "+pluralize(p.MaxLength, "byte"))
+	}
+	if p.RequireUppercase && !upper {
+		problems = append(problems, "an uppercase letter")
+	}
+	if p.RequireLowercase && !lower {
+		problems = append(problems, "a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		problems = append(problems, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		problems = append(problems, "a symbol")
+	}
+
+	return problems
+}
+
+// pluralize formats the count with the unit, "1 character" or "8 characters"
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,41 @@
+package passwordutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	strict := Policy{MinLength: 8, MaxLength: 72, RequireUppercase: true, RequireLowercase: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		want     []string
+	}{
+		{"empty", Policy{MinLength: 8}, "", []string{"at least 8 characters"}},
+		{"empty without minimum", Policy{}, "", []string{"at least 1 character"}},
+		{"single character without minimum", Policy{}, "a", nil},
+		{"too short", Policy{MinLength: 8}, "short", []string{"at least 8 characters"}},
+		{"length in characters", Policy{MinLength: 4}, "äöüß", nil},
+		{"one byte at most", Policy{MaxLength: 1}, "ab", []string{"at most 1 byte"}},
+		{"too long in bytes", Policy{MinLength: 1, MaxLength: 4}, "äöü", []string{"at most 4 bytes"}},
+		{"meets everything", strict, "Secret-pass1", nil},
+		{"missing uppercase", strict, "secret-pass1", []string{"an uppercase letter"}},
+		{"missing lowercase", strict, "SECRET-PASS1", []string{"a lowercase letter"}},
+		{"missing digit", strict, "Secret-pass", []string{"a digit"}},
+		{"missing symbol", strict, "Secretpass1", []string{"a symbol"}},
+		{"space counts as symbol", strict, "Secret pass1", nil},
+		{"non-ASCII letters", strict, "Ünïcode-pass1", nil},
+		{"missing everything", strict, "", []string{"at least 8 characters", "an uppercase letter", "a lowercase letter", "a digit", "a symbol"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Check(tt.password); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check(%q) = %q, want %q", tt.password, got, tt.want)
+			}
+		})
+	}
+}
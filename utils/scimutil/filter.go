@@ -0,0 +1,350 @@
+package scimutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/rijwanansari/vivaLearning/utils/errutil"
+)
+
+// Attribute maps a filterable SCIM attribute onto a SQL column or expression
+type Attribute struct {
+	Column  string
+	Boolean bool // the column holds a boolean, string operators do not apply
+}
+
+// Filter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2), e.g.
+// userName eq "jane@example.com" and active eq true
+type Filter struct {
+	root expression
+}
+
+type expression interface {
+	where(attributes map[string]Attribute) (string, []interface{}, error)
+}
+
+type logicalExpression struct {
+	operator    string // and, or
+	left, right expression
+}
+
+type notExpression struct {
+	expr expression
+}
+
+type comparison struct {
+	attribute string
+	operator  string
+	value     interface{} // string, bool or nil, numbers are kept as text
+}
+
+// ParseFilter parses the filter query parameter. Attribute names and operators
+// are case-insensitive, value paths (emails[type eq "work"]) are not supported.
+func ParseFilter(input string) (*Filter, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", errutil.ErrInvalidSCIMFilter, p.tokens[p.pos].text)
+	}
+
+	return &Filter{root: root}, nil
+}
+
+// Where renders the filter as a SQL condition with its arguments. Attributes
+// missing from the map are rejected.
+func (f *Filter) Where(attributes map[string]Attribute) (string, []interface{}, error) {
+	return f.root.where(attributes)
+}
+
+func (e *logicalExpression) where(attributes map[string]Attribute) (string, []interface{}, error) {
+	left, leftArgs, err := e.left.where(attributes)
+	if err != nil {
+		return "", nil, err
+	}
+	right, rightArgs, err := e.right.where(attributes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "(" + left + " " + strings.ToUpper(e.operator) + " " + right + ")", append(leftArgs, rightArgs...), nil
+}
+
+func (e *notExpression) where(attributes map[string]Attribute) (string, []interface{}, error) {
+	sql, args, err := e.expr.where(attributes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "NOT (" + sql + ")", args, nil
+}
+
+func (e *comparison) where(attributes map[string]Attribute) (string, []interface{}, error) {
+	attribute, ok := attributes[e.attribute]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: attribute %q cannot be filtered", errutil.ErrInvalidSCIMFilter, e.attribute)
+	}
+	column := attribute.Column
+
+	if e.operator == "pr" {
+		if attribute.Boolean {
+			return column + " IS NOT NULL", nil, nil
+		}
+		return "(" + column + " IS NOT NULL AND " + column + " <> '')", nil, nil
+	}
+
+	if attribute.Boolean {
+		value, ok := e.value.(bool)
+		if !ok || (e.operator != "eq" && e.operator != "ne") {
+			return "", nil, fmt.Errorf("%w: %s only supports eq and ne with true or false", errutil.ErrInvalidSCIMFilter, e.attribute)
+		}
+		if e.operator == "ne" {
+			value = !value
+		}
+		return column + " = ?", []interface{}{value}, nil
+	}
+
+	if e.value == nil {
+		switch e.operator {
+		case "eq":
+			return "(" + column + " IS NULL OR " + column + " = '')", nil, nil
+		case "ne":
+			return "(" + column + " IS NOT NULL AND " + column + " <> '')", nil, nil
+		}
+		return "", nil, fmt.Errorf("%w: null only supports eq and ne", errutil.ErrInvalidSCIMFilter)
+	}
+
+	value := fmt.Sprint(e.value)
+	switch e.operator {
+	case "eq":
+		return "LOWER(" + column + ") = LOWER(?)", []interface{}{value}, nil
+	case "ne":
+		return "LOWER(" + column + ") <> LOWER(?)", []interface{}{value}, nil
+	case "co":
+		return column + " ILIKE ?", []interface{}{"%" + escapeLike(value) + "%"}, nil
+	case "sw":
+		return column + " ILIKE ?", []interface{}{escapeLike(value) + "%"}, nil
+	case "ew":
+		return column + " ILIKE ?", []interface{}{"%" + escapeLike(value)}, nil
+	case "gt":
+		return column + " > ?", []interface{}{value}, nil
+	case "ge":
+		return column + " >= ?", []interface{}{value}, nil
+	case "lt":
+		return column + " < ?", []interface{}{value}, nil
+	case "le":
+		return column + " <= ?", []interface{}{value}, nil
+	}
+
+	return "", nil, fmt.Errorf("%w: unknown operator %q", errutil.ErrInvalidSCIMFilter, e.operator)
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")"})
+			i++
+		case r == '"':
+			// strings are JSON strings, so reuse the JSON decoder for escapes
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string", errutil.ErrInvalidSCIMFilter)
+			}
+			var value string
+			if err := json.Unmarshal([]byte(string(runes[i:j+1])), &value); err != nil {
+				return nil, fmt.Errorf("%w: invalid string", errutil.ErrInvalidSCIMFilter)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value})
+			i = j + 1
+		case r == '[':
+			return nil, fmt.Errorf("%w: value paths are not supported", errutil.ErrInvalidSCIMFilter)
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && runes[j] != '(' && runes[j] != ')' && runes[j] != '"' && runes[j] != '[' {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: string(runes[i:j])})
+			i = j
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty filter", errutil.ErrInvalidSCIMFilter)
+	}
+
+	return tokens, nil
+}
+
+// parser is a recursive descent parser, "and" binds tighter than "or"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) parseOr() (expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekWord("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpression{operator: "or", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekWord("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpression{operator: "and", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expression, error) {
+	if p.peekWord("not") {
+		p.pos++
+		if !p.peek(tokenOpen) {
+			return nil, fmt.Errorf("%w: not must be followed by parentheses", errutil.ErrInvalidSCIMFilter)
+		}
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpression{expr: expr}, nil
+	}
+
+	if p.peek(tokenOpen) {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(tokenClose) {
+			return nil, fmt.Errorf("%w: missing closing parenthesis", errutil.ErrInvalidSCIMFilter)
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (expression, error) {
+	if !p.peek(tokenWord) {
+		return nil, fmt.Errorf("%w: attribute expected", errutil.ErrInvalidSCIMFilter)
+	}
+	attribute := strings.ToLower(p.tokens[p.pos].text)
+	p.pos++
+
+	if !p.peek(tokenWord) {
+		return nil, fmt.Errorf("%w: operator expected after %s", errutil.ErrInvalidSCIMFilter, attribute)
+	}
+	operator := strings.ToLower(p.tokens[p.pos].text)
+	p.pos++
+
+	if operator == "pr" {
+		return &comparison{attribute: attribute, operator: operator}, nil
+	}
+
+	switch operator {
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("%w: unknown operator %q", errutil.ErrInvalidSCIMFilter, operator)
+	}
+
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("%w: value expected after %s", errutil.ErrInvalidSCIMFilter, operator)
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	var value interface{}
+	switch {
+	case tok.kind == tokenString:
+		value = tok.text
+	case tok.kind == tokenWord && strings.EqualFold(tok.text, "true"):
+		value = true
+	case tok.kind == tokenWord && strings.EqualFold(tok.text, "false"):
+		value = false
+	case tok.kind == tokenWord && strings.EqualFold(tok.text, "null"):
+		value = nil
+	case tok.kind == tokenWord:
+		var number float64
+		if err := json.Unmarshal([]byte(tok.text), &number); err != nil {
+			return nil, fmt.Errorf("%w: invalid value %q", errutil.ErrInvalidSCIMFilter, tok.text)
+		}
+		value = tok.text
+	default:
+		return nil, fmt.Errorf("%w: value expected after %s", errutil.ErrInvalidSCIMFilter, operator)
+	}
+
+	return &comparison{attribute: attribute, operator: operator, value: value}, nil
+}
+
+func (p *parser) peek(kind tokenKind) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == kind
+}
+
+func (p *parser) peekWord(word string) bool {
+	return p.peek(tokenWord) && strings.EqualFold(p.tokens[p.pos].text, word)
+}
@@ -0,0 +1,40 @@
+// Package testdb opens a throwaway database with the schema of the
+// application for repository and service tests. It runs on SQLite, so the
+// Postgres specific parts of InitDB (seeds, append-only triggers, row locks)
+// are not covered.
+package testdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/rijwanansari/vivaLearning/conn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns a migrated database in a file of the temporary directory of the
+// test. Writers wait for each other instead of failing with "database is
+// locked", so concurrent transactions can be tested.
+func Open(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(10000)&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+
+	if err := conn.Migrate(db); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	return db
+}